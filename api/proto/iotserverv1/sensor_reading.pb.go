@@ -0,0 +1,226 @@
+// Wire schema of the Protobuf payload codec (content type
+// application/x-protobuf). Every MQTT message carries one SensorReadingBatch,
+// a single reading is a batch of one.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sensor_reading.proto
+
+package iotserverv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SensorReading struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id1         string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2         int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	SensorType  string                 `protobuf:"bytes,3,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	SensorValue float64                `protobuf:"fixed64,4,opt,name=sensor_value,json=sensorValue,proto3" json:"sensor_value,omitempty"`
+	// Optional, the receive time is used when missing
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Optional, for exactly-once ingestion
+	MessageId     string `protobuf:"bytes,6,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorReading) Reset() {
+	*x = SensorReading{}
+	mi := &file_sensor_reading_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorReading) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorReading) ProtoMessage() {}
+
+func (x *SensorReading) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_reading_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorReading.ProtoReflect.Descriptor instead.
+func (*SensorReading) Descriptor() ([]byte, []int) {
+	return file_sensor_reading_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SensorReading) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *SensorReading) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *SensorReading) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+func (x *SensorReading) GetSensorValue() float64 {
+	if x != nil {
+		return x.SensorValue
+	}
+	return 0
+}
+
+func (x *SensorReading) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *SensorReading) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+type SensorReadingBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Readings      []*SensorReading       `protobuf:"bytes,1,rep,name=readings,proto3" json:"readings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorReadingBatch) Reset() {
+	*x = SensorReadingBatch{}
+	mi := &file_sensor_reading_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorReadingBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorReadingBatch) ProtoMessage() {}
+
+func (x *SensorReadingBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_reading_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorReadingBatch.ProtoReflect.Descriptor instead.
+func (*SensorReadingBatch) Descriptor() ([]byte, []int) {
+	return file_sensor_reading_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SensorReadingBatch) GetReadings() []*SensorReading {
+	if x != nil {
+		return x.Readings
+	}
+	return nil
+}
+
+var File_sensor_reading_proto protoreflect.FileDescriptor
+
+const file_sensor_reading_proto_rawDesc = "" +
+	"\n" +
+	"\x14sensor_reading.proto\x12\fiotserver.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd0\x01\n" +
+	"\rSensorReading\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x12\x1f\n" +
+	"\vsensor_type\x18\x03 \x01(\tR\n" +
+	"sensorType\x12!\n" +
+	"\fsensor_value\x18\x04 \x01(\x01R\vsensorValue\x128\n" +
+	"\ttimestamp\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x06 \x01(\tR\tmessageId\"M\n" +
+	"\x12SensorReadingBatch\x127\n" +
+	"\breadings\x18\x01 \x03(\v2\x1b.iotserver.v1.SensorReadingR\breadingsB\"Z iot-server/api/proto/iotserverv1b\x06proto3"
+
+var (
+	file_sensor_reading_proto_rawDescOnce sync.Once
+	file_sensor_reading_proto_rawDescData []byte
+)
+
+func file_sensor_reading_proto_rawDescGZIP() []byte {
+	file_sensor_reading_proto_rawDescOnce.Do(func() {
+		file_sensor_reading_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sensor_reading_proto_rawDesc), len(file_sensor_reading_proto_rawDesc)))
+	})
+	return file_sensor_reading_proto_rawDescData
+}
+
+var file_sensor_reading_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_sensor_reading_proto_goTypes = []any{
+	(*SensorReading)(nil),         // 0: iotserver.v1.SensorReading
+	(*SensorReadingBatch)(nil),    // 1: iotserver.v1.SensorReadingBatch
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_sensor_reading_proto_depIdxs = []int32{
+	2, // 0: iotserver.v1.SensorReading.timestamp:type_name -> google.protobuf.Timestamp
+	0, // 1: iotserver.v1.SensorReadingBatch.readings:type_name -> iotserver.v1.SensorReading
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_sensor_reading_proto_init() }
+func file_sensor_reading_proto_init() {
+	if File_sensor_reading_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sensor_reading_proto_rawDesc), len(file_sensor_reading_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_sensor_reading_proto_goTypes,
+		DependencyIndexes: file_sensor_reading_proto_depIdxs,
+		MessageInfos:      file_sensor_reading_proto_msgTypes,
+	}.Build()
+	File_sensor_reading_proto = out.File
+	file_sensor_reading_proto_goTypes = nil
+	file_sensor_reading_proto_depIdxs = nil
+}
@@ -0,0 +1,1516 @@
+// gRPC API of the sensor readings, mirroring the /api/v1/sensor routes. Every
+// call carries the token of POST /api/users/login as "authorization: Bearer
+// <token>" metadata. Searches are open to any user, CreateReadings to admins
+// and ingestion clients, every other mutation to admins.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sensor_service.proto
+
+package iotserverv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SensorRecord struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	SensorValue float64                `protobuf:"fixed64,1,opt,name=sensor_value,json=sensorValue,proto3" json:"sensor_value,omitempty"`
+	Timestamp   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// Set on create, device or server
+	TimestampSource string `protobuf:"bytes,3,opt,name=timestamp_source,json=timestampSource,proto3" json:"timestamp_source,omitempty"`
+	// Set on create when the reading was skipped as a duplicate
+	Duplicate bool `protobuf:"varint,4,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	// Set when a device search spans several sensor types
+	SensorType    string `protobuf:"bytes,5,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SensorRecord) Reset() {
+	*x = SensorRecord{}
+	mi := &file_sensor_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorRecord) ProtoMessage() {}
+
+func (x *SensorRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorRecord.ProtoReflect.Descriptor instead.
+func (*SensorRecord) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SensorRecord) GetSensorValue() float64 {
+	if x != nil {
+		return x.SensorValue
+	}
+	return 0
+}
+
+func (x *SensorRecord) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *SensorRecord) GetTimestampSource() string {
+	if x != nil {
+		return x.TimestampSource
+	}
+	return ""
+}
+
+func (x *SensorRecord) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+func (x *SensorRecord) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type Sensor struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id1           string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2           int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	SensorType    string                 `protobuf:"bytes,3,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	Records       []*SensorRecord        `protobuf:"bytes,4,rep,name=records,proto3" json:"records,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sensor) Reset() {
+	*x = Sensor{}
+	mi := &file_sensor_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sensor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sensor) ProtoMessage() {}
+
+func (x *Sensor) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sensor.ProtoReflect.Descriptor instead.
+func (*Sensor) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Sensor) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *Sensor) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *Sensor) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+func (x *Sensor) GetRecords() []*SensorRecord {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+type PageMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Page          int32                  `protobuf:"varint,1,opt,name=page,proto3" json:"page,omitempty"`
+	Size          int32                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	TotalItem     int64                  `protobuf:"varint,3,opt,name=total_item,json=totalItem,proto3" json:"total_item,omitempty"`
+	TotalPage     int64                  `protobuf:"varint,4,opt,name=total_page,json=totalPage,proto3" json:"total_page,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PageMetadata) Reset() {
+	*x = PageMetadata{}
+	mi := &file_sensor_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PageMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PageMetadata) ProtoMessage() {}
+
+func (x *PageMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PageMetadata.ProtoReflect.Descriptor instead.
+func (*PageMetadata) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PageMetadata) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *PageMetadata) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *PageMetadata) GetTotalItem() int64 {
+	if x != nil {
+		return x.TotalItem
+	}
+	return 0
+}
+
+func (x *PageMetadata) GetTotalPage() int64 {
+	if x != nil {
+		return x.TotalPage
+	}
+	return 0
+}
+
+type CreateReadingResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Position of the reading in the batch
+	Index     int32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Sensor    *Sensor `protobuf:"bytes,2,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Duplicate bool    `protobuf:"varint,3,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	// Set when the reading was rejected
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReadingResult) Reset() {
+	*x = CreateReadingResult{}
+	mi := &file_sensor_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReadingResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReadingResult) ProtoMessage() {}
+
+func (x *CreateReadingResult) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReadingResult.ProtoReflect.Descriptor instead.
+func (*CreateReadingResult) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateReadingResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *CreateReadingResult) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+func (x *CreateReadingResult) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+func (x *CreateReadingResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type CreateReadingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*CreateReadingResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReadingsResponse) Reset() {
+	*x = CreateReadingsResponse{}
+	mi := &file_sensor_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReadingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReadingsResponse) ProtoMessage() {}
+
+func (x *CreateReadingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReadingsResponse.ProtoReflect.Descriptor instead.
+func (*CreateReadingsResponse) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateReadingsResponse) GetResults() []*CreateReadingResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Page defaults to 1 and page_size to 20 on every search
+type SearchByIdRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id1      string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2      int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	Page     int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,5,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchByIdRequest) Reset() {
+	*x = SearchByIdRequest{}
+	mi := &file_sensor_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchByIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchByIdRequest) ProtoMessage() {}
+
+func (x *SearchByIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchByIdRequest.ProtoReflect.Descriptor instead.
+func (*SearchByIdRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SearchByIdRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *SearchByIdRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *SearchByIdRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchByIdRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *SearchByIdRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type SearchByTimeRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize      int32                  `protobuf:"varint,4,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchByTimeRangeRequest) Reset() {
+	*x = SearchByTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchByTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchByTimeRangeRequest) ProtoMessage() {}
+
+func (x *SearchByTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchByTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*SearchByTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SearchByTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *SearchByTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *SearchByTimeRangeRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchByTimeRangeRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+type SearchByIdAndTimeRangeRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id1      string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2      int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	Start    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start,proto3" json:"start,omitempty"`
+	End      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end,proto3" json:"end,omitempty"`
+	Page     int32                  `protobuf:"varint,5,opt,name=page,proto3" json:"page,omitempty"`
+	PageSize int32                  `protobuf:"varint,6,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,7,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchByIdAndTimeRangeRequest) Reset() {
+	*x = SearchByIdAndTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchByIdAndTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchByIdAndTimeRangeRequest) ProtoMessage() {}
+
+func (x *SearchByIdAndTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchByIdAndTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*SearchByIdAndTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *SearchByIdAndTimeRangeRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type SearchByIdResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sensor        *Sensor                `protobuf:"bytes,1,opt,name=sensor,proto3" json:"sensor,omitempty"`
+	Paging        *PageMetadata          `protobuf:"bytes,2,opt,name=paging,proto3" json:"paging,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchByIdResponse) Reset() {
+	*x = SearchByIdResponse{}
+	mi := &file_sensor_service_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchByIdResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchByIdResponse) ProtoMessage() {}
+
+func (x *SearchByIdResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchByIdResponse.ProtoReflect.Descriptor instead.
+func (*SearchByIdResponse) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SearchByIdResponse) GetSensor() *Sensor {
+	if x != nil {
+		return x.Sensor
+	}
+	return nil
+}
+
+func (x *SearchByIdResponse) GetPaging() *PageMetadata {
+	if x != nil {
+		return x.Paging
+	}
+	return nil
+}
+
+type SearchByTimeRangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sensors       []*Sensor              `protobuf:"bytes,1,rep,name=sensors,proto3" json:"sensors,omitempty"`
+	Paging        *PageMetadata          `protobuf:"bytes,2,opt,name=paging,proto3" json:"paging,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchByTimeRangeResponse) Reset() {
+	*x = SearchByTimeRangeResponse{}
+	mi := &file_sensor_service_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchByTimeRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchByTimeRangeResponse) ProtoMessage() {}
+
+func (x *SearchByTimeRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchByTimeRangeResponse.ProtoReflect.Descriptor instead.
+func (*SearchByTimeRangeResponse) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SearchByTimeRangeResponse) GetSensors() []*Sensor {
+	if x != nil {
+		return x.Sensors
+	}
+	return nil
+}
+
+func (x *SearchByTimeRangeResponse) GetPaging() *PageMetadata {
+	if x != nil {
+		return x.Paging
+	}
+	return nil
+}
+
+type StreamTimeRangeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	// Optional, both set to stream a single device
+	Id1 string `protobuf:"bytes,3,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2 int64  `protobuf:"varint,4,opt,name=id2,proto3" json:"id2,omitempty"`
+	// Readings per page, 100 by default
+	PageSize int32 `protobuf:"varint,5,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// Optional, with id1 and id2 to stream a single sensor type
+	SensorType    string `protobuf:"bytes,6,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTimeRangeRequest) Reset() {
+	*x = StreamTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTimeRangeRequest) ProtoMessage() {}
+
+func (x *StreamTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*StreamTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StreamTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *StreamTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *StreamTimeRangeRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *StreamTimeRangeRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *StreamTimeRangeRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *StreamTimeRangeRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type DeleteByIdRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id1   string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2   int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,3,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteByIdRequest) Reset() {
+	*x = DeleteByIdRequest{}
+	mi := &file_sensor_service_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteByIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByIdRequest) ProtoMessage() {}
+
+func (x *DeleteByIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByIdRequest.ProtoReflect.Descriptor instead.
+func (*DeleteByIdRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DeleteByIdRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *DeleteByIdRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *DeleteByIdRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type DeleteByTimeRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteByTimeRangeRequest) Reset() {
+	*x = DeleteByTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteByTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByTimeRangeRequest) ProtoMessage() {}
+
+func (x *DeleteByTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*DeleteByTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteByTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *DeleteByTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+type DeleteByIdAndTimeRangeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id1   string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2   int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	Start *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start,proto3" json:"start,omitempty"`
+	End   *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end,proto3" json:"end,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,5,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) Reset() {
+	*x = DeleteByIdAndTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByIdAndTimeRangeRequest) ProtoMessage() {}
+
+func (x *DeleteByIdAndTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByIdAndTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*DeleteByIdAndTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *DeleteByIdAndTimeRangeRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deleted       int64                  `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_sensor_service_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteResponse) GetDeleted() int64 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+type UpdateByIdRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id1         string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2         int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	SensorValue float64                `protobuf:"fixed64,3,opt,name=sensor_value,json=sensorValue,proto3" json:"sensor_value,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,4,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateByIdRequest) Reset() {
+	*x = UpdateByIdRequest{}
+	mi := &file_sensor_service_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateByIdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateByIdRequest) ProtoMessage() {}
+
+func (x *UpdateByIdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateByIdRequest.ProtoReflect.Descriptor instead.
+func (*UpdateByIdRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateByIdRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *UpdateByIdRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *UpdateByIdRequest) GetSensorValue() float64 {
+	if x != nil {
+		return x.SensorValue
+	}
+	return 0
+}
+
+func (x *UpdateByIdRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type UpdateByTimeRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	SensorValue   float64                `protobuf:"fixed64,3,opt,name=sensor_value,json=sensorValue,proto3" json:"sensor_value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateByTimeRangeRequest) Reset() {
+	*x = UpdateByTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateByTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateByTimeRangeRequest) ProtoMessage() {}
+
+func (x *UpdateByTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateByTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*UpdateByTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UpdateByTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *UpdateByTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *UpdateByTimeRangeRequest) GetSensorValue() float64 {
+	if x != nil {
+		return x.SensorValue
+	}
+	return 0
+}
+
+type UpdateByIdAndTimeRangeRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Id1         string                 `protobuf:"bytes,1,opt,name=id1,proto3" json:"id1,omitempty"`
+	Id2         int64                  `protobuf:"varint,2,opt,name=id2,proto3" json:"id2,omitempty"`
+	Start       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=start,proto3" json:"start,omitempty"`
+	End         *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=end,proto3" json:"end,omitempty"`
+	SensorValue float64                `protobuf:"fixed64,5,opt,name=sensor_value,json=sensorValue,proto3" json:"sensor_value,omitempty"`
+	// Optional, every sensor type of the device if empty
+	SensorType    string `protobuf:"bytes,6,opt,name=sensor_type,json=sensorType,proto3" json:"sensor_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) Reset() {
+	*x = UpdateByIdAndTimeRangeRequest{}
+	mi := &file_sensor_service_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateByIdAndTimeRangeRequest) ProtoMessage() {}
+
+func (x *UpdateByIdAndTimeRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateByIdAndTimeRangeRequest.ProtoReflect.Descriptor instead.
+func (*UpdateByIdAndTimeRangeRequest) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetId1() string {
+	if x != nil {
+		return x.Id1
+	}
+	return ""
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetId2() int64 {
+	if x != nil {
+		return x.Id2
+	}
+	return 0
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetSensorValue() float64 {
+	if x != nil {
+		return x.SensorValue
+	}
+	return 0
+}
+
+func (x *UpdateByIdAndTimeRangeRequest) GetSensorType() string {
+	if x != nil {
+		return x.SensorType
+	}
+	return ""
+}
+
+type UpdateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updated       int64                  `protobuf:"varint,1,opt,name=updated,proto3" json:"updated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateResponse) Reset() {
+	*x = UpdateResponse{}
+	mi := &file_sensor_service_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateResponse) ProtoMessage() {}
+
+func (x *UpdateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sensor_service_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateResponse.ProtoReflect.Descriptor instead.
+func (*UpdateResponse) Descriptor() ([]byte, []int) {
+	return file_sensor_service_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *UpdateResponse) GetUpdated() int64 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+var File_sensor_service_proto protoreflect.FileDescriptor
+
+const file_sensor_service_proto_rawDesc = "" +
+	"\n" +
+	"\x14sensor_service.proto\x12\fiotserver.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x14sensor_reading.proto\"\xd5\x01\n" +
+	"\fSensorRecord\x12!\n" +
+	"\fsensor_value\x18\x01 \x01(\x01R\vsensorValue\x128\n" +
+	"\ttimestamp\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12)\n" +
+	"\x10timestamp_source\x18\x03 \x01(\tR\x0ftimestampSource\x12\x1c\n" +
+	"\tduplicate\x18\x04 \x01(\bR\tduplicate\x12\x1f\n" +
+	"\vsensor_type\x18\x05 \x01(\tR\n" +
+	"sensorType\"\x83\x01\n" +
+	"\x06Sensor\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x12\x1f\n" +
+	"\vsensor_type\x18\x03 \x01(\tR\n" +
+	"sensorType\x124\n" +
+	"\arecords\x18\x04 \x03(\v2\x1a.iotserver.v1.SensorRecordR\arecords\"t\n" +
+	"\fPageMetadata\x12\x12\n" +
+	"\x04page\x18\x01 \x01(\x05R\x04page\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x05R\x04size\x12\x1d\n" +
+	"\n" +
+	"total_item\x18\x03 \x01(\x03R\ttotalItem\x12\x1d\n" +
+	"\n" +
+	"total_page\x18\x04 \x01(\x03R\ttotalPage\"\x8d\x01\n" +
+	"\x13CreateReadingResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12,\n" +
+	"\x06sensor\x18\x02 \x01(\v2\x14.iotserver.v1.SensorR\x06sensor\x12\x1c\n" +
+	"\tduplicate\x18\x03 \x01(\bR\tduplicate\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"U\n" +
+	"\x16CreateReadingsResponse\x12;\n" +
+	"\aresults\x18\x01 \x03(\v2!.iotserver.v1.CreateReadingResultR\aresults\"\x89\x01\n" +
+	"\x11SearchByIdRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vsensor_type\x18\x05 \x01(\tR\n" +
+	"sensorType\"\xab\x01\n" +
+	"\x18SearchByTimeRangeRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x04 \x01(\x05R\bpageSize\"\xf5\x01\n" +
+	"\x1dSearchByIdAndTimeRangeRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x120\n" +
+	"\x05start\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x12\n" +
+	"\x04page\x18\x05 \x01(\x05R\x04page\x12\x1b\n" +
+	"\tpage_size\x18\x06 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vsensor_type\x18\a \x01(\tR\n" +
+	"sensorType\"v\n" +
+	"\x12SearchByIdResponse\x12,\n" +
+	"\x06sensor\x18\x01 \x01(\v2\x14.iotserver.v1.SensorR\x06sensor\x122\n" +
+	"\x06paging\x18\x02 \x01(\v2\x1a.iotserver.v1.PageMetadataR\x06paging\"\x7f\n" +
+	"\x19SearchByTimeRangeResponse\x12.\n" +
+	"\asensors\x18\x01 \x03(\v2\x14.iotserver.v1.SensorR\asensors\x122\n" +
+	"\x06paging\x18\x02 \x01(\v2\x1a.iotserver.v1.PageMetadataR\x06paging\"\xda\x01\n" +
+	"\x16StreamTimeRangeRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x10\n" +
+	"\x03id1\x18\x03 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x04 \x01(\x03R\x03id2\x12\x1b\n" +
+	"\tpage_size\x18\x05 \x01(\x05R\bpageSize\x12\x1f\n" +
+	"\vsensor_type\x18\x06 \x01(\tR\n" +
+	"sensorType\"X\n" +
+	"\x11DeleteByIdRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x12\x1f\n" +
+	"\vsensor_type\x18\x03 \x01(\tR\n" +
+	"sensorType\"z\n" +
+	"\x18DeleteByTimeRangeRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\"\xc4\x01\n" +
+	"\x1dDeleteByIdAndTimeRangeRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x120\n" +
+	"\x05start\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12\x1f\n" +
+	"\vsensor_type\x18\x05 \x01(\tR\n" +
+	"sensorType\"*\n" +
+	"\x0eDeleteResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\x03R\adeleted\"{\n" +
+	"\x11UpdateByIdRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x12!\n" +
+	"\fsensor_value\x18\x03 \x01(\x01R\vsensorValue\x12\x1f\n" +
+	"\vsensor_type\x18\x04 \x01(\tR\n" +
+	"sensorType\"\x9d\x01\n" +
+	"\x18UpdateByTimeRangeRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12!\n" +
+	"\fsensor_value\x18\x03 \x01(\x01R\vsensorValue\"\xe7\x01\n" +
+	"\x1dUpdateByIdAndTimeRangeRequest\x12\x10\n" +
+	"\x03id1\x18\x01 \x01(\tR\x03id1\x12\x10\n" +
+	"\x03id2\x18\x02 \x01(\x03R\x03id2\x120\n" +
+	"\x05start\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x12!\n" +
+	"\fsensor_value\x18\x05 \x01(\x01R\vsensorValue\x12\x1f\n" +
+	"\vsensor_type\x18\x06 \x01(\tR\n" +
+	"sensorType\"*\n" +
+	"\x0eUpdateResponse\x12\x18\n" +
+	"\aupdated\x18\x01 \x01(\x03R\aupdated2\xb8\b\n" +
+	"\rSensorService\x12B\n" +
+	"\rCreateReading\x12\x1b.iotserver.v1.SensorReading\x1a\x14.iotserver.v1.Sensor\x12X\n" +
+	"\x0eCreateReadings\x12 .iotserver.v1.SensorReadingBatch\x1a$.iotserver.v1.CreateReadingsResponse\x12O\n" +
+	"\n" +
+	"SearchById\x12\x1f.iotserver.v1.SearchByIdRequest\x1a .iotserver.v1.SearchByIdResponse\x12d\n" +
+	"\x11SearchByTimeRange\x12&.iotserver.v1.SearchByTimeRangeRequest\x1a'.iotserver.v1.SearchByTimeRangeResponse\x12g\n" +
+	"\x16SearchByIdAndTimeRange\x12+.iotserver.v1.SearchByIdAndTimeRangeRequest\x1a .iotserver.v1.SearchByIdResponse\x12O\n" +
+	"\x0fStreamTimeRange\x12$.iotserver.v1.StreamTimeRangeRequest\x1a\x14.iotserver.v1.Sensor0\x01\x12K\n" +
+	"\n" +
+	"DeleteById\x12\x1f.iotserver.v1.DeleteByIdRequest\x1a\x1c.iotserver.v1.DeleteResponse\x12Y\n" +
+	"\x11DeleteByTimeRange\x12&.iotserver.v1.DeleteByTimeRangeRequest\x1a\x1c.iotserver.v1.DeleteResponse\x12c\n" +
+	"\x16DeleteByIdAndTimeRange\x12+.iotserver.v1.DeleteByIdAndTimeRangeRequest\x1a\x1c.iotserver.v1.DeleteResponse\x12K\n" +
+	"\n" +
+	"UpdateById\x12\x1f.iotserver.v1.UpdateByIdRequest\x1a\x1c.iotserver.v1.UpdateResponse\x12Y\n" +
+	"\x11UpdateByTimeRange\x12&.iotserver.v1.UpdateByTimeRangeRequest\x1a\x1c.iotserver.v1.UpdateResponse\x12c\n" +
+	"\x16UpdateByIdAndTimeRange\x12+.iotserver.v1.UpdateByIdAndTimeRangeRequest\x1a\x1c.iotserver.v1.UpdateResponseB\"Z iot-server/api/proto/iotserverv1b\x06proto3"
+
+var (
+	file_sensor_service_proto_rawDescOnce sync.Once
+	file_sensor_service_proto_rawDescData []byte
+)
+
+func file_sensor_service_proto_rawDescGZIP() []byte {
+	file_sensor_service_proto_rawDescOnce.Do(func() {
+		file_sensor_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sensor_service_proto_rawDesc), len(file_sensor_service_proto_rawDesc)))
+	})
+	return file_sensor_service_proto_rawDescData
+}
+
+var file_sensor_service_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_sensor_service_proto_goTypes = []any{
+	(*SensorRecord)(nil),                  // 0: iotserver.v1.SensorRecord
+	(*Sensor)(nil),                        // 1: iotserver.v1.Sensor
+	(*PageMetadata)(nil),                  // 2: iotserver.v1.PageMetadata
+	(*CreateReadingResult)(nil),           // 3: iotserver.v1.CreateReadingResult
+	(*CreateReadingsResponse)(nil),        // 4: iotserver.v1.CreateReadingsResponse
+	(*SearchByIdRequest)(nil),             // 5: iotserver.v1.SearchByIdRequest
+	(*SearchByTimeRangeRequest)(nil),      // 6: iotserver.v1.SearchByTimeRangeRequest
+	(*SearchByIdAndTimeRangeRequest)(nil), // 7: iotserver.v1.SearchByIdAndTimeRangeRequest
+	(*SearchByIdResponse)(nil),            // 8: iotserver.v1.SearchByIdResponse
+	(*SearchByTimeRangeResponse)(nil),     // 9: iotserver.v1.SearchByTimeRangeResponse
+	(*StreamTimeRangeRequest)(nil),        // 10: iotserver.v1.StreamTimeRangeRequest
+	(*DeleteByIdRequest)(nil),             // 11: iotserver.v1.DeleteByIdRequest
+	(*DeleteByTimeRangeRequest)(nil),      // 12: iotserver.v1.DeleteByTimeRangeRequest
+	(*DeleteByIdAndTimeRangeRequest)(nil), // 13: iotserver.v1.DeleteByIdAndTimeRangeRequest
+	(*DeleteResponse)(nil),                // 14: iotserver.v1.DeleteResponse
+	(*UpdateByIdRequest)(nil),             // 15: iotserver.v1.UpdateByIdRequest
+	(*UpdateByTimeRangeRequest)(nil),      // 16: iotserver.v1.UpdateByTimeRangeRequest
+	(*UpdateByIdAndTimeRangeRequest)(nil), // 17: iotserver.v1.UpdateByIdAndTimeRangeRequest
+	(*UpdateResponse)(nil),                // 18: iotserver.v1.UpdateResponse
+	(*timestamppb.Timestamp)(nil),         // 19: google.protobuf.Timestamp
+	(*SensorReading)(nil),                 // 20: iotserver.v1.SensorReading
+	(*SensorReadingBatch)(nil),            // 21: iotserver.v1.SensorReadingBatch
+}
+var file_sensor_service_proto_depIdxs = []int32{
+	19, // 0: iotserver.v1.SensorRecord.timestamp:type_name -> google.protobuf.Timestamp
+	0,  // 1: iotserver.v1.Sensor.records:type_name -> iotserver.v1.SensorRecord
+	1,  // 2: iotserver.v1.CreateReadingResult.sensor:type_name -> iotserver.v1.Sensor
+	3,  // 3: iotserver.v1.CreateReadingsResponse.results:type_name -> iotserver.v1.CreateReadingResult
+	19, // 4: iotserver.v1.SearchByTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 5: iotserver.v1.SearchByTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	19, // 6: iotserver.v1.SearchByIdAndTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 7: iotserver.v1.SearchByIdAndTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	1,  // 8: iotserver.v1.SearchByIdResponse.sensor:type_name -> iotserver.v1.Sensor
+	2,  // 9: iotserver.v1.SearchByIdResponse.paging:type_name -> iotserver.v1.PageMetadata
+	1,  // 10: iotserver.v1.SearchByTimeRangeResponse.sensors:type_name -> iotserver.v1.Sensor
+	2,  // 11: iotserver.v1.SearchByTimeRangeResponse.paging:type_name -> iotserver.v1.PageMetadata
+	19, // 12: iotserver.v1.StreamTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 13: iotserver.v1.StreamTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	19, // 14: iotserver.v1.DeleteByTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 15: iotserver.v1.DeleteByTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	19, // 16: iotserver.v1.DeleteByIdAndTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 17: iotserver.v1.DeleteByIdAndTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	19, // 18: iotserver.v1.UpdateByTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 19: iotserver.v1.UpdateByTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	19, // 20: iotserver.v1.UpdateByIdAndTimeRangeRequest.start:type_name -> google.protobuf.Timestamp
+	19, // 21: iotserver.v1.UpdateByIdAndTimeRangeRequest.end:type_name -> google.protobuf.Timestamp
+	20, // 22: iotserver.v1.SensorService.CreateReading:input_type -> iotserver.v1.SensorReading
+	21, // 23: iotserver.v1.SensorService.CreateReadings:input_type -> iotserver.v1.SensorReadingBatch
+	5,  // 24: iotserver.v1.SensorService.SearchById:input_type -> iotserver.v1.SearchByIdRequest
+	6,  // 25: iotserver.v1.SensorService.SearchByTimeRange:input_type -> iotserver.v1.SearchByTimeRangeRequest
+	7,  // 26: iotserver.v1.SensorService.SearchByIdAndTimeRange:input_type -> iotserver.v1.SearchByIdAndTimeRangeRequest
+	10, // 27: iotserver.v1.SensorService.StreamTimeRange:input_type -> iotserver.v1.StreamTimeRangeRequest
+	11, // 28: iotserver.v1.SensorService.DeleteById:input_type -> iotserver.v1.DeleteByIdRequest
+	12, // 29: iotserver.v1.SensorService.DeleteByTimeRange:input_type -> iotserver.v1.DeleteByTimeRangeRequest
+	13, // 30: iotserver.v1.SensorService.DeleteByIdAndTimeRange:input_type -> iotserver.v1.DeleteByIdAndTimeRangeRequest
+	15, // 31: iotserver.v1.SensorService.UpdateById:input_type -> iotserver.v1.UpdateByIdRequest
+	16, // 32: iotserver.v1.SensorService.UpdateByTimeRange:input_type -> iotserver.v1.UpdateByTimeRangeRequest
+	17, // 33: iotserver.v1.SensorService.UpdateByIdAndTimeRange:input_type -> iotserver.v1.UpdateByIdAndTimeRangeRequest
+	1,  // 34: iotserver.v1.SensorService.CreateReading:output_type -> iotserver.v1.Sensor
+	4,  // 35: iotserver.v1.SensorService.CreateReadings:output_type -> iotserver.v1.CreateReadingsResponse
+	8,  // 36: iotserver.v1.SensorService.SearchById:output_type -> iotserver.v1.SearchByIdResponse
+	9,  // 37: iotserver.v1.SensorService.SearchByTimeRange:output_type -> iotserver.v1.SearchByTimeRangeResponse
+	8,  // 38: iotserver.v1.SensorService.SearchByIdAndTimeRange:output_type -> iotserver.v1.SearchByIdResponse
+	1,  // 39: iotserver.v1.SensorService.StreamTimeRange:output_type -> iotserver.v1.Sensor
+	14, // 40: iotserver.v1.SensorService.DeleteById:output_type -> iotserver.v1.DeleteResponse
+	14, // 41: iotserver.v1.SensorService.DeleteByTimeRange:output_type -> iotserver.v1.DeleteResponse
+	14, // 42: iotserver.v1.SensorService.DeleteByIdAndTimeRange:output_type -> iotserver.v1.DeleteResponse
+	18, // 43: iotserver.v1.SensorService.UpdateById:output_type -> iotserver.v1.UpdateResponse
+	18, // 44: iotserver.v1.SensorService.UpdateByTimeRange:output_type -> iotserver.v1.UpdateResponse
+	18, // 45: iotserver.v1.SensorService.UpdateByIdAndTimeRange:output_type -> iotserver.v1.UpdateResponse
+	34, // [34:46] is the sub-list for method output_type
+	22, // [22:34] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_sensor_service_proto_init() }
+func file_sensor_service_proto_init() {
+	if File_sensor_service_proto != nil {
+		return
+	}
+	file_sensor_reading_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sensor_service_proto_rawDesc), len(file_sensor_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sensor_service_proto_goTypes,
+		DependencyIndexes: file_sensor_service_proto_depIdxs,
+		MessageInfos:      file_sensor_service_proto_msgTypes,
+	}.Build()
+	File_sensor_service_proto = out.File
+	file_sensor_service_proto_goTypes = nil
+	file_sensor_service_proto_depIdxs = nil
+}
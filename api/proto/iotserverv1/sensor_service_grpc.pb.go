@@ -0,0 +1,560 @@
+// gRPC API of the sensor readings, mirroring the /api/v1/sensor routes. Every
+// call carries the token of POST /api/users/login as "authorization: Bearer
+// <token>" metadata. Searches are open to any user, CreateReadings to admins
+// and ingestion clients, every other mutation to admins.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sensor_service.proto
+
+package iotserverv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SensorService_CreateReading_FullMethodName          = "/iotserver.v1.SensorService/CreateReading"
+	SensorService_CreateReadings_FullMethodName         = "/iotserver.v1.SensorService/CreateReadings"
+	SensorService_SearchById_FullMethodName             = "/iotserver.v1.SensorService/SearchById"
+	SensorService_SearchByTimeRange_FullMethodName      = "/iotserver.v1.SensorService/SearchByTimeRange"
+	SensorService_SearchByIdAndTimeRange_FullMethodName = "/iotserver.v1.SensorService/SearchByIdAndTimeRange"
+	SensorService_StreamTimeRange_FullMethodName        = "/iotserver.v1.SensorService/StreamTimeRange"
+	SensorService_DeleteById_FullMethodName             = "/iotserver.v1.SensorService/DeleteById"
+	SensorService_DeleteByTimeRange_FullMethodName      = "/iotserver.v1.SensorService/DeleteByTimeRange"
+	SensorService_DeleteByIdAndTimeRange_FullMethodName = "/iotserver.v1.SensorService/DeleteByIdAndTimeRange"
+	SensorService_UpdateById_FullMethodName             = "/iotserver.v1.SensorService/UpdateById"
+	SensorService_UpdateByTimeRange_FullMethodName      = "/iotserver.v1.SensorService/UpdateByTimeRange"
+	SensorService_UpdateByIdAndTimeRange_FullMethodName = "/iotserver.v1.SensorService/UpdateByIdAndTimeRange"
+)
+
+// SensorServiceClient is the client API for SensorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SensorServiceClient interface {
+	// Stores a single reading
+	CreateReading(ctx context.Context, in *SensorReading, opts ...grpc.CallOption) (*Sensor, error)
+	// Stores the valid readings of a batch in one transaction, invalid ones are
+	// reported per item
+	CreateReadings(ctx context.Context, in *SensorReadingBatch, opts ...grpc.CallOption) (*CreateReadingsResponse, error)
+	SearchById(ctx context.Context, in *SearchByIdRequest, opts ...grpc.CallOption) (*SearchByIdResponse, error)
+	SearchByTimeRange(ctx context.Context, in *SearchByTimeRangeRequest, opts ...grpc.CallOption) (*SearchByTimeRangeResponse, error)
+	SearchByIdAndTimeRange(ctx context.Context, in *SearchByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*SearchByIdResponse, error)
+	// Streams every reading of a time range one page at a time, oldest page
+	// first, optionally of a single device. A page is sent as one message per
+	// sensor.
+	StreamTimeRange(ctx context.Context, in *StreamTimeRangeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Sensor], error)
+	DeleteById(ctx context.Context, in *DeleteByIdRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	DeleteByTimeRange(ctx context.Context, in *DeleteByTimeRangeRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	DeleteByIdAndTimeRange(ctx context.Context, in *DeleteByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	UpdateById(ctx context.Context, in *UpdateByIdRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+	UpdateByTimeRange(ctx context.Context, in *UpdateByTimeRangeRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+	UpdateByIdAndTimeRange(ctx context.Context, in *UpdateByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+}
+
+type sensorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSensorServiceClient(cc grpc.ClientConnInterface) SensorServiceClient {
+	return &sensorServiceClient{cc}
+}
+
+func (c *sensorServiceClient) CreateReading(ctx context.Context, in *SensorReading, opts ...grpc.CallOption) (*Sensor, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Sensor)
+	err := c.cc.Invoke(ctx, SensorService_CreateReading_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) CreateReadings(ctx context.Context, in *SensorReadingBatch, opts ...grpc.CallOption) (*CreateReadingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateReadingsResponse)
+	err := c.cc.Invoke(ctx, SensorService_CreateReadings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) SearchById(ctx context.Context, in *SearchByIdRequest, opts ...grpc.CallOption) (*SearchByIdResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchByIdResponse)
+	err := c.cc.Invoke(ctx, SensorService_SearchById_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) SearchByTimeRange(ctx context.Context, in *SearchByTimeRangeRequest, opts ...grpc.CallOption) (*SearchByTimeRangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchByTimeRangeResponse)
+	err := c.cc.Invoke(ctx, SensorService_SearchByTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) SearchByIdAndTimeRange(ctx context.Context, in *SearchByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*SearchByIdResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchByIdResponse)
+	err := c.cc.Invoke(ctx, SensorService_SearchByIdAndTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) StreamTimeRange(ctx context.Context, in *StreamTimeRangeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Sensor], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SensorService_ServiceDesc.Streams[0], SensorService_StreamTimeRange_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTimeRangeRequest, Sensor]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_StreamTimeRangeClient = grpc.ServerStreamingClient[Sensor]
+
+func (c *sensorServiceClient) DeleteById(ctx context.Context, in *DeleteByIdRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, SensorService_DeleteById_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) DeleteByTimeRange(ctx context.Context, in *DeleteByTimeRangeRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, SensorService_DeleteByTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) DeleteByIdAndTimeRange(ctx context.Context, in *DeleteByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, SensorService_DeleteByIdAndTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) UpdateById(ctx context.Context, in *UpdateByIdRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, SensorService_UpdateById_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) UpdateByTimeRange(ctx context.Context, in *UpdateByTimeRangeRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, SensorService_UpdateByTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sensorServiceClient) UpdateByIdAndTimeRange(ctx context.Context, in *UpdateByIdAndTimeRangeRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, SensorService_UpdateByIdAndTimeRange_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SensorServiceServer is the server API for SensorService service.
+// All implementations must embed UnimplementedSensorServiceServer
+// for forward compatibility.
+type SensorServiceServer interface {
+	// Stores a single reading
+	CreateReading(context.Context, *SensorReading) (*Sensor, error)
+	// Stores the valid readings of a batch in one transaction, invalid ones are
+	// reported per item
+	CreateReadings(context.Context, *SensorReadingBatch) (*CreateReadingsResponse, error)
+	SearchById(context.Context, *SearchByIdRequest) (*SearchByIdResponse, error)
+	SearchByTimeRange(context.Context, *SearchByTimeRangeRequest) (*SearchByTimeRangeResponse, error)
+	SearchByIdAndTimeRange(context.Context, *SearchByIdAndTimeRangeRequest) (*SearchByIdResponse, error)
+	// Streams every reading of a time range one page at a time, oldest page
+	// first, optionally of a single device. A page is sent as one message per
+	// sensor.
+	StreamTimeRange(*StreamTimeRangeRequest, grpc.ServerStreamingServer[Sensor]) error
+	DeleteById(context.Context, *DeleteByIdRequest) (*DeleteResponse, error)
+	DeleteByTimeRange(context.Context, *DeleteByTimeRangeRequest) (*DeleteResponse, error)
+	DeleteByIdAndTimeRange(context.Context, *DeleteByIdAndTimeRangeRequest) (*DeleteResponse, error)
+	UpdateById(context.Context, *UpdateByIdRequest) (*UpdateResponse, error)
+	UpdateByTimeRange(context.Context, *UpdateByTimeRangeRequest) (*UpdateResponse, error)
+	UpdateByIdAndTimeRange(context.Context, *UpdateByIdAndTimeRangeRequest) (*UpdateResponse, error)
+	mustEmbedUnimplementedSensorServiceServer()
+}
+
+// UnimplementedSensorServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSensorServiceServer struct{}
+
+func (UnimplementedSensorServiceServer) CreateReading(context.Context, *SensorReading) (*Sensor, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateReading not implemented")
+}
+func (UnimplementedSensorServiceServer) CreateReadings(context.Context, *SensorReadingBatch) (*CreateReadingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateReadings not implemented")
+}
+func (UnimplementedSensorServiceServer) SearchById(context.Context, *SearchByIdRequest) (*SearchByIdResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchById not implemented")
+}
+func (UnimplementedSensorServiceServer) SearchByTimeRange(context.Context, *SearchByTimeRangeRequest) (*SearchByTimeRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchByTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) SearchByIdAndTimeRange(context.Context, *SearchByIdAndTimeRangeRequest) (*SearchByIdResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchByIdAndTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) StreamTimeRange(*StreamTimeRangeRequest, grpc.ServerStreamingServer[Sensor]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) DeleteById(context.Context, *DeleteByIdRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteById not implemented")
+}
+func (UnimplementedSensorServiceServer) DeleteByTimeRange(context.Context, *DeleteByTimeRangeRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteByTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) DeleteByIdAndTimeRange(context.Context, *DeleteByIdAndTimeRangeRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteByIdAndTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) UpdateById(context.Context, *UpdateByIdRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateById not implemented")
+}
+func (UnimplementedSensorServiceServer) UpdateByTimeRange(context.Context, *UpdateByTimeRangeRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateByTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) UpdateByIdAndTimeRange(context.Context, *UpdateByIdAndTimeRangeRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateByIdAndTimeRange not implemented")
+}
+func (UnimplementedSensorServiceServer) mustEmbedUnimplementedSensorServiceServer() {}
+func (UnimplementedSensorServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeSensorServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SensorServiceServer will
+// result in compilation errors.
+type UnsafeSensorServiceServer interface {
+	mustEmbedUnimplementedSensorServiceServer()
+}
+
+func RegisterSensorServiceServer(s grpc.ServiceRegistrar, srv SensorServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSensorServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SensorService_ServiceDesc, srv)
+}
+
+func _SensorService_CreateReading_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SensorReading)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).CreateReading(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_CreateReading_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).CreateReading(ctx, req.(*SensorReading))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_CreateReadings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SensorReadingBatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).CreateReadings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_CreateReadings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).CreateReadings(ctx, req.(*SensorReadingBatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_SearchById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).SearchById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_SearchById_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).SearchById(ctx, req.(*SearchByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_SearchByTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchByTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).SearchByTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_SearchByTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).SearchByTimeRange(ctx, req.(*SearchByTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_SearchByIdAndTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchByIdAndTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).SearchByIdAndTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_SearchByIdAndTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).SearchByIdAndTimeRange(ctx, req.(*SearchByIdAndTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_StreamTimeRange_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTimeRangeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SensorServiceServer).StreamTimeRange(m, &grpc.GenericServerStream[StreamTimeRangeRequest, Sensor]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SensorService_StreamTimeRangeServer = grpc.ServerStreamingServer[Sensor]
+
+func _SensorService_DeleteById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).DeleteById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_DeleteById_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).DeleteById(ctx, req.(*DeleteByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_DeleteByTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteByTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).DeleteByTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_DeleteByTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).DeleteByTimeRange(ctx, req.(*DeleteByTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_DeleteByIdAndTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteByIdAndTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).DeleteByIdAndTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_DeleteByIdAndTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).DeleteByIdAndTimeRange(ctx, req.(*DeleteByIdAndTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_UpdateById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).UpdateById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_UpdateById_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).UpdateById(ctx, req.(*UpdateByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_UpdateByTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateByTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).UpdateByTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_UpdateByTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).UpdateByTimeRange(ctx, req.(*UpdateByTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SensorService_UpdateByIdAndTimeRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateByIdAndTimeRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SensorServiceServer).UpdateByIdAndTimeRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SensorService_UpdateByIdAndTimeRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SensorServiceServer).UpdateByIdAndTimeRange(ctx, req.(*UpdateByIdAndTimeRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SensorService_ServiceDesc is the grpc.ServiceDesc for SensorService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SensorService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "iotserver.v1.SensorService",
+	HandlerType: (*SensorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateReading",
+			Handler:    _SensorService_CreateReading_Handler,
+		},
+		{
+			MethodName: "CreateReadings",
+			Handler:    _SensorService_CreateReadings_Handler,
+		},
+		{
+			MethodName: "SearchById",
+			Handler:    _SensorService_SearchById_Handler,
+		},
+		{
+			MethodName: "SearchByTimeRange",
+			Handler:    _SensorService_SearchByTimeRange_Handler,
+		},
+		{
+			MethodName: "SearchByIdAndTimeRange",
+			Handler:    _SensorService_SearchByIdAndTimeRange_Handler,
+		},
+		{
+			MethodName: "DeleteById",
+			Handler:    _SensorService_DeleteById_Handler,
+		},
+		{
+			MethodName: "DeleteByTimeRange",
+			Handler:    _SensorService_DeleteByTimeRange_Handler,
+		},
+		{
+			MethodName: "DeleteByIdAndTimeRange",
+			Handler:    _SensorService_DeleteByIdAndTimeRange_Handler,
+		},
+		{
+			MethodName: "UpdateById",
+			Handler:    _SensorService_UpdateById_Handler,
+		},
+		{
+			MethodName: "UpdateByTimeRange",
+			Handler:    _SensorService_UpdateByTimeRange_Handler,
+		},
+		{
+			MethodName: "UpdateByIdAndTimeRange",
+			Handler:    _SensorService_UpdateByIdAndTimeRange_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTimeRange",
+			Handler:       _SensorService_StreamTimeRange_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sensor_service.proto",
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"iot-server/internal/config"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Imports a CSV file of historical readings with the configuration of the
+// web service, e.g.
+//
+//	go run ./cmd/import -file logger.csv -timestamp-format "2006-01-02 15:04:05" -time-zone Asia/Jakarta
+func main() {
+	var request model.CSVImportRequest
+	flag.StringVar(&request.File, "file", "", "CSV file to import (required)")
+	flag.StringVar(&request.ID1Column, "id1-column", "", "header of the id1 column (default id1)")
+	flag.StringVar(&request.ID2Column, "id2-column", "", "header of the id2 column (default id2)")
+	flag.StringVar(&request.SensorTypeColumn, "sensor-type-column", "", "header of the sensor_type column (default sensor_type)")
+	flag.StringVar(&request.SensorValueColumn, "sensor-value-column", "", "header of the sensor_value column (default sensor_value)")
+	flag.StringVar(&request.TimestampColumn, "timestamp-column", "", "header of the timestamp column (default timestamp)")
+	flag.StringVar(&request.TimestampFormat, "timestamp-format", "", "rfc3339, unix, unix_ms or a Go time layout (default rfc3339)")
+	flag.StringVar(&request.TimeZone, "time-zone", "", "IANA time zone of timestamps without a zone (default UTC)")
+	flag.StringVar(&request.Delimiter, "delimiter", "", "column delimiter (default ,)")
+	progressInterval := flag.Duration("progress", 2*time.Second, "interval of the progress log")
+	flag.Parse()
+
+	if request.File == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	request.FileName = filepath.Base(request.File)
+
+	viperConfig := config.NewViper()
+	log := config.NewLogger(viperConfig)
+	db := config.NewDatabase(viperConfig, log)
+	defer db.Close()
+	validate := config.NewValidator(viperConfig)
+	redisClient := config.NewRedis(viperConfig, log)
+	defer redisClient.Close()
+
+	sensorUseCase := usecase.NewSensorUsecase(
+		db, log, validate, redisClient,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil,
+		config.NewDuplicatePolicy(viperConfig, log),
+		config.NewTimestampWindow(viperConfig, log),
+	)
+	csvImportUsecase := usecase.NewCSVImportUsecase(log, validate, redisClient, sensorUseCase)
+
+	job, err := csvImportUsecase.Start(context.Background(), &request)
+	if err != nil {
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			err = fmt.Errorf("%v", httpErr.Message)
+		}
+		log.Fatalf("Failed to start import: %v", err)
+	}
+	log.Infof("Importing %s as job %s", job.FileName, job.ID)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(*progressInterval)
+	defer ticker.Stop()
+
+	for job.Status == usecase.CSVImportRunning {
+		select {
+		case s := <-sigCh:
+			log.Infof("Received signal: %s. Stopping import...", s.String())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := csvImportUsecase.Stop(ctx); err != nil {
+				log.Errorf("CSV import shutdown error: %v", err)
+			}
+			cancel()
+		case <-ticker.C:
+		}
+
+		current, err := csvImportUsecase.Get(context.Background(), &model.CSVImportGetRequest{ID: job.ID})
+		if err != nil {
+			log.Fatalf("Failed to get import progress: %v", err)
+		}
+		job = current
+		log.Infof("%s: %d%% read, %d rows, %d imported, %d duplicates, %d failed",
+			job.Status, percent(job.BytesRead, job.Size), job.Rows, job.Imported, job.Duplicates, job.Failed)
+	}
+
+	for _, rowErr := range job.Errors {
+		log.Warnf("line %d: %s", rowErr.Line, rowErr.Message)
+	}
+	if job.Failed > int64(len(job.Errors)) {
+		log.Warnf("%d more failed rows not listed", job.Failed-int64(len(job.Errors)))
+	}
+	if job.Status != usecase.CSVImportCompleted {
+		log.Errorf("Import %s: %s", job.Status, job.Error)
+		os.Exit(1)
+	}
+}
+
+func percent(read, size int64) int64 {
+	if size == 0 {
+		return 100
+	}
+	return read * 100 / size
+}
@@ -18,6 +18,7 @@ func main() {
 	db := config.NewDatabase(viperConfig, log)
 	validate := config.NewValidator(viperConfig)
 	app := config.NewEcho(viperConfig)
+	embeddedBroker := config.NewEmbeddedBroker(viperConfig, log)
 	mqttClient := config.NewMqtt(viperConfig, log)
 	redisClient := config.NewRedis(viperConfig, log)
 
@@ -32,13 +33,13 @@ func main() {
 		}
 	}()
 
-	config.Bootstrap(&config.BootstrapConfig{
+	runtime := config.Bootstrap(&config.BootstrapConfig{
 		DB:       db,
 		App:      app,
 		Log:      log,
 		Validate: validate,
 		Config:   viperConfig,
-		Mqtt:     &mqttClient,
+		Mqtt:     mqttClient,
 		Redis:    redisClient,
 	})
 
@@ -48,19 +49,75 @@ func main() {
 	s := <-sigCh
 	log.Infof("Received signal: %s. Shutting down...", s.String())
 
-	// Shutdown Echo then MQTT
+	// Shutdown Echo, CoAP and gRPC, then drain the ingest pipeline before MQTT disconnects, stop CSV imports and flush buffered records
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Live streams never end by themselves, they are closed before Echo waits for open requests
+	if runtime.SensorStream != nil {
+		if err := runtime.SensorStream.Stop(ctx); err != nil {
+			log.Errorf("Live stream shutdown error: %v", err)
+		}
+	}
+
 	if err := app.Shutdown(ctx); err != nil {
 		log.Errorf("Echo shutdown error: %v", err)
 	} else {
 		log.Info("HTTP server stopped")
 	}
 
+	if runtime.CoapServer != nil {
+		if err := runtime.CoapServer.Stop(ctx); err != nil {
+			log.Errorf("CoAP server shutdown error: %v", err)
+		} else {
+			log.Info("CoAP server stopped")
+		}
+	}
+
+	if runtime.GrpcServer != nil {
+		if err := runtime.GrpcServer.Stop(ctx); err != nil {
+			log.Errorf("gRPC server shutdown error: %v", err)
+		} else {
+			log.Info("gRPC server stopped")
+		}
+	}
+
+	// Stop taking MQTT messages, then finish and acknowledge the queued ones
+	// while the client is still connected
+	runtime.Subscriptions.Stop()
+	if err := runtime.IngestPipeline.Stop(ctx); err != nil {
+		log.Errorf("Ingest pipeline shutdown error: %v", err)
+	} else {
+		log.Info("Ingest pipeline drained")
+	}
+
 	// Allow in-flight MQTT work to flush
-	mqttClient.Disconnect(250)
+	mqttClient.Disconnect(250 * time.Millisecond)
 	log.Info("MQTT disconnected")
 
+	if embeddedBroker != nil {
+		if err := embeddedBroker.Close(); err != nil {
+			log.Errorf("Embedded MQTT broker shutdown error: %v", err)
+		}
+	}
+
+	if err := runtime.DeviceCommand.Stop(ctx); err != nil {
+		log.Errorf("Device command sweep shutdown error: %v", err)
+	}
+
+	if err := runtime.CSVImport.Stop(ctx); err != nil {
+		log.Errorf("CSV import shutdown error: %v", err)
+	} else {
+		log.Info("CSV imports stopped")
+	}
+
+	if runtime.SensorRecordWriter != nil {
+		if err := runtime.SensorRecordWriter.Stop(ctx); err != nil {
+			log.Errorf("Sensor record writer flush error: %v", err)
+		} else {
+			log.Info("Sensor record writer flushed")
+		}
+	}
+
 	log.Info("Shutdown complete")
 }
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    dead_letter_id   BIGINT AUTO_INCREMENT PRIMARY KEY,
+    topic            VARCHAR(255) NOT NULL,
+    payload          MEDIUMBLOB   NOT NULL,
+    error_class      VARCHAR(50)  NOT NULL,
+    error_message    TEXT         NOT NULL,
+    received_at      TIMESTAMP(6) NOT NULL,
+    replay_count     INT          NOT NULL DEFAULT 0,
+    last_replayed_at TIMESTAMP(6) NULL,
+    INDEX idx_dead_letters_class_time (error_class, received_at)
+);
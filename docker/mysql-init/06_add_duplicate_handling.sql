@@ -0,0 +1,8 @@
+-- NULL falls back to SENSOR_DUPLICATE_POLICY
+ALTER TABLE sensors
+    ADD COLUMN duplicate_policy VARCHAR(20) NULL;
+
+-- Optional device message id for exactly-once ingestion
+ALTER TABLE sensor_records
+    ADD COLUMN message_id VARCHAR(100) NULL,
+    ADD UNIQUE KEY unique_message (sensor_id, message_id);
@@ -0,0 +1,3 @@
+-- device: timestamp sent by the device, server: receive time used as fallback
+ALTER TABLE sensor_records
+    ADD COLUMN timestamp_source VARCHAR(10) NOT NULL DEFAULT 'device';
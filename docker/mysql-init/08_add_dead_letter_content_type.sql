@@ -0,0 +1,3 @@
+-- Content type of the payload, picks the codec when a dead letter is replayed
+ALTER TABLE dead_letters
+    ADD COLUMN content_type VARCHAR(100) NOT NULL DEFAULT 'application/json' AFTER topic;
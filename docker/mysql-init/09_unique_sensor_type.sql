@@ -0,0 +1,5 @@
+-- A device may report several sensor types, e.g. every named record of a
+-- SenML pack, so sensors are unique by their type too
+ALTER TABLE sensors
+    DROP INDEX unique_sensor,
+    ADD UNIQUE KEY unique_sensor (id1, id2, sensor_type);
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS device_commands
+(
+    command_id    BIGINT AUTO_INCREMENT PRIMARY KEY,
+    id1           VARCHAR(20)   NOT NULL,
+    id2           BIGINT        NOT NULL,
+    name          VARCHAR(50)   NOT NULL,
+    params        JSON          NULL,
+    status        VARCHAR(20)   NOT NULL,
+    result        JSON          NULL,
+    error_message VARCHAR(1000) NOT NULL DEFAULT '',
+    created_by    VARCHAR(100)  NOT NULL,
+    created_at    TIMESTAMP(6)  NOT NULL,
+    delivered_at  TIMESTAMP(6)  NULL,
+    completed_at  TIMESTAMP(6)  NULL,
+    expires_at    TIMESTAMP(6)  NOT NULL,
+    INDEX idx_device_commands_device_time (id1, id2, created_at),
+    INDEX idx_device_commands_status_expiry (status, expires_at)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS device_shadows
+(
+    id1                 VARCHAR(20)  NOT NULL,
+    id2                 BIGINT       NOT NULL,
+    desired             JSON         NULL,
+    desired_version     BIGINT       NOT NULL DEFAULT 0,
+    desired_updated_at  TIMESTAMP(6) NULL,
+    reported            JSON         NULL,
+    reported_version    BIGINT       NOT NULL DEFAULT 0,
+    reported_updated_at TIMESTAMP(6) NULL,
+    -- Last delta sent to the device, NULL before the first
+    published_delta     JSON         NULL,
+    PRIMARY KEY (id1, id2)
+);
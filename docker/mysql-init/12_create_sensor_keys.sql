@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS sensor_keys
+(
+    id1        VARCHAR(20)   NOT NULL,
+    id2        BIGINT        NOT NULL,
+    psk        VARBINARY(64) NOT NULL,
+    created_at TIMESTAMP(6)  NOT NULL,
+    updated_at TIMESTAMP(6)  NOT NULL,
+    PRIMARY KEY (id1, id2)
+);
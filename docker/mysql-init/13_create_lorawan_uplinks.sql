@@ -0,0 +1,17 @@
+-- Radio metadata of the LoRaWAN uplinks, as seen by the gateway with the best RSSI
+CREATE TABLE IF NOT EXISTS lorawan_uplinks
+(
+    uplink_id   BIGINT AUTO_INCREMENT PRIMARY KEY,
+    id1         VARCHAR(20)  NOT NULL,
+    id2         BIGINT       NOT NULL,
+    network     VARCHAR(20)  NOT NULL,
+    message_id  VARCHAR(100) NULL,
+    f_cnt       BIGINT       NOT NULL,
+    rssi        INT          NULL,
+    snr         DOUBLE       NULL,
+    gateway_id  VARCHAR(64)  NULL,
+    readings    INT          NOT NULL,
+    received_at TIMESTAMP(6) NOT NULL,
+    UNIQUE KEY unique_uplink (id1, id2, message_id),
+    INDEX idx_lorawan_uplinks_device_time (id1, id2, received_at)
+);
@@ -0,0 +1,16 @@
+-- One reading per sensor and timestamp for the ignore and overwrite duplicate
+-- policies. Their records set dedupe_timestamp to their timestamp; keep_all
+-- records leave it NULL, which the unique key does not compare.
+ALTER TABLE sensor_records
+    ADD COLUMN dedupe_timestamp TIMESTAMP(6) NULL DEFAULT NULL;
+
+-- The latest record of every stored timestamp is the one a later ignore or
+-- overwrite reading is checked against
+UPDATE sensor_records r
+    JOIN (SELECT MAX(record_id) AS record_id
+          FROM sensor_records
+          GROUP BY sensor_id, timestamp) latest ON latest.record_id = r.record_id
+SET r.dedupe_timestamp = r.timestamp;
+
+ALTER TABLE sensor_records
+    ADD UNIQUE KEY unique_dedupe (sensor_id, dedupe_timestamp);
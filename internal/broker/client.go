@@ -0,0 +1,133 @@
+// Package broker hides the MQTT client library, so the server runs on
+// MQTT 3.1.1 (paho.mqtt.golang) or MQTT 5 (paho.golang) alike.
+package broker
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client is a connected MQTT client. Operations return a Token that completes
+// once the broker acknowledged them.
+type Client interface {
+	Subscribe(filter string, qos byte, handler Handler) Token
+	// Publish sends a message; properties may be nil and are dropped on
+	// MQTT 3.1.1
+	Publish(topic string, qos byte, retained bool, payload []byte, properties *Properties) Token
+	IsConnected() bool
+	// OnConnect registers a callback run after every following connection,
+	// i.e. reconnects of an already connected client. It may run on the
+	// client's network goroutine and must not block.
+	OnConnect(callback func())
+	// Disconnect waits up to quiesce for in-flight work before closing
+	Disconnect(quiesce time.Duration)
+}
+
+// Token is the pending result of an operation
+type Token interface {
+	Done() <-chan struct{}
+	Error() error
+}
+
+// Handler receives the messages of a subscription. Acknowledgement is manual,
+// a message is redelivered unless Ack is called.
+type Handler func(Message)
+
+type Message interface {
+	Topic() string
+	Payload() []byte
+	Qos() byte
+	Retained() bool
+	// Properties are the MQTT 5 properties, empty on MQTT 3.1.1
+	Properties() Properties
+	Ack()
+}
+
+// Properties are the MQTT 5 publish properties used by the server
+type Properties struct {
+	ContentType string
+	// ResponseTopic and CorrelationData ask the receiver for an answer
+	ResponseTopic   string
+	CorrelationData []byte
+	// MessageExpiry is how long the broker keeps the message for
+	// subscribers, zero for no expiry
+	MessageExpiry time.Duration
+	User          map[string]string
+}
+
+// Protocol versions
+const (
+	Version311 = "3.1.1"
+	Version5   = "5"
+)
+
+// callbacks are the OnConnect callbacks of a client
+type callbacks struct {
+	mu    sync.Mutex
+	funcs []func()
+}
+
+func (c *callbacks) add(callback func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.funcs = append(c.funcs, callback)
+}
+
+func (c *callbacks) run() {
+	c.mu.Lock()
+	funcs := append([]func(){}, c.funcs...)
+	c.mu.Unlock()
+	for _, callback := range funcs {
+		callback()
+	}
+}
+
+// token is a Token completed by the client
+type token struct {
+	done chan struct{}
+	err  error
+}
+
+func newToken() *token {
+	return &token{done: make(chan struct{})}
+}
+
+func (t *token) complete(err error) {
+	t.err = err
+	close(t.done)
+}
+
+func (t *token) Done() <-chan struct{} { return t.done }
+
+func (t *token) Error() error {
+	<-t.done
+	return t.err
+}
+
+// matchTopic reports whether topic matches a subscription filter, which may
+// be a shared subscription $share/<group>/<filter>
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
@@ -0,0 +1,55 @@
+package broker
+
+import (
+	"log/slog"
+
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// Embedded is an in-process MQTT broker (mochi-mqtt) speaking MQTT 3.1.1 and
+// 5 over TCP, for development and tests without an external broker
+type Embedded struct {
+	server   *mqttserver.Server
+	listener *listeners.TCP
+}
+
+// NewEmbedded starts a broker listening on address, e.g. "127.0.0.1:1883" or
+// ":0" for a free port. With a username, clients have to present it and the
+// password; without one, any client may connect.
+func NewEmbedded(address, username, password string, logger *slog.Logger) (*Embedded, error) {
+	server := mqttserver.New(&mqttserver.Options{Logger: logger})
+
+	var err error
+	if username == "" {
+		err = server.AddHook(new(auth.AllowHook), nil)
+	} else {
+		err = server.AddHook(new(auth.Hook), &auth.Options{Ledger: &auth.Ledger{
+			Auth: auth.AuthRules{{Username: auth.RString(username), Password: auth.RString(password), Allow: true}},
+			ACL:  auth.ACLRules{{Username: auth.RString(username)}},
+		}})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listener := listeners.NewTCP(listeners.Config{ID: "tcp", Address: address})
+	if err := server.AddListener(listener); err != nil {
+		return nil, err
+	}
+	if err := server.Serve(); err != nil {
+		return nil, err
+	}
+	return &Embedded{server: server, listener: listener}, nil
+}
+
+// Address returns the address the broker listens on, with the port resolved
+func (e *Embedded) Address() string {
+	return e.listener.Address()
+}
+
+// Close disconnects all clients and stops the broker
+func (e *Embedded) Close() error {
+	return e.server.Close()
+}
@@ -0,0 +1,84 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT3Client adapts a paho.mqtt.golang client, which speaks MQTT 3.1.1
+type MQTT3Client struct {
+	client    mqtt.Client
+	onConnect callbacks
+}
+
+// NewMQTT3Client creates a client from opts, which must have auto-ack
+// disabled. It is not connected yet.
+func NewMQTT3Client(opts *mqtt.ClientOptions) *MQTT3Client {
+	c := &MQTT3Client{}
+	onConnect := opts.OnConnect
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		if onConnect != nil {
+			onConnect(client)
+		}
+		c.onConnect.run()
+	})
+	c.client = mqtt.NewClient(opts)
+	return c
+}
+
+// Connect connects for the first time, later reconnects are automatic
+func (c *MQTT3Client) Connect(timeout time.Duration) error {
+	token := c.client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return errors.New("connect timed out")
+	}
+	return token.Error()
+}
+
+// Subscribe also fails when the broker refused the subscription, which
+// paho only reports in the suback result
+func (c *MQTT3Client) Subscribe(filter string, qos byte, handler Handler) Token {
+	subscribe := c.client.Subscribe(filter, qos, func(_ mqtt.Client, msg mqtt.Message) {
+		handler(mqtt3Message{msg})
+	})
+
+	t := newToken()
+	go func() {
+		<-subscribe.Done()
+		err := subscribe.Error()
+		if st, ok := subscribe.(*mqtt.SubscribeToken); ok && err == nil {
+			if granted, ok := st.Result()[filter]; ok && granted >= 0x80 {
+				err = fmt.Errorf("subscription to %s refused by the broker", filter)
+			}
+		}
+		t.complete(err)
+	}()
+	return t
+}
+
+func (c *MQTT3Client) Publish(topic string, qos byte, retained bool, payload []byte, _ *Properties) Token {
+	return c.client.Publish(topic, qos, retained, payload)
+}
+
+func (c *MQTT3Client) IsConnected() bool {
+	return c.client.IsConnected()
+}
+
+func (c *MQTT3Client) OnConnect(callback func()) {
+	c.onConnect.add(callback)
+}
+
+func (c *MQTT3Client) Disconnect(quiesce time.Duration) {
+	c.client.Disconnect(uint(quiesce.Milliseconds()))
+}
+
+type mqtt3Message struct {
+	mqtt.Message
+}
+
+func (mqtt3Message) Properties() Properties {
+	return Properties{}
+}
@@ -0,0 +1,201 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// MQTT5Client adapts a paho.golang connection manager, which speaks MQTT 5
+// and reconnects on its own
+type MQTT5Client struct {
+	manager   *autopaho.ConnectionManager
+	connected atomic.Bool
+	onConnect callbacks
+
+	mu     sync.RWMutex
+	routes []route
+}
+
+type route struct {
+	filter  string
+	handler Handler
+}
+
+// NewMQTT5Client starts connecting with cfg in the background. Received
+// messages are routed to the handlers of matching subscriptions and have to
+// be acknowledged by them.
+func NewMQTT5Client(cfg autopaho.ClientConfig) (*MQTT5Client, error) {
+	c := &MQTT5Client{}
+	cfg.EnableManualAcknowledgment = true
+	cfg.OnPublishReceived = append(cfg.OnPublishReceived, c.route)
+	onUp, onDown := cfg.OnConnectionUp, cfg.OnConnectionDown
+	cfg.OnConnectionUp = func(manager *autopaho.ConnectionManager, connack *paho.Connack) {
+		c.connected.Store(true)
+		if onUp != nil {
+			onUp(manager, connack)
+		}
+		c.onConnect.run()
+	}
+	cfg.OnConnectionDown = func() bool {
+		c.connected.Store(false)
+		if onDown != nil {
+			return onDown()
+		}
+		return true
+	}
+
+	manager, err := autopaho.NewConnection(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.manager = manager
+	return c, nil
+}
+
+// AwaitConnection blocks until the first connection is up
+func (c *MQTT5Client) AwaitConnection(ctx context.Context) error {
+	return c.manager.AwaitConnection(ctx)
+}
+
+// Subscribe replaces the handler of a filter that is subscribed again
+func (c *MQTT5Client) Subscribe(filter string, qos byte, handler Handler) Token {
+	c.mu.Lock()
+	replaced := false
+	for i := range c.routes {
+		if c.routes[i].filter == filter {
+			c.routes[i].handler, replaced = handler, true
+		}
+	}
+	if !replaced {
+		c.routes = append(c.routes, route{filter: filter, handler: handler})
+	}
+	c.mu.Unlock()
+
+	t := newToken()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		suback, err := c.manager.Subscribe(ctx, &paho.Subscribe{
+			Subscriptions: []paho.SubscribeOptions{{Topic: filter, QoS: qos}},
+		})
+		if err == nil && len(suback.Reasons) > 0 && suback.Reasons[0] >= 0x80 {
+			err = fmt.Errorf("subscription to %s refused with reason code 0x%02x", filter, suback.Reasons[0])
+		}
+		t.complete(err)
+	}()
+	return t
+}
+
+func (c *MQTT5Client) Publish(topic string, qos byte, retained bool, payload []byte, properties *Properties) Token {
+	publish := &paho.Publish{
+		Topic:   topic,
+		QoS:     qos,
+		Retain:  retained,
+		Payload: payload,
+	}
+	if properties != nil {
+		publish.Properties = &paho.PublishProperties{
+			ContentType:     properties.ContentType,
+			ResponseTopic:   properties.ResponseTopic,
+			CorrelationData: properties.CorrelationData,
+		}
+		if properties.MessageExpiry > 0 {
+			expiry := uint32((properties.MessageExpiry + time.Second - 1) / time.Second)
+			publish.Properties.MessageExpiry = &expiry
+		}
+		for key, value := range properties.User {
+			publish.Properties.User.Add(key, value)
+		}
+	}
+
+	t := newToken()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_, err := c.manager.Publish(ctx, publish)
+		t.complete(err)
+	}()
+	return t
+}
+
+func (c *MQTT5Client) IsConnected() bool {
+	return c.connected.Load()
+}
+
+func (c *MQTT5Client) OnConnect(callback func()) {
+	c.onConnect.add(callback)
+}
+
+func (c *MQTT5Client) Disconnect(quiesce time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), quiesce)
+	defer cancel()
+	_ = c.manager.Disconnect(ctx)
+}
+
+func (c *MQTT5Client) route(received paho.PublishReceived) (bool, error) {
+	msg := &mqtt5Message{publish: received.Packet, client: received.Client}
+	handler := c.handler(received.Packet.Topic)
+	if handler == nil {
+		// Nobody subscribed to it any more, acknowledge so it does not hold
+		// back the acknowledgements of later messages
+		msg.Ack()
+		return false, nil
+	}
+	handler(msg)
+	return true, nil
+}
+
+// handler returns the handler of the first subscription matching topic. It is
+// called without the lock held, so a handler may subscribe.
+func (c *MQTT5Client) handler(topic string) Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, r := range c.routes {
+		if matchTopic(r.filter, topic) {
+			return r.handler
+		}
+	}
+	return nil
+}
+
+type mqtt5Message struct {
+	publish *paho.Publish
+	client  *paho.Client
+}
+
+func (m *mqtt5Message) Topic() string   { return m.publish.Topic }
+func (m *mqtt5Message) Payload() []byte { return m.publish.Payload }
+func (m *mqtt5Message) Qos() byte       { return m.publish.QoS }
+func (m *mqtt5Message) Retained() bool  { return m.publish.Retain }
+
+func (m *mqtt5Message) Properties() Properties {
+	var properties Properties
+	p := m.publish.Properties
+	if p == nil {
+		return properties
+	}
+	properties.ContentType = p.ContentType
+	properties.ResponseTopic = p.ResponseTopic
+	properties.CorrelationData = p.CorrelationData
+	if p.MessageExpiry != nil {
+		properties.MessageExpiry = time.Duration(*p.MessageExpiry) * time.Second
+	}
+	if len(p.User) > 0 {
+		properties.User = make(map[string]string, len(p.User))
+		for _, user := range p.User {
+			properties.User[user.Key] = user.Value
+		}
+	}
+	return properties
+}
+
+func (m *mqtt5Message) Ack() {
+	_ = m.client.Ack(m.publish)
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"iot-server/internal/model"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR decodes a reading map or an array of reading maps (RFC 8949). Keys
+// are the JSON field names; timestamps may be tag 0/1 or an RFC 3339 string.
+type CBOR struct{}
+
+func (CBOR) Name() string { return "cbor" }
+
+func (CBOR) ContentTypes() []string { return []string{ContentTypeCBOR} }
+
+func (CBOR) Decode(payload []byte) ([]Reading, bool, error) {
+	// Major type 4 is an array
+	if len(payload) > 0 && payload[0]>>5 == 4 {
+		var items []cbor.RawMessage
+		if err := cbor.Unmarshal(payload, &items); err != nil {
+			return nil, true, err
+		}
+		raw := make([][]byte, len(items))
+		for i, item := range items {
+			raw[i] = item
+		}
+		return decodeItems(raw, cbor.Unmarshal), true, nil
+	}
+
+	var req model.CreateSensorRequest
+	if err := cbor.Unmarshal(payload, &req); err != nil {
+		return nil, false, err
+	}
+	return []Reading{{Request: &req, Raw: payload}}, false, nil
+}
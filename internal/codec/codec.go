@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"fmt"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"mime"
+	"strings"
+)
+
+// Content types of the built-in codecs
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeCBOR        = "application/cbor"
+	ContentTypeMessagePack = "application/msgpack"
+	ContentTypeProtobuf    = "application/x-protobuf"
+)
+
+// Reading is one reading of a decoded payload. Raw holds the reading on its
+// own, encoded with the same codec, so it can be stored as a dead letter when
+// only this reading is rejected.
+type Reading struct {
+	Request *model.CreateSensorRequest
+	Raw     []byte
+	Err     error // set when this reading could not be decoded
+}
+
+// Codec decodes a sensor payload into readings
+type Codec interface {
+	// Name identifies the codec in configuration, e.g. "cbor"
+	Name() string
+	// ContentTypes lists the accepted content types, the first is canonical
+	ContentTypes() []string
+	// Decode returns the readings of a payload and whether the payload was a
+	// list of readings. A list with undecodable items is not an error, those
+	// readings carry Err instead.
+	Decode(payload []byte) ([]Reading, bool, error)
+}
+
+// ContentType returns the canonical content type of a codec
+func ContentType(c Codec) string {
+	return c.ContentTypes()[0]
+}
+
+type topicRule struct {
+	filter *util.TopicPattern
+	codec  Codec
+}
+
+// Registry picks the codec of a message, by its content type when it has one
+// or else by the first topic rule that matches, falling back to JSON
+type Registry struct {
+	codecs   map[string]Codec
+	rules    []topicRule
+	fallback Codec
+}
+
+// NewRegistry returns a registry with the JSON, CBOR, MessagePack and
+// Protobuf codecs
+func NewRegistry() *Registry {
+	r := &Registry{codecs: make(map[string]Codec)}
+	r.Register(JSON{})
+	r.Register(CBOR{})
+	r.Register(MessagePack{})
+	r.Register(Protobuf{})
+	r.fallback = JSON{}
+	return r
+}
+
+// Register adds a codec under its name and content types
+func (r *Registry) Register(c Codec) {
+	r.codecs[c.Name()] = c
+	for _, contentType := range c.ContentTypes() {
+		r.codecs[contentType] = c
+	}
+}
+
+// Lookup finds a codec by name or content type, ignoring content type parameters
+func (r *Registry) Lookup(nameOrContentType string) (Codec, bool) {
+	key := strings.ToLower(strings.TrimSpace(nameOrContentType))
+	if mediaType, _, err := mime.ParseMediaType(key); err == nil {
+		key = mediaType
+	}
+	c, ok := r.codecs[key]
+	return c, ok
+}
+
+// SetDefault sets the codec used when neither content type nor topic decide
+func (r *Registry) SetDefault(name string) error {
+	c, ok := r.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown codec %q", name)
+	}
+	r.fallback = c
+	return nil
+}
+
+// AddTopic routes topics matching an MQTT topic filter to a codec
+func (r *Registry) AddTopic(filter, name string) error {
+	c, ok := r.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown codec %q", name)
+	}
+	pattern, err := util.NewTopicPattern(filter)
+	if err != nil {
+		return err
+	}
+	r.rules = append(r.rules, topicRule{filter: pattern, codec: c})
+	return nil
+}
+
+// Resolve returns the codec for a message. An empty content type means the
+// message did not carry one.
+func (r *Registry) Resolve(topic, contentType string) (Codec, error) {
+	if contentType != "" {
+		c, ok := r.Lookup(contentType)
+		if !ok {
+			return nil, fmt.Errorf("unsupported content type %q", contentType)
+		}
+		return c, nil
+	}
+
+	for _, rule := range r.rules {
+		if _, ok := rule.filter.Match(topic); ok {
+			return rule.codec, nil
+		}
+	}
+	return r.fallback, nil
+}
+
+// decodeItems decodes every item of a list on its own, so one bad item does
+// not reject the others
+func decodeItems(items [][]byte, unmarshal func([]byte, any) error) []Reading {
+	readings := make([]Reading, len(items))
+	for i, item := range items {
+		readings[i].Raw = item
+		var req model.CreateSensorRequest
+		if err := unmarshal(item, &req); err != nil {
+			readings[i].Err = err
+			continue
+		}
+		readings[i].Request = &req
+	}
+	return readings
+}
@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iot-server/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp formats of CSV files besides Go time layouts
+const (
+	CSVTimestampRFC3339 = "rfc3339"
+	CSVTimestampUnix    = "unix"
+	CSVTimestampUnixMs  = "unix_ms"
+)
+
+// CSVColumns names the header columns holding each reading field
+type CSVColumns struct {
+	ID1         string
+	ID2         string
+	SensorType  string
+	SensorValue string
+	Timestamp   string
+}
+
+// CSVOptions configures a CSVReader. Empty columns default to the field
+// names, e.g. "sensor_value", an empty format to RFC 3339 and a nil location
+// to UTC. The location applies to layouts without a zone.
+type CSVOptions struct {
+	Columns         CSVColumns
+	TimestampFormat string
+	Location        *time.Location
+	Comma           rune
+}
+
+// CSVReader reads one reading per row of a CSV file with a header row. It is
+// not a Codec, as files are read row by row instead of as one payload.
+type CSVReader struct {
+	reader  *csv.Reader
+	options CSVOptions
+	columns [5]int // id1, id2, sensor_type, sensor_value, timestamp
+	fields  int
+}
+
+// NewCSVReader reads the header row and resolves the mapped columns, header
+// names are matched case-insensitively
+func NewCSVReader(r io.Reader, options CSVOptions) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	if options.Comma != 0 {
+		reader.Comma = options.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if options.TimestampFormat == "" {
+		options.TimestampFormat = CSVTimestampRFC3339
+	}
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file is empty")
+		}
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\uFEFF") // byte order mark
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	c := &CSVReader{reader: reader, options: options, fields: len(header)}
+	names := [5]string{
+		columnOrDefault(options.Columns.ID1, "id1"),
+		columnOrDefault(options.Columns.ID2, "id2"),
+		columnOrDefault(options.Columns.SensorType, "sensor_type"),
+		columnOrDefault(options.Columns.SensorValue, "sensor_value"),
+		columnOrDefault(options.Columns.Timestamp, "timestamp"),
+	}
+	for i, name := range names {
+		column, ok := index[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("csv header has no column %q", name)
+		}
+		c.columns[i] = column
+	}
+	return c, nil
+}
+
+// Next returns the reading of the next row and the line it starts on, or
+// io.EOF after the last row. A row that cannot be parsed is returned with
+// Err set; other errors stop the file.
+func (c *CSVReader) Next() (Reading, int, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// A malformed row is skipped, the reader resumes after it
+			return Reading{Err: parseErr.Err}, parseErr.StartLine, nil
+		}
+		return Reading{}, 0, err
+	}
+	line, _ := c.reader.FieldPos(0)
+
+	if len(record) != c.fields {
+		return Reading{Err: fmt.Errorf("expected %d columns, got %d", c.fields, len(record))}, line, nil
+	}
+	request, err := c.parse(record)
+	if err != nil {
+		return Reading{Err: err}, line, nil
+	}
+	return Reading{Request: request}, line, nil
+}
+
+// Offset returns the number of bytes read so far
+func (c *CSVReader) Offset() int64 {
+	return c.reader.InputOffset()
+}
+
+func (c *CSVReader) parse(record []string) (*model.CreateSensorRequest, error) {
+	field := func(i int) string {
+		return strings.TrimSpace(record[c.columns[i]])
+	}
+
+	id2, err := strconv.ParseInt(field(1), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id2 %q", field(1))
+	}
+	value, err := strconv.ParseFloat(field(3), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sensor_value %q", field(3))
+	}
+	timestamp, err := c.parseTimestamp(field(4))
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.CreateSensorRequest{
+		ID1:         field(0),
+		ID2:         id2,
+		SensorType:  field(2),
+		SensorValue: value,
+		Timestamp:   timestamp,
+	}, nil
+}
+
+func (c *CSVReader) parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing timestamp")
+	}
+
+	var timestamp time.Time
+	var err error
+	switch c.options.TimestampFormat {
+	case CSVTimestampRFC3339:
+		timestamp, err = time.Parse(time.RFC3339Nano, value)
+	case CSVTimestampUnix, CSVTimestampUnixMs:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		if c.options.TimestampFormat == CSVTimestampUnix {
+			timestamp = time.Unix(n, 0)
+		} else {
+			timestamp = time.UnixMilli(n)
+		}
+	default:
+		timestamp, err = time.ParseInLocation(c.options.TimestampFormat, value, c.options.Location)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q for format %s", value, c.options.TimestampFormat)
+	}
+	return timestamp.UTC(), nil
+}
+
+func columnOrDefault(column, field string) string {
+	if column == "" {
+		return field
+	}
+	return column
+}
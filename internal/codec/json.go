@@ -0,0 +1,35 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"iot-server/internal/model"
+)
+
+// JSON decodes a reading object or an array of reading objects
+type JSON struct{}
+
+func (JSON) Name() string { return "json" }
+
+func (JSON) ContentTypes() []string { return []string{ContentTypeJSON} }
+
+func (JSON) Decode(payload []byte) ([]Reading, bool, error) {
+	trimmed := bytes.TrimLeft(payload, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(payload, &items); err != nil {
+			return nil, true, err
+		}
+		raw := make([][]byte, len(items))
+		for i, item := range items {
+			raw[i] = item
+		}
+		return decodeItems(raw, json.Unmarshal), true, nil
+	}
+
+	var req model.CreateSensorRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, false, err
+	}
+	return []Reading{{Request: &req, Raw: payload}}, false, nil
+}
@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"bytes"
+	"iot-server/internal/model"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// MessagePack decodes a reading map or an array of reading maps. Keys are
+// the JSON field names; timestamps may be the timestamp extension or an
+// RFC 3339 string.
+type MessagePack struct{}
+
+func (MessagePack) Name() string { return "msgpack" }
+
+func (MessagePack) ContentTypes() []string {
+	return []string{ContentTypeMessagePack, "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (MessagePack) Decode(payload []byte) ([]Reading, bool, error) {
+	if len(payload) > 0 && isMsgpackArray(payload[0]) {
+		var items []msgpack.RawMessage
+		if err := unmarshalMsgpack(payload, &items); err != nil {
+			return nil, true, err
+		}
+		raw := make([][]byte, len(items))
+		for i, item := range items {
+			raw[i] = item
+		}
+		return decodeItems(raw, unmarshalMsgpack), true, nil
+	}
+
+	var req model.CreateSensorRequest
+	if err := unmarshalMsgpack(payload, &req); err != nil {
+		return nil, false, err
+	}
+	return []Reading{{Request: &req, Raw: payload}}, false, nil
+}
+
+func isMsgpackArray(c byte) bool {
+	return msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32
+}
+
+// unmarshalMsgpack decodes with the json struct tags shared by every codec
+func unmarshalMsgpack(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"iot-server/api/proto/iotserverv1"
+	"iot-server/internal/model/converter"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// batchReadingsField is the field number of SensorReadingBatch.readings, read
+// from the generated descriptor so it follows api/proto/sensor_reading.proto
+var batchReadingsField = (&iotserverv1.SensorReadingBatch{}).ProtoReflect().Descriptor().
+	Fields().ByName("readings").Number()
+
+var errProtobufWireType = errors.New("protobuf: unexpected wire type")
+
+// Protobuf decodes a SensorReadingBatch as published in
+// api/proto/sensor_reading.proto. The payload is always a list, a single
+// reading is a batch of one. Unknown fields are skipped so the schema can grow.
+type Protobuf struct{}
+
+func (Protobuf) Name() string { return "protobuf" }
+
+func (Protobuf) ContentTypes() []string {
+	return []string{ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf"}
+}
+
+// Decode only splits the batch into its readings by hand, so a malformed
+// reading is rejected on its own. Every reading is unmarshalled into the
+// generated SensorReading, the same message the gRPC API takes.
+func (Protobuf) Decode(payload []byte) ([]Reading, bool, error) {
+	items, err := batchItems(payload)
+	if err != nil {
+		return nil, true, err
+	}
+
+	readings := make([]Reading, len(items))
+	for i, item := range items {
+		// Keep a rejected reading replayable as a batch of one
+		raw := protowire.AppendTag(nil, batchReadingsField, protowire.BytesType)
+		readings[i].Raw = protowire.AppendBytes(raw, item)
+
+		reading := new(iotserverv1.SensorReading)
+		if err := proto.Unmarshal(item, reading); err != nil {
+			readings[i].Err = fmt.Errorf("protobuf: reading: %w", err)
+			continue
+		}
+		readings[i].Request = converter.SensorReadingToRequest(reading)
+	}
+	return readings, true, nil
+}
+
+// batchItems returns the encoded readings of a SensorReadingBatch, skipping
+// unknown fields
+func batchItems(b []byte) ([][]byte, error) {
+	items := make([][]byte, 0)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, fmt.Errorf("protobuf: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return nil, fmt.Errorf("protobuf: field %d: %w", num, protowire.ParseError(m))
+		}
+		if num == batchReadingsField {
+			if typ != protowire.BytesType {
+				return nil, fmt.Errorf("protobuf: field %d: %w", num, errProtobufWireType)
+			}
+			item, _ := protowire.ConsumeBytes(b[:m])
+			items = append(items, item)
+		}
+		b = b[m:]
+	}
+	return items, nil
+}
@@ -0,0 +1,189 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Content types of SenML packs (RFC 8428)
+const (
+	ContentTypeSenMLJSON = "application/senml+json"
+	ContentTypeSenMLCBOR = "application/senml+cbor"
+)
+
+// senmlRelativeLimit is the RFC 8428 bound below which times are relative to now
+const senmlRelativeLimit = 1 << 28
+
+// senmlRecord holds the fields of a SenML record, labelled by name in JSON
+// and by the integer labels of RFC 8428 section 6 in CBOR. Base time and base
+// value are pointers, an explicit zero resets them.
+type senmlRecord struct {
+	BaseName  string   `json:"bn,omitempty" cbor:"-2,keyasint,omitempty"`
+	BaseTime  *float64 `json:"bt,omitempty" cbor:"-3,keyasint,omitempty"`
+	BaseUnit  string   `json:"bu,omitempty" cbor:"-4,keyasint,omitempty"`
+	BaseValue *float64 `json:"bv,omitempty" cbor:"-5,keyasint,omitempty"`
+	Name      string   `json:"n,omitempty" cbor:"0,keyasint,omitempty"`
+	Unit      string   `json:"u,omitempty" cbor:"1,keyasint,omitempty"`
+	Value     *float64 `json:"v,omitempty" cbor:"2,keyasint,omitempty"`
+	BoolValue *bool    `json:"vb,omitempty" cbor:"4,keyasint,omitempty"`
+	Time      float64  `json:"t,omitempty" cbor:"6,keyasint,omitempty"`
+}
+
+// SenML decodes a SenML pack into one reading per record. Base name, base
+// time and base value are resolved as in RFC 8428, and the resolved name is
+// mapped onto id1, id2 and sensor_type by a name pattern. Records without a
+// time take the receive time; string and data values are rejected.
+type SenML struct {
+	Names *util.TopicPattern
+	CBOR  bool // decode SenML CBOR instead of SenML JSON
+	// Now returns the time relative record times refer to, time.Now when nil
+	Now func() time.Time
+}
+
+func NewSenML(names *util.TopicPattern, isCBOR bool) *SenML {
+	return &SenML{Names: names, CBOR: isCBOR}
+}
+
+func (s *SenML) Name() string {
+	if s.CBOR {
+		return "senml-cbor"
+	}
+	return "senml-json"
+}
+
+func (s *SenML) ContentTypes() []string {
+	if s.CBOR {
+		return []string{ContentTypeSenMLCBOR}
+	}
+	return []string{ContentTypeSenMLJSON}
+}
+
+func (s *SenML) Decode(payload []byte) ([]Reading, bool, error) {
+	var pack []senmlRecord
+	if err := s.unmarshal(payload, &pack); err != nil {
+		return nil, true, err
+	}
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	var base senmlRecord
+	readings := make([]Reading, len(pack))
+	for i, record := range pack {
+		// Base fields apply to this and the following records until replaced
+		if record.BaseName != "" {
+			base.BaseName = record.BaseName
+		}
+		if record.BaseTime != nil {
+			base.BaseTime = record.BaseTime
+		}
+		if record.BaseUnit != "" {
+			base.BaseUnit = record.BaseUnit
+		}
+		if record.BaseValue != nil {
+			base.BaseValue = record.BaseValue
+		}
+
+		resolved := senmlRecord{
+			Name: base.BaseName + record.Name,
+			Unit: record.Unit,
+			Time: deref(base.BaseTime) + record.Time,
+		}
+		// Relative times are fixed now, so a stored record replays unchanged
+		if resolved.Time != 0 && math.Abs(resolved.Time) < senmlRelativeLimit {
+			resolved.Time += float64(now.UnixNano()) / float64(time.Second)
+		}
+		if resolved.Unit == "" {
+			resolved.Unit = base.BaseUnit
+		}
+		switch {
+		case record.Value != nil:
+			v := deref(base.BaseValue) + *record.Value
+			resolved.Value = &v
+		case record.BoolValue != nil:
+			resolved.BoolValue = record.BoolValue
+		}
+
+		// A resolved record stands on its own, so it is replayable by itself
+		raw, err := s.marshal([]senmlRecord{resolved})
+		if err != nil {
+			return nil, true, err
+		}
+		readings[i].Raw = raw
+
+		req, err := s.toRequest(resolved)
+		if err != nil {
+			readings[i].Err = fmt.Errorf("senml record %d: %w", i, err)
+			continue
+		}
+		readings[i].Request = req
+	}
+	return readings, true, nil
+}
+
+func (s *SenML) toRequest(record senmlRecord) (*model.CreateSensorRequest, error) {
+	var req model.CreateSensorRequest
+
+	values, ok := s.Names.Match(record.Name)
+	if !ok {
+		return nil, fmt.Errorf("name %q does not match pattern %q", record.Name, s.Names.Pattern)
+	}
+	req.ID1 = values["id1"]
+	req.SensorType = values["sensor_type"]
+	if raw, ok := values["id2"]; ok {
+		id2, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("name segment id2 %q is not a number", raw)
+		}
+		req.ID2 = id2
+	}
+
+	switch {
+	case record.Value != nil:
+		req.SensorValue = *record.Value
+	case record.BoolValue != nil && *record.BoolValue:
+		req.SensorValue = 1
+	case record.BoolValue != nil:
+		req.SensorValue = 0
+	default:
+		return nil, errors.New("record has no numeric or boolean value")
+	}
+
+	// A zero time means "now" and is left to the receive time fallback
+	if record.Time != 0 {
+		seconds, fraction := math.Modf(record.Time)
+		req.Timestamp = time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC()
+	}
+	return &req, nil
+}
+
+func deref(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
+
+func (s *SenML) unmarshal(data []byte, v any) error {
+	if s.CBOR {
+		return cbor.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *SenML) marshal(v any) ([]byte, error) {
+	if s.CBOR {
+		return cbor.Marshal(v)
+	}
+	return json.Marshal(v)
+}
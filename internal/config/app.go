@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"iot-server/internal/broker"
+	"iot-server/internal/delivery/coap"
+	"iot-server/internal/delivery/grpc"
 	"iot-server/internal/delivery/http"
 	"iot-server/internal/delivery/http/middleware"
 	"iot-server/internal/delivery/http/route"
@@ -14,7 +17,6 @@ import (
 	"iot-server/internal/util"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
@@ -29,15 +31,32 @@ type BootstrapConfig struct {
 	Log      *logrus.Logger
 	Validate *validator.Validate
 	Config   *viper.Viper
-	Mqtt     *mqtt.Client
+	Mqtt     broker.Client
 	Redis    *redis.Client
 }
 
-func Bootstrap(config *BootstrapConfig) {
+// Runtime holds the components that have to be stopped on shutdown
+type Runtime struct {
+	Subscriptions      *usecase.SubscriptionManager
+	IngestPipeline     *usecase.IngestPipeline
+	SensorRecordWriter *usecase.SensorRecordWriter // nil when disabled
+	CSVImport          *usecase.CSVImportUsecase
+	DeviceCommand      *usecase.DeviceCommandUsecase
+	CoapServer         *coap.Server                 // nil when disabled
+	SensorStream       *usecase.SensorStreamUsecase // nil when disabled
+	GrpcServer         *grpc.Server                 // nil when disabled
+}
+
+func Bootstrap(config *BootstrapConfig) *Runtime {
 	// setup repository
 	sensorRepository := repository.NewSensorRepository(config.DB, config.Log)
 	sensorRecordRepository := repository.NewSensorRecordRepository(config.Log)
 	userRepository := repository.NewUserRepository(config.DB, config.Log)
+	deadLetterRepository := repository.NewDeadLetterRepository(config.DB, config.Log)
+	deviceCommandRepository := repository.NewDeviceCommandRepository(config.DB, config.Log)
+	deviceShadowRepository := repository.NewDeviceShadowRepository(config.DB, config.Log)
+	sensorKeyRepository := repository.NewSensorKeyRepository(config.DB, config.Log)
+	loRaWANUplinkRepository := repository.NewLoRaWANUplinkRepository(config.DB, config.Log)
 
 	// setup util
 	redisClient := config.Redis
@@ -46,36 +65,204 @@ func Bootstrap(config *BootstrapConfig) {
 	duration := config.Config.GetInt("RATE_LIMIT_DURATION")
 	rateLimitUtil := util.NewRateLimiterUtil(redisClient, config.Log, maxRequest, duration)
 
+	codecRegistry := NewCodecRegistry(config.Config, config.Log)
+
 	// setup use cases
-	sensorUseCase := usecase.NewSensorUsecase(config.DB, config.Log, config.Validate, redisClient, sensorRepository, sensorRecordRepository)
+	sensorTopic := NewMqttTopic(config.Config, config.Log)
+	sensorRecordWriter := NewSensorRecordWriter(config, sensorRecordRepository)
+	sensorProducer := NewSensorProducer(config, sensorTopic)
+	sensorUseCase := usecase.NewSensorUsecase(config.DB, config.Log, config.Validate, redisClient, sensorRepository, sensorRecordRepository, sensorRecordWriter, sensorProducer, NewSensorStreamProducer(config), NewDuplicatePolicy(config.Config, config.Log), NewTimestampWindow(config.Config, config.Log))
 	userUsecase := usecase.NewUserUsecase(config.DB, config.Log, config.Validate, userRepository, tokenUtil)
+	deadLetterUsecase := usecase.NewDeadLetterUsecase(config.Log, config.Validate, deadLetterRepository, sensorUseCase, codecRegistry)
+	csvImportUsecase := usecase.NewCSVImportUsecase(config.Log, config.Validate, redisClient, sensorUseCase)
+	commandAckTopic := NewCommandAckTopic(config.Config, config.Log)
+	deviceCommandUsecase := usecase.NewDeviceCommandUsecase(
+		config.Log,
+		config.Validate,
+		deviceCommandRepository,
+		sensorRepository,
+		NewCommandProducer(config, commandAckTopic),
+		time.Duration(config.Config.GetInt("COMMAND_TIMEOUT_SECONDS"))*time.Second,
+		time.Duration(config.Config.GetInt("COMMAND_SWEEP_INTERVAL_SECONDS"))*time.Second,
+	)
+	presenceUsecase := usecase.NewPresenceUsecase(
+		config.Log,
+		config.Validate,
+		redisClient,
+		sensorRepository,
+		time.Duration(config.Config.GetInt("PRESENCE_TIMEOUT_MINUTES"))*time.Minute,
+	)
+	shadowReportedTopic := NewShadowReportedTopic(config.Config, config.Log)
+	deviceShadowUsecase := usecase.NewDeviceShadowUsecase(config.DB, config.Log, config.Validate, deviceShadowRepository, sensorRepository, NewShadowProducer(config, shadowReportedTopic))
+	sensorKeyUsecase := usecase.NewSensorKeyUsecase(config.Log, config.Validate, sensorKeyRepository)
+	sensorStreamUsecase := NewSensorStreamUsecase(config, tokenUtil)
+	loRaWANUsecase := usecase.NewLoRaWANUsecase(config.Log, config.Validate, sensorUseCase, loRaWANUplinkRepository)
+
+	// setup ingest pipeline
+	ingestPipeline := usecase.NewIngestPipeline(
+		config.Log,
+		deadLetterUsecase,
+		config.Config.GetInt("INGEST_QUEUE_SIZE"),
+		config.Config.GetInt("INGEST_WORKERS"),
+		NewOverflowPolicy(config.Config, config.Log),
+	)
 
 	// setup MQTT broker
-	sensorConsumer := messaging.NewSensorConsumer(sensorUseCase, config.Log)
-	mqttClient := *config.Mqtt
-	mqttClient.Subscribe(config.Config.GetString("MQTT_TOPIC"), 0, sensorConsumer.SensorMQTTHandler)
+	topicPrecedence := messaging.TopicPrecedence(config.Config.GetString("MQTT_TOPIC_PRECEDENCE"))
+	if topicPrecedence == "" {
+		topicPrecedence = messaging.TopicPrecedencePayload
+	}
+	sensorConsumer := messaging.NewSensorConsumer(config.Mqtt, sensorUseCase, deadLetterUsecase, ingestPipeline, codecRegistry, config.Log, sensorTopic, topicPrecedence)
+	// replayed dead letters take their fields from the stored topic like live messages
+	deadLetterUsecase.Topics = sensorConsumer
+	mqttQos := NewMqttQos(config.Config, config.Log)
+	shareGroup := NewMqttShareGroup(config.Config, config.Log)
+	subscriptionManager := usecase.NewSubscriptionManager(config.Mqtt, config.Log, 10*time.Second)
+	subscribe := func(filter string, handler broker.Handler) {
+		if err := subscriptionManager.Subscribe(filter, mqttQos, handler); err != nil {
+			config.Log.WithError(err).Fatalf("failed to subscribe to %s", filter)
+		}
+	}
+	subscribe(sensorTopic.SharedSubscription(shareGroup), sensorConsumer.SensorMQTTHandler)
+
+	commandConsumer := messaging.NewCommandConsumer(deviceCommandUsecase, config.Log, commandAckTopic)
+	subscribe(commandAckTopic.SharedSubscription(shareGroup), commandConsumer.CommandAckHandler)
+
+	shadowConsumer := messaging.NewShadowConsumer(deviceShadowUsecase, config.Log, shadowReportedTopic)
+	subscribe(shadowReportedTopic.SharedSubscription(shareGroup), shadowConsumer.ShadowReportedHandler)
+
+	// Status stays unshared: shared subscriptions get no retained messages,
+	// and every replica storing the same status is harmless
+	statusTopic := NewStatusTopic(config.Config, config.Log, sensorTopic)
+	statusConsumer := messaging.NewStatusConsumer(presenceUsecase, config.Log, statusTopic)
+	subscribe(statusTopic.Subscription(), statusConsumer.StatusHandler)
 
 	// setup controller
-	sensorController := http.NewSensorController(sensorUseCase, config.Log)
+	sensorController := http.NewSensorController(sensorUseCase, codecRegistry, config.Log)
 	userController := http.NewUserController(userUsecase, config.Log)
+	deadLetterController := http.NewDeadLetterController(deadLetterUsecase, config.Log)
+	ingestController := http.NewIngestController(ingestPipeline, subscriptionManager, config.Log)
+	csvImportController := http.NewCSVImportController(csvImportUsecase, config.Log)
+	deviceCommandController := http.NewDeviceCommandController(deviceCommandUsecase, config.Log)
+	deviceShadowController := http.NewDeviceShadowController(deviceShadowUsecase, config.Log)
+	presenceController := http.NewPresenceController(presenceUsecase, config.Log)
+	sensorKeyController := http.NewSensorKeyController(sensorKeyUsecase, config.Log)
+	loRaWANController := http.NewLoRaWANController(loRaWANUsecase, config.Log)
 
 	// setup middleware
 	authMiddleware := middleware.NewAuth(userUsecase, tokenUtil, rateLimitUtil)
 
+	var sensorStreamController *http.SensorStreamController
+	var streamAuthMiddleware echo.MiddlewareFunc
+	if sensorStreamUsecase != nil {
+		sensorStreamController = http.NewSensorStreamController(sensorStreamUsecase, config.Log, NewStreamAllowedOrigins(config.Config))
+		streamAuthMiddleware = middleware.NewStreamAuth(sensorStreamUsecase, authMiddleware)
+	}
+
 	routeConfig := route.RouteConfig{
-		App:              config.App,
-		SensorController: sensorController,
-		UserController:   userController,
-		AuthMiddleware:   authMiddleware,
+		App:                     config.App,
+		SensorController:        sensorController,
+		UserController:          userController,
+		DeadLetterController:    deadLetterController,
+		CSVImportController:     csvImportController,
+		DeviceCommandController: deviceCommandController,
+		DeviceShadowController:  deviceShadowController,
+		PresenceController:      presenceController,
+		IngestController:        ingestController,
+		SensorKeyController:     sensorKeyController,
+		SensorStreamController:  sensorStreamController,
+		LoRaWANController:       loRaWANController,
+		AuthMiddleware:          authMiddleware,
+		StreamAuthMiddleware:    streamAuthMiddleware,
+		WebhookMiddleware:       NewLoRaWANWebhookAuth(config.Config),
 	}
 	routeConfig.Setup()
 
+	// setup CoAP endpoint
+	sensorHandler := coap.NewSensorHandler(sensorUseCase, codecRegistry, config.Log)
+	coapServer := NewCoapServer(config, sensorKeyUsecase, sensorHandler)
+
+	// setup gRPC API
+	grpcServer := NewGrpcServer(config, sensorUseCase, tokenUtil, rateLimitUtil)
+
 	// Seed admin user
 	ctx := context.Background()
 	if err := seedAdmin(ctx, config); err != nil {
 		config.Log.WithError(err).Fatalf("failed to seedAdmin")
 	}
 
+	return &Runtime{
+		Subscriptions:      subscriptionManager,
+		IngestPipeline:     ingestPipeline,
+		SensorRecordWriter: sensorRecordWriter,
+		CSVImport:          csvImportUsecase,
+		DeviceCommand:      deviceCommandUsecase,
+		CoapServer:         coapServer,
+		SensorStream:       sensorStreamUsecase,
+		GrpcServer:         grpcServer,
+	}
+}
+
+// NewSensorRecordWriter builds the write-behind writer when SENSOR_WRITER_ENABLED is set
+func NewSensorRecordWriter(config *BootstrapConfig, repo *repository.SensorRecordRepository) *usecase.SensorRecordWriter {
+	if !config.Config.GetBool("SENSOR_WRITER_ENABLED") {
+		return nil
+	}
+
+	batchSize := config.Config.GetInt("SENSOR_WRITER_BATCH_SIZE")
+	flushInterval := time.Duration(config.Config.GetInt("SENSOR_WRITER_FLUSH_INTERVAL_MS")) * time.Millisecond
+	config.Log.Infof("Sensor record writer enabled: batch size %d, flush interval %s", batchSize, flushInterval)
+
+	return usecase.NewSensorRecordWriter(config.DB, config.Log, repo, batchSize, flushInterval)
+}
+
+// NewOverflowPolicy reads INGEST_OVERFLOW_POLICY, defaulting to block
+func NewOverflowPolicy(config *viper.Viper, log *logrus.Logger) usecase.OverflowPolicy {
+	policy := usecase.OverflowPolicy(config.GetString("INGEST_OVERFLOW_POLICY"))
+	switch policy {
+	case "":
+		return usecase.OverflowBlock
+	case usecase.OverflowBlock, usecase.OverflowDropOldest, usecase.OverflowDeadLetter:
+		return policy
+	default:
+		log.Fatalf("invalid INGEST_OVERFLOW_POLICY: %s", policy)
+		return ""
+	}
+}
+
+// NewDuplicatePolicy reads SENSOR_DUPLICATE_POLICY, defaulting to keep_all
+func NewDuplicatePolicy(config *viper.Viper, log *logrus.Logger) string {
+	policy := config.GetString("SENSOR_DUPLICATE_POLICY")
+	switch policy {
+	case "":
+		return entity.DuplicateKeepAll
+	case entity.DuplicateKeepAll, entity.DuplicateIgnore, entity.DuplicateOverwrite:
+		return policy
+	default:
+		log.Fatalf("invalid SENSOR_DUPLICATE_POLICY: %s", policy)
+		return ""
+	}
+}
+
+// NewTimestampWindow reads the timestamp acceptance window and the late-data
+// policy, defaulting to correct
+func NewTimestampWindow(config *viper.Viper, log *logrus.Logger) usecase.TimestampWindow {
+	window := usecase.TimestampWindow{
+		MaxPast:   time.Duration(config.GetInt64("SENSOR_TIMESTAMP_MAX_PAST_SECONDS")) * time.Second,
+		MaxFuture: time.Duration(config.GetInt64("SENSOR_TIMESTAMP_MAX_FUTURE_SECONDS")) * time.Second,
+		LateData:  usecase.LateDataPolicy(config.GetString("SENSOR_LATE_DATA_POLICY")),
+		// 0 keeps the counters for a day
+		StatsWindow: time.Duration(config.GetInt64("SENSOR_TIMESTAMP_STATS_WINDOW_SECONDS")) * time.Second,
+	}
+
+	switch window.LateData {
+	case "":
+		window.LateData = usecase.LateDataCorrect
+	case usecase.LateDataCorrect, usecase.LateDataReject:
+	default:
+		log.Fatalf("invalid SENSOR_LATE_DATA_POLICY: %s", window.LateData)
+	}
+	return window
 }
 
 func seedAdmin(ctx context.Context, config *BootstrapConfig) error {
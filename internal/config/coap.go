@@ -0,0 +1,25 @@
+package config
+
+import (
+	"iot-server/internal/delivery/coap"
+	"iot-server/internal/usecase"
+)
+
+// NewCoapServer starts the CoAP over DTLS endpoint when COAP_ENABLED is set,
+// listening on COAP_ADDRESS (default :5684)
+func NewCoapServer(config *BootstrapConfig, keys *usecase.SensorKeyUsecase, handler *coap.SensorHandler) *coap.Server {
+	if !config.Config.GetBool("COAP_ENABLED") {
+		return nil
+	}
+
+	address := config.Config.GetString("COAP_ADDRESS")
+	if address == "" {
+		address = ":5684"
+	}
+	server, err := coap.NewServer(address, keys, handler, config.Log)
+	if err != nil {
+		config.Log.WithError(err).Fatalf("failed to start CoAP server on %s", address)
+	}
+	config.Log.Infof("CoAP server listening on %s", server.Address())
+	return server
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"iot-server/internal/codec"
+	"iot-server/internal/util"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// NewCodecRegistry builds the payload codec registry. MQTT_CODEC_TOPICS maps
+// topic filters to codecs, e.g. "iot/cbor/#=cbor,iot/pb/#=protobuf", and
+// MQTT_DEFAULT_CODEC is used for the other topics (default json).
+func NewCodecRegistry(config *viper.Viper, log *logrus.Logger) *codec.Registry {
+	registry := codec.NewRegistry()
+
+	senmlNames := NewSenMLNames(config, log)
+	registry.Register(codec.NewSenML(senmlNames, false))
+	registry.Register(codec.NewSenML(senmlNames, true))
+
+	if name := config.GetString("MQTT_DEFAULT_CODEC"); name != "" {
+		if err := registry.SetDefault(name); err != nil {
+			log.Fatalf("invalid MQTT_DEFAULT_CODEC: %v", err)
+		}
+	}
+
+	for _, rule := range strings.Split(config.GetString("MQTT_CODEC_TOPICS"), ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		filter, name, ok := strings.Cut(rule, "=")
+		if !ok {
+			log.Fatalf("invalid MQTT_CODEC_TOPICS rule %q, expected <topic filter>=<codec>", rule)
+		}
+		if err := registry.AddTopic(strings.TrimSpace(filter), strings.TrimSpace(name)); err != nil {
+			log.Fatalf("invalid MQTT_CODEC_TOPICS rule %q: %v", rule, err)
+		}
+	}
+	return registry
+}
+
+// NewSenMLNames parses SENML_NAME_PATTERN, which maps resolved SenML names
+// onto id1, id2 and sensor_type, with segments split by SENML_NAME_SEPARATOR
+func NewSenMLNames(config *viper.Viper, log *logrus.Logger) *util.TopicPattern {
+	pattern := config.GetString("SENML_NAME_PATTERN")
+	if pattern == "" {
+		pattern = "{id1}/{id2}/{sensor_type}"
+	}
+	separator := config.GetString("SENML_NAME_SEPARATOR")
+	if separator == "" {
+		separator = "/"
+	}
+
+	names, err := util.NewNamePattern(pattern, separator)
+	if err != nil {
+		log.Fatalf("invalid SENML_NAME_PATTERN: %v", err)
+	}
+	return names
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"iot-server/internal/delivery/grpc"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+)
+
+// NewGrpcServer starts the gRPC API when GRPC_ENABLED is set, listening on
+// GRPC_ADDRESS (default :9090)
+func NewGrpcServer(config *BootstrapConfig, sensorUsecase *usecase.SensorUsecase, tokenUtil *util.TokenUtil, rateLimiter *util.RateLimiterUtil) *grpc.Server {
+	if !config.Config.GetBool("GRPC_ENABLED") {
+		return nil
+	}
+
+	address := config.Config.GetString("GRPC_ADDRESS")
+	if address == "" {
+		address = ":9090"
+	}
+	auth := grpc.NewAuthInterceptor(tokenUtil, rateLimiter, config.Log, grpc.SensorServiceRoles)
+	server, err := grpc.NewServer(address, auth, grpc.NewSensorService(sensorUsecase, config.Log), config.Log)
+	if err != nil {
+		config.Log.WithError(err).Fatalf("failed to start gRPC server on %s", address)
+	}
+	config.Log.Infof("gRPC server listening on %s", server.Address())
+	return server
+}
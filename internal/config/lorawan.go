@@ -0,0 +1,21 @@
+package config
+
+import (
+	"iot-server/internal/delivery/http/middleware"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+)
+
+// LoRaWANWebhookHeader carries the shared secret of the LoRaWAN webhooks
+const LoRaWANWebhookHeader = "X-Webhook-Secret"
+
+// NewLoRaWANWebhookAuth checks the LORAWAN_WEBHOOK_SECRET of the network
+// server webhooks, which stay disabled while it is unset
+func NewLoRaWANWebhookAuth(config *viper.Viper) echo.MiddlewareFunc {
+	secret := config.GetString("LORAWAN_WEBHOOK_SECRET")
+	if secret == "" {
+		return nil
+	}
+	return middleware.RequireSecret(LoRaWANWebhookHeader, secret)
+}
@@ -1,33 +1,112 @@
 package config
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"iot-server/internal/broker"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
 	"github.com/eclipse/paho.mqtt.golang"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
-func NewMqtt(config *viper.Viper, log *logrus.Logger) mqtt.Client {
+// NewEmbeddedBroker starts the in-process broker when MQTT_EMBEDDED_ENABLED
+// is set, listening on MQTT_EMBEDDED_ADDRESS (default 127.0.0.1:1883) and
+// accepting MQTT_USER and MQTT_PASS. The MQTT client is pointed at it, so
+// MQTT_PROTOCOL, MQTT_HOST and MQTT_PORT are overridden. It returns nil when
+// disabled.
+func NewEmbeddedBroker(config *viper.Viper, log *logrus.Logger) *broker.Embedded {
+	if !config.GetBool("MQTT_EMBEDDED_ENABLED") {
+		return nil
+	}
+
+	address := config.GetString("MQTT_EMBEDDED_ADDRESS")
+	if address == "" {
+		address = "127.0.0.1:1883"
+	}
+	logger := slog.New(slog.NewTextHandler(log.Out, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	embedded, err := broker.NewEmbedded(address, config.GetString("MQTT_USER"), config.GetString("MQTT_PASS"), logger)
+	if err != nil {
+		log.Fatalf("failed to start embedded MQTT broker on %s: %v", address, err)
+	}
+
+	host, port, err := net.SplitHostPort(embedded.Address())
+	if err != nil {
+		log.Fatalf("invalid embedded MQTT broker address %s: %v", embedded.Address(), err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	config.Set("MQTT_PROTOCOL", "tcp")
+	config.Set("MQTT_HOST", host)
+	config.Set("MQTT_PORT", port)
+
+	log.Warnf("Embedded MQTT broker listening on %s, for development and tests only", embedded.Address())
+	return embedded
+}
+
+// NewMqtt connects to the broker with MQTT_VERSION 3.1.1 (default) or 5
+func NewMqtt(config *viper.Viper, log *logrus.Logger) broker.Client {
 	protocol := config.GetString("MQTT_PROTOCOL")
 	host := config.GetString("MQTT_HOST")
 	port := config.GetString("MQTT_PORT")
-	user := config.GetString("MQTT_USER")
-	pass := config.GetString("MQTT_PASS")
-	clientID := config.GetString("MQTT_CLIENT_ID")
 
-	broker := fmt.Sprintf("%s://%s:%s", protocol, host, port)
+	server := fmt.Sprintf("%s://%s:%s", protocol, host, port)
+	switch protocol {
+	case "tcp", "ssl", "tls", "mqtts":
+	case "ws", "wss":
+		path := config.GetString("MQTT_WS_PATH")
+		if path == "" {
+			path = "/mqtt"
+		}
+		server += path
+	default:
+		log.Fatalf("invalid MQTT_PROTOCOL: %s", protocol)
+	}
+
+	switch version := config.GetString("MQTT_VERSION"); version {
+	case "", broker.Version311:
+		return newMqtt3(config, log, server)
+	case broker.Version5:
+		return newMqtt5(config, log, server)
+	default:
+		log.Fatalf("invalid MQTT_VERSION: %s (3.1.1 or 5)", version)
+		return nil
+	}
+}
+
+func newMqtt3(config *viper.Viper, log *logrus.Logger, server string) broker.Client {
+	clientID := NewMqttClientID(config, log)
 
 	// MQTT broker config
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(broker)
+	opts.AddBroker(server)
 	opts.SetClientID(clientID)
-	opts.SetUsername(user)
-	opts.SetPassword(pass)
+	opts.SetUsername(config.GetString("MQTT_USER"))
+	opts.SetPassword(config.GetString("MQTT_PASS"))
 	opts.SetKeepAlive(60 * time.Second)
+	opts.SetCleanSession(config.GetBool("MQTT_CLEAN_SESSION"))
+	if tlsConfig := NewMqttTLSConfig(config, log); tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	// Messages are acknowledged by the handlers once they are persisted
+	opts.SetAutoAckDisabled(true)
 	opts.OnConnect = func(c mqtt.Client) {
-		log.Infof("MQTT connected to %s", broker)
+		log.Infof("MQTT connected to %s as %s", server, clientID)
 	}
 	opts.OnConnectionLost = func(c mqtt.Client, err error) {
 		log.Errorf("MQTT connection lost: %v", err)
@@ -36,18 +115,297 @@ func NewMqtt(config *viper.Viper, log *logrus.Logger) mqtt.Client {
 		log.Warn("MQTT reconnecting…")
 	}
 
-	// Connect MQTT
-	mqttClient := mqtt.NewClient(opts)
-	token := mqttClient.Connect()
+	// Connect MQTT, failing fast instead of waiting forever
+	client := broker.NewMQTT3Client(opts)
+	if err := client.Connect(15 * time.Second); err != nil {
+		log.Fatalf("MQTT connect error: %v", err)
+	}
+	log.Info("MQTT connected successfully")
+
+	return client
+}
 
-	// Fail fast (instead of waiting forever)
-	if !token.WaitTimeout(15 * time.Second) {
-		log.Fatal("MQTT connect timed out")
+// newMqtt5 connects with MQTT 5. A persistent session outlives the connection
+// by MQTT_SESSION_EXPIRY_SECONDS (default one day).
+func newMqtt5(config *viper.Viper, log *logrus.Logger, server string) broker.Client {
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		log.Fatalf("invalid MQTT broker URL %s: %v", server, err)
 	}
-	if err := token.Error(); err != nil {
+	clientID := NewMqttClientID(config, log)
+	cleanSession := config.GetBool("MQTT_CLEAN_SESSION")
+
+	var sessionExpiry uint32
+	if !cleanSession {
+		sessionExpiry = 86400
+		if seconds := config.GetInt("MQTT_SESSION_EXPIRY_SECONDS"); seconds > 0 {
+			sessionExpiry = uint32(seconds)
+		}
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{serverURL},
+		TlsCfg:                        NewMqttTLSConfig(config, log),
+		KeepAlive:                     60,
+		CleanStartOnInitialConnection: cleanSession,
+		SessionExpiryInterval:         sessionExpiry,
+		ConnectUsername:               config.GetString("MQTT_USER"),
+		ConnectPassword:               []byte(config.GetString("MQTT_PASS")),
+		OnConnectionUp: func(*autopaho.ConnectionManager, *paho.Connack) {
+			log.Infof("MQTT 5 connected to %s as %s", server, clientID)
+		},
+		OnConnectionDown: func() bool {
+			log.Error("MQTT connection lost, reconnecting…")
+			return true
+		},
+		OnConnectError: func(err error) {
+			log.Warnf("MQTT connect error: %v", err)
+		},
+		ClientConfig: paho.ClientConfig{ClientID: clientID},
+	}
+
+	client, err := broker.NewMQTT5Client(cfg)
+	if err != nil {
 		log.Fatalf("MQTT connect error: %v", err)
 	}
+
+	// Fail fast (instead of waiting forever)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := client.AwaitConnection(ctx); err != nil {
+		log.Fatal("MQTT connect timed out")
+	}
 	log.Info("MQTT connected successfully")
 
-	return mqttClient
+	return client
+}
+
+// NewMqttTLSConfig builds the TLS configuration of the ssl and wss
+// transports from MQTT_TLS_*. The CA bundle and the client certificate are
+// read again when they change on disk. It returns nil for plain transports.
+func NewMqttTLSConfig(config *viper.Viper, log *logrus.Logger) *tls.Config {
+	switch config.GetString("MQTT_PROTOCOL") {
+	case "ssl", "tls", "mqtts", "wss":
+	default:
+		if config.GetString("MQTT_TLS_CA_FILE") != "" || config.GetString("MQTT_TLS_CERT_FILE") != "" {
+			log.Warn("MQTT_TLS_* is ignored by MQTT_PROTOCOL tcp and ws, use ssl or wss")
+		}
+		return nil
+	}
+
+	var minVersion uint16
+	switch version := config.GetString("MQTT_TLS_MIN_VERSION"); version {
+	case "", "1.2":
+		minVersion = tls.VersionTLS12
+	case "1.3":
+		minVersion = tls.VersionTLS13
+	default:
+		log.Fatalf("invalid MQTT_TLS_MIN_VERSION: %s (1.2 or 1.3)", version)
+	}
+
+	reloader, err := util.NewTLSReloader(
+		config.GetString("MQTT_TLS_CA_FILE"),
+		config.GetString("MQTT_TLS_CERT_FILE"),
+		config.GetString("MQTT_TLS_KEY_FILE"),
+		log,
+	)
+	if err != nil {
+		log.Fatalf("invalid MQTT TLS certificates: %v", err)
+	}
+
+	skipVerify := config.GetBool("MQTT_TLS_SKIP_VERIFY")
+	if skipVerify {
+		log.Warn("MQTT_TLS_SKIP_VERIFY is set, the broker certificate is not verified")
+	}
+	serverName := config.GetString("MQTT_TLS_SERVER_NAME")
+	if serverName == "" {
+		serverName = config.GetString("MQTT_HOST")
+	}
+	return reloader.Config(serverName, minVersion, skipVerify)
+}
+
+// NewMqttClientID expands MQTT_CLIENT_ID. Replicas sharing one ID would
+// take turns disconnecting each other, so {hostname} is replaced by the host
+// name and {random} by random hex digits. A persistent session is bound to
+// the client ID, so it must not contain {random}; without MQTT_CLIENT_ID the
+// ID is iot-server-{hostname}, or iot-server-{hostname}-{random} with a clean
+// session.
+func NewMqttClientID(config *viper.Viper, log *logrus.Logger) string {
+	clientID := config.GetString("MQTT_CLIENT_ID")
+	persistent := !config.GetBool("MQTT_CLEAN_SESSION")
+	if persistent && strings.Contains(clientID, "{random}") {
+		log.Fatal("MQTT_CLIENT_ID must not contain {random} when MQTT_CLEAN_SESSION is false")
+	}
+	if clientID == "" {
+		clientID = "iot-server-{hostname}-{random}"
+		if persistent {
+			clientID = "iot-server-{hostname}"
+		}
+	}
+
+	if strings.Contains(clientID, "{hostname}") {
+		hostname, err := os.Hostname()
+		if err != nil {
+			log.Fatalf("failed to get host name for MQTT_CLIENT_ID: %v", err)
+		}
+		clientID = strings.ReplaceAll(clientID, "{hostname}", hostname)
+	}
+	if strings.Contains(clientID, "{random}") {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatalf("failed to generate MQTT_CLIENT_ID: %v", err)
+		}
+		clientID = strings.ReplaceAll(clientID, "{random}", hex.EncodeToString(b))
+	}
+	return clientID
+}
+
+// NewMqttShareGroup reads MQTT_SHARE_GROUP. When set, consumers subscribe
+// with shared subscriptions of that group, so replicas split the messages
+// instead of each receiving all of them.
+func NewMqttShareGroup(config *viper.Viper, log *logrus.Logger) string {
+	group := config.GetString("MQTT_SHARE_GROUP")
+	if strings.ContainsAny(group, "/+#") {
+		log.Fatalf("invalid MQTT_SHARE_GROUP: %s", group)
+	}
+	return group
+}
+
+// NewMqttTopic parses MQTT_TOPIC, which may contain named segments such as
+// iot/{id1}/{id2}/{sensor_type}
+func NewMqttTopic(config *viper.Viper, log *logrus.Logger) *util.TopicPattern {
+	pattern, err := util.NewTopicPattern(config.GetString("MQTT_TOPIC"))
+	if err != nil {
+		log.Fatalf("invalid MQTT_TOPIC: %v", err)
+	}
+	return pattern
+}
+
+// NewMqttQos reads the subscription QoS from MQTT_QOS
+func NewMqttQos(config *viper.Viper, log *logrus.Logger) byte {
+	qos := config.GetInt("MQTT_QOS")
+	if qos < 0 || qos > 2 {
+		log.Fatalf("invalid MQTT_QOS: %d", qos)
+	}
+	return byte(qos)
+}
+
+// NewSensorProducer builds the gateway republishing committed readings when
+// MQTT_PUBLISH_ENABLED is set. The processed topics must lie outside the
+// ingest subscription, or every reading would be ingested again.
+func NewSensorProducer(config *BootstrapConfig, sensorTopic *util.TopicPattern) *messaging.SensorProducer {
+	if !config.Config.GetBool("MQTT_PUBLISH_ENABLED") {
+		return nil
+	}
+
+	prefix := config.Config.GetString("MQTT_PUBLISH_TOPIC_PREFIX")
+	if prefix == "" {
+		prefix = "iot/processed"
+	}
+	qos := config.Config.GetInt("MQTT_PUBLISH_QOS")
+	if qos < 0 || qos > 2 {
+		config.Log.Fatalf("invalid MQTT_PUBLISH_QOS: %d", qos)
+	}
+	retained := config.Config.GetBool("MQTT_PUBLISH_RETAINED")
+
+	producer := messaging.NewSensorProducer(config.Mqtt, config.Log, prefix, byte(qos), retained)
+	sample := producer.Topic(&model.SensorReadingEvent{ID1: "SENSOR", ID2: 1, SensorType: "type"})
+	if _, ok := sensorTopic.Match(sample); ok {
+		config.Log.Fatalf("MQTT_PUBLISH_TOPIC_PREFIX %s overlaps MQTT_TOPIC", prefix)
+	}
+
+	config.Log.Infof("Sensor producer enabled: %s/<id1>/<id2>/<sensor_type>, qos %d, retained %t", producer.TopicPrefix, qos, retained)
+	return producer
+}
+
+// NewCommandProducer publishes device commands to MQTT_COMMAND_TOPIC_PREFIX
+// with MQTT_COMMAND_QOS, naming the ack topic as MQTT 5 response topic
+func NewCommandProducer(config *BootstrapConfig, ackTopic *util.TopicPattern) *messaging.CommandProducer {
+	prefix := config.Config.GetString("MQTT_COMMAND_TOPIC_PREFIX")
+	if prefix == "" {
+		prefix = "iot/command"
+	}
+	qos := config.Config.GetInt("MQTT_COMMAND_QOS")
+	if qos < 0 || qos > 2 {
+		config.Log.Fatalf("invalid MQTT_COMMAND_QOS: %d", qos)
+	}
+	return messaging.NewCommandProducer(config.Mqtt, config.Log, prefix, byte(qos), ackTopic)
+}
+
+// NewCommandAckTopic parses MQTT_COMMAND_ACK_TOPIC, which has to name the
+// id1 and id2 segments of the answering device
+func NewCommandAckTopic(config *viper.Viper, log *logrus.Logger) *util.TopicPattern {
+	raw := config.GetString("MQTT_COMMAND_ACK_TOPIC")
+	if raw == "" {
+		raw = "iot/command/{id1}/{id2}/ack"
+	}
+	if !strings.Contains(raw, "{id1}") || !strings.Contains(raw, "{id2}") {
+		log.Fatalf("MQTT_COMMAND_ACK_TOPIC must contain {id1} and {id2}: %s", raw)
+	}
+	pattern, err := util.NewTopicPattern(raw)
+	if err != nil {
+		log.Fatalf("invalid MQTT_COMMAND_ACK_TOPIC: %v", err)
+	}
+	return pattern
+}
+
+// NewShadowProducer publishes shadow deltas below MQTT_SHADOW_TOPIC_PREFIX
+// with MQTT_SHADOW_QOS. The delta topics must lie outside the reported
+// subscription, or the server would consume its own deltas.
+func NewShadowProducer(config *BootstrapConfig, reportedTopic *util.TopicPattern) *messaging.ShadowProducer {
+	prefix := config.Config.GetString("MQTT_SHADOW_TOPIC_PREFIX")
+	if prefix == "" {
+		prefix = "iot/shadow"
+	}
+	qos := config.Config.GetInt("MQTT_SHADOW_QOS")
+	if qos < 0 || qos > 2 {
+		config.Log.Fatalf("invalid MQTT_SHADOW_QOS: %d", qos)
+	}
+
+	producer := messaging.NewShadowProducer(config.Mqtt, config.Log, prefix, byte(qos))
+	if _, ok := reportedTopic.Match(producer.Topic("SENSOR", 1)); ok {
+		config.Log.Fatalf("MQTT_SHADOW_TOPIC_PREFIX %s overlaps MQTT_SHADOW_REPORTED_TOPIC", prefix)
+	}
+	return producer
+}
+
+// NewShadowReportedTopic parses MQTT_SHADOW_REPORTED_TOPIC, which has to name
+// the id1 and id2 segments of the reporting device
+func NewShadowReportedTopic(config *viper.Viper, log *logrus.Logger) *util.TopicPattern {
+	raw := config.GetString("MQTT_SHADOW_REPORTED_TOPIC")
+	if raw == "" {
+		raw = "iot/shadow/{id1}/{id2}/reported"
+	}
+	if !strings.Contains(raw, "{id1}") || !strings.Contains(raw, "{id2}") {
+		log.Fatalf("MQTT_SHADOW_REPORTED_TOPIC must contain {id1} and {id2}: %s", raw)
+	}
+	pattern, err := util.NewTopicPattern(raw)
+	if err != nil {
+		log.Fatalf("invalid MQTT_SHADOW_REPORTED_TOPIC: %v", err)
+	}
+	return pattern
+}
+
+// NewStatusTopic parses MQTT_STATUS_TOPIC, which has to name the id1 and id2
+// segments of the announcing device. The status topics must lie outside the
+// ingest subscription, or the sensor consumer would receive them.
+func NewStatusTopic(config *viper.Viper, log *logrus.Logger, sensorTopic *util.TopicPattern) *util.TopicPattern {
+	raw := config.GetString("MQTT_STATUS_TOPIC")
+	if raw == "" {
+		raw = "iot/status/{id1}/{id2}/state"
+	}
+	if !strings.Contains(raw, "{id1}") || !strings.Contains(raw, "{id2}") {
+		log.Fatalf("MQTT_STATUS_TOPIC must contain {id1} and {id2}: %s", raw)
+	}
+	pattern, err := util.NewTopicPattern(raw)
+	if err != nil {
+		log.Fatalf("invalid MQTT_STATUS_TOPIC: %v", err)
+	}
+	sample, err := pattern.Topic(map[string]string{"id1": "SENSOR", "id2": "1"})
+	if err == nil {
+		if _, ok := sensorTopic.Match(sample); ok {
+			log.Fatalf("MQTT_STATUS_TOPIC %s overlaps MQTT_TOPIC", raw)
+		}
+	}
+	return pattern
 }
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// NewSensorStreamChannel reads SENSOR_STREAM_CHANNEL, the Redis channel
+// shared by all replicas, defaulting to sensor-stream
+func NewSensorStreamChannel(config *viper.Viper) string {
+	channel := config.GetString("SENSOR_STREAM_CHANNEL")
+	if channel == "" {
+		channel = "sensor-stream"
+	}
+	return channel
+}
+
+// NewSensorStreamProducer publishes committed readings for the live streams
+// when SENSOR_STREAM_ENABLED is set. Up to SENSOR_STREAM_PUBLISH_BUFFER_SIZE
+// readings (default 1024) wait for Redis.
+func NewSensorStreamProducer(config *BootstrapConfig) *messaging.SensorStreamProducer {
+	if !config.Config.GetBool("SENSOR_STREAM_ENABLED") {
+		return nil
+	}
+
+	bufferSize := config.Config.GetInt("SENSOR_STREAM_PUBLISH_BUFFER_SIZE")
+	if bufferSize == 0 {
+		bufferSize = 1024
+	}
+	return messaging.NewSensorStreamProducer(config.Redis, config.Log, NewSensorStreamChannel(config.Config), bufferSize)
+}
+
+// NewSensorStreamUsecase subscribes to the live stream channel when
+// SENSOR_STREAM_ENABLED is set. Every client gets a buffer of
+// SENSOR_STREAM_BUFFER_SIZE readings (default 64), and stream tickets are
+// valid for SENSOR_STREAM_TICKET_TTL_SECONDS (default 30).
+func NewSensorStreamUsecase(config *BootstrapConfig, tokenUtil *util.TokenUtil) *usecase.SensorStreamUsecase {
+	if !config.Config.GetBool("SENSOR_STREAM_ENABLED") {
+		return nil
+	}
+
+	bufferSize := config.Config.GetInt("SENSOR_STREAM_BUFFER_SIZE")
+	if bufferSize == 0 {
+		bufferSize = 64
+	}
+	ticketTTL := time.Duration(config.Config.GetInt("SENSOR_STREAM_TICKET_TTL_SECONDS")) * time.Second
+	if ticketTTL <= 0 {
+		ticketTTL = 30 * time.Second
+	}
+	return usecase.NewSensorStreamUsecase(
+		config.Log,
+		config.Validate,
+		tokenUtil,
+		config.Redis,
+		NewSensorStreamChannel(config.Config),
+		bufferSize,
+		NewStreamDropPolicy(config.Config, config.Log),
+		ticketTTL,
+	)
+}
+
+// NewStreamAllowedOrigins reads SENSOR_STREAM_ALLOWED_ORIGINS, the comma
+// separated origins of pages allowed to open a WebSocket stream besides the
+// server's own. "*" allows every origin.
+func NewStreamAllowedOrigins(config *viper.Viper) []string {
+	var origins []string
+	for _, origin := range strings.Split(config.GetString("SENSOR_STREAM_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	return origins
+}
+
+// NewStreamDropPolicy reads SENSOR_STREAM_DROP_POLICY, defaulting to drop-oldest
+func NewStreamDropPolicy(config *viper.Viper, log *logrus.Logger) usecase.StreamDropPolicy {
+	policy := usecase.StreamDropPolicy(config.GetString("SENSOR_STREAM_DROP_POLICY"))
+	switch policy {
+	case "":
+		return usecase.StreamDropOldest
+	case usecase.StreamDropOldest, usecase.StreamDropNewest, usecase.StreamDisconnect:
+		return policy
+	default:
+		log.Fatalf("invalid SENSOR_STREAM_DROP_POLICY: %s", policy)
+		return ""
+	}
+}
@@ -0,0 +1,174 @@
+package coap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iot-server/internal/codec"
+	"iot-server/internal/usecase"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	piondtls "github.com/pion/dtls/v3"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPayloadSize caps a reading, a constrained device sends a few bytes
+const maxPayloadSize = 1024
+
+type SensorHandler struct {
+	UseCase *usecase.SensorUsecase
+	Codecs  *codec.Registry
+	Log     *logrus.Logger
+}
+
+func NewSensorHandler(useCase *usecase.SensorUsecase, codecs *codec.Registry, logger *logrus.Logger) *SensorHandler {
+	return &SensorHandler{
+		UseCase: useCase,
+		Codecs:  codecs,
+		Log:     logger,
+	}
+}
+
+// Create stores the reading POSTed to /sensor/{id1}/{id2}/{type}. The
+// content-format option selects the codec and the path sets id1, id2 and the
+// sensor type, which have to be those of the PSK identity the device
+// authenticated with. A stored reading is answered with 2.01 Created, piggybacked
+// on the ACK of a confirmable request; a retransmitted request gets the same
+// response again without being stored twice.
+func (h *SensorHandler) Create(w mux.ResponseWriter, r *mux.Message) {
+	receivedAt := time.Now()
+	path := r.RouteParams.Path
+	if r.Code() != codes.POST {
+		h.respond(w, codes.MethodNotAllowed, "only POST is allowed")
+		return
+	}
+
+	vars := r.RouteParams.Vars
+	id1, sensorType := vars["id1"], vars["type"]
+	id2, err := strconv.ParseInt(vars["id2"], 10, 64)
+	if err != nil {
+		h.respond(w, codes.BadRequest, fmt.Sprintf("id2 %q is not a number", vars["id2"]))
+		return
+	}
+
+	log := h.Log.WithFields(logrus.Fields{"path": path, "remote": w.Conn().RemoteAddr().String()})
+	identity := peerIdentity(w.Conn())
+	if identity != usecase.SensorKeyIdentity(id1, id2) {
+		log.WithField("identity", identity).Warn("CoAP: device is not allowed to post to path")
+		h.respond(w, codes.Forbidden, "path does not belong to the authenticated device")
+		return
+	}
+
+	// Without content-format the default codec is used
+	contentType := ""
+	if mediaType, err := r.ContentFormat(); err == nil {
+		contentType = mediaType.String()
+	}
+	decoder, err := h.Codecs.Resolve("", contentType)
+	if err != nil {
+		h.respond(w, codes.UnsupportedMediaType, err.Error())
+		return
+	}
+
+	payload, err := r.ReadBody()
+	if err != nil {
+		h.respond(w, codes.BadRequest, "failed to read payload")
+		return
+	}
+	if len(payload) > maxPayloadSize {
+		h.respond(w, codes.RequestEntityTooLarge, "payload too large")
+		return
+	}
+	readings, isBatch, err := decoder.Decode(payload)
+	if err != nil {
+		log.WithField("codec", decoder.Name()).WithError(err).Warn("CoAP: invalid payload")
+		h.respond(w, codes.BadRequest, err.Error())
+		return
+	}
+	if isBatch {
+		h.respond(w, codes.BadRequest, "one reading per request")
+		return
+	}
+
+	req := readings[0].Request
+	req.ID1, req.ID2, req.SensorType = id1, id2, sensorType
+	req.ReceivedAt = receivedAt
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := h.UseCase.Create(ctx, req)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"sensor_value": req.SensorValue,
+			"timestamp":    req.Timestamp,
+		}).WithError(err).Error("CoAP: create failed")
+		code, diagnostic := errorCode(err)
+		h.respond(w, code, diagnostic)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"sensor_value": resp.SensorsRecords[0].SensorValue,
+		"timestamp":    resp.SensorsRecords[0].Timestamp,
+	}).Info("CoAP: sensor data created")
+	h.respond(w, codes.Created, "")
+}
+
+// respond sets the response code with an optional diagnostic payload
+func (h *SensorHandler) respond(w mux.ResponseWriter, code codes.Code, diagnostic string) {
+	var body io.ReadSeeker
+	if diagnostic != "" {
+		body = strings.NewReader(diagnostic)
+	}
+	if err := w.SetResponse(code, message.TextPlain, body); err != nil {
+		h.Log.WithError(err).Error("CoAP: failed to set response")
+	}
+}
+
+// peerIdentity returns the PSK identity the peer presented in the DTLS handshake
+func peerIdentity(conn mux.Conn) string {
+	dtlsConn, ok := conn.NetConn().(*piondtls.Conn)
+	if !ok {
+		return ""
+	}
+	state, ok := dtlsConn.ConnectionState()
+	if !ok {
+		return ""
+	}
+	return string(state.IdentityHint)
+}
+
+// errorCode maps a use case error onto a CoAP response code and diagnostic
+func errorCode(err error) (codes.Code, string) {
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		return codes.InternalServerError, "internal server error"
+	}
+
+	diagnostic := fmt.Sprint(httpErr.Message)
+	switch httpErr.Code {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity:
+		return codes.BadRequest, diagnostic
+	case http.StatusForbidden:
+		return codes.Forbidden, diagnostic
+	case http.StatusNotFound:
+		return codes.NotFound, diagnostic
+	case http.StatusRequestEntityTooLarge:
+		return codes.RequestEntityTooLarge, diagnostic
+	case http.StatusTooManyRequests:
+		return codes.TooManyRequests, diagnostic
+	case http.StatusServiceUnavailable:
+		return codes.ServiceUnavailable, diagnostic
+	default:
+		return codes.InternalServerError, "internal server error"
+	}
+}
@@ -0,0 +1,91 @@
+package coap
+
+import (
+	"context"
+	"iot-server/internal/usecase"
+	"time"
+
+	piondtls "github.com/pion/dtls/v3"
+	"github.com/plgd-dev/go-coap/v3/dtls"
+	dtlsserver "github.com/plgd-dev/go-coap/v3/dtls/server"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapnet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/sirupsen/logrus"
+)
+
+// cipherSuites are the PSK suites offered, CCM_8 first as mandated by RFC 7252
+var cipherSuites = []piondtls.CipherSuiteID{
+	piondtls.TLS_PSK_WITH_AES_128_CCM_8,
+	piondtls.TLS_PSK_WITH_AES_128_CCM,
+	piondtls.TLS_PSK_WITH_AES_128_GCM_SHA256,
+	piondtls.TLS_PSK_WITH_AES_128_CBC_SHA256,
+}
+
+// Server accepts CoAP requests over DTLS. A device authenticates with the PSK
+// identity "<id1>/<id2>" and the pre-shared key stored for it.
+type Server struct {
+	server   *dtlsserver.Server
+	listener *coapnet.DTLSListener
+	done     chan struct{}
+}
+
+// NewServer starts listening on a UDP address, e.g. ":5684" or
+// "127.0.0.1:0" for a free port
+func NewServer(address string, keys *usecase.SensorKeyUsecase, handler *SensorHandler, log *logrus.Logger) (*Server, error) {
+	router := mux.NewRouter()
+	if err := router.Handle("/sensor/{id1}/{id2}/{type}", mux.HandlerFunc(handler.Create)); err != nil {
+		return nil, err
+	}
+
+	listener, err := coapnet.NewDTLSListener("udp", address, &piondtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			key, err := keys.Lookup(ctx, string(identity))
+			if err != nil {
+				log.WithField("identity", string(identity)).WithError(err).Warn("CoAP: DTLS handshake refused")
+			}
+			return key, err
+		},
+		CipherSuites: cipherSuites,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		server: dtls.NewServer(
+			options.WithMux(router),
+			options.WithErrors(func(err error) {
+				log.WithError(err).Debug("CoAP: connection error")
+			}),
+		),
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(listener); err != nil {
+			log.WithError(err).Error("CoAP: server stopped")
+		}
+	}()
+	return s, nil
+}
+
+// Address returns the address the server listens on, with the port resolved
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Stop closes the listener and waits until the open connections are closed
+func (s *Server) Stop(ctx context.Context) error {
+	s.server.Stop()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
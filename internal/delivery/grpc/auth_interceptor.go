@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type authKey struct{}
+
+// AuthInterceptor authenticates every call with the same tokens as the HTTP
+// API and checks the roles a method requires. Methods without roles are
+// denied, so a new method stays closed until it is listed.
+type AuthInterceptor struct {
+	TokenUtil   *util.TokenUtil
+	RateLimiter *util.RateLimiterUtil
+	Log         *logrus.Logger
+	Roles       map[string][]entity.Role // by full method name
+}
+
+func NewAuthInterceptor(tokenUtil *util.TokenUtil, rateLimiter *util.RateLimiterUtil, log *logrus.Logger, roles map[string][]entity.Role) *AuthInterceptor {
+	return &AuthInterceptor{
+		TokenUtil:   tokenUtil,
+		RateLimiter: rateLimiter,
+		Log:         log,
+		Roles:       roles,
+	}
+}
+
+func (i *AuthInterceptor) Unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	auth, err := i.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, authKey{}, auth), req)
+}
+
+func (i *AuthInterceptor) Stream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	auth, err := i.authorize(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: stream, ctx: context.WithValue(stream.Context(), authKey{}, auth)})
+}
+
+func (i *AuthInterceptor) authorize(ctx context.Context, method string) (*model.Auth, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		i.Log.Warn("missing authorization metadata")
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+
+	// Accept "Bearer <token>"
+	parts := strings.SplitN(values[0], " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || strings.TrimSpace(parts[1]) == "" {
+		i.Log.Warn("invalid authorization metadata format")
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+
+	auth, err := i.TokenUtil.ParseToken(ctx, strings.TrimSpace(parts[1]))
+	if err != nil {
+		i.Log.WithError(err).Warn("failed to parsing/validation token")
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	if !i.RateLimiter.IsAllowed(ctx, auth) {
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+
+	for _, role := range i.Roles[method] {
+		if entity.Role(auth.Role) == role {
+			return auth, nil
+		}
+	}
+	return nil, status.Error(codes.PermissionDenied, "forbidden")
+}
+
+// GetUser returns the user authenticated by the interceptor
+func GetUser(ctx context.Context) (*model.Auth, bool) {
+	auth, ok := ctx.Value(authKey{}).(*model.Auth)
+	return auth, ok
+}
+
+// authStream carries the authenticated user in the context of a stream
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
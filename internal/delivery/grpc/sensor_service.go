@@ -0,0 +1,369 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iot-server/api/proto/iotserverv1"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/usecase"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 20
+	streamPageSize  = 100
+)
+
+// SensorServiceRoles are the roles of every method, matching the HTTP routes:
+// searches are open to every role, mutations including CreateReadings (the
+// live /ingest route) to admin only
+var SensorServiceRoles = map[string][]entity.Role{
+	iotserverv1.SensorService_SearchById_FullMethodName:             {entity.RoleAdmin, entity.RoleUser, entity.RoleIngest},
+	iotserverv1.SensorService_SearchByTimeRange_FullMethodName:      {entity.RoleAdmin, entity.RoleUser, entity.RoleIngest},
+	iotserverv1.SensorService_SearchByIdAndTimeRange_FullMethodName: {entity.RoleAdmin, entity.RoleUser, entity.RoleIngest},
+	iotserverv1.SensorService_StreamTimeRange_FullMethodName:        {entity.RoleAdmin, entity.RoleUser, entity.RoleIngest},
+	iotserverv1.SensorService_CreateReading_FullMethodName:          {entity.RoleAdmin},
+	iotserverv1.SensorService_CreateReadings_FullMethodName:         {entity.RoleAdmin},
+	iotserverv1.SensorService_DeleteById_FullMethodName:             {entity.RoleAdmin},
+	iotserverv1.SensorService_DeleteByTimeRange_FullMethodName:      {entity.RoleAdmin},
+	iotserverv1.SensorService_DeleteByIdAndTimeRange_FullMethodName: {entity.RoleAdmin},
+	iotserverv1.SensorService_UpdateById_FullMethodName:             {entity.RoleAdmin},
+	iotserverv1.SensorService_UpdateByTimeRange_FullMethodName:      {entity.RoleAdmin},
+	iotserverv1.SensorService_UpdateByIdAndTimeRange_FullMethodName: {entity.RoleAdmin},
+}
+
+// SensorService serves api/proto/sensor_service.proto with the sensor use case
+type SensorService struct {
+	iotserverv1.UnimplementedSensorServiceServer
+
+	UseCase *usecase.SensorUsecase
+	Log     *logrus.Logger
+}
+
+func NewSensorService(useCase *usecase.SensorUsecase, log *logrus.Logger) *SensorService {
+	return &SensorService{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (s *SensorService) CreateReading(ctx context.Context, reading *iotserverv1.SensorReading) (*iotserverv1.Sensor, error) {
+	response, err := s.UseCase.Create(ctx, converter.SensorReadingToRequest(reading))
+	if err != nil {
+		s.Log.WithError(err).Error("failed to create sensor record")
+		return nil, toStatus(err)
+	}
+	return sensorToProto(response), nil
+}
+
+func (s *SensorService) CreateReadings(ctx context.Context, batch *iotserverv1.SensorReadingBatch) (*iotserverv1.CreateReadingsResponse, error) {
+	requests := make([]*model.CreateSensorRequest, len(batch.GetReadings()))
+	for i, reading := range batch.GetReadings() {
+		requests[i] = converter.SensorReadingToRequest(reading)
+	}
+
+	results, err := s.UseCase.CreateBatch(ctx, requests)
+	if err != nil {
+		s.Log.WithError(err).Error("failed to create sensor records")
+		return nil, toStatus(err)
+	}
+
+	response := &iotserverv1.CreateReadingsResponse{Results: make([]*iotserverv1.CreateReadingResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = &iotserverv1.CreateReadingResult{Index: int32(result.Index), Duplicate: result.Duplicate}
+		if result.Err != nil {
+			response.Results[i].Error = errorMessage(result.Err)
+		} else {
+			response.Results[i].Sensor = sensorToProto(result.Response)
+		}
+	}
+	return response, nil
+}
+
+func (s *SensorService) SearchById(ctx context.Context, req *iotserverv1.SearchByIdRequest) (*iotserverv1.SearchByIdResponse, error) {
+	page, pageSize := pageDefaults(req.GetPage(), req.GetPageSize(), defaultPageSize)
+	response, metadata, err := s.UseCase.SearchByIdCombination(ctx, &model.SensorSearchByIdRequest{
+		ID1:        req.GetId1(),
+		ID2:        req.GetId2(),
+		SensorType: req.GetSensorType(),
+		Page:       page,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to search sensor record")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.SearchByIdResponse{Sensor: sensorToProto(response), Paging: pageToProto(metadata)}, nil
+}
+
+func (s *SensorService) SearchByTimeRange(ctx context.Context, req *iotserverv1.SearchByTimeRangeRequest) (*iotserverv1.SearchByTimeRangeResponse, error) {
+	page, pageSize := pageDefaults(req.GetPage(), req.GetPageSize(), defaultPageSize)
+	responses, metadata, err := s.UseCase.SearchByTimeRange(ctx, &model.SensorSearchByTimeRangeRequest{
+		Start:    toTime(req.GetStart()),
+		End:      toTime(req.GetEnd()),
+		Page:     page,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to search sensor records")
+		return nil, toStatus(err)
+	}
+
+	sensors := make([]*iotserverv1.Sensor, len(responses))
+	for i := range responses {
+		sensors[i] = sensorToProto(&responses[i])
+	}
+	return &iotserverv1.SearchByTimeRangeResponse{Sensors: sensors, Paging: pageToProto(metadata)}, nil
+}
+
+func (s *SensorService) SearchByIdAndTimeRange(ctx context.Context, req *iotserverv1.SearchByIdAndTimeRangeRequest) (*iotserverv1.SearchByIdResponse, error) {
+	page, pageSize := pageDefaults(req.GetPage(), req.GetPageSize(), defaultPageSize)
+	response, metadata, err := s.UseCase.SearchByIdAndTimeRange(ctx, &model.SensorSearchByIdAndTimeRangeRequest{
+		ID1:        req.GetId1(),
+		ID2:        req.GetId2(),
+		SensorType: req.GetSensorType(),
+		Start:      toTime(req.GetStart()),
+		End:        toTime(req.GetEnd()),
+		Page:       page,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to search sensor record")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.SearchByIdResponse{Sensor: sensorToProto(response), Paging: pageToProto(metadata)}, nil
+}
+
+// StreamTimeRange walks the pages of a time-range search until the last one
+func (s *SensorService) StreamTimeRange(req *iotserverv1.StreamTimeRangeRequest, stream grpc.ServerStreamingServer[iotserverv1.Sensor]) error {
+	ctx := stream.Context()
+	_, pageSize := pageDefaults(1, req.GetPageSize(), streamPageSize)
+
+	for page := 1; ; page++ {
+		var (
+			sensors  []model.SensorResponse
+			metadata *model.PageMetadata
+			err      error
+		)
+		if req.GetId1() != "" || req.GetId2() != 0 {
+			var sensor *model.SensorResponse
+			sensor, metadata, err = s.UseCase.SearchByIdAndTimeRange(ctx, &model.SensorSearchByIdAndTimeRangeRequest{
+				ID1:        req.GetId1(),
+				ID2:        req.GetId2(),
+				SensorType: req.GetSensorType(),
+				Start:      toTime(req.GetStart()),
+				End:        toTime(req.GetEnd()),
+				Page:       page,
+				PageSize:   pageSize,
+			})
+			if sensor != nil && len(sensor.SensorsRecords) > 0 {
+				sensors = []model.SensorResponse{*sensor}
+			}
+		} else {
+			sensors, metadata, err = s.UseCase.SearchByTimeRange(ctx, &model.SensorSearchByTimeRangeRequest{
+				Start:    toTime(req.GetStart()),
+				End:      toTime(req.GetEnd()),
+				Page:     page,
+				PageSize: pageSize,
+			})
+		}
+		if err != nil {
+			s.Log.WithError(err).Error("failed to stream sensor records")
+			return toStatus(err)
+		}
+
+		for i := range sensors {
+			if err := stream.Send(sensorToProto(&sensors[i])); err != nil {
+				return err
+			}
+		}
+		if int64(page) >= metadata.TotalPage {
+			return nil
+		}
+	}
+}
+
+func (s *SensorService) DeleteById(ctx context.Context, req *iotserverv1.DeleteByIdRequest) (*iotserverv1.DeleteResponse, error) {
+	response, err := s.UseCase.DeleteByIdCombination(ctx, &model.SensorSearchByIdRequest{
+		ID1:        req.GetId1(),
+		ID2:        req.GetId2(),
+		SensorType: req.GetSensorType(),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to delete sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.DeleteResponse{Deleted: response.Deleted}, nil
+}
+
+func (s *SensorService) DeleteByTimeRange(ctx context.Context, req *iotserverv1.DeleteByTimeRangeRequest) (*iotserverv1.DeleteResponse, error) {
+	response, err := s.UseCase.DeleteByTimeRange(ctx, &model.SensorSearchByTimeRangeRequest{
+		Start: toTime(req.GetStart()),
+		End:   toTime(req.GetEnd()),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to delete sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.DeleteResponse{Deleted: response.Deleted}, nil
+}
+
+func (s *SensorService) DeleteByIdAndTimeRange(ctx context.Context, req *iotserverv1.DeleteByIdAndTimeRangeRequest) (*iotserverv1.DeleteResponse, error) {
+	response, err := s.UseCase.DeleteByIdAndTimeRange(ctx, &model.SensorSearchByIdAndTimeRangeRequest{
+		ID1:        req.GetId1(),
+		ID2:        req.GetId2(),
+		SensorType: req.GetSensorType(),
+		Start:      toTime(req.GetStart()),
+		End:        toTime(req.GetEnd()),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to delete sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.DeleteResponse{Deleted: response.Deleted}, nil
+}
+
+func (s *SensorService) UpdateById(ctx context.Context, req *iotserverv1.UpdateByIdRequest) (*iotserverv1.UpdateResponse, error) {
+	response, err := s.UseCase.UpdateByIdCombination(ctx, &model.SensorUpdateByIdRequest{
+		ID1:         req.GetId1(),
+		ID2:         req.GetId2(),
+		SensorType:  req.GetSensorType(),
+		SensorValue: req.GetSensorValue(),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to update sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.UpdateResponse{Updated: response.Updated}, nil
+}
+
+func (s *SensorService) UpdateByTimeRange(ctx context.Context, req *iotserverv1.UpdateByTimeRangeRequest) (*iotserverv1.UpdateResponse, error) {
+	response, err := s.UseCase.UpdateByTimeRange(ctx, &model.SensorUpdateByTimeRangeRequest{
+		Start:       toTime(req.GetStart()),
+		End:         toTime(req.GetEnd()),
+		SensorValue: req.GetSensorValue(),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to update sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.UpdateResponse{Updated: response.Updated}, nil
+}
+
+func (s *SensorService) UpdateByIdAndTimeRange(ctx context.Context, req *iotserverv1.UpdateByIdAndTimeRangeRequest) (*iotserverv1.UpdateResponse, error) {
+	response, err := s.UseCase.UpdateByIdAndTimeRange(ctx, &model.SensorUpdateByIdAndTimeRangeRequest{
+		ID1:         req.GetId1(),
+		ID2:         req.GetId2(),
+		SensorType:  req.GetSensorType(),
+		Start:       toTime(req.GetStart()),
+		End:         toTime(req.GetEnd()),
+		SensorValue: req.GetSensorValue(),
+	})
+	if err != nil {
+		s.Log.WithError(err).Error("failed to update sensor records")
+		return nil, toStatus(err)
+	}
+	return &iotserverv1.UpdateResponse{Updated: response.Updated}, nil
+}
+
+func sensorToProto(response *model.SensorResponse) *iotserverv1.Sensor {
+	if response == nil {
+		return nil
+	}
+
+	records := make([]*iotserverv1.SensorRecord, len(response.SensorsRecords))
+	for i, record := range response.SensorsRecords {
+		records[i] = &iotserverv1.SensorRecord{
+			SensorType:      record.SensorType,
+			SensorValue:     record.SensorValue,
+			Timestamp:       timestamppb.New(record.Timestamp),
+			TimestampSource: record.TimestampSource,
+			Duplicate:       record.Duplicate,
+		}
+	}
+	return &iotserverv1.Sensor{
+		Id1:        response.ID1,
+		Id2:        response.ID2,
+		SensorType: response.SensorType,
+		Records:    records,
+	}
+}
+
+func pageToProto(metadata *model.PageMetadata) *iotserverv1.PageMetadata {
+	if metadata == nil {
+		return nil
+	}
+	return &iotserverv1.PageMetadata{
+		Page:      int32(metadata.Page),
+		Size:      int32(metadata.Size),
+		TotalItem: metadata.TotalItem,
+		TotalPage: metadata.TotalPage,
+	}
+}
+
+// pageDefaults fills in an unset page and page size. A page size above the
+// maximum is left to the validation.
+func pageDefaults(page, pageSize int32, defaultSize int) (int, int) {
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = int32(defaultSize)
+	}
+	return int(page), int(pageSize)
+}
+
+// toTime maps an unset timestamp to the zero time, which the validation
+// rejects where a time is required
+func toTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// toStatus maps the HTTP errors of the use cases to gRPC status codes
+func toStatus(err error) error {
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	code := codes.Internal
+	switch httpErr.Code {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+	return status.Error(code, errorMessage(httpErr))
+}
+
+func errorMessage(err error) string {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return fmt.Sprint(httpErr.Message)
+	}
+	return err.Error()
+}
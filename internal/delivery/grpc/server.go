@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"iot-server/api/proto/iotserverv1"
+	"net"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// Server serves the gRPC API over plain TCP, TLS is left to a proxy as for
+// the HTTP API
+type Server struct {
+	server   *grpc.Server
+	listener net.Listener
+	done     chan struct{}
+}
+
+// NewServer starts listening on a TCP address, e.g. ":9090" or "127.0.0.1:0"
+// for a free port
+func NewServer(address string, auth *AuthInterceptor, sensorService *SensorService, log *logrus.Logger) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		server: grpc.NewServer(
+			grpc.UnaryInterceptor(auth.Unary),
+			grpc.StreamInterceptor(auth.Stream),
+		),
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	iotserverv1.RegisterSensorServiceServer(s.server, sensorService)
+
+	go func() {
+		defer close(s.done)
+		if err := s.server.Serve(listener); err != nil {
+			log.WithError(err).Error("gRPC: server stopped")
+		}
+	}()
+	return s, nil
+}
+
+// Address returns the address the server listens on, with the port resolved
+func (s *Server) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Stop waits for the pending calls to finish, cancelling them when the
+// context expires first
+func (s *Server) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.server.GracefulStop()
+	}()
+
+	select {
+	case <-stopped:
+		<-s.done
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		<-s.done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"io"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type CSVImportController struct {
+	UseCase *usecase.CSVImportUsecase
+	Log     *logrus.Logger
+}
+
+func NewCSVImportController(useCase *usecase.CSVImportUsecase, log *logrus.Logger) *CSVImportController {
+	return &CSVImportController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+// Start imports the CSV file of a multipart upload in the background. The
+// upload is copied to a temporary file first, as the multipart files are
+// removed when the request ends.
+func (c CSVImportController) Start(ctx echo.Context) error {
+	var request model.CSVImportRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		c.Log.WithError(err).Warn("missing csv file")
+		return echo.NewHTTPError(http.StatusBadRequest, "file is required")
+	}
+	upload, err := header.Open()
+	if err != nil {
+		c.Log.WithError(err).Error("failed to open uploaded file")
+		return echo.ErrInternalServerError
+	}
+	defer upload.Close()
+
+	file, err := os.CreateTemp("", "csv-import-*.csv")
+	if err != nil {
+		c.Log.WithError(err).Error("failed to create temporary file")
+		return echo.ErrInternalServerError
+	}
+	_, err = io.Copy(file, upload)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		c.Log.WithError(err).Error("failed to store uploaded file")
+		return echo.ErrInternalServerError
+	}
+
+	request.File = file.Name()
+	request.FileName = header.Filename
+	request.Temporary = true
+
+	response, err := c.UseCase.Start(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to start csv import")
+		return err
+	}
+
+	return ctx.JSON(http.StatusAccepted, model.WebResponse[*model.CSVImportJobResponse]{Data: response})
+}
+
+func (c CSVImportController) Get(ctx echo.Context) error {
+	var request model.CSVImportGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Get(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to get csv import")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.CSVImportJobResponse]{Data: response})
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type DeadLetterController struct {
+	UseCase *usecase.DeadLetterUsecase
+	Log     *logrus.Logger
+}
+
+func NewDeadLetterController(useCase *usecase.DeadLetterUsecase, log *logrus.Logger) *DeadLetterController {
+	return &DeadLetterController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (c DeadLetterController) List(ctx echo.Context) error {
+	var request model.DeadLetterListRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	// Defaults value
+	if request.Page == 0 {
+		request.Page = 1
+	}
+	if request.PageSize == 0 {
+		request.PageSize = 20
+	}
+
+	response, metadata, err := c.UseCase.List(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to list dead letters")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[[]model.DeadLetterResponse]{
+		Data:   response,
+		Paging: metadata,
+	})
+}
+
+func (c DeadLetterController) Get(ctx echo.Context) error {
+	var request model.DeadLetterGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Get(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to get dead letter")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeadLetterResponse]{Data: response})
+}
+
+func (c DeadLetterController) Update(ctx echo.Context) error {
+	var request model.DeadLetterUpdateRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Update(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to update dead letter")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeadLetterResponse]{Data: response})
+}
+
+func (c DeadLetterController) Replay(ctx echo.Context) error {
+	var request model.DeadLetterGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Replay(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to replay dead letter")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeadLetterReplayResponse]{Data: response})
+}
+
+func (c DeadLetterController) Delete(ctx echo.Context) error {
+	var request model.DeadLetterGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Delete(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to delete dead letter")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeadLetterPurgeResponse]{Data: response})
+}
+
+func (c DeadLetterController) Purge(ctx echo.Context) error {
+	var request model.DeadLetterPurgeRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Purge(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to purge dead letters")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeadLetterPurgeResponse]{Data: response})
+}
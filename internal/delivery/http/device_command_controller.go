@@ -0,0 +1,91 @@
+package http
+
+import (
+	"iot-server/internal/delivery/http/middleware"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type DeviceCommandController struct {
+	UseCase *usecase.DeviceCommandUsecase
+	Log     *logrus.Logger
+}
+
+func NewDeviceCommandController(useCase *usecase.DeviceCommandUsecase, log *logrus.Logger) *DeviceCommandController {
+	return &DeviceCommandController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (c DeviceCommandController) Create(ctx echo.Context) error {
+	var request model.DeviceCommandCreateRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+	if auth, ok := middleware.GetUser(ctx); ok {
+		request.CreatedBy = auth.ID
+	}
+
+	response, err := c.UseCase.Create(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to create device command")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeviceCommandResponse]{Data: response})
+}
+
+func (c DeviceCommandController) List(ctx echo.Context) error {
+	var request model.DeviceCommandListRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	// Defaults value
+	if request.Page == 0 {
+		request.Page = 1
+	}
+	if request.PageSize == 0 {
+		request.PageSize = 20
+	}
+
+	response, metadata, err := c.UseCase.List(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to list device commands")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[[]model.DeviceCommandResponse]{
+		Data:   response,
+		Paging: metadata,
+	})
+}
+
+func (c DeviceCommandController) Get(ctx echo.Context) error {
+	var request model.DeviceCommandGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Get(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to get device command")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeviceCommandResponse]{Data: response})
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type DeviceShadowController struct {
+	UseCase *usecase.DeviceShadowUsecase
+	Log     *logrus.Logger
+}
+
+func NewDeviceShadowController(useCase *usecase.DeviceShadowUsecase, log *logrus.Logger) *DeviceShadowController {
+	return &DeviceShadowController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (c DeviceShadowController) Get(ctx echo.Context) error {
+	var request model.DeviceShadowGetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Get(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to get device shadow")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeviceShadowResponse]{Data: response})
+}
+
+func (c DeviceShadowController) UpdateDesired(ctx echo.Context) error {
+	var request model.DeviceShadowUpdateRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.UpdateDesired(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to update desired shadow")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.DeviceShadowResponse]{Data: response})
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type IngestController struct {
+	Pipeline            *usecase.IngestPipeline
+	SubscriptionManager *usecase.SubscriptionManager
+	Log                 *logrus.Logger
+}
+
+func NewIngestController(pipeline *usecase.IngestPipeline, subscriptionManager *usecase.SubscriptionManager, log *logrus.Logger) *IngestController {
+	return &IngestController{
+		Pipeline:            pipeline,
+		SubscriptionManager: subscriptionManager,
+		Log:                 log,
+	}
+}
+
+func (c IngestController) Stats(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.IngestStatsResponse]{Data: c.Pipeline.Stats()})
+}
+
+// Subscriptions answers 503 while MQTT messages are not received, so it can
+// back a health check
+func (c IngestController) Subscriptions(ctx echo.Context) error {
+	status := c.SubscriptionManager.Status()
+	code := http.StatusOK
+	if !status.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	return ctx.JSON(code, model.WebResponse[*model.MQTTSubscriptionsResponse]{Data: status})
+}
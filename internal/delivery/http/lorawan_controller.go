@@ -0,0 +1,103 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type LoRaWANController struct {
+	UseCase *usecase.LoRaWANUsecase
+	Log     *logrus.Logger
+}
+
+func NewLoRaWANController(useCase *usecase.LoRaWANUsecase, log *logrus.Logger) *LoRaWANController {
+	return &LoRaWANController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+// ChirpStack receives the events of a ChirpStack v4 HTTP integration with
+// the JSON encoding. Events other than uplinks are acknowledged and ignored.
+func (c LoRaWANController) ChirpStack(ctx echo.Context) error {
+	if event := ctx.QueryParam("event"); event != "" && event != "up" {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType)); mediaType != echo.MIMEApplicationJSON {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "only the JSON encoding of the HTTP integration is supported")
+	}
+
+	var request model.ChirpStackUplink
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.ChirpStackUplink(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to store ChirpStack uplink")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.LoRaWANUplinkResponse]{Data: response})
+}
+
+// TTS receives the uplink messages of a The Things Stack v3 webhook. Other
+// message types are acknowledged and ignored.
+func (c LoRaWANController) TTS(ctx echo.Context) error {
+	var request model.TTSUplink
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+	if request.UplinkMessage == nil {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+
+	response, err := c.UseCase.TTSUplink(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to store The Things Stack uplink")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.LoRaWANUplinkResponse]{Data: response})
+}
+
+// List returns the radio metadata of the uplinks of a device and port
+func (c LoRaWANController) List(ctx echo.Context) error {
+	var request model.LoRaWANUplinkListRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	// Defaults value
+	if request.Page == 0 {
+		request.Page = 1
+	}
+	if request.PageSize == 0 {
+		request.PageSize = 20
+	}
+
+	response, metadata, err := c.UseCase.List(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to list LoRaWAN uplinks")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[[]model.LoRaWANUplinkMetadataResponse]{
+		Data:   response,
+		Paging: metadata,
+	})
+}
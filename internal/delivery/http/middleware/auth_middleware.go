@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"iot-server/internal/entity"
 	"iot-server/internal/model"
 	"iot-server/internal/usecase"
@@ -75,3 +76,38 @@ func RequireRoles(allowed ...entity.Role) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// NewStreamAuth admits live stream requests by the single-use ticket in the
+// ticket query parameter, for browsers that cannot set headers on WebSocket
+// and EventSource requests. Requests without a ticket go through auth.
+func NewStreamAuth(streamUC *usecase.SensorStreamUsecase, auth echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		withAuth := auth(next)
+		return func(c echo.Context) error {
+			ticket := c.QueryParam("ticket")
+			if ticket == "" {
+				return withAuth(c)
+			}
+
+			user, err := streamUC.RedeemTicket(c.Request().Context(), ticket)
+			if err != nil {
+				return err
+			}
+			c.Set(ctxAuthKey, user)
+			return next(c)
+		}
+	}
+}
+
+// RequireSecret admits requests carrying a shared secret in a header, for
+// webhooks of systems that cannot log in
+func RequireSecret(header, secret string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if subtle.ConstantTimeCompare([]byte(c.Request().Header.Get(header)), []byte(secret)) != 1 {
+				return echo.ErrUnauthorized
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type PresenceController struct {
+	UseCase *usecase.PresenceUsecase
+	Log     *logrus.Logger
+}
+
+func NewPresenceController(useCase *usecase.PresenceUsecase, log *logrus.Logger) *PresenceController {
+	return &PresenceController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (c PresenceController) List(ctx echo.Context) error {
+	var request model.SensorPresenceListRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	// Defaults value
+	if request.Page == 0 {
+		request.Page = 1
+	}
+	if request.PageSize == 0 {
+		request.PageSize = 20
+	}
+
+	response, metadata, err := c.UseCase.List(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to list sensor presence")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[[]model.SensorPresenceResponse]{
+		Data:   response,
+		Paging: metadata,
+	})
+}
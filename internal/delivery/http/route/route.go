@@ -9,15 +9,27 @@ import (
 )
 
 type RouteConfig struct {
-	App              *echo.Echo
-	SensorController *http.SensorController
-	UserController   *http.UserController
-	AuthMiddleware   echo.MiddlewareFunc
+	App                     *echo.Echo
+	SensorController        *http.SensorController
+	UserController          *http.UserController
+	DeadLetterController    *http.DeadLetterController
+	CSVImportController     *http.CSVImportController
+	DeviceCommandController *http.DeviceCommandController
+	DeviceShadowController  *http.DeviceShadowController
+	PresenceController      *http.PresenceController
+	IngestController        *http.IngestController
+	SensorKeyController     *http.SensorKeyController
+	SensorStreamController  *http.SensorStreamController // nil when live streams are disabled
+	LoRaWANController       *http.LoRaWANController
+	AuthMiddleware          echo.MiddlewareFunc
+	StreamAuthMiddleware    echo.MiddlewareFunc // nil when live streams are disabled
+	WebhookMiddleware       echo.MiddlewareFunc // nil when the LoRaWAN webhooks are disabled
 }
 
 func (c *RouteConfig) Setup() {
 	c.SetupGuestRoute()
 	c.SetupAuthRoute()
+	c.SetupWebhookRoute()
 }
 
 func (c *RouteConfig) SetupGuestRoute() {
@@ -32,19 +44,80 @@ func (c *RouteConfig) SetupAuthRoute() {
 	sensor.GET("/search/by-id", c.SensorController.SearchByCombinedId)
 	sensor.GET("/search/by-time-range", c.SensorController.SearchByTimeRange)
 	sensor.GET("/search/by-id-time-range", c.SensorController.SearchByIdAndTimeRange)
+	sensor.GET("/timestamp-stats", c.SensorController.TimestampStats)
+	sensor.GET("/presence", c.PresenceController.List)
 
 	// Admin-only (mutations)
 	admin := sensor.Group("", middleware.RequireRoles(entity.RoleAdmin))
 	admin.POST("/create", c.SensorController.CreateSensor)
+	admin.POST("/ingest", c.SensorController.Ingest)
 	admin.DELETE("/delete/by-id", c.SensorController.DeleteByCombinedId)
 	admin.DELETE("/delete/by-time-range", c.SensorController.DeleteByTimeRange)
 	admin.DELETE("/delete/by-id-time-range", c.SensorController.DeleteByIdAndTimeRange)
 	admin.PATCH("/update/by-id", c.SensorController.UpdateByCombinedId)
 	admin.PATCH("/update/by-time-range", c.SensorController.UpdateByTimeRange)
 	admin.PATCH("/update/by-id-time-range", c.SensorController.UpdateByIdAndTimeRange)
+	admin.PATCH("/duplicate-policy", c.SensorController.UpdateDuplicatePolicy)
+	admin.PUT("/psk", c.SensorKeyController.Set)
+	admin.DELETE("/psk", c.SensorKeyController.Delete)
+
+	// Bulk ingestion and CSV imports, open to ingestion clients without admin rights
+	bulk := sensor.Group("", middleware.RequireRoles(entity.RoleAdmin, entity.RoleIngest))
+	bulk.POST("/bulk", c.SensorController.BulkCreate)
+	bulk.POST("/import", c.CSVImportController.Start)
+	bulk.GET("/import/:id", c.CSVImportController.Get)
+
+	// Live readings. Browsers cannot set headers on WebSocket and EventSource
+	// requests, so they open a stream with a ticket instead of the token.
+	if c.SensorStreamController != nil {
+		sensor.POST("/stream/ticket", c.SensorStreamController.Ticket)
+		stream := c.App.Group("/api/v1/sensor/stream", c.StreamAuthMiddleware)
+		stream.GET("/ws", c.SensorStreamController.WebSocket)
+		stream.GET("/sse", c.SensorStreamController.SSE)
+	}
+
+	// LoRaWAN radio metadata, readable by any user
+	v1.GET("/lorawan/uplinks", c.LoRaWANController.List)
+
+	// Admin-only dead letters of rejected MQTT messages
+	deadLetter := v1.Group("/dead-letter", middleware.RequireRoles(entity.RoleAdmin))
+	deadLetter.GET("/list", c.DeadLetterController.List)
+	deadLetter.DELETE("/purge", c.DeadLetterController.Purge)
+	deadLetter.GET("/:id", c.DeadLetterController.Get)
+	deadLetter.PATCH("/:id", c.DeadLetterController.Update)
+	deadLetter.DELETE("/:id", c.DeadLetterController.Delete)
+	deadLetter.POST("/:id/replay", c.DeadLetterController.Replay)
+
+	// Admin-only device commands
+	command := v1.Group("/command", middleware.RequireRoles(entity.RoleAdmin))
+	command.POST("", c.DeviceCommandController.Create)
+	command.GET("/list", c.DeviceCommandController.List)
+	command.GET("/:id", c.DeviceCommandController.Get)
+
+	// Device shadows, readable by any user, desired state set by admins
+	shadow := v1.Group("/shadow")
+	shadow.GET("", c.DeviceShadowController.Get)
+	shadow.PATCH("/desired", c.DeviceShadowController.UpdateDesired, middleware.RequireRoles(entity.RoleAdmin))
+
+	// Admin-only ingestion metrics
+	ingest := v1.Group("/ingest", middleware.RequireRoles(entity.RoleAdmin))
+	ingest.GET("/stats", c.IngestController.Stats)
+	ingest.GET("/subscriptions", c.IngestController.Subscriptions)
 
 	// Authenticated
 	user := c.App.Group("/api/users", c.AuthMiddleware)
 	user.POST("", c.UserController.Register, middleware.RequireRoles(entity.RoleAdmin))
 	user.GET("/logout", c.UserController.Logout)
 }
+
+// SetupWebhookRoute registers the uplink webhooks of the LoRaWAN network
+// servers, authenticated by a shared secret instead of a token
+func (c *RouteConfig) SetupWebhookRoute() {
+	if c.WebhookMiddleware == nil {
+		return
+	}
+
+	lorawan := c.App.Group("/api/v1/lorawan", c.WebhookMiddleware)
+	lorawan.POST("/chirpstack", c.LoRaWANController.ChirpStack)
+	lorawan.POST("/tts", c.LoRaWANController.TTS)
+}
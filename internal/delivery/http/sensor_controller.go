@@ -1,22 +1,32 @@
 package http
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"iot-server/internal/codec"
 	"iot-server/internal/model"
 	"iot-server/internal/usecase"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 )
 
+// maxIngestBodySize bounds the payload of an ingest request
+const maxIngestBodySize = 1 << 20
+
 type SensorController struct {
 	UseCase *usecase.SensorUsecase
+	Codecs  *codec.Registry
 	Log     *logrus.Logger
 }
 
-func NewSensorController(useCase *usecase.SensorUsecase, log *logrus.Logger) *SensorController {
+func NewSensorController(useCase *usecase.SensorUsecase, codecs *codec.Registry, log *logrus.Logger) *SensorController {
 	return &SensorController{
 		UseCase: useCase,
+		Codecs:  codecs,
 		Log:     log,
 	}
 }
@@ -39,6 +49,58 @@ func (c SensorController) CreateSensor(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorResponse]{Data: response})
 }
 
+// Ingest stores the readings of a payload decoded by the codec of its
+// Content-Type, e.g. a SenML pack. JSON is assumed without a Content-Type.
+func (c SensorController) Ingest(ctx echo.Context) error {
+	decoder, err := c.Codecs.Resolve("", ctx.Request().Header.Get(echo.HeaderContentType))
+	if err != nil {
+		c.Log.WithError(err).Warn("unsupported ingest content type")
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, err.Error())
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(ctx.Request().Body, maxIngestBodySize+1))
+	if err != nil {
+		c.Log.WithError(err).Error("failed to read request body")
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body")
+	}
+	if len(payload) > maxIngestBodySize {
+		return echo.ErrStatusRequestEntityTooLarge
+	}
+
+	readings, _, err := decoder.Decode(payload)
+	if err != nil {
+		c.Log.WithError(err).WithField("codec", decoder.Name()).Warn("failed to decode ingest payload")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	response, err := c.UseCase.Ingest(ctx.Request().Context(), readings, time.Now())
+	if err != nil {
+		c.Log.WithError(err).Error("failed to ingest sensor records")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorIngestResponse]{Data: response})
+}
+
+// BulkCreate streams a newline-delimited JSON body of sensor records into the
+// database in chunks. When the import stops early the summary of the lines
+// handled so far is returned with the error.
+func (c SensorController) BulkCreate(ctx echo.Context) error {
+	response, err := c.UseCase.BulkCreate(ctx.Request().Context(), ctx.Request().Body, time.Now())
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bulk create sensor records")
+
+		code, message := http.StatusInternalServerError, err.Error()
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			code, message = httpErr.Code, fmt.Sprint(httpErr.Message)
+		}
+		return ctx.JSON(code, model.WebResponse[*model.SensorBulkResponse]{Data: response, Errors: message})
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorBulkResponse]{Data: response})
+}
+
 func (c SensorController) SearchByCombinedId(ctx echo.Context) error {
 	var request model.SensorSearchByIdRequest
 
@@ -245,3 +307,39 @@ func (c SensorController) UpdateByIdAndTimeRange(ctx echo.Context) error {
 		Data: response,
 	})
 }
+
+func (c SensorController) UpdateDuplicatePolicy(ctx echo.Context) error {
+	var request model.SensorDuplicatePolicyRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.UpdateDuplicatePolicy(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to update sensor duplicate policy")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorDuplicatePolicyResponse]{Data: response})
+}
+
+func (c SensorController) TimestampStats(ctx echo.Context) error {
+	var request model.SensorTimestampStatsRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.TimestampStats(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to get timestamp stats")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorTimestampStatsResponse]{Data: response})
+}
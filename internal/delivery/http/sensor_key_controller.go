@@ -0,0 +1,59 @@
+package http
+
+import (
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+type SensorKeyController struct {
+	UseCase *usecase.SensorKeyUsecase
+	Log     *logrus.Logger
+}
+
+func NewSensorKeyController(useCase *usecase.SensorKeyUsecase, log *logrus.Logger) *SensorKeyController {
+	return &SensorKeyController{
+		UseCase: useCase,
+		Log:     log,
+	}
+}
+
+func (c SensorKeyController) Set(ctx echo.Context) error {
+	var request model.SensorKeySetRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	response, err := c.UseCase.Set(ctx.Request().Context(), &request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to set sensor key")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorKeyResponse]{Data: response})
+}
+
+func (c SensorKeyController) Delete(ctx echo.Context) error {
+	var request model.SensorKeyDeleteRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return err
+	}
+
+	if err := c.UseCase.Delete(ctx.Request().Context(), &request); err != nil {
+		c.Log.WithError(err).Error("failed to delete sensor key")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.MessageResponse[string]{
+		Message: "Sensor key deleted",
+	})
+}
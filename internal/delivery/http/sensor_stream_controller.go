@@ -0,0 +1,210 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"iot-server/internal/delivery/http/middleware"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	streamWriteTimeout = 10 * time.Second
+	// streamKeepAlive keeps idle streams open through proxies
+	streamKeepAlive = 30 * time.Second
+)
+
+type SensorStreamController struct {
+	UseCase *usecase.SensorStreamUsecase
+	Log     *logrus.Logger
+	// AllowedOrigins may open WebSocket streams besides the server's own
+	// origin, "*" allows every origin
+	AllowedOrigins []string
+
+	upgrader websocket.Upgrader
+}
+
+func NewSensorStreamController(useCase *usecase.SensorStreamUsecase, log *logrus.Logger, allowedOrigins []string) *SensorStreamController {
+	c := &SensorStreamController{
+		UseCase:        useCase,
+		Log:            log,
+		AllowedOrigins: allowedOrigins,
+	}
+	c.upgrader = websocket.Upgrader{CheckOrigin: c.checkOrigin}
+	return c
+}
+
+// Ticket issues a single-use ticket opening a stream as the current user
+func (c SensorStreamController) Ticket(ctx echo.Context) error {
+	auth, ok := middleware.GetUser(ctx)
+	if !ok {
+		return echo.ErrUnauthorized
+	}
+
+	response, err := c.UseCase.CreateTicket(ctx.Request().Context(), auth)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to create stream ticket")
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, model.WebResponse[*model.SensorStreamTicketResponse]{Data: response})
+}
+
+// WebSocket pushes every matching reading as a SensorStreamMessage. When the
+// client falls behind, a "dropped" message precedes the next reading.
+func (c SensorStreamController) WebSocket(ctx echo.Context) error {
+	stream, err := c.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.UseCase.Unsubscribe(stream)
+
+	conn, err := c.upgrader.Upgrade(ctx.Response(), ctx.Request(), nil)
+	if err != nil {
+		// the upgrader already answered the request
+		c.Log.WithError(err).Warn("failed to upgrade live stream to WebSocket")
+		return nil
+	}
+	defer conn.Close()
+
+	// The client sends nothing, reading only tells when it is gone
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(message *model.SensorStreamMessage) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(message)
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-stream.Events():
+			if dropped := stream.TakeDropped(); dropped > 0 {
+				if err := write(&model.SensorStreamMessage{Type: model.SensorStreamDropped, Dropped: dropped}); err != nil {
+					return nil
+				}
+			}
+			if err := write(&model.SensorStreamMessage{Type: model.SensorStreamReading, Reading: event}); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return nil
+			}
+		case <-stream.Closed():
+			message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "stream closed by server")
+			_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(streamWriteTimeout))
+			return nil
+		case <-gone:
+			return nil
+		}
+	}
+}
+
+// SSE pushes every matching reading as a "reading" event. When the client
+// falls behind, a "dropped" event precedes the next reading.
+func (c SensorStreamController) SSE(ctx echo.Context) error {
+	stream, err := c.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.UseCase.Unsubscribe(stream)
+
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no") // unbuffered behind nginx
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-stream.Events():
+			if dropped := stream.TakeDropped(); dropped > 0 {
+				if err := writeEvent(res, model.SensorStreamDropped, &model.SensorStreamMessage{Type: model.SensorStreamDropped, Dropped: dropped}); err != nil {
+					return nil
+				}
+			}
+			if err := writeEvent(res, model.SensorStreamReading, event); err != nil {
+				return nil
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-stream.Closed():
+			return nil
+		case <-ctx.Request().Context().Done():
+			return nil
+		}
+	}
+}
+
+func (c SensorStreamController) subscribe(ctx echo.Context) (*usecase.SensorStream, error) {
+	var request model.SensorStreamRequest
+
+	err := ctx.Bind(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to bind request")
+		return nil, err
+	}
+
+	stream, err := c.UseCase.Subscribe(&request)
+	if err != nil {
+		c.Log.WithError(err).Error("failed to open live stream")
+		return nil, err
+	}
+	return stream, nil
+}
+
+func writeEvent(res *echo.Response, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// checkOrigin admits requests without an Origin header, which do not come
+// from a browser, from the server's own origin and from AllowedOrigins. A
+// ticket is single-use, but a foreign page must not open streams with it.
+func (c SensorStreamController) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	c.Log.WithField("origin", origin).Warn("live stream from a foreign origin refused")
+	return false
+}
@@ -0,0 +1,82 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandConsumer receives device acks on a topic naming the device, e.g.
+// iot/command/{id1}/{id2}/ack. The device is taken from the topic so a device
+// can only answer its own commands when the broker ACL restricts its topics.
+type CommandConsumer struct {
+	UseCase *usecase.DeviceCommandUsecase
+	Log     *logrus.Logger
+	Topic   *util.TopicPattern
+}
+
+func NewCommandConsumer(useCase *usecase.DeviceCommandUsecase, logger *logrus.Logger, topic *util.TopicPattern) *CommandConsumer {
+	return &CommandConsumer{
+		UseCase: useCase,
+		Log:     logger,
+		Topic:   topic,
+	}
+}
+
+// CommandAckHandler records an ack. Invalid acks are dropped and a database
+// failure is retried a few times; the message is acknowledged either way so it
+// does not stay in flight.
+func (c *CommandConsumer) CommandAckHandler(msg broker.Message) {
+	c.handle(msg)
+	msg.Ack()
+}
+
+func (c *CommandConsumer) handle(msg broker.Message) {
+	values, ok := c.Topic.Match(msg.Topic())
+	if !ok {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: command ack on unexpected topic")
+		return
+	}
+
+	var ack model.DeviceCommandAck
+	if err := json.Unmarshal(msg.Payload(), &ack); err != nil {
+		c.Log.WithField("topic", msg.Topic()).WithError(err).Warn("MQTT: invalid command ack")
+		return
+	}
+	// id1 is escaped in the topic as by the command producer
+	id1, err := util.UnescapeTopicLevel(values["id1"])
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: command ack topic has an invalid id1")
+		return
+	}
+	ack.ID1 = id1
+	id2, err := strconv.ParseInt(values["id2"], 10, 64)
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: command ack topic has an invalid id2")
+		return
+	}
+	ack.ID2 = id2
+	// MQTT 5 devices may answer with the correlation data of the command
+	if ack.CommandID == 0 {
+		ack.CommandID, _ = strconv.ParseInt(string(msg.Properties().CorrelationData), 10, 64)
+	}
+
+	_, err = retryTransient(func() (struct{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return struct{}{}, c.UseCase.Ack(ctx, &ack)
+	})
+	if err != nil {
+		c.Log.WithFields(logrus.Fields{
+			"topic":   msg.Topic(),
+			"command": ack.CommandID,
+		}).WithError(err).Error("MQTT: command ack failed")
+	}
+}
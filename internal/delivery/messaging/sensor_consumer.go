@@ -3,59 +3,401 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"iot-server/internal/broker"
+	"iot-server/internal/codec"
+	"iot-server/internal/entity"
 	"iot-server/internal/model"
+	"iot-server/internal/repository"
 	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/eclipse/paho.mqtt.golang"
+	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 )
 
+// TopicPrecedence decides which side wins when a field is present in both the
+// payload and the topic
+type TopicPrecedence string
+
+const (
+	TopicPrecedencePayload TopicPrecedence = "payload"
+	TopicPrecedenceTopic   TopicPrecedence = "topic"
+)
+
+// traceProperties are the MQTT 5 user properties added to the log fields
+var traceProperties = []string{"trace_id", "firmware"}
+
+const (
+	// createAttempts bounds how often a transient failure is retried before
+	// the message is dead-lettered
+	createAttempts = 3
+	// retryBackoff is the first pause between attempts, doubled every time
+	retryBackoff = 250 * time.Millisecond
+)
+
 type SensorConsumer struct {
-	UseCase *usecase.SensorUsecase
-	Log     *logrus.Logger
+	Client     broker.Client // publishes replies to MQTT 5 response topics
+	UseCase    *usecase.SensorUsecase
+	DeadLetter *usecase.DeadLetterUsecase
+	Pipeline   *usecase.IngestPipeline
+	Codecs     *codec.Registry
+	Log        *logrus.Logger
+	Topic      *util.TopicPattern
+	Precedence TopicPrecedence
 }
 
-func NewSensorConsumer(useCase *usecase.SensorUsecase, logger *logrus.Logger) *SensorConsumer {
+func NewSensorConsumer(
+	client broker.Client,
+	useCase *usecase.SensorUsecase,
+	deadLetter *usecase.DeadLetterUsecase,
+	pipeline *usecase.IngestPipeline,
+	codecs *codec.Registry,
+	logger *logrus.Logger,
+	topic *util.TopicPattern,
+	precedence TopicPrecedence,
+) *SensorConsumer {
 	return &SensorConsumer{
-		UseCase: useCase,
-		Log:     logger,
+		Client:     client,
+		UseCase:    useCase,
+		DeadLetter: deadLetter,
+		Pipeline:   pipeline,
+		Codecs:     codecs,
+		Log:        logger,
+		Topic:      topic,
+		Precedence: precedence,
 	}
 }
 
-func (c *SensorConsumer) SensorMQTTHandler(_ mqtt.Client, msg mqtt.Message) {
+// SensorMQTTHandler hands the message to the ingest pipeline so paho's
+// callback returns immediately. A worker acknowledges the message once its
+// readings are committed or once its rejected parts are stored as dead
+// letters. A transient database failure is retried a few times first; if it
+// persists the readings are dead-lettered too. A message whose dead letter
+// could not be stored either is still acknowledged and counted as failed, so
+// it never stays in flight and holds back the acknowledgements behind it.
+//
+// On MQTT 5 the content-type property selects the codec, and a message with a
+// response topic is answered with a SensorMQTTReply once it is stored or
+// dead-lettered.
+func (c *SensorConsumer) SensorMQTTHandler(msg broker.Message) {
+	receivedAt := time.Now()
+
+	// MQTT 3.1.1 has no content-type property, the codec is then picked by topic
+	contentType := msg.Properties().ContentType
+	decoder, err := c.Codecs.Resolve(msg.Topic(), contentType)
+	if err == nil {
+		contentType = codec.ContentType(decoder)
+	}
 
-	var req model.CreateSensorRequest
-	if err := json.Unmarshal(msg.Payload(), &req); err != nil {
-		c.Log.WithFields(logrus.Fields{
-			"topic":   msg.Topic(),
+	c.Pipeline.Submit(&usecase.IngestJob{
+		Topic:       msg.Topic(),
+		ContentType: contentType,
+		Payload:     msg.Payload(),
+		ReceivedAt:  receivedAt,
+		Process: func(done func(bool)) {
+			reply := &model.SensorMQTTReply{}
+			finish := func(ok bool) {
+				if ok {
+					c.reply(msg, reply)
+				}
+				done(ok)
+			}
+			if err != nil {
+				c.logger(msg).WithError(err).Warn("MQTT: no codec for message")
+				reply.Failed, reply.Error = 1, err.Error()
+				finish(c.reject(msg.Topic(), contentType, msg.Payload(), entity.DeadLetterInvalidPayload, err, receivedAt))
+				return
+			}
+			c.handle(msg, decoder, receivedAt, reply, finish)
+		},
+		Ack: msg.Ack,
+	})
+}
+
+// handle processes a message and calls done with whether it was stored or
+// dead-lettered. The outcome is counted in reply.
+func (c *SensorConsumer) handle(msg broker.Message, decoder codec.Codec, receivedAt time.Time, reply *model.SensorMQTTReply, done func(bool)) {
+	// A payload is either a single reading or a list of readings
+	readings, isBatch, err := decoder.Decode(msg.Payload())
+	if err != nil {
+		c.logger(msg).WithFields(logrus.Fields{
+			"codec":   decoder.Name(),
 			"payload": string(msg.Payload()),
-		}).WithError(err).Warn("MQTT: invalid JSON")
+		}).WithError(err).Warn("MQTT: invalid payload")
+		reply.Failed, reply.Error = 1, err.Error()
+		done(c.reject(msg.Topic(), codec.ContentType(decoder), msg.Payload(), entity.DeadLetterInvalidPayload, err, receivedAt))
+		return
+	}
+	if isBatch {
+		done(c.handleBatch(msg, decoder, readings, receivedAt, reply))
 		return
 	}
 
+	req := readings[0].Request
+	if err := c.ApplyTopic(msg.Topic(), req); err != nil {
+		c.logger(msg).WithError(err).Warn("MQTT: invalid topic")
+		reply.Failed, reply.Error = 1, err.Error()
+		done(c.reject(msg.Topic(), codec.ContentType(decoder), msg.Payload(), entity.DeadLetterInvalidTopic, err, receivedAt))
+		return
+	}
+	req.ReceivedAt = receivedAt
+
+	c.create(req, 1, retryBackoff, func(resp *model.SensorResponse, err error) {
+		if err != nil {
+			c.logger(msg).WithFields(logrus.Fields{
+				"id1":          req.ID1,
+				"id2":          req.ID2,
+				"sensor_type":  req.SensorType,
+				"sensor_value": req.SensorValue,
+				"timestamp":    req.Timestamp,
+			}).WithError(err).Error("MQTT: create failed")
+			reply.Failed, reply.Error = 1, err.Error()
+			done(c.rejectRequests(msg.Topic(), req, errorClass(err), err, receivedAt))
+			return
+		}
+
+		reply.Created = 1
+		c.logger(msg).WithFields(logrus.Fields{
+			"id1":          resp.ID1,
+			"id2":          resp.ID2,
+			"sensor_type":  resp.SensorType,
+			"sensor_value": resp.SensorsRecords[0].SensorValue,
+			"timestamp":    resp.SensorsRecords[0].Timestamp,
+		}).Info("MQTT: sensor data created")
+		done(true)
+	})
+}
+
+// create stores a reading without holding up the worker while it waits for a
+// write-behind batch, retrying transient failures with backoff
+func (c *SensorConsumer) create(req *model.CreateSensorRequest, attempt int, backoff time.Duration, done func(*model.SensorResponse, error)) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	c.UseCase.CreateAsync(ctx, req, func(resp *model.SensorResponse, err error) {
+		cancel()
+		if err != nil && isRetryable(err) && attempt < createAttempts {
+			time.AfterFunc(backoff, func() {
+				c.create(req, attempt+1, backoff*2, done)
+			})
+			return
+		}
+		done(resp, err)
+	})
+}
+
+func (c *SensorConsumer) handleBatch(msg broker.Message, decoder codec.Codec, readings []codec.Reading, receivedAt time.Time, reply *model.SensorMQTTReply) bool {
+	ok := true
+
+	// Undecodable items are rejected on their own so they do not reject the batch
+	requests := make([]*model.CreateSensorRequest, 0, len(readings))
+	for i, reading := range readings {
+		if reading.Err != nil {
+			c.logger(msg).WithFields(logrus.Fields{
+				"codec":   decoder.Name(),
+				"index":   i,
+				"payload": string(reading.Raw),
+			}).WithError(reading.Err).Warn("MQTT: invalid batch item")
+			reply.Failed, reply.Error = reply.Failed+1, reading.Err.Error()
+			ok = c.reject(msg.Topic(), codec.ContentType(decoder), reading.Raw, entity.DeadLetterInvalidPayload, reading.Err, receivedAt) && ok
+			continue
+		}
+		req := reading.Request
+		if err := c.ApplyTopic(msg.Topic(), req); err != nil {
+			c.logger(msg).WithError(err).Warn("MQTT: invalid topic")
+			reply.Failed, reply.Error = len(readings), err.Error()
+			return c.reject(msg.Topic(), codec.ContentType(decoder), msg.Payload(), entity.DeadLetterInvalidTopic, err, receivedAt)
+		}
+		req.ReceivedAt = receivedAt
+		requests = append(requests, req)
+	}
+
+	if len(requests) == 0 {
+		return ok
+	}
+
+	results, err := retryTransient(func() ([]model.CreateSensorResult, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		return c.UseCase.CreateBatch(ctx, requests)
+	})
+	if err != nil {
+		c.logger(msg).WithField("size", len(requests)).WithError(err).Error("MQTT: batch create failed")
+		reply.Failed, reply.Error = reply.Failed+len(requests), err.Error()
+		return c.rejectRequests(msg.Topic(), requests, errorClass(err), err, receivedAt) && ok
+	}
+
+	created := 0
+	for _, result := range results {
+		if result.Err != nil {
+			req := requests[result.Index]
+			c.logger(msg).WithFields(logrus.Fields{
+				"id1":          req.ID1,
+				"id2":          req.ID2,
+				"sensor_type":  req.SensorType,
+				"sensor_value": req.SensorValue,
+				"timestamp":    req.Timestamp,
+			}).WithError(result.Err).Error("MQTT: create failed")
+			reply.Failed, reply.Error = reply.Failed+1, result.Err.Error()
+			ok = c.rejectRequests(msg.Topic(), req, errorClass(result.Err), result.Err, receivedAt) && ok
+			continue
+		}
+		created++
+	}
+
+	reply.Created = created
+	c.logger(msg).WithFields(logrus.Fields{
+		"received": len(readings),
+		"created":  created,
+	}).Info("MQTT: sensor batch created")
+	return ok
+}
+
+// logger adds the topic and the trace user properties to the log fields
+func (c *SensorConsumer) logger(msg broker.Message) *logrus.Entry {
+	fields := logrus.Fields{"topic": msg.Topic()}
+	user := msg.Properties().User
+	for _, key := range traceProperties {
+		if value, ok := user[key]; ok {
+			fields[key] = value
+		}
+	}
+	return c.Log.WithFields(fields)
+}
 
-	resp, err := c.UseCase.Create(ctx, &req)
+// reply answers a message that asked for it through its response topic.
+// The message is already processed, so a failed reply is only logged.
+func (c *SensorConsumer) reply(msg broker.Message, reply *model.SensorMQTTReply) {
+	properties := msg.Properties()
+	if c.Client == nil || properties.ResponseTopic == "" {
+		return
+	}
+
+	payload, err := json.Marshal(reply)
 	if err != nil {
-		c.Log.WithFields(logrus.Fields{
-			"topic":        msg.Topic(),
-			"id1":          req.ID1,
-			"id2":          req.ID2,
-			"sensor_type":  req.SensorType,
-			"sensor_value": req.SensorValue,
-			"timestamp":    req.Timestamp,
-		}).WithError(err).Error("MQTT: create failed")
+		c.Log.WithError(err).Error("MQTT: failed to encode reply")
 		return
 	}
+	token := c.Client.Publish(properties.ResponseTopic, msg.Qos(), false, payload, &broker.Properties{
+		ContentType:     codec.ContentTypeJSON,
+		CorrelationData: properties.CorrelationData,
+	})
+	go func() {
+		<-token.Done()
+		if err := token.Error(); err != nil {
+			c.logger(msg).WithError(err).WithField("response_topic", properties.ResponseTopic).Warn("MQTT: failed to publish reply")
+		}
+	}()
+}
+
+// reject stores a dead letter and reports whether it was persisted. A
+// transient failure of the dead letter store is retried like a create.
+func (c *SensorConsumer) reject(topic, contentType string, payload []byte, class string, cause error, receivedAt time.Time) bool {
+	_, err := retryTransient(func() (struct{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return struct{}{}, c.DeadLetter.Store(ctx, topic, contentType, payload, class, cause, receivedAt)
+	})
+	return err == nil
+}
+
+// rejectRequests stores decoded requests as a JSON dead letter, after the
+// topic fields were applied, so they can be replayed as they are
+func (c *SensorConsumer) rejectRequests(topic string, requests any, class string, cause error, receivedAt time.Time) bool {
+	payload, err := json.Marshal(requests)
+	if err != nil {
+		c.Log.WithError(err).Error("MQTT: failed to encode dead letter")
+		return false
+	}
+	return c.reject(topic, codec.ContentTypeJSON, payload, class, cause, receivedAt)
+}
+
+// ApplyTopic fills the request fields captured by the named segments of the
+// topic pattern. Fields missing from the payload always come from the topic,
+// conflicting fields are resolved by the configured precedence.
+func (c *SensorConsumer) ApplyTopic(topic string, req *model.CreateSensorRequest) error {
+	if c.Topic == nil || !c.Topic.HasNames() {
+		return nil
+	}
+
+	values, ok := c.Topic.Match(topic)
+	if !ok {
+		return fmt.Errorf("topic %q does not match pattern %q", topic, c.Topic.Pattern)
+	}
+
+	if id1, ok := values["id1"]; ok {
+		req.ID1 = c.resolveField(topic, "id1", req.ID1, id1, req.ID1 == "")
+	}
+	if raw, ok := values["id2"]; ok {
+		id2, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("topic segment id2 %q is not a number", raw)
+		}
+		if c.resolveField(topic, "id2", strconv.FormatInt(req.ID2, 10), raw, req.ID2 == 0) == raw {
+			req.ID2 = id2
+		}
+	}
+	if sensorType, ok := values["sensor_type"]; ok {
+		req.SensorType = c.resolveField(topic, "sensor_type", req.SensorType, sensorType, req.SensorType == "")
+	}
+	return nil
+}
+
+func (c *SensorConsumer) resolveField(topic, field, payloadValue, topicValue string, missing bool) string {
+	if missing || payloadValue == topicValue {
+		return topicValue
+	}
 
 	c.Log.WithFields(logrus.Fields{
-		"topic":        msg.Topic(),
-		"id1":          resp.ID1,
-		"id2":          resp.ID2,
-		"sensor_type":  resp.SensorType,
-		"sensor_value": resp.SensorsRecords[0].SensorValue,
-		"timestamp":    resp.SensorsRecords[0].Timestamp,
-	}).Info("MQTT: sensor data created")
+		"topic":      topic,
+		"field":      field,
+		"payload":    payloadValue,
+		"from_topic": topicValue,
+		"precedence": c.Precedence,
+	}).Debug("MQTT: payload and topic disagree")
+
+	if c.Precedence == TopicPrecedenceTopic {
+		return topicValue
+	}
+	return payloadValue
+}
+
+// retryTransient calls fn until it succeeds, fails permanently or runs out of
+// attempts, backing off between attempts
+func retryTransient[T any](fn func() (T, error)) (T, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil || !isRetryable(err) || attempt == createAttempts {
+			return result, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isRetryable reports whether err is a transient server side failure that
+// may succeed on another attempt. Rejected requests and unique key violations
+// fail the same way every time, so they are not retried.
+func isRetryable(err error) bool {
+	if repository.IsDuplicateKey(err) {
+		return false
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// errorClass maps a use case error onto a dead letter class
+func errorClass(err error) string {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code < http.StatusInternalServerError {
+		return entity.DeadLetterValidation
+	}
+	return entity.DeadLetterDatabase
 }
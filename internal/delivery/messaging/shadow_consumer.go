@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ShadowConsumer receives reported state on a topic naming the device, e.g.
+// iot/shadow/{id1}/{id2}/reported, with a payload of
+// {"state": {...}, "version": n}. The version is optional.
+type ShadowConsumer struct {
+	UseCase *usecase.DeviceShadowUsecase
+	Log     *logrus.Logger
+	Topic   *util.TopicPattern
+}
+
+func NewShadowConsumer(useCase *usecase.DeviceShadowUsecase, logger *logrus.Logger, topic *util.TopicPattern) *ShadowConsumer {
+	return &ShadowConsumer{
+		UseCase: useCase,
+		Log:     logger,
+		Topic:   topic,
+	}
+}
+
+// ShadowReportedHandler merges reported state into the shadow. Invalid or
+// conflicting reports are dropped and a database failure is retried a few
+// times; the message is acknowledged either way so it does not stay in flight.
+func (c *ShadowConsumer) ShadowReportedHandler(msg broker.Message) {
+	c.handle(msg)
+	msg.Ack()
+}
+
+func (c *ShadowConsumer) handle(msg broker.Message) {
+	values, ok := c.Topic.Match(msg.Topic())
+	if !ok {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: shadow report on unexpected topic")
+		return
+	}
+
+	var request model.DeviceShadowUpdateRequest
+	if err := json.Unmarshal(msg.Payload(), &request); err != nil {
+		c.Log.WithField("topic", msg.Topic()).WithError(err).Warn("MQTT: invalid shadow report")
+		return
+	}
+	id1, err := util.UnescapeTopicLevel(values["id1"])
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: shadow report topic has an invalid id1")
+		return
+	}
+	request.ID1 = id1
+	id2, err := strconv.ParseInt(values["id2"], 10, 64)
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: shadow report topic has an invalid id2")
+		return
+	}
+	request.ID2 = id2
+
+	_, err = retryTransient(func() (*model.DeviceShadowResponse, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return c.UseCase.UpdateReported(ctx, &request)
+	})
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).WithError(err).Error("MQTT: shadow report failed")
+	}
+}
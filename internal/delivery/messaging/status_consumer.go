@@ -0,0 +1,84 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// StatusConsumer receives device status messages on a topic naming the
+// device, e.g. iot/status/{id1}/{id2}/state. Devices publish "online" when
+// they connect and register "offline" as their last will, which the broker
+// publishes when the connection is lost.
+type StatusConsumer struct {
+	UseCase *usecase.PresenceUsecase
+	Log     *logrus.Logger
+	Topic   *util.TopicPattern
+}
+
+func NewStatusConsumer(useCase *usecase.PresenceUsecase, logger *logrus.Logger, topic *util.TopicPattern) *StatusConsumer {
+	return &StatusConsumer{
+		UseCase: useCase,
+		Log:     logger,
+		Topic:   topic,
+	}
+}
+
+// StatusHandler stores a device status. Invalid messages are dropped and a
+// Redis failure is retried a few times; the message is acknowledged either way
+// so it does not stay in flight.
+func (c *StatusConsumer) StatusHandler(msg broker.Message) {
+	c.handle(msg)
+	msg.Ack()
+}
+
+func (c *StatusConsumer) handle(msg broker.Message) {
+	values, ok := c.Topic.Match(msg.Topic())
+	if !ok {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: status on unexpected topic")
+		return
+	}
+
+	payload := bytes.TrimSpace(msg.Payload())
+	if len(payload) == 0 {
+		// a cleared retained status
+		return
+	}
+
+	var request model.DeviceStatusRequest
+	if payload[0] == '{' {
+		if err := json.Unmarshal(payload, &request); err != nil {
+			c.Log.WithField("topic", msg.Topic()).WithError(err).Warn("MQTT: invalid device status")
+			return
+		}
+	} else {
+		request.Status = string(payload)
+	}
+	request.Status = strings.ToLower(request.Status)
+	request.ID1 = values["id1"]
+	id2, err := strconv.ParseInt(values["id2"], 10, 64)
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).Warn("MQTT: status topic has an invalid id2")
+		return
+	}
+	request.ID2 = id2
+	request.Retained = msg.Retained()
+
+	_, err = retryTransient(func() (struct{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return struct{}{}, c.UseCase.UpdateStatus(ctx, &request)
+	})
+	if err != nil {
+		c.Log.WithField("topic", msg.Topic()).WithError(err).Error("MQTT: device status failed")
+	}
+}
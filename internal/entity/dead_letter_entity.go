@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// DeadLetter is a rejected MQTT message kept for inspection and replay
+type DeadLetter struct {
+	DeadLetterID   int64
+	Topic          string
+	ContentType    string // content type of Payload, picks the codec on replay
+	Payload        []byte
+	ErrorClass     string
+	ErrorMessage   string
+	ReceivedAt     time.Time
+	ReplayCount    int
+	LastReplayedAt *time.Time
+}
+
+const (
+	DeadLetterInvalidPayload = "invalid_payload"
+	DeadLetterInvalidTopic   = "invalid_topic"
+	DeadLetterValidation     = "validation"
+	DeadLetterDatabase       = "database"
+	DeadLetterOverflow       = "overflow"
+)
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// DeviceCommand is a command sent to a device over MQTT, with its delivery
+// and acknowledgement status
+type DeviceCommand struct {
+	CommandID    int64
+	ID1          string
+	ID2          int64
+	Name         string
+	Params       []byte // JSON, nil without parameters
+	Status       string
+	Result       []byte // JSON reported by the device with its ack
+	ErrorMessage string
+	CreatedBy    string
+	CreatedAt    time.Time
+	DeliveredAt  *time.Time
+	CompletedAt  *time.Time // acked, failed or timed out
+	ExpiresAt    time.Time
+}
+
+const (
+	// CommandPending is stored but not yet accepted by the broker
+	CommandPending = "pending"
+	// CommandDelivered is accepted by the broker for the device. The device
+	// may not have received it yet, only its ack confirms that.
+	CommandDelivered = "delivered"
+	// CommandAcked is acknowledged by the device as done
+	CommandAcked = "acked"
+	// CommandFailed could not be published or was reported failed by the device
+	CommandFailed = "failed"
+	// CommandTimedOut was not acknowledged before it expired
+	CommandTimedOut = "timed_out"
+)
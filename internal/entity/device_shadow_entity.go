@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// DeviceShadow is the configuration state of a device (id1/id2): what admins
+// want it to be and what the device last reported. Each document has its own
+// version, incremented on every update.
+type DeviceShadow struct {
+	ID1               string
+	ID2               int64
+	Desired           []byte // JSON object, nil until first set
+	DesiredVersion    int64
+	DesiredUpdatedAt  *time.Time
+	Reported          []byte // JSON object, nil until first reported
+	ReportedVersion   int64
+	ReportedUpdatedAt *time.Time
+	PublishedDelta    []byte // JSON object last sent to the device, nil before the first
+}
+
+// Shadow documents
+const (
+	ShadowDesired  = "desired"
+	ShadowReported = "reported"
+)
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// LoRaWANUplink is the radio metadata of an uplink whose decoded measurements
+// were stored as readings of id1 (DevEUI) and id2 (fPort)
+type LoRaWANUplink struct {
+	UplinkID   int64
+	ID1        string
+	ID2        int64
+	Network    string
+	MessageID  string // unique id of the uplink given by the network server, if any
+	FCnt       int64
+	RSSI       *int64   // nil when no gateway metadata was given
+	SNR        *float64 // nil when no gateway metadata was given
+	GatewayID  string
+	Readings   int
+	ReceivedAt time.Time
+}
+
+// LoRaWAN network servers
+const (
+	LoRaWANChirpStack = "chirpstack"
+	LoRaWANTTS        = "tts"
+)
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// Presence of a sensor
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+	// PresenceUnknown is a sensor without readings or status since tracking began
+	PresenceUnknown = "unknown"
+)
+
+// DeviceStatus is the last status a device (id1/id2) announced on its status
+// topic, usually an "offline" last will published by the broker
+type DeviceStatus struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
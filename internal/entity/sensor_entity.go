@@ -5,12 +5,25 @@ type Sensor struct {
 	ID1        string `json:"id1" gorm:"column:id1;size:20;not null"`
 	ID2        int64  `json:"id2" gorm:"column:id2;not null"`
 	SensorType string `json:"sensor_type" gorm:"column:sensor_type;size:50;not null"`
+	// DuplicatePolicy is empty when the sensor uses the server default
+	DuplicatePolicy string `json:"duplicate_policy,omitempty" gorm:"column:duplicate_policy;size:20"`
 
 	Records []SensorRecord `json:"records,omitempty" gorm:"foreignKey:sensor_id;references:sensor_id"`
 
-	// Unique constraint across id1, id2
+	// Unique constraint across id1, id2, sensor_type
 }
 
 func (Sensor) TableName() string {
 	return "sensors"
 }
+
+// Duplicate policies decide how a reading is stored when the sensor already
+// has a reading at the same timestamp
+const (
+	// DuplicateKeepAll stores every distinct value, exact resends are skipped
+	DuplicateKeepAll = "keep_all"
+	// DuplicateIgnore keeps the first reading of a timestamp
+	DuplicateIgnore = "ignore"
+	// DuplicateOverwrite replaces the latest reading of a timestamp
+	DuplicateOverwrite = "overwrite"
+)
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// SensorKey is the DTLS pre-shared key of a sensor device (id1/id2), which
+// authenticates it on the CoAP endpoint with the identity "<id1>/<id2>"
+type SensorKey struct {
+	ID1       string
+	ID2       int64
+	PSK       []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
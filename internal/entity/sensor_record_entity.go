@@ -7,6 +7,9 @@ type SensorRecord struct {
 	SensorID    int64     `json:"sensor_id" gorm:"column:sensor_id;not null;index"`
 	SensorValue float64   `json:"sensor_value" gorm:"column:sensor_value;not null"`
 	Timestamp   time.Time `json:"timestamp" gorm:"column:timestamp;not null;precision:6"`
+	MessageID   string    `json:"message_id,omitempty" gorm:"column:message_id;size:100"`
+	// TimestampSource tells whether Timestamp came from the device or the server
+	TimestampSource string `json:"timestamp_source" gorm:"column:timestamp_source;size:10;not null;default:device"`
 
 	// Relations
 	Sensor Sensor `json:"sensor,omitempty" gorm:"foreignKey:sensor_id;references:sensor_id"`
@@ -15,3 +18,11 @@ type SensorRecord struct {
 func (SensorRecord) TableName() string {
 	return "sensor_records"
 }
+
+const (
+	// TimestampSourceDevice is a timestamp sent by the device
+	TimestampSourceDevice = "device"
+	// TimestampSourceServer is the server receive time, used when the device
+	// timestamp is missing or out of range
+	TimestampSourceServer = "server"
+)
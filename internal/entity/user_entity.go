@@ -15,4 +15,6 @@ type Role string
 const (
 	RoleAdmin Role = "admin"
 	RoleUser  Role = "user"
+	// RoleIngest may post sensor records in bulk without admin rights
+	RoleIngest Role = "ingest"
 )
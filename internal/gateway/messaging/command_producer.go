@@ -0,0 +1,79 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// CommandProducer publishes device commands to <TopicPrefix>/<id1>/<id2>.
+// On MQTT 5 a command also carries the device's AckTopic as response topic,
+// its ID as correlation data and expires on the broker with the command.
+type CommandProducer struct {
+	Client      broker.Client
+	Log         *logrus.Logger
+	TopicPrefix string
+	Qos         byte
+	AckTopic    *util.TopicPattern
+}
+
+func NewCommandProducer(client broker.Client, log *logrus.Logger, topicPrefix string, qos byte, ackTopic *util.TopicPattern) *CommandProducer {
+	return &CommandProducer{
+		Client:      client,
+		Log:         log,
+		TopicPrefix: strings.TrimSuffix(topicPrefix, "/"),
+		Qos:         qos,
+		AckTopic:    ackTopic,
+	}
+}
+
+// Topic returns the command topic of a device. id1 is escaped with
+// util.EscapeTopicLevel, the ack consumer decodes it back.
+func (p *CommandProducer) Topic(id1 string, id2 int64) string {
+	return p.TopicPrefix + "/" + util.EscapeTopicLevel(id1) + "/" + strconv.FormatInt(id2, 10)
+}
+
+// Send publishes a command and waits until the broker has accepted it. It is
+// not retained, so a device does not run an old command again when it
+// reconnects; devices with a persistent session still receive commands sent
+// while they were offline.
+func (p *CommandProducer) Send(ctx context.Context, id1 string, id2 int64, message *model.DeviceCommandMessage) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		p.Log.WithError(err).Error("failed to marshal device command")
+		return err
+	}
+
+	properties := &broker.Properties{
+		ContentType:     "application/json",
+		CorrelationData: []byte(strconv.FormatInt(message.CommandID, 10)),
+	}
+	if expiry := time.Until(message.ExpiresAt); expiry > 0 {
+		properties.MessageExpiry = expiry
+	}
+	if p.AckTopic != nil {
+		ackTopic, err := p.AckTopic.Topic(map[string]string{
+			"id1": util.EscapeTopicLevel(id1),
+			"id2": strconv.FormatInt(id2, 10),
+		})
+		if err != nil {
+			p.Log.WithError(err).Warn("failed to build command response topic")
+		}
+		properties.ResponseTopic = ackTopic
+	}
+
+	token := p.Client.Publish(p.Topic(id1, id2), p.Qos, false, payload, properties)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// jsonProperties mark JSON payloads on MQTT 5
+var jsonProperties = &broker.Properties{ContentType: "application/json"}
+
+// topicEscaper keeps identifiers to a single topic level without wildcards
+var topicEscaper = strings.NewReplacer("/", "_", "+", "_", "#", "_")
+
+// SensorProducer republishes committed readings to
+// <TopicPrefix>/<id1>/<id2>/<sensor_type> for downstream subscribers
+type SensorProducer struct {
+	Client      broker.Client
+	Log         *logrus.Logger
+	TopicPrefix string
+	Qos         byte
+	Retained    bool // keep the last reading of every sensor on the broker
+}
+
+func NewSensorProducer(client broker.Client, log *logrus.Logger, topicPrefix string, qos byte, retained bool) *SensorProducer {
+	return &SensorProducer{
+		Client:      client,
+		Log:         log,
+		TopicPrefix: strings.TrimSuffix(topicPrefix, "/"),
+		Qos:         qos,
+		Retained:    retained,
+	}
+}
+
+// Topic returns the topic of a reading's sensor
+func (p *SensorProducer) Topic(event *model.SensorReadingEvent) string {
+	return p.TopicPrefix + "/" + topicEscaper.Replace(event.ID1) + "/" +
+		strconv.FormatInt(event.ID2, 10) + "/" + topicEscaper.Replace(event.SensorType)
+}
+
+// Send publishes a reading without waiting for the broker, so ingestion is
+// not slowed down by downstream delivery. Failed deliveries are logged.
+func (p *SensorProducer) Send(event *model.SensorReadingEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		p.Log.WithError(err).Error("failed to marshal sensor reading event")
+		return err
+	}
+
+	topic := p.Topic(event)
+	token := p.Client.Publish(topic, p.Qos, p.Retained, payload, jsonProperties)
+	go func() {
+		<-token.Done()
+		if err := token.Error(); err != nil {
+			p.Log.WithError(err).WithField("topic", topic).Warn("failed to publish sensor reading")
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// streamPublishBatch caps the readings sent in one Redis pipeline
+const streamPublishBatch = 100
+
+// SensorStreamProducer publishes committed readings to a Redis channel, from
+// which every replica fans them out to its live stream clients
+type SensorStreamProducer struct {
+	Redis   *redis.Client
+	Log     *logrus.Logger
+	Channel string
+
+	queue chan []byte
+}
+
+// NewSensorStreamProducer starts publishing in the background. Up to
+// bufferSize readings wait for Redis.
+func NewSensorStreamProducer(redis *redis.Client, log *logrus.Logger, channel string, bufferSize int) *SensorStreamProducer {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	p := &SensorStreamProducer{
+		Redis:   redis,
+		Log:     log,
+		Channel: channel,
+		queue:   make(chan []byte, bufferSize),
+	}
+	go p.run()
+	return p
+}
+
+// Send queues a reading without waiting for Redis, so ingestion is not slowed
+// down by the live streams. They are best effort: a reading is dropped when
+// the queue is full, and a failed publish is only logged.
+func (p *SensorStreamProducer) Send(event *model.SensorReadingEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		p.Log.WithError(err).Error("failed to marshal sensor reading event")
+		return err
+	}
+
+	select {
+	case p.queue <- payload:
+	default:
+		p.Log.WithField("channel", p.Channel).Warn("sensor stream queue full, dropped reading")
+	}
+	return nil
+}
+
+// run publishes the queued readings, those that queued up meanwhile in one
+// pipeline
+func (p *SensorStreamProducer) run() {
+	for payload := range p.queue {
+		pipe := p.Redis.Pipeline()
+		pipe.Publish(context.Background(), p.Channel, payload)
+	drain:
+		for pipe.Len() < streamPublishBatch {
+			select {
+			case payload := <-p.queue:
+				pipe.Publish(context.Background(), p.Channel, payload)
+			default:
+				break drain
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		if _, err := pipe.Exec(ctx); err != nil {
+			p.Log.WithError(err).WithField("channel", p.Channel).Warn("failed to publish sensor readings to stream")
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ShadowProducer publishes shadow deltas to <TopicPrefix>/<id1>/<id2>/delta
+type ShadowProducer struct {
+	Client      broker.Client
+	Log         *logrus.Logger
+	TopicPrefix string
+	Qos         byte
+}
+
+func NewShadowProducer(client broker.Client, log *logrus.Logger, topicPrefix string, qos byte) *ShadowProducer {
+	return &ShadowProducer{
+		Client:      client,
+		Log:         log,
+		TopicPrefix: strings.TrimSuffix(topicPrefix, "/"),
+		Qos:         qos,
+	}
+}
+
+// Topic returns the delta topic of a device, with id1 escaped as in command
+// topics
+func (p *ShadowProducer) Topic(id1 string, id2 int64) string {
+	return p.TopicPrefix + "/" + util.EscapeTopicLevel(id1) + "/" + strconv.FormatInt(id2, 10) + "/delta"
+}
+
+// Send publishes the current delta of a device as a retained message, so a
+// device receives its pending configuration when it (re)subscribes. A nil
+// delta clears the retained message once the device is in sync.
+func (p *ShadowProducer) Send(ctx context.Context, id1 string, id2 int64, delta *model.DeviceShadowDelta) error {
+	var payload []byte
+	if delta != nil {
+		var err error
+		if payload, err = json.Marshal(delta); err != nil {
+			p.Log.WithError(err).Error("failed to marshal shadow delta")
+			return err
+		}
+	}
+
+	var properties *broker.Properties
+	if delta != nil {
+		properties = jsonProperties
+	}
+	token := p.Client.Publish(p.Topic(id1, id2), p.Qos, true, payload, properties)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
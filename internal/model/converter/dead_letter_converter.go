@@ -0,0 +1,36 @@
+package converter
+
+import (
+	"encoding/base64"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"unicode/utf8"
+)
+
+func DeadLetterToResponse(deadLetter *entity.DeadLetter) *model.DeadLetterResponse {
+	payload, encoding := string(deadLetter.Payload), "text"
+	if !utf8.Valid(deadLetter.Payload) {
+		payload, encoding = base64.StdEncoding.EncodeToString(deadLetter.Payload), "base64"
+	}
+
+	return &model.DeadLetterResponse{
+		ID:              deadLetter.DeadLetterID,
+		Topic:           deadLetter.Topic,
+		ContentType:     deadLetter.ContentType,
+		Payload:         payload,
+		PayloadEncoding: encoding,
+		ErrorClass:      deadLetter.ErrorClass,
+		ErrorMessage:    deadLetter.ErrorMessage,
+		ReceivedAt:      deadLetter.ReceivedAt,
+		ReplayCount:     deadLetter.ReplayCount,
+		LastReplayedAt:  deadLetter.LastReplayedAt,
+	}
+}
+
+func DeadLettersToResponse(deadLetters []entity.DeadLetter) []model.DeadLetterResponse {
+	responses := make([]model.DeadLetterResponse, 0, len(deadLetters))
+	for i := range deadLetters {
+		responses = append(responses, *DeadLetterToResponse(&deadLetters[i]))
+	}
+	return responses
+}
@@ -0,0 +1,32 @@
+package converter
+
+import (
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+)
+
+func DeviceCommandToResponse(command *entity.DeviceCommand) *model.DeviceCommandResponse {
+	return &model.DeviceCommandResponse{
+		ID:          command.CommandID,
+		ID1:         command.ID1,
+		ID2:         command.ID2,
+		Name:        command.Name,
+		Params:      command.Params,
+		Status:      command.Status,
+		Result:      command.Result,
+		Error:       command.ErrorMessage,
+		CreatedBy:   command.CreatedBy,
+		CreatedAt:   command.CreatedAt,
+		DeliveredAt: command.DeliveredAt,
+		CompletedAt: command.CompletedAt,
+		ExpiresAt:   command.ExpiresAt,
+	}
+}
+
+func DeviceCommandsToResponse(commands []entity.DeviceCommand) []model.DeviceCommandResponse {
+	responses := make([]model.DeviceCommandResponse, 0, len(commands))
+	for i := range commands {
+		responses = append(responses, *DeviceCommandToResponse(&commands[i]))
+	}
+	return responses
+}
@@ -0,0 +1,33 @@
+package converter
+
+import (
+	"encoding/json"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+)
+
+var emptyJSONObject = json.RawMessage("{}")
+
+func DeviceShadowToResponse(shadow *entity.DeviceShadow, delta json.RawMessage) *model.DeviceShadowResponse {
+	response := &model.DeviceShadowResponse{
+		ID1:               shadow.ID1,
+		ID2:               shadow.ID2,
+		Desired:           shadow.Desired,
+		DesiredVersion:    shadow.DesiredVersion,
+		DesiredUpdatedAt:  shadow.DesiredUpdatedAt,
+		Reported:          shadow.Reported,
+		ReportedVersion:   shadow.ReportedVersion,
+		ReportedUpdatedAt: shadow.ReportedUpdatedAt,
+		Delta:             delta,
+	}
+	if len(response.Desired) == 0 {
+		response.Desired = emptyJSONObject
+	}
+	if len(response.Reported) == 0 {
+		response.Reported = emptyJSONObject
+	}
+	if len(response.Delta) == 0 {
+		response.Delta = emptyJSONObject
+	}
+	return response
+}
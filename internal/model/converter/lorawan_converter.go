@@ -0,0 +1,89 @@
+package converter
+
+import (
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"strings"
+)
+
+func ChirpStackToUplink(event *model.ChirpStackUplink) *model.LoRaWANUplinkRequest {
+	req := &model.LoRaWANUplinkRequest{
+		Network:      entity.LoRaWANChirpStack,
+		DevEUI:       event.DeviceInfo.DevEUI,
+		FPort:        event.FPort,
+		FCnt:         event.FCnt,
+		MessageID:    event.DeduplicationID,
+		Measurements: event.Object,
+		ReceivedAt:   event.Time,
+	}
+
+	best := -1
+	for i, rx := range event.RxInfo {
+		if best < 0 || rx.RSSI > event.RxInfo[best].RSSI {
+			best = i
+		}
+	}
+	if best >= 0 {
+		rx := event.RxInfo[best]
+		req.RSSI, req.SNR, req.GatewayID = &rx.RSSI, &rx.SNR, rx.GatewayID
+	}
+	return req
+}
+
+// TTSToUplink converts an uplink message, identified by its application
+// server correlation id
+func TTSToUplink(event *model.TTSUplink) *model.LoRaWANUplinkRequest {
+	uplink := event.UplinkMessage
+	req := &model.LoRaWANUplinkRequest{
+		Network:      entity.LoRaWANTTS,
+		DevEUI:       event.EndDeviceIDs.DevEUI,
+		FPort:        uplink.FPort,
+		FCnt:         uplink.FCnt,
+		Measurements: uplink.DecodedPayload,
+		ReceivedAt:   uplink.ReceivedAt,
+	}
+	if req.ReceivedAt.IsZero() {
+		req.ReceivedAt = event.ReceivedAt
+	}
+	for _, id := range event.CorrelationIDs {
+		if strings.HasPrefix(id, "as:up:") {
+			req.MessageID = id
+			break
+		}
+	}
+
+	best := -1
+	for i, rx := range uplink.RxMetadata {
+		if best < 0 || rx.RSSI > uplink.RxMetadata[best].RSSI {
+			best = i
+		}
+	}
+	if best >= 0 {
+		rx := uplink.RxMetadata[best]
+		req.RSSI, req.SNR, req.GatewayID = &rx.RSSI, &rx.SNR, rx.GatewayIDs.GatewayID
+	}
+	return req
+}
+
+func LoRaWANUplinkToResponse(uplink *entity.LoRaWANUplink) *model.LoRaWANUplinkMetadataResponse {
+	return &model.LoRaWANUplinkMetadataResponse{
+		ID1:        uplink.ID1,
+		ID2:        uplink.ID2,
+		Network:    uplink.Network,
+		MessageID:  uplink.MessageID,
+		FCnt:       uplink.FCnt,
+		RSSI:       uplink.RSSI,
+		SNR:        uplink.SNR,
+		GatewayID:  uplink.GatewayID,
+		Readings:   uplink.Readings,
+		ReceivedAt: uplink.ReceivedAt,
+	}
+}
+
+func LoRaWANUplinksToResponse(uplinks []entity.LoRaWANUplink) []model.LoRaWANUplinkMetadataResponse {
+	responses := make([]model.LoRaWANUplinkMetadataResponse, 0, len(uplinks))
+	for i := range uplinks {
+		responses = append(responses, *LoRaWANUplinkToResponse(&uplinks[i]))
+	}
+	return responses
+}
@@ -2,19 +2,28 @@ package converter
 
 import (
 	"fmt"
+	"iot-server/api/proto/iotserverv1"
 	"iot-server/internal/entity"
 	"iot-server/internal/model"
+	"time"
 )
 
+// SensorToResponse describes a sensor with its records. A device search
+// spanning several sensor types leaves the type of sensor empty, each record
+// then carries its own.
 func SensorToResponse(sensor *entity.Sensor) *model.SensorResponse {
 
 	sensorRecords := make([]model.SensorRecord, 0)
 
 	for _, record := range sensor.Records {
-		sensorRecords = append(sensorRecords, model.SensorRecord{
+		sensorRecord := model.SensorRecord{
 			SensorValue: record.SensorValue,
 			Timestamp:   record.Timestamp,
-		})
+		}
+		if sensor.SensorType == "" {
+			sensorRecord.SensorType = record.Sensor.SensorType
+		}
+		sensorRecords = append(sensorRecords, sensorRecord)
 	}
 
 	return &model.SensorResponse{
@@ -25,39 +34,71 @@ func SensorToResponse(sensor *entity.Sensor) *model.SensorResponse {
 	}
 }
 
+// SensorRecordsToResponse groups records by sensor, in the order each sensor
+// first appears
 func SensorRecordsToResponse(records []entity.SensorRecord) []model.SensorResponse {
 	if len(records) == 0 {
 		return []model.SensorResponse{}
 	}
 
-	// Map key: "id1|id2"
-	grouped := make(map[string]*model.SensorResponse)
+	// Map key: "id1|id2|sensor_type", to the index in responses
+	index := make(map[string]int)
+	responses := make([]model.SensorResponse, 0)
 
 	for _, rec := range records {
-		key := rec.Sensor.ID1 + "|" +
-			fmt.Sprintf("%d", rec.Sensor.ID2)
+		key := fmt.Sprintf("%s|%d|%s", rec.Sensor.ID1, rec.Sensor.ID2, rec.Sensor.SensorType)
 
 		// Initialize if not exists
-		if _, exists := grouped[key]; !exists {
-			grouped[key] = &model.SensorResponse{
+		i, exists := index[key]
+		if !exists {
+			i = len(responses)
+			index[key] = i
+			responses = append(responses, model.SensorResponse{
 				ID1:        rec.Sensor.ID1,
 				ID2:        rec.Sensor.ID2,
 				SensorType: rec.Sensor.SensorType,
-			}
+			})
 		}
 
 		// Append record to the sensor response
-		grouped[key].SensorsRecords = append(grouped[key].SensorsRecords, model.SensorRecord{
+		responses[i].SensorsRecords = append(responses[i].SensorsRecords, model.SensorRecord{
 			SensorValue: rec.SensorValue,
 			Timestamp:   rec.Timestamp,
 		})
 	}
 
-	// Convert map into slice
-	responses := make([]model.SensorResponse, 0, len(grouped))
-	for _, resp := range grouped {
-		responses = append(responses, *resp)
+	return responses
+}
+
+// SensorRecordToEvent describes a committed record, with its sensor, for the
+// MQTT gateway
+func SensorRecordToEvent(record *entity.SensorRecord) *model.SensorReadingEvent {
+	return &model.SensorReadingEvent{
+		SensorID:        record.SensorID,
+		ID1:             record.Sensor.ID1,
+		ID2:             record.Sensor.ID2,
+		SensorType:      record.Sensor.SensorType,
+		SensorValue:     record.SensorValue,
+		Timestamp:       record.Timestamp,
+		TimestampSource: record.TimestampSource,
+		MessageID:       record.MessageID,
+		DuplicatePolicy: record.Sensor.DuplicatePolicy,
+		ProcessedAt:     time.Now().UTC(),
 	}
+}
 
-	return responses
+// SensorReadingToRequest maps the protobuf reading shared by the gRPC API and
+// the MQTT protobuf codec. A missing timestamp stays zero.
+func SensorReadingToRequest(reading *iotserverv1.SensorReading) *model.CreateSensorRequest {
+	request := &model.CreateSensorRequest{
+		ID1:         reading.GetId1(),
+		ID2:         reading.GetId2(),
+		SensorType:  reading.GetSensorType(),
+		SensorValue: reading.GetSensorValue(),
+		MessageID:   reading.GetMessageId(),
+	}
+	if reading.GetTimestamp() != nil {
+		request.Timestamp = reading.GetTimestamp().AsTime()
+	}
+	return request
 }
@@ -0,0 +1,17 @@
+package converter
+
+import (
+	"encoding/hex"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+)
+
+func SensorKeyToResponse(key *entity.SensorKey, identity string) *model.SensorKeyResponse {
+	return &model.SensorKeyResponse{
+		ID1:       key.ID1,
+		ID2:       key.ID2,
+		Identity:  identity,
+		PSK:       hex.EncodeToString(key.PSK),
+		UpdatedAt: key.UpdatedAt,
+	}
+}
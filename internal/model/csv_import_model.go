@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+type CSVImportRequest struct {
+	ID1Column         string `form:"id1_column" validate:"omitempty,max=100"`          // optional, defaults to id1
+	ID2Column         string `form:"id2_column" validate:"omitempty,max=100"`          // optional, defaults to id2
+	SensorTypeColumn  string `form:"sensor_type_column" validate:"omitempty,max=100"`  // optional, defaults to sensor_type
+	SensorValueColumn string `form:"sensor_value_column" validate:"omitempty,max=100"` // optional, defaults to sensor_value
+	TimestampColumn   string `form:"timestamp_column" validate:"omitempty,max=100"`    // optional, defaults to timestamp
+	TimestampFormat   string `form:"timestamp_format" validate:"omitempty,max=100"`    // rfc3339, unix, unix_ms or a Go time layout
+	TimeZone          string `form:"time_zone" validate:"omitempty,max=100"`           // IANA name for layouts without a zone, defaults to UTC
+	Delimiter         string `form:"delimiter" validate:"omitempty,len=1"`             // optional, defaults to a comma
+
+	// Set by the server
+	File      string `json:"-" form:"-"` // path of the file to import
+	FileName  string `json:"-" form:"-"` // name shown in the job
+	Temporary bool   `json:"-" form:"-"` // remove the file once imported
+}
+
+type CSVImportGetRequest struct {
+	ID string `param:"id" validate:"required,max=64"`
+}
+
+// CSVImportJobResponse is the progress of a CSV import job
+type CSVImportJobResponse struct {
+	ID         string           `json:"id"`
+	Status     string           `json:"status"` // running, completed, failed or cancelled
+	FileName   string           `json:"file_name"`
+	Size       int64            `json:"size"`       // bytes
+	BytesRead  int64            `json:"bytes_read"` // progress through the file
+	Rows       int64            `json:"rows"`
+	Imported   int64            `json:"imported"`
+	Duplicates int64            `json:"duplicates"`
+	Failed     int64            `json:"failed"`
+	Errors     []CSVImportError `json:"errors,omitempty"` // first failed rows, in order
+	Error      string           `json:"error,omitempty"`  // why the job stopped early
+	StartedAt  time.Time        `json:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+// CSVImportError is a failed row, by the line it starts on
+type CSVImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+type DeadLetterResponse struct {
+	ID              int64      `json:"id"`
+	Topic           string     `json:"topic"`
+	ContentType     string     `json:"content_type"`
+	Payload         string     `json:"payload"`
+	PayloadEncoding string     `json:"payload_encoding"`
+	ErrorClass      string     `json:"error_class"`
+	ErrorMessage    string     `json:"error_message"`
+	ReceivedAt      time.Time  `json:"received_at"`
+	ReplayCount     int        `json:"replay_count"`
+	LastReplayedAt  *time.Time `json:"last_replayed_at,omitempty"`
+}
+
+type DeadLetterListRequest struct {
+	ErrorClass string `query:"error_class" validate:"omitempty,max=50"`
+	Page       int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize   int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+}
+
+type DeadLetterGetRequest struct {
+	ID int64 `param:"id" validate:"required,min=1"`
+}
+
+type DeadLetterUpdateRequest struct {
+	ID              int64  `param:"id" json:"-" validate:"required,min=1"`
+	Topic           string `json:"topic" validate:"omitempty,max=255"`
+	ContentType     string `json:"content_type" validate:"omitempty,max=100"` // optional, keeps the current one when empty
+	Payload         string `json:"payload" validate:"required"`
+	PayloadEncoding string `json:"payload_encoding" validate:"omitempty,oneof=text base64"`
+}
+
+type DeadLetterPurgeRequest struct {
+	ErrorClass string    `query:"error_class" validate:"omitempty,max=50"`
+	Before     time.Time `query:"before"` // optional, defaults to now
+}
+
+type DeadLetterReplayResponse struct {
+	Replayed   int                 `json:"replayed"`
+	Failed     int                 `json:"failed"`
+	DeadLetter *DeadLetterResponse `json:"dead_letter,omitempty"` // remaining failures, absent when fully replayed
+}
+
+type DeadLetterPurgeResponse struct {
+	Deleted int64 `json:"deleted"`
+}
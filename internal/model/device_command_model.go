@@ -0,0 +1,65 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type DeviceCommandResponse struct {
+	ID          int64           `json:"id"`
+	ID1         string          `json:"id1"`
+	ID2         int64           `json:"id2"`
+	Name        string          `json:"name"`
+	Params      json.RawMessage `json:"params,omitempty"`
+	Status      string          `json:"status"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	CreatedBy   string          `json:"created_by"`
+	CreatedAt   time.Time       `json:"created_at"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+}
+
+type DeviceCommandCreateRequest struct {
+	ID1            string          `json:"id1" validate:"required,uppercase,max=20"`
+	ID2            int64           `json:"id2" validate:"required"`
+	Name           string          `json:"name" validate:"required,max=50"`                      // e.g. reboot, set_sampling_interval
+	Params         json.RawMessage `json:"params,omitempty"`                                     // optional, any JSON value passed to the device
+	TimeoutSeconds int             `json:"timeout_seconds" validate:"omitempty,min=1,max=86400"` // optional, defaults to COMMAND_TIMEOUT_SECONDS
+
+	// Set by the server
+	CreatedBy string `json:"-"`
+}
+
+type DeviceCommandListRequest struct {
+	ID1      string `query:"id1" validate:"omitempty,max=20"`
+	ID2      int64  `query:"id2"`
+	Status   string `query:"status" validate:"omitempty,oneof=pending delivered acked failed timed_out"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+}
+
+type DeviceCommandGetRequest struct {
+	ID int64 `param:"id" validate:"required,min=1"`
+}
+
+// DeviceCommandMessage is a command as published to the device
+type DeviceCommandMessage struct {
+	CommandID int64           `json:"command_id"`
+	Name      string          `json:"name"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// DeviceCommandAck is a device's answer to a command on the ack topic
+type DeviceCommandAck struct {
+	CommandID int64           `json:"command_id" validate:"required,min=1"`
+	Status    string          `json:"status" validate:"required,oneof=ok error"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty" validate:"max=1000"`
+
+	// Set from the ack topic
+	ID1 string `json:"-" validate:"required"`
+	ID2 int64  `json:"-" validate:"required"`
+}
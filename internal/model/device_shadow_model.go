@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type DeviceShadowResponse struct {
+	ID1               string          `json:"id1"`
+	ID2               int64           `json:"id2"`
+	Desired           json.RawMessage `json:"desired"`
+	DesiredVersion    int64           `json:"desired_version"`
+	DesiredUpdatedAt  *time.Time      `json:"desired_updated_at,omitempty"`
+	Reported          json.RawMessage `json:"reported"`
+	ReportedVersion   int64           `json:"reported_version"`
+	ReportedUpdatedAt *time.Time      `json:"reported_updated_at,omitempty"`
+	Delta             json.RawMessage `json:"delta"` // desired values the device has not reported yet
+}
+
+type DeviceShadowGetRequest struct {
+	ID1 string `query:"id1" validate:"required,uppercase,max=20"`
+	ID2 int64  `query:"id2" validate:"required"`
+}
+
+// DeviceShadowUpdateRequest merges State into the desired or reported
+// document as a JSON merge patch, null removes a key
+type DeviceShadowUpdateRequest struct {
+	ID1     string          `json:"id1" validate:"required,uppercase,max=20"`
+	ID2     int64           `json:"id2" validate:"required"`
+	State   json.RawMessage `json:"state" validate:"required"`
+	Version *int64          `json:"version,omitempty" validate:"omitempty,min=0"` // optional, rejects the update if the document has moved on
+}
+
+// DeviceShadowDelta is published to the device when the delta between its
+// desired and reported documents changes
+type DeviceShadowDelta struct {
+	Version   int64          `json:"version"` // desired version
+	State     map[string]any `json:"state"`
+	Timestamp time.Time      `json:"timestamp"`
+}
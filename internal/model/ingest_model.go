@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type IngestStatsResponse struct {
+	QueueDepth    int    `json:"queue_depth"`
+	QueueCapacity int    `json:"queue_capacity"`
+	Workers       int    `json:"workers"`
+	Policy        string `json:"overflow_policy"`
+	Submitted     int64  `json:"submitted"`
+	Processed     int64  `json:"processed"`
+	Failed        int64  `json:"failed"`
+	Dropped       int64  `json:"dropped"`
+	Spilled       int64  `json:"spilled"`
+	Refused       int64  `json:"refused"`
+}
+
+// MQTTSubscriptionsResponse tells whether the server receives MQTT messages.
+// It is healthy while connected with every subscription granted.
+type MQTTSubscriptionsResponse struct {
+	Connected     bool                       `json:"connected"`
+	Healthy       bool                       `json:"healthy"`
+	Subscriptions []MQTTSubscriptionResponse `json:"subscriptions"`
+}
+
+type MQTTSubscriptionResponse struct {
+	Topic         string     `json:"topic"`
+	Qos           byte       `json:"qos"`
+	Subscribed    bool       `json:"subscribed"`
+	Error         string     `json:"error,omitempty"` // of the last attempt
+	SubscribedAt  *time.Time `json:"subscribed_at,omitempty"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	Messages      int64      `json:"messages"`
+}
@@ -0,0 +1,107 @@
+package model
+
+import "time"
+
+// ChirpStackUplink is the "up" event of the ChirpStack v4 HTTP integration,
+// JSON encoded. Only the fields used are listed.
+type ChirpStackUplink struct {
+	DeduplicationID string               `json:"deduplicationId"`
+	Time            time.Time            `json:"time"`
+	DeviceInfo      ChirpStackDeviceInfo `json:"deviceInfo"`
+	FCnt            int64                `json:"fCnt"`
+	FPort           int64                `json:"fPort"`
+	Object          map[string]any       `json:"object"` // set by the codec of the device profile
+	RxInfo          []ChirpStackRxInfo   `json:"rxInfo"`
+}
+
+type ChirpStackDeviceInfo struct {
+	DeviceName string `json:"deviceName"`
+	DevEUI     string `json:"devEui"`
+}
+
+type ChirpStackRxInfo struct {
+	GatewayID string  `json:"gatewayId"`
+	RSSI      int64   `json:"rssi"`
+	SNR       float64 `json:"snr"`
+}
+
+// TTSUplink is an uplink message of a The Things Stack v3 webhook. Only the
+// fields used are listed.
+type TTSUplink struct {
+	EndDeviceIDs   TTSEndDeviceIDs   `json:"end_device_ids"`
+	CorrelationIDs []string          `json:"correlation_ids"`
+	ReceivedAt     time.Time         `json:"received_at"`
+	UplinkMessage  *TTSUplinkMessage `json:"uplink_message"` // nil on other message types
+}
+
+type TTSEndDeviceIDs struct {
+	DeviceID string `json:"device_id"`
+	DevEUI   string `json:"dev_eui"`
+}
+
+type TTSUplinkMessage struct {
+	FPort          int64           `json:"f_port"`
+	FCnt           int64           `json:"f_cnt"`
+	DecodedPayload map[string]any  `json:"decoded_payload"` // set by the payload formatter
+	RxMetadata     []TTSRxMetadata `json:"rx_metadata"`
+	ReceivedAt     time.Time       `json:"received_at"`
+}
+
+type TTSRxMetadata struct {
+	GatewayIDs TTSGatewayIDs `json:"gateway_ids"`
+	RSSI       int64         `json:"rssi"`
+	SNR        float64       `json:"snr"`
+}
+
+type TTSGatewayIDs struct {
+	GatewayID string `json:"gateway_id"`
+}
+
+// LoRaWANUplinkRequest is an uplink of either network server, with the
+// metadata of the gateway that received it best
+type LoRaWANUplinkRequest struct {
+	Network      string         `validate:"required"`
+	DevEUI       string         `validate:"required,len=16,hexadecimal"`
+	FPort        int64          `validate:"min=0,max=255"` // 0 carries MAC commands only
+	FCnt         int64          `validate:"min=0"`
+	MessageID    string         `validate:"max=100"`
+	Measurements map[string]any // decoded payload, nil without a decoder
+	RSSI         *int64
+	SNR          *float64
+	GatewayID    string
+	ReceivedAt   time.Time // defaults to now
+}
+
+type LoRaWANUplinkResponse struct {
+	ID1        string                    `json:"id1"`
+	ID2        int64                     `json:"id2"`
+	Created    int                       `json:"created"`
+	Duplicates int                       `json:"duplicates"`
+	Failed     []LoRaWANMeasurementError `json:"failed,omitempty"`
+}
+
+// LoRaWANMeasurementError is a decoded measurement that was not stored
+type LoRaWANMeasurementError struct {
+	Measurement string `json:"measurement"`
+	Message     string `json:"message"`
+}
+
+type LoRaWANUplinkListRequest struct {
+	ID1      string `query:"id1" validate:"required,uppercase"`
+	ID2      int64  `query:"id2" validate:"required"`
+	Page     int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+}
+
+type LoRaWANUplinkMetadataResponse struct {
+	ID1        string    `json:"id1"`
+	ID2        int64     `json:"id2"`
+	Network    string    `json:"network"`
+	MessageID  string    `json:"message_id,omitempty"`
+	FCnt       int64     `json:"f_cnt"`
+	RSSI       *int64    `json:"rssi,omitempty"`
+	SNR        *float64  `json:"snr,omitempty"`
+	GatewayID  string    `json:"gateway_id,omitempty"`
+	Readings   int       `json:"readings"`
+	ReceivedAt time.Time `json:"received_at"`
+}
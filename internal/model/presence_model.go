@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type SensorPresenceResponse struct {
+	ID1        string `json:"id1"`
+	ID2        int64  `json:"id2"`
+	SensorType string `json:"sensor_type"`
+	// Status is online, offline or unknown
+	Status   string     `json:"status"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+	// DeviceStatus is the last status announced by the device, if any
+	DeviceStatus   string     `json:"device_status,omitempty"`
+	DeviceStatusAt *time.Time `json:"device_status_at,omitempty"`
+}
+
+type SensorPresenceListRequest struct {
+	ID1           string `query:"id1" validate:"omitempty,uppercase,max=20"`
+	ID2           int64  `query:"id2" validate:"omitempty,min=1"`
+	SilentMinutes int    `query:"silent_minutes" validate:"omitempty,min=1"`   // optional, only sensors without readings for this long
+	Page          int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize      int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+}
+
+// DeviceStatusRequest is a status message of a device, either "online" and
+// "offline" as plain text or {"status": "offline", "timestamp": "..."}
+type DeviceStatusRequest struct {
+	ID1       string    `json:"-" validate:"required,uppercase,max=20"`
+	ID2       int64     `json:"-" validate:"required"`
+	Status    string    `json:"status" validate:"required,oneof=online offline"`
+	Timestamp time.Time `json:"timestamp"` // optional, defaults to the receive time
+	// Retained messages may predate the stored status and never replace it
+	Retained bool `json:"-"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// SensorKeySetRequest sets the CoAP pre-shared key of a device. Without a
+// key, a random one is generated.
+type SensorKeySetRequest struct {
+	ID1 string `json:"id1" validate:"required,uppercase,max=20"`
+	ID2 int64  `json:"id2" validate:"required"`
+	PSK string `json:"psk,omitempty" validate:"omitempty,hexadecimal"` // hex encoded, 16 to 64 bytes
+}
+
+type SensorKeyDeleteRequest struct {
+	ID1 string `query:"id1" validate:"required,uppercase,max=20"`
+	ID2 int64  `query:"id2" validate:"required"`
+}
+
+type SensorKeyResponse struct {
+	ID1       string    `json:"id1"`
+	ID2       int64     `json:"id2"`
+	Identity  string    `json:"identity"` // DTLS PSK identity the device presents
+	PSK       string    `json:"psk"`      // hex encoded
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -3,8 +3,11 @@ package model
 import "time"
 
 type SensorRecord struct {
-	SensorValue float64   `json:"sensor_value"`
-	Timestamp   time.Time `json:"timestamp"`
+	SensorType      string    `json:"sensor_type,omitempty"` // set when a device search spans several sensor types
+	SensorValue     float64   `json:"sensor_value"`
+	Timestamp       time.Time `json:"timestamp"`
+	TimestampSource string    `json:"timestamp_source,omitempty"` // set on create, device or server
+	Duplicate       bool      `json:"duplicate,omitempty"`        // set on create when the reading was skipped as a duplicate
 }
 
 type SensorResponse struct {
@@ -19,14 +22,81 @@ type CreateSensorRequest struct {
 	ID2         int64     `json:"id2" validate:"required"`
 	SensorType  string    `json:"sensor_type" validate:"required"`
 	SensorValue float64   `json:"sensor_value" validate:"required"`
-	Timestamp   time.Time `json:"timestamp" validate:"required"`
+	Timestamp   time.Time `json:"timestamp"`                                         // optional, defaults to the receive time
+	MessageID   string    `json:"message_id,omitempty" validate:"omitempty,max=100"` // optional, for exactly-once ingestion
+
+	// Set by the server
+	ReceivedAt      time.Time `json:"-"`
+	TimestampSource string    `json:"-"`
+	Historical      bool      `json:"-"` // imported data, exempt from the timestamp window
+}
+
+// CreateSensorResult is the outcome of a single item of a batch create
+type CreateSensorResult struct {
+	Index     int             `json:"index"`
+	Response  *SensorResponse `json:"response,omitempty"`
+	Duplicate bool            `json:"duplicate"`
+	Err       error           `json:"-"`
+}
+
+// SensorIngestResponse summarises the readings of an ingested payload
+type SensorIngestResponse struct {
+	Received   int                 `json:"received"`
+	Created    int                 `json:"created"`
+	Duplicates int                 `json:"duplicates"`
+	Failed     []SensorIngestError `json:"failed,omitempty"`
+}
+
+// SensorIngestError is a rejected reading, by its position in the payload
+type SensorIngestError struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+// SensorBulkResponse summarises a newline-delimited bulk import. Accepted
+// includes the lines skipped as duplicates.
+type SensorBulkResponse struct {
+	Lines      int               `json:"lines"`
+	Accepted   int               `json:"accepted"`
+	Duplicates int               `json:"duplicates"`
+	Rejected   int               `json:"rejected"`
+	Errors     []SensorBulkError `json:"errors,omitempty"`    // first rejected lines, in order
+	Truncated  bool              `json:"truncated,omitempty"` // more lines were rejected than listed
+}
+
+// SensorBulkError is a rejected line, numbered from 1
+type SensorBulkError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// SensorReadingEvent is a committed reading published by the MQTT gateway
+type SensorReadingEvent struct {
+	SensorID        int64     `json:"sensor_id"`
+	ID1             string    `json:"id1"`
+	ID2             int64     `json:"id2"`
+	SensorType      string    `json:"sensor_type"`
+	SensorValue     float64   `json:"sensor_value"`
+	Timestamp       time.Time `json:"timestamp"`
+	TimestampSource string    `json:"timestamp_source"`
+	MessageID       string    `json:"message_id,omitempty"`
+	DuplicatePolicy string    `json:"duplicate_policy"` // effective policy of the sensor
+	ProcessedAt     time.Time `json:"processed_at"`
+}
+
+// SensorMQTTReply answers an MQTT 5 message that named a response topic
+type SensorMQTTReply struct {
+	Created int    `json:"created"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"` // last rejection
 }
 
 type SensorSearchByIdRequest struct {
-	ID1      string `query:"id1" validate:"required,uppercase"`
-	ID2      int64  `query:"id2" validate:"required"`
-	Page     int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
-	PageSize int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+	ID1        string `query:"id1" validate:"required,uppercase"`
+	ID2        int64  `query:"id2" validate:"required"`
+	SensorType string `query:"sensor_type" validate:"omitempty,max=50"`     // optional, every type of the device if empty
+	Page       int    `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize   int    `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
 }
 
 type SensorSearchByTimeRangeRequest struct {
@@ -37,12 +107,13 @@ type SensorSearchByTimeRangeRequest struct {
 }
 
 type SensorSearchByIdAndTimeRangeRequest struct {
-	ID1      string    `query:"id1" validate:"required,uppercase"`
-	ID2      int64     `query:"id2" validate:"required"`
-	Start    time.Time `query:"start" validate:"required"`
-	End      time.Time `query:"end" validate:"required"`
-	Page     int       `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
-	PageSize int       `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
+	ID1        string    `query:"id1" validate:"required,uppercase"`
+	ID2        int64     `query:"id2" validate:"required"`
+	SensorType string    `query:"sensor_type" validate:"omitempty,max=50"` // optional, every type of the device if empty
+	Start      time.Time `query:"start" validate:"required"`
+	End        time.Time `query:"end" validate:"required"`
+	Page       int       `query:"page" validate:"omitempty,min=1"`             // optional, must be >= 1 if provided
+	PageSize   int       `query:"pageSize" validate:"omitempty,min=1,max=100"` // optional, must be between 1–100
 }
 
 type SensorDeleteResponse struct {
@@ -56,6 +127,7 @@ type SensorUpdateResponse struct {
 type SensorUpdateByIdRequest struct {
 	ID1         string  `json:"id1" validate:"required,uppercase"`
 	ID2         int64   `json:"id2" validate:"required"`
+	SensorType  string  `json:"sensor_type" validate:"omitempty,max=50"` // optional, every type of the device if empty
 	SensorValue float64 `json:"sensor_value" validate:"required"`
 }
 
@@ -68,7 +140,42 @@ type SensorUpdateByTimeRangeRequest struct {
 type SensorUpdateByIdAndTimeRangeRequest struct {
 	ID1         string    `json:"id1" validate:"required,uppercase"`
 	ID2         int64     `json:"id2" validate:"required"`
+	SensorType  string    `json:"sensor_type" validate:"omitempty,max=50"` // optional, every type of the device if empty
 	Start       time.Time `json:"start" validate:"required"`
 	End         time.Time `json:"end" validate:"required"`
 	SensorValue float64   `json:"sensor_value" validate:"required"`
 }
+
+type SensorDuplicatePolicyRequest struct {
+	ID1             string `json:"id1" validate:"required,uppercase"`
+	ID2             int64  `json:"id2" validate:"required"`
+	SensorType      string `json:"sensor_type" validate:"required"`
+	DuplicatePolicy string `json:"duplicate_policy" validate:"omitempty,oneof=keep_all ignore overwrite"` // empty restores the server default
+}
+
+type SensorDuplicatePolicyResponse struct {
+	ID1             string `json:"id1"`
+	ID2             int64  `json:"id2"`
+	SensorType      string `json:"sensor_type"`
+	DuplicatePolicy string `json:"duplicate_policy"`
+	Default         bool   `json:"default"`
+}
+
+type SensorTimestampStatsRequest struct {
+	ID1        string `query:"id1" validate:"required,uppercase"`
+	ID2        int64  `query:"id2" validate:"required"`
+	SensorType string `query:"sensor_type" validate:"required"`
+}
+
+// SensorTimestampStatsResponse counts readings whose timestamp was replaced
+// by the receive time (missing or corrected) or that were rejected, within a
+// window of Window seconds from the first counted reading
+type SensorTimestampStatsResponse struct {
+	ID1        string `json:"id1"`
+	ID2        int64  `json:"id2"`
+	SensorType string `json:"sensor_type"`
+	Missing    int64  `json:"missing"`
+	Corrected  int64  `json:"corrected"`
+	Rejected   int64  `json:"rejected"`
+	Window     int64  `json:"window_seconds"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// SensorStreamRequest filters the readings of a live stream, empty fields
+// match every reading
+type SensorStreamRequest struct {
+	ID1        string `query:"id1" validate:"omitempty,uppercase,max=20"`
+	ID2        int64  `query:"id2" validate:"omitempty,min=1"`
+	SensorType string `query:"sensor_type" validate:"omitempty,max=50"`
+}
+
+// Live stream message types
+const (
+	SensorStreamReading = "reading"
+	SensorStreamDropped = "dropped"
+)
+
+// SensorStreamMessage is a WebSocket message of a live stream: a committed
+// reading, or the number of readings dropped since the previous message
+// because the client did not keep up
+type SensorStreamMessage struct {
+	Type    string              `json:"type"`
+	Reading *SensorReadingEvent `json:"reading,omitempty"`
+	Dropped int64               `json:"dropped,omitempty"`
+}
+
+// SensorStreamTicketResponse is a single-use ticket opening a live stream
+type SensorStreamTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DeadLetterRepository struct {
+	DB  *sql.DB
+	Log *logrus.Logger
+}
+
+func NewDeadLetterRepository(db *sql.DB, log *logrus.Logger) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		DB:  db,
+		Log: log,
+	}
+}
+
+// Create inserts a new dead letter
+func (r *DeadLetterRepository) Create(ctx context.Context, deadLetter *entity.DeadLetter) error {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		INSERT INTO dead_letters (topic, content_type, payload, error_class, error_message, received_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	res, err := r.DB.ExecContext(ctx, q,
+		deadLetter.Topic,
+		deadLetter.ContentType,
+		deadLetter.Payload,
+		deadLetter.ErrorClass,
+		deadLetter.ErrorMessage,
+		deadLetter.ReceivedAt,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to insert dead letter")
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get last insert id for dead letter")
+		return err
+	}
+
+	deadLetter.DeadLetterID = id
+	return nil
+}
+
+// FindByID returns a dead letter by ID
+func (r *DeadLetterRepository) FindByID(ctx context.Context, id int64) (*entity.DeadLetter, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		SELECT dead_letter_id, topic, content_type, payload, error_class, error_message, received_at, replay_count, last_replayed_at
+		FROM dead_letters
+		WHERE dead_letter_id = ?
+		LIMIT 1
+	`
+	var d entity.DeadLetter
+	err := r.DB.QueryRowContext(ctx, q, id).Scan(
+		&d.DeadLetterID, &d.Topic, &d.ContentType, &d.Payload, &d.ErrorClass, &d.ErrorMessage, &d.ReceivedAt, &d.ReplayCount, &d.LastReplayedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// FindAll lists dead letters newest first, optionally filtered by error class
+func (r *DeadLetterRepository) FindAll(
+	ctx context.Context,
+	errorClass string,
+	page, pageSize int,
+) ([]entity.DeadLetter, *model.PageMetadata, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	offset := (page - 1) * pageSize
+
+	const q = `
+		SELECT dead_letter_id, topic, content_type, payload, error_class, error_message, received_at, replay_count, last_replayed_at
+		FROM dead_letters
+		WHERE (? = '' OR error_class = ?)
+		ORDER BY received_at DESC, dead_letter_id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.DB.QueryContext(ctx, q, errorClass, errorClass, pageSize, offset)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to retrieve dead letters")
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.DeadLetter, 0, pageSize)
+	for rows.Next() {
+		var d entity.DeadLetter
+		if err := rows.Scan(
+			&d.DeadLetterID, &d.Topic, &d.ContentType, &d.Payload, &d.ErrorClass, &d.ErrorMessage, &d.ReceivedAt, &d.ReplayCount, &d.LastReplayedAt,
+		); err != nil {
+			r.Log.WithError(err).Error("failed to scan dead letter row")
+			return nil, nil, err
+		}
+		out = append(out, d)
+	}
+	err = rows.Err()
+	if err != nil {
+		r.Log.WithError(err).Error("row iteration error for dead letters")
+		return nil, nil, err
+	}
+
+	// Count total record
+	const qCount = `
+		SELECT COUNT(*)
+		FROM dead_letters
+		WHERE (? = '' OR error_class = ?)
+	`
+	var total int64
+	err = r.DB.QueryRowContext(ctx, qCount, errorClass, errorClass).Scan(&total)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to count dead letters")
+		return nil, nil, err
+	}
+
+	return out, pageMeta(page, pageSize, total), nil
+}
+
+// UpdatePayload overwrites the topic, content type and payload of a dead letter
+func (r *DeadLetterRepository) UpdatePayload(ctx context.Context, id int64, topic, contentType string, payload []byte) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		UPDATE dead_letters
+		SET topic = ?, content_type = ?, payload = ?
+		WHERE dead_letter_id = ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, topic, contentType, payload, id)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to update dead letter")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateReplayFailure records a failed replay attempt
+func (r *DeadLetterRepository) UpdateReplayFailure(ctx context.Context, deadLetter *entity.DeadLetter, replayedAt time.Time) error {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		UPDATE dead_letters
+		SET content_type = ?, payload = ?, error_class = ?, error_message = ?, replay_count = replay_count + 1, last_replayed_at = ?
+		WHERE dead_letter_id = ?
+	`
+	_, err := r.DB.ExecContext(ctx, q,
+		deadLetter.ContentType,
+		deadLetter.Payload,
+		deadLetter.ErrorClass,
+		deadLetter.ErrorMessage,
+		replayedAt,
+		deadLetter.DeadLetterID,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to record dead letter replay")
+		return err
+	}
+
+	deadLetter.ReplayCount++
+	deadLetter.LastReplayedAt = &replayedAt
+	return nil
+}
+
+// Delete removes a single dead letter
+func (r *DeadLetterRepository) Delete(ctx context.Context, id int64) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		DELETE FROM dead_letters
+		WHERE dead_letter_id = ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, id)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to delete dead letter")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Purge removes dead letters received before the given time, optionally
+// restricted to one error class
+func (r *DeadLetterRepository) Purge(ctx context.Context, errorClass string, before time.Time) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		DELETE FROM dead_letters
+		WHERE (? = '' OR error_class = ?)
+		  AND received_at < ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, errorClass, errorClass, before)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to purge dead letters")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DeviceCommandRepository struct {
+	DB  *sql.DB
+	Log *logrus.Logger
+}
+
+func NewDeviceCommandRepository(db *sql.DB, log *logrus.Logger) *DeviceCommandRepository {
+	return &DeviceCommandRepository{
+		DB:  db,
+		Log: log,
+	}
+}
+
+const deviceCommandColumns = `command_id, id1, id2, name, params, status, result, error_message, created_by, created_at, delivered_at, completed_at, expires_at`
+
+// Create inserts a new command
+func (r *DeviceCommandRepository) Create(ctx context.Context, command *entity.DeviceCommand) error {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		INSERT INTO device_commands (id1, id2, name, params, status, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	res, err := r.DB.ExecContext(ctx, q,
+		command.ID1,
+		command.ID2,
+		command.Name,
+		command.Params,
+		command.Status,
+		command.CreatedBy,
+		command.CreatedAt,
+		command.ExpiresAt,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to insert device command")
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get last insert id for device command")
+		return err
+	}
+
+	command.CommandID = id
+	return nil
+}
+
+// FindByID returns a command by ID
+func (r *DeviceCommandRepository) FindByID(ctx context.Context, id int64) (*entity.DeviceCommand, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		SELECT ` + deviceCommandColumns + `
+		FROM device_commands
+		WHERE command_id = ?
+		LIMIT 1
+	`
+	var c entity.DeviceCommand
+	err := r.DB.QueryRowContext(ctx, q, id).Scan(
+		&c.CommandID, &c.ID1, &c.ID2, &c.Name, &c.Params, &c.Status, &c.Result, &c.ErrorMessage,
+		&c.CreatedBy, &c.CreatedAt, &c.DeliveredAt, &c.CompletedAt, &c.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindAll lists commands newest first, optionally filtered by device and status
+func (r *DeviceCommandRepository) FindAll(
+	ctx context.Context,
+	id1 string, id2 int64, status string,
+	page, pageSize int,
+) ([]entity.DeviceCommand, *model.PageMetadata, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	offset := (page - 1) * pageSize
+
+	const q = `
+		SELECT ` + deviceCommandColumns + `
+		FROM device_commands
+		WHERE (? = '' OR id1 = ?)
+		  AND (? = 0 OR id2 = ?)
+		  AND (? = '' OR status = ?)
+		ORDER BY created_at DESC, command_id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.DB.QueryContext(ctx, q, id1, id1, id2, id2, status, status, pageSize, offset)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to retrieve device commands")
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.DeviceCommand, 0, pageSize)
+	for rows.Next() {
+		var c entity.DeviceCommand
+		if err := rows.Scan(
+			&c.CommandID, &c.ID1, &c.ID2, &c.Name, &c.Params, &c.Status, &c.Result, &c.ErrorMessage,
+			&c.CreatedBy, &c.CreatedAt, &c.DeliveredAt, &c.CompletedAt, &c.ExpiresAt,
+		); err != nil {
+			r.Log.WithError(err).Error("failed to scan device command row")
+			return nil, nil, err
+		}
+		out = append(out, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		r.Log.WithError(err).Error("row iteration error for device commands")
+		return nil, nil, err
+	}
+
+	// Count total record
+	const qCount = `
+		SELECT COUNT(*)
+		FROM device_commands
+		WHERE (? = '' OR id1 = ?)
+		  AND (? = 0 OR id2 = ?)
+		  AND (? = '' OR status = ?)
+	`
+	var total int64
+	err = r.DB.QueryRowContext(ctx, qCount, id1, id1, id2, id2, status, status).Scan(&total)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to count device commands")
+		return nil, nil, err
+	}
+
+	return out, pageMeta(page, pageSize, total), nil
+}
+
+// MarkDelivered moves a pending command to delivered. A command the device
+// already answered is left alone.
+func (r *DeviceCommandRepository) MarkDelivered(ctx context.Context, id int64, deliveredAt time.Time) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		UPDATE device_commands
+		SET status = ?, delivered_at = ?
+		WHERE command_id = ? AND status = ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, entity.CommandDelivered, deliveredAt, id, entity.CommandPending)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to mark device command delivered")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Complete records the final status of an open command of the given device.
+// It affects no row when the command is unknown, belongs to another device,
+// is already complete or expired before completedAt.
+func (r *DeviceCommandRepository) Complete(
+	ctx context.Context,
+	id int64, id1 string, id2 int64,
+	status string, result []byte, errorMessage string,
+	completedAt time.Time,
+) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		UPDATE device_commands
+		SET status = ?, result = ?, error_message = ?, completed_at = ?
+		WHERE command_id = ? AND id1 = ? AND id2 = ? AND status IN (?, ?) AND expires_at >= ?
+	`
+	res, err := r.DB.ExecContext(ctx, q,
+		status, result, errorMessage, completedAt,
+		id, id1, id2, entity.CommandPending, entity.CommandDelivered, completedAt,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to complete device command")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ExpireOpen times out the open commands that expired before now
+func (r *DeviceCommandRepository) ExpireOpen(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		UPDATE device_commands
+		SET status = ?, error_message = 'no acknowledgement before expiry', completed_at = ?
+		WHERE status IN (?, ?) AND expires_at < ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, entity.CommandTimedOut, now, entity.CommandPending, entity.CommandDelivered, now)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to expire device commands")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
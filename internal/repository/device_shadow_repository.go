@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iot-server/internal/entity"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type DeviceShadowRepository struct {
+	DB  *sql.DB
+	Log *logrus.Logger
+}
+
+func NewDeviceShadowRepository(db *sql.DB, log *logrus.Logger) *DeviceShadowRepository {
+	return &DeviceShadowRepository{
+		DB:  db,
+		Log: log,
+	}
+}
+
+// Find returns the shadow of a device
+func (r *DeviceShadowRepository) Find(ctx context.Context, id1 string, id2 int64) (*entity.DeviceShadow, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		SELECT id1, id2, desired, desired_version, desired_updated_at, reported, reported_version, reported_updated_at
+		FROM device_shadows
+		WHERE id1 = ? AND id2 = ?
+		LIMIT 1
+	`
+	var s entity.DeviceShadow
+	err := r.DB.QueryRowContext(ctx, q, id1, id2).Scan(
+		&s.ID1, &s.ID2, &s.Desired, &s.DesiredVersion, &s.DesiredUpdatedAt, &s.Reported, &s.ReportedVersion, &s.ReportedUpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindForUpdateTx returns the documents and the last published delta of a
+// shadow and locks it until tx ends
+func (r *DeviceShadowRepository) FindForUpdateTx(ctx context.Context, tx *sql.Tx, id1 string, id2 int64) (*entity.DeviceShadow, error) {
+	const q = `
+		SELECT id1, id2, desired, desired_version, reported, reported_version, published_delta
+		FROM device_shadows
+		WHERE id1 = ? AND id2 = ?
+		FOR UPDATE
+	`
+	var s entity.DeviceShadow
+	err := tx.QueryRowContext(ctx, q, id1, id2).Scan(
+		&s.ID1, &s.ID2, &s.Desired, &s.DesiredVersion, &s.Reported, &s.ReportedVersion, &s.PublishedDelta,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to lock device shadow")
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdatePublishedDeltaTx records the delta last sent to the device
+func (r *DeviceShadowRepository) UpdatePublishedDeltaTx(ctx context.Context, tx *sql.Tx, id1 string, id2 int64, delta []byte) error {
+	const q = `
+		UPDATE device_shadows
+		SET published_delta = ?
+		WHERE id1 = ? AND id2 = ?
+	`
+	if _, err := tx.ExecContext(ctx, q, delta, id1, id2); err != nil {
+		r.Log.WithError(err).Error("failed to update published shadow delta")
+		return err
+	}
+	return nil
+}
+
+// Ensure creates an empty shadow for a device that has none
+func (r *DeviceShadowRepository) Ensure(ctx context.Context, id1 string, id2 int64) error {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		INSERT IGNORE INTO device_shadows (id1, id2)
+		VALUES (?, ?)
+	`
+	if _, err := r.DB.ExecContext(ctx, q, id1, id2); err != nil {
+		r.Log.WithError(err).Error("failed to create device shadow")
+		return err
+	}
+	return nil
+}
+
+// UpdateDocument replaces the desired or reported document and increments its
+// version, provided the version is still the one the document was read at.
+// It affects no row when another update came first.
+func (r *DeviceShadowRepository) UpdateDocument(
+	ctx context.Context,
+	id1 string, id2 int64,
+	document string, value []byte, version int64,
+	updatedAt time.Time,
+) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	var q string
+	switch document {
+	case entity.ShadowDesired:
+		q = `
+		UPDATE device_shadows
+		SET desired = ?, desired_version = desired_version + 1, desired_updated_at = ?
+		WHERE id1 = ? AND id2 = ? AND desired_version = ?
+	`
+	case entity.ShadowReported:
+		q = `
+		UPDATE device_shadows
+		SET reported = ?, reported_version = reported_version + 1, reported_updated_at = ?
+		WHERE id1 = ? AND id2 = ? AND reported_version = ?
+	`
+	default:
+		return 0, fmt.Errorf("unknown shadow document %q", document)
+	}
+
+	res, err := r.DB.ExecContext(ctx, q, value, updatedAt, id1, id2, version)
+	if err != nil {
+		r.Log.WithError(err).WithField("document", document).Error("failed to update device shadow")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
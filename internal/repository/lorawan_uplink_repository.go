@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LoRaWANUplinkRepository struct {
+	DB  *sql.DB
+	Log *logrus.Logger
+}
+
+func NewLoRaWANUplinkRepository(db *sql.DB, log *logrus.Logger) *LoRaWANUplinkRepository {
+	return &LoRaWANUplinkRepository{
+		DB:  db,
+		Log: log,
+	}
+}
+
+// Create inserts the metadata of an uplink. An uplink redelivered with the
+// same message id is ignored, Create then reports false.
+func (r *LoRaWANUplinkRepository) Create(ctx context.Context, uplink *entity.LoRaWANUplink) (bool, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		INSERT IGNORE INTO lorawan_uplinks (id1, id2, network, message_id, f_cnt, rssi, snr, gateway_id, readings, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	res, err := r.DB.ExecContext(ctx, q,
+		uplink.ID1,
+		uplink.ID2,
+		uplink.Network,
+		nullString(uplink.MessageID),
+		uplink.FCnt,
+		uplink.RSSI,
+		uplink.SNR,
+		nullString(uplink.GatewayID),
+		uplink.Readings,
+		uplink.ReceivedAt,
+	)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to insert LoRaWAN uplink")
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get number of rows affected after LoRaWAN uplink insert")
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get last insert id for LoRaWAN uplink")
+		return false, err
+	}
+	uplink.UplinkID = id
+	return true, nil
+}
+
+// FindAll lists the uplinks of a device and port, newest first
+func (r *LoRaWANUplinkRepository) FindAll(
+	ctx context.Context,
+	id1 string,
+	id2 int64,
+	page, pageSize int,
+) ([]entity.LoRaWANUplink, *model.PageMetadata, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	offset := (page - 1) * pageSize
+
+	const q = `
+		SELECT uplink_id, id1, id2, network, message_id, f_cnt, rssi, snr, gateway_id, readings, received_at
+		FROM lorawan_uplinks
+		WHERE id1 = ? AND id2 = ?
+		ORDER BY received_at DESC, uplink_id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.DB.QueryContext(ctx, q, id1, id2, pageSize, offset)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to retrieve LoRaWAN uplinks")
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.LoRaWANUplink, 0, pageSize)
+	for rows.Next() {
+		var u entity.LoRaWANUplink
+		var messageID, gatewayID sql.NullString
+		if err := rows.Scan(
+			&u.UplinkID, &u.ID1, &u.ID2, &u.Network, &messageID, &u.FCnt, &u.RSSI, &u.SNR, &gatewayID, &u.Readings, &u.ReceivedAt,
+		); err != nil {
+			r.Log.WithError(err).Error("failed to scan LoRaWAN uplink row")
+			return nil, nil, err
+		}
+		u.MessageID = messageID.String
+		u.GatewayID = gatewayID.String
+		out = append(out, u)
+	}
+	err = rows.Err()
+	if err != nil {
+		r.Log.WithError(err).Error("row iteration error for LoRaWAN uplinks")
+		return nil, nil, err
+	}
+
+	// Count total record
+	const qCount = `
+		SELECT COUNT(*)
+		FROM lorawan_uplinks
+		WHERE id1 = ? AND id2 = ?
+	`
+	var total int64
+	err = r.DB.QueryRowContext(ctx, qCount, id1, id2).Scan(&total)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to count LoRaWAN uplinks")
+		return nil, nil, err
+	}
+
+	return out, pageMeta(page, pageSize, total), nil
+}
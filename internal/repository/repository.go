@@ -2,12 +2,38 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"iot-server/internal/model"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 const defaultQueryTimeout = 5 * time.Second
 
+// maxBatchRows caps the number of rows in a single multi-row INSERT
+const maxBatchRows = 500
+
+// errDuplicateEntry is the MySQL error number of a unique key violation
+const errDuplicateEntry = 1062
+
+// errLockDeadlock is the MySQL error number of a transaction rolled back to
+// resolve a deadlock
+const errLockDeadlock = 1213
+
+// IsDuplicateKey reports whether err is a MySQL unique key violation
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
+// IsDeadlock reports whether err is a MySQL deadlock. The whole transaction
+// was rolled back and may be run again.
+func IsDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errLockDeadlock
+}
+
 // helper
 func pageMeta(page, pageSize int, total int64) *model.PageMetadata {
 	if page < 1 {
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"iot-server/internal/entity"
+
+	"github.com/sirupsen/logrus"
+)
+
+type SensorKeyRepository struct {
+	DB  *sql.DB
+	Log *logrus.Logger
+}
+
+func NewSensorKeyRepository(db *sql.DB, log *logrus.Logger) *SensorKeyRepository {
+	return &SensorKeyRepository{
+		DB:  db,
+		Log: log,
+	}
+}
+
+// Find returns the key of a device
+func (r *SensorKeyRepository) Find(ctx context.Context, id1 string, id2 int64) (*entity.SensorKey, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		SELECT id1, id2, psk, created_at, updated_at
+		FROM sensor_keys
+		WHERE id1 = ? AND id2 = ?
+		LIMIT 1
+	`
+	var k entity.SensorKey
+	err := r.DB.QueryRowContext(ctx, q, id1, id2).Scan(&k.ID1, &k.ID2, &k.PSK, &k.CreatedAt, &k.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Upsert sets the key of a device, replacing the previous one
+func (r *SensorKeyRepository) Upsert(ctx context.Context, key *entity.SensorKey) error {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		INSERT INTO sensor_keys (id1, id2, psk, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE psk = VALUES(psk), updated_at = VALUES(updated_at)
+	`
+	if _, err := r.DB.ExecContext(ctx, q, key.ID1, key.ID2, key.PSK, key.CreatedAt, key.UpdatedAt); err != nil {
+		r.Log.WithError(err).Error("failed to upsert sensor key")
+		return err
+	}
+	return nil
+}
+
+// Delete removes the key of a device and returns the number of deleted rows
+func (r *SensorKeyRepository) Delete(ctx context.Context, id1 string, id2 int64) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `
+		DELETE FROM sensor_keys
+		WHERE id1 = ? AND id2 = ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, id1, id2)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to delete sensor key")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
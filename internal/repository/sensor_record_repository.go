@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"iot-server/internal/entity"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -38,3 +40,222 @@ func (r *SensorRecordRepository) CreateTx(ctx context.Context, tx *sql.Tx, recor
 	record.RecordID = id
 	return nil
 }
+
+// CreateBatchTx inserts records using multi-row INSERT statements, at most
+// maxBatchRows rows per statement, and reports per record whether it was
+// rejected by a unique key. A statement that hits a unique key is retried row
+// by row, so one duplicate does not reject the rest of its chunk.
+func (r *SensorRecordRepository) CreateBatchTx(ctx context.Context, tx *sql.Tx, records []*entity.SensorRecord) ([]bool, error) {
+	return r.createBatchTx(ctx, tx, records, false)
+}
+
+// createBatchTx is CreateBatchTx. With dedupe the records also take the
+// unique_dedupe key of their timestamp, so at most one is stored per sensor
+// and timestamp.
+func (r *SensorRecordRepository) createBatchTx(ctx context.Context, tx *sql.Tx, records []*entity.SensorRecord, dedupe bool) ([]bool, error) {
+	duplicates := make([]bool, len(records))
+	for start := 0; start < len(records); start += maxBatchRows {
+		end := start + maxBatchRows
+		if end > len(records) {
+			end = len(records)
+		}
+		err := r.createChunkTx(ctx, tx, records[start:end], dedupe)
+		if err == nil {
+			continue
+		}
+		if !IsDuplicateKey(err) {
+			return nil, err
+		}
+		if end-start == 1 {
+			duplicates[start] = true
+			continue
+		}
+
+		// MySQL only rolls back the failed statement, the transaction goes on
+		for i := start; i < end; i++ {
+			err := r.createChunkTx(ctx, tx, records[i:i+1], dedupe)
+			if IsDuplicateKey(err) {
+				duplicates[i] = true
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+func (r *SensorRecordRepository) createChunkTx(ctx context.Context, tx *sql.Tx, records []*entity.SensorRecord, dedupe bool) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	columns, row := 5, "(?, ?, ?, ?, ?)"
+	var q strings.Builder
+	if dedupe {
+		columns, row = 6, "(?, ?, ?, ?, ?, ?)"
+		q.WriteString("INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source, dedupe_timestamp) VALUES ")
+	} else {
+		q.WriteString("INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES ")
+	}
+	args := make([]any, 0, len(records)*columns)
+	for i, record := range records {
+		if i > 0 {
+			q.WriteString(", ")
+		}
+		q.WriteString(row)
+		args = append(args, record.SensorID, record.SensorValue, record.Timestamp, nullString(record.MessageID), timestampSource(record))
+		if dedupe {
+			args = append(args, record.Timestamp)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, q.String(), args...)
+	if err != nil {
+		r.Log.WithError(err).Errorf("failed to insert %d sensor records", len(records))
+		return err
+	}
+
+	// MySQL returns the id of the first row of a multi-row insert, the rest
+	// are allocated consecutively.
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get last insert id for records")
+		return err
+	}
+
+	for i, record := range records {
+		record.RecordID = id + int64(i)
+	}
+	return nil
+}
+
+// OverwriteTx stores a record, or replaces the value of the record stored at
+// its sensor and timestamp, and reports whether it was skipped as a
+// duplicate: the stored value is the same, or the stored record carries the
+// same message id. The unique_dedupe key finds the stored record, so no gap of
+// the index is locked when there is none.
+func (r *SensorRecordRepository) OverwriteTx(ctx context.Context, tx *sql.Tx, record *entity.SensorRecord) (bool, error) {
+	// sensor_value is assigned before message_id, so it still sees the stored one
+	const q = `
+		INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source, dedupe_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			record_id = LAST_INSERT_ID(record_id),
+			sensor_value = IF(message_id IS NOT NULL AND message_id = VALUES(message_id), sensor_value, VALUES(sensor_value)),
+			message_id = COALESCE(VALUES(message_id), message_id)
+	`
+	res, err := tx.ExecContext(ctx, q, record.SensorID, record.SensorValue, record.Timestamp, nullString(record.MessageID), timestampSource(record), record.Timestamp)
+	if IsDuplicateKey(err) {
+		// The new message id or value is stored in another record
+		return true, nil
+	}
+	if err != nil {
+		r.Log.WithError(err).Error("failed to overwrite sensor record")
+		return false, err
+	}
+
+	// 1 for an insert, 2 for an update, 0 when nothing changed
+	affected, err := res.RowsAffected()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get affected rows for record")
+		return false, err
+	}
+	if affected == 0 {
+		return true, nil
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get last insert id for record")
+		return false, err
+	}
+	record.RecordID = id
+	return false, nil
+}
+
+// UpsertBatchTx stores records according to the duplicate policy of their
+// sensor (record.Sensor.DuplicatePolicy) and reports, per record, whether it
+// was skipped as a duplicate. The unique keys decide: unique_message skips a
+// message id already stored for the sensor whatever the policy,
+// unique_sensor an exact resend, and unique_dedupe a second reading of a
+// timestamp under ignore. Nothing is read beforehand, so concurrent writers
+// only wait for each other on rows they both write.
+func (r *SensorRecordRepository) UpsertBatchTx(ctx context.Context, tx *sql.Tx, records []*entity.SensorRecord) ([]bool, error) {
+	duplicates := make([]bool, len(records))
+	var keepAll, ignore, overwrite []int
+	latest := make(map[timestampKey]*entity.SensorRecord)
+	for i, record := range records {
+		record.RecordID = 0 // left over from a transaction that was rolled back
+		switch record.Sensor.DuplicatePolicy {
+		case entity.DuplicateIgnore:
+			ignore = append(ignore, i)
+		case entity.DuplicateOverwrite:
+			// A later reading of a timestamp in the batch replaces the value
+			// of the earlier one, which stores it, and counts as duplicate
+			key := newTimestampKey(record)
+			if earlier, ok := latest[key]; ok {
+				earlier.SensorValue = record.SensorValue
+				duplicates[i] = true
+				continue
+			}
+			latest[key] = record
+			overwrite = append(overwrite, i)
+		default:
+			keepAll = append(keepAll, i)
+		}
+	}
+
+	for _, group := range []struct {
+		positions []int
+		dedupe    bool
+	}{{keepAll, false}, {ignore, true}} {
+		if len(group.positions) == 0 {
+			continue
+		}
+		batch := make([]*entity.SensorRecord, len(group.positions))
+		for n, i := range group.positions {
+			batch[n] = records[i]
+		}
+		rejected, err := r.createBatchTx(ctx, tx, batch, group.dedupe)
+		if err != nil {
+			return nil, err
+		}
+		for n, i := range group.positions {
+			duplicates[i] = rejected[n]
+		}
+	}
+
+	for _, i := range overwrite {
+		duplicate, err := r.OverwriteTx(ctx, tx, records[i])
+		if err != nil {
+			return nil, err
+		}
+		duplicates[i] = duplicate
+	}
+	return duplicates, nil
+}
+
+type timestampKey struct {
+	sensorID int64
+	micros   int64
+}
+
+// newTimestampKey matches TIMESTAMP(6), which stores microseconds
+func newTimestampKey(record *entity.SensorRecord) timestampKey {
+	return timestampKey{record.SensorID, record.Timestamp.Round(time.Microsecond).UnixMicro()}
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func timestampSource(record *entity.SensorRecord) string {
+	if record.TimestampSource == "" {
+		return entity.TimestampSourceDevice
+	}
+	return record.TimestampSource
+}
@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"iot-server/internal/entity"
 	"iot-server/internal/model"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -52,14 +54,15 @@ func (r *SensorRepository) FindByUnique(ctx context.Context, id1 string, id2 int
 	defer cancel()
 
 	const q = `
-		SELECT sensor_id, id1, id2, sensor_type
+		SELECT sensor_id, id1, id2, sensor_type, duplicate_policy
 		FROM sensors
 		WHERE id1 = ? AND id2 = ? AND sensor_type = ?
 		LIMIT 1
 	`
 	var s entity.Sensor
+	var duplicatePolicy sql.NullString
 	err := r.DB.QueryRowContext(ctx, q, id1, id2, sensorType).Scan(
-		&s.SensorID, &s.ID1, &s.ID2, &s.SensorType,
+		&s.SensorID, &s.ID1, &s.ID2, &s.SensorType, &duplicatePolicy,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -68,9 +71,168 @@ func (r *SensorRepository) FindByUnique(ctx context.Context, id1 string, id2 int
 		r.Log.WithError(err).Errorf("failed to find sensor: id1=%s id2=%d sensorType=%s", id1, id2, sensorType)
 		return nil, err
 	}
+	s.DuplicatePolicy = duplicatePolicy.String
 	return &s, nil
 }
 
+// ExistsDevice reports whether the device id1/id2 has any sensor
+func (r *SensorRepository) ExistsDevice(ctx context.Context, id1 string, id2 int64) (bool, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	const q = `SELECT EXISTS (SELECT 1 FROM sensors WHERE id1 = ? AND id2 = ?)`
+	var exists bool
+	if err := r.DB.QueryRowContext(ctx, q, id1, id2).Scan(&exists); err != nil {
+		r.Log.WithError(err).Errorf("failed to find device: id1=%s id2=%d", id1, id2)
+		return false, err
+	}
+	return exists, nil
+}
+
+// FindAll lists sensors ordered by id1, id2 and type, optionally of one id1
+// or id1/id2 device. Sensors in exclude are skipped: their ids are loaded into
+// a temporary table joined against sensors, so the queries keep their size
+// however many sensors are excluded.
+func (r *SensorRepository) FindAll(
+	ctx context.Context,
+	id1 string, id2 int64,
+	exclude []int64,
+	page, pageSize int,
+) ([]entity.Sensor, *model.PageMetadata, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	// A temporary table only exists on the connection that created it
+	conn, err := r.DB.Conn(ctx)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get connection")
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	offset := (page - 1) * pageSize
+
+	from := `FROM sensors s`
+	where := `WHERE (? = '' OR s.id1 = ?) AND (? = 0 OR s.id2 = ?)`
+	args := []any{id1, id1, id2, id2}
+	if len(exclude) > 0 {
+		if err := r.createExcludedSensors(ctx, conn, exclude); err != nil {
+			return nil, nil, err
+		}
+		defer r.dropExcludedSensors(conn)
+
+		from += ` LEFT JOIN excluded_sensors e ON e.sensor_id = s.sensor_id`
+		where += ` AND e.sensor_id IS NULL`
+	}
+
+	q := `
+		SELECT s.sensor_id, s.id1, s.id2, s.sensor_type, s.duplicate_policy
+		` + from + `
+		` + where + `
+		ORDER BY s.id1, s.id2, s.sensor_type
+		LIMIT ? OFFSET ?
+	`
+	rows, err := conn.QueryContext(ctx, q, append(args, pageSize, offset)...)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to retrieve sensors")
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	out := make([]entity.Sensor, 0, pageSize)
+	for rows.Next() {
+		var s entity.Sensor
+		var duplicatePolicy sql.NullString
+		if err := rows.Scan(&s.SensorID, &s.ID1, &s.ID2, &s.SensorType, &duplicatePolicy); err != nil {
+			r.Log.WithError(err).Error("failed to scan sensor row")
+			return nil, nil, err
+		}
+		s.DuplicatePolicy = duplicatePolicy.String
+		out = append(out, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		r.Log.WithError(err).Error("row iteration error for sensors")
+		return nil, nil, err
+	}
+
+	// Count total record
+	var total int64
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*) `+from+` `+where, args...).Scan(&total)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to count sensors")
+		return nil, nil, err
+	}
+
+	return out, pageMeta(page, pageSize, total), nil
+}
+
+// createExcludedSensors fills the temporary table excluded_sensors of conn
+// with ids, maxBatchRows at a time
+func (r *SensorRepository) createExcludedSensors(ctx context.Context, conn *sql.Conn, ids []int64) error {
+	const q = `CREATE TEMPORARY TABLE excluded_sensors (sensor_id BIGINT NOT NULL PRIMARY KEY)`
+	if _, err := conn.ExecContext(ctx, q); err != nil {
+		r.Log.WithError(err).Error("failed to create excluded sensors table")
+		return err
+	}
+
+	for start := 0; start < len(ids); start += maxBatchRows {
+		end := start + maxBatchRows
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		q := `INSERT IGNORE INTO excluded_sensors (sensor_id) VALUES (?)` + strings.Repeat(`, (?)`, end-start-1)
+		args := make([]any, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+		if _, err := conn.ExecContext(ctx, q, args...); err != nil {
+			r.Log.WithError(err).Error("failed to insert excluded sensors")
+			r.dropExcludedSensors(conn)
+			return err
+		}
+	}
+	return nil
+}
+
+// dropExcludedSensors removes the temporary table before conn goes back to
+// the pool, also when the query context is done. A connection that may still
+// hold the table is discarded instead.
+func (r *SensorRepository) dropExcludedSensors(conn *sql.Conn) {
+	ctx, cancel := ctxWithTimeout(context.Background())
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, `DROP TEMPORARY TABLE IF EXISTS excluded_sensors`); err != nil {
+		r.Log.WithError(err).Warn("failed to drop excluded sensors table")
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+}
+
+// UpdateDuplicatePolicy sets the duplicate policy of a sensor, an empty
+// policy restores the server default
+func (r *SensorRepository) UpdateDuplicatePolicy(ctx context.Context, id1 string, id2 int64, sensorType, policy string) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	var value any
+	if policy != "" {
+		value = policy
+	}
+
+	const q = `
+		UPDATE sensors
+		SET duplicate_policy = ?
+		WHERE id1 = ? AND id2 = ? AND sensor_type = ?
+	`
+	res, err := r.DB.ExecContext(ctx, q, value, id1, id2, sensorType)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to update sensor duplicate policy")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 func (r *SensorRepository) FindSensorRecordsByIdCombination(
 	ctx context.Context,
 	id1 string,
@@ -103,7 +265,7 @@ func (r *SensorRepository) FindSensorRecordsByIdCombination(
 	s.Records = make([]entity.SensorRecord, 0, pageSize)
 	for rows.Next() {
 		var rec entity.SensorRecord
-		if err := rows.Scan(&rec.RecordID, &rec.SensorID, &rec.SensorValue, &rec.Timestamp, &s.ID1, &s.ID2, &s.SensorType); err != nil {
+		if err := rows.Scan(&rec.RecordID, &rec.SensorID, &rec.SensorValue, &rec.Timestamp, &s.ID1, &s.ID2, &rec.Sensor.SensorType); err != nil {
 			r.Log.WithError(err).Error("failed to scan sensor record row")
 			return nil, nil, err
 		}
@@ -114,6 +276,7 @@ func (r *SensorRepository) FindSensorRecordsByIdCombination(
 		r.Log.WithError(err).Error("row iteration error for sensor records")
 		return nil, nil, err
 	}
+	fillDeviceRecords(&s)
 
 	// Count total record
 	const qCount = `
@@ -228,7 +391,7 @@ func (r *SensorRepository) FindSensorRecordsByIdAndTimeRange(
 	s.Records = make([]entity.SensorRecord, 0, pageSize)
 	for result.Next() {
 		var rec entity.SensorRecord
-		err := result.Scan(&rec.RecordID, &rec.SensorID, &rec.SensorValue, &rec.Timestamp, &s.ID1, &s.ID2, &s.SensorType)
+		err := result.Scan(&rec.RecordID, &rec.SensorID, &rec.SensorValue, &rec.Timestamp, &s.ID1, &s.ID2, &rec.Sensor.SensorType)
 		if err != nil {
 			r.Log.WithError(err).Error("failed to scan id+time range row")
 			return nil, nil, err
@@ -240,6 +403,7 @@ func (r *SensorRepository) FindSensorRecordsByIdAndTimeRange(
 		r.Log.WithError(err).Error("row iteration error for id+time range")
 		return nil, nil, err
 	}
+	fillDeviceRecords(&s)
 
 	// Count total record
 	const qCount = `
@@ -259,6 +423,86 @@ func (r *SensorRepository) FindSensorRecordsByIdAndTimeRange(
 	return &s, pageMeta(page, pageSize, total), nil
 }
 
+// fillDeviceRecords completes the sensor of each record of a device search.
+// A device has one sensor per type, s keeps the type only when every record
+// shares it.
+func fillDeviceRecords(s *entity.Sensor) {
+	for i := range s.Records {
+		rec := &s.Records[i]
+		rec.Sensor.SensorID = rec.SensorID
+		rec.Sensor.ID1 = s.ID1
+		rec.Sensor.ID2 = s.ID2
+		if i == 0 {
+			s.SensorType = rec.Sensor.SensorType
+		} else if s.SensorType != rec.Sensor.SensorType {
+			s.SensorType = ""
+		}
+	}
+}
+
+// FindSensorRecordsBySensorID returns the records of a single sensor, the
+// device search narrowed to one sensor type, optionally only those between
+// startTime and endTime when both are set
+func (r *SensorRepository) FindSensorRecordsBySensorID(
+	ctx context.Context,
+	sensorID int64,
+	startTime, endTime time.Time,
+	page, pageSize int,
+) ([]entity.SensorRecord, *model.PageMetadata, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	where := `WHERE sensor_id = ?`
+	args := []any{sensorID}
+	if !startTime.IsZero() && !endTime.IsZero() {
+		where += ` AND timestamp BETWEEN ? AND ?`
+		args = append(args, startTime, endTime)
+	}
+	offset := (page - 1) * pageSize
+
+	q := `SELECT record_id, sensor_id, sensor_value, timestamp FROM sensor_records ` + where + ` ORDER BY timestamp ASC LIMIT ? OFFSET ?`
+	rows, err := r.DB.QueryContext(ctx, q, append(args, pageSize, offset)...)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to retrieve sensor records by sensor")
+		return nil, nil, err
+	}
+	out, err := r.scanSensorRecords(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Count total record
+	var total int64
+	err = r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM sensor_records `+where, args...).Scan(&total)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to count sensor records by sensor")
+		return nil, nil, err
+	}
+
+	return out, pageMeta(page, pageSize, total), nil
+}
+
+// scanSensorRecords reads and closes rows of record_id, sensor_id,
+// sensor_value and timestamp
+func (r *SensorRepository) scanSensorRecords(rows *sql.Rows) ([]entity.SensorRecord, error) {
+	defer rows.Close()
+
+	out := make([]entity.SensorRecord, 0)
+	for rows.Next() {
+		var rec entity.SensorRecord
+		if err := rows.Scan(&rec.RecordID, &rec.SensorID, &rec.SensorValue, &rec.Timestamp); err != nil {
+			r.Log.WithError(err).Error("failed to scan sensor record row")
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	if err := rows.Err(); err != nil {
+		r.Log.WithError(err).Error("row iteration error for sensor records")
+		return nil, err
+	}
+	return out, nil
+}
+
 func (r *SensorRepository) DeleteRecordsByIdCombination(
 	ctx context.Context,
 	id1 string,
@@ -340,6 +584,35 @@ func (r *SensorRepository) DeleteRecordsByIdAndTimeRange(
 	return affected, nil
 }
 
+// DeleteRecordsBySensorID deletes the records of a single sensor, optionally
+// only those between startTime and endTime when both are set
+func (r *SensorRepository) DeleteRecordsBySensorID(
+	ctx context.Context,
+	sensorID int64,
+	startTime, endTime time.Time,
+) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	q := `DELETE FROM sensor_records WHERE sensor_id = ?`
+	args := []any{sensorID}
+	if !startTime.IsZero() && !endTime.IsZero() {
+		q += ` AND timestamp BETWEEN ? AND ?`
+		args = append(args, startTime, endTime)
+	}
+	res, err := r.DB.ExecContext(ctx, q, args...)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to delete records by sensor")
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
 func (r *SensorRepository) UpdateSensorValuesByIdCombination(
 	ctx context.Context,
 	id1 string,
@@ -425,3 +698,35 @@ func (r *SensorRepository) UpdateSensorValueByIdAndTimeRange(
 	}
 	return affected, nil
 }
+
+// UpdateSensorValuesBySensorID sets the value of the records of a single
+// sensor, optionally only those between startTime and endTime when both are
+// set
+func (r *SensorRepository) UpdateSensorValuesBySensorID(
+	ctx context.Context,
+	sensorID int64,
+	startTime, endTime time.Time,
+	newValue float64,
+) (int64, error) {
+	ctx, cancel := ctxWithTimeout(ctx)
+	defer cancel()
+
+	q := `UPDATE sensor_records SET sensor_value = ? WHERE sensor_id = ?`
+	args := []any{newValue, sensorID}
+	if !startTime.IsZero() && !endTime.IsZero() {
+		q += ` AND timestamp BETWEEN ? AND ?`
+		args = append(args, startTime, endTime)
+	}
+	res, err := r.DB.ExecContext(ctx, q, args...)
+	if err != nil {
+		r.Log.WithError(err).Error("failed to update sensor values by sensor")
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		r.Log.WithError(err).Error("failed to get number of rows affected after update")
+		return 0, err
+	}
+	return affected, nil
+}
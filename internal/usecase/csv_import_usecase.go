@@ -0,0 +1,320 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"iot-server/internal/codec"
+	"iot-server/internal/model"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Statuses of a CSV import job
+const (
+	CSVImportRunning   = "running"
+	CSVImportCompleted = "completed"
+	CSVImportFailed    = "failed"
+	CSVImportCancelled = "cancelled"
+)
+
+const (
+	// csvImportTTL is how long the progress of a job is kept in Redis
+	csvImportTTL = 7 * 24 * time.Hour
+	// maxCSVImportErrors bounds the failed rows listed in a job
+	maxCSVImportErrors = 100
+)
+
+// CSVImportUsecase imports historical readings from CSV files in background
+// jobs. Rows are stored in chunks through SensorUsecase.CreateBatch and the
+// progress of every job is kept in Redis.
+type CSVImportUsecase struct {
+	Log           *logrus.Logger
+	Validate      *validator.Validate
+	Redis         *redis.Client
+	SensorUsecase *SensorUsecase
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewCSVImportUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	redis *redis.Client,
+	sensorUsecase *SensorUsecase,
+) *CSVImportUsecase {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CSVImportUsecase{
+		Log:           logger,
+		Validate:      validate,
+		Redis:         redis,
+		SensorUsecase: sensorUsecase,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// Start checks the header of the file against the column mapping and imports
+// the rows in the background. A temporary file is removed once imported.
+func (u *CSVImportUsecase) Start(ctx context.Context, request *model.CSVImportRequest) (*model.CSVImportJobResponse, error) {
+	started := false
+	defer func() {
+		if !started && request.Temporary {
+			_ = os.Remove(request.File)
+		}
+	}()
+
+	if err := u.Validate.Struct(request); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	options, err := csvOptions(request)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	file, err := os.Open(request.File)
+	if err != nil {
+		u.Log.WithError(err).Error("failed to open csv file")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "failed to open csv file")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		u.Log.WithError(err).Error("failed to stat csv file")
+		return nil, echo.ErrInternalServerError
+	}
+	reader, err := codec.NewCSVReader(file, options)
+	if err != nil {
+		_ = file.Close()
+		u.Log.WithError(err).Warn("invalid csv file")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	job := &model.CSVImportJobResponse{
+		ID:        newJobID(),
+		Status:    CSVImportRunning,
+		FileName:  request.FileName,
+		Size:      info.Size(),
+		BytesRead: reader.Offset(),
+		StartedAt: time.Now().UTC(),
+	}
+	if err := u.save(ctx, job); err != nil {
+		_ = file.Close()
+		return nil, echo.ErrInternalServerError
+	}
+
+	// The job keeps changing, hand out a copy
+	response := *job
+	started = true
+	u.wg.Add(1)
+	go u.run(job, file, reader, request.Temporary)
+
+	u.Log.WithField("job", job.ID).WithField("file", job.FileName).Info("csv import started")
+	return &response, nil
+}
+
+// Get returns the progress of a job
+func (u *CSVImportUsecase) Get(ctx context.Context, request *model.CSVImportGetRequest) (*model.CSVImportJobResponse, error) {
+	if err := u.Validate.Struct(request); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.ErrBadRequest
+	}
+
+	raw, err := u.Redis.Get(ctx, csvImportKey(request.ID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "import job not found")
+	}
+	if err != nil {
+		u.Log.WithError(err).Error("failed to get import job")
+		return nil, echo.ErrInternalServerError
+	}
+
+	var job model.CSVImportJobResponse
+	if err := json.Unmarshal(raw, &job); err != nil {
+		u.Log.WithError(err).Error("failed to decode import job")
+		return nil, echo.ErrInternalServerError
+	}
+	return &job, nil
+}
+
+// Stop cancels the running jobs and waits until they have recorded how far
+// they got
+func (u *CSVImportUsecase) Stop(ctx context.Context) error {
+	u.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (u *CSVImportUsecase) run(job *model.CSVImportJobResponse, file *os.File, reader *codec.CSVReader, temporary bool) {
+	defer u.wg.Done()
+	defer func() {
+		_ = file.Close()
+		if temporary {
+			_ = os.Remove(file.Name())
+		}
+	}()
+	log := u.Log.WithField("job", job.ID)
+
+	requests := make([]*model.CreateSensorRequest, 0, bulkChunkSize)
+	lines := make([]int, 0, bulkChunkSize)
+	flush := func() error {
+		if len(requests) > 0 {
+			results, err := u.SensorUsecase.CreateBatch(u.ctx, requests)
+			if err != nil {
+				return err
+			}
+			for _, result := range results {
+				job.Rows++
+				switch {
+				case result.Err != nil:
+					rejectCSVRow(job, lines[result.Index], errorMessage(result.Err))
+				case result.Duplicate:
+					job.Duplicates++
+				default:
+					job.Imported++
+				}
+			}
+			requests, lines = requests[:0], lines[:0]
+		}
+
+		job.BytesRead = reader.Offset()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return u.save(ctx, job)
+	}
+
+	err := func() error {
+		for u.ctx.Err() == nil {
+			reading, line, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return flush()
+			}
+			if err != nil {
+				return err
+			}
+
+			if reading.Err != nil {
+				job.Rows++
+				rejectCSVRow(job, line, reading.Err.Error())
+				continue
+			}
+			reading.Request.ReceivedAt = time.Now()
+			reading.Request.Historical = true
+			requests = append(requests, reading.Request)
+			lines = append(lines, line)
+
+			if len(requests) == bulkChunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return u.ctx.Err()
+	}()
+
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	switch {
+	case err == nil:
+		job.Status = CSVImportCompleted
+		log.WithField("rows", job.Rows).Info("csv import completed")
+	case u.ctx.Err() != nil:
+		job.Status = CSVImportCancelled
+		job.Error = "import cancelled on shutdown"
+		log.Warn("csv import cancelled")
+	default:
+		job.Status = CSVImportFailed
+		job.Error = errorMessage(err)
+		log.WithError(err).Error("csv import failed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = u.save(ctx, job)
+}
+
+func (u *CSVImportUsecase) save(ctx context.Context, job *model.CSVImportJobResponse) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		u.Log.WithError(err).Error("failed to encode import job")
+		return err
+	}
+	if err := u.Redis.Set(ctx, csvImportKey(job.ID), raw, csvImportTTL).Err(); err != nil {
+		u.Log.WithError(err).WithField("job", job.ID).Error("failed to save import job")
+		return err
+	}
+	return nil
+}
+
+// csvOptions converts the column mapping and the timestamp options of a request
+func csvOptions(request *model.CSVImportRequest) (codec.CSVOptions, error) {
+	options := codec.CSVOptions{
+		Columns: codec.CSVColumns{
+			ID1:         request.ID1Column,
+			ID2:         request.ID2Column,
+			SensorType:  request.SensorTypeColumn,
+			SensorValue: request.SensorValueColumn,
+			Timestamp:   request.TimestampColumn,
+		},
+		TimestampFormat: request.TimestampFormat,
+	}
+
+	if request.TimeZone != "" {
+		location, err := time.LoadLocation(request.TimeZone)
+		if err != nil {
+			return options, errors.New("unknown time zone " + request.TimeZone)
+		}
+		options.Location = location
+	}
+	if request.Delimiter != "" {
+		delimiter, _ := utf8.DecodeRuneInString(request.Delimiter)
+		if delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+			return options, errors.New("invalid delimiter")
+		}
+		options.Comma = delimiter
+	}
+	return options, nil
+}
+
+// rejectCSVRow counts a failed row and lists it while below maxCSVImportErrors
+func rejectCSVRow(job *model.CSVImportJobResponse, line int, message string) {
+	job.Failed++
+	if len(job.Errors) < maxCSVImportErrors {
+		job.Errors = append(job.Errors, model.CSVImportError{Line: line, Message: message})
+	}
+}
+
+func csvImportKey(id string) string {
+	return "csv-import:" + id
+}
+
+func newJobID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
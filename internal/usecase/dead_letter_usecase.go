@@ -0,0 +1,323 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"iot-server/internal/codec"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/repository"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// TopicFields fills the fields of a reading carried by the segments of the
+// topic it was received on
+type TopicFields interface {
+	ApplyTopic(topic string, request *model.CreateSensorRequest) error
+}
+
+type DeadLetterUsecase struct {
+	Log           *logrus.Logger
+	Validate      *validator.Validate
+	Repository    *repository.DeadLetterRepository
+	SensorUsecase *SensorUsecase
+	Codecs        *codec.Registry
+	Topics        TopicFields // optional, applied to the stored topic on replay
+}
+
+func NewDeadLetterUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	repo *repository.DeadLetterRepository,
+	sensorUsecase *SensorUsecase,
+	codecs *codec.Registry,
+) *DeadLetterUsecase {
+	return &DeadLetterUsecase{
+		Log:           logger,
+		Validate:      validate,
+		Repository:    repo,
+		SensorUsecase: sensorUsecase,
+		Codecs:        codecs,
+	}
+}
+
+// Store persists a rejected message. An empty content type means the payload
+// is decoded with the codec of its topic on replay.
+func (u *DeadLetterUsecase) Store(ctx context.Context, topic, contentType string, payload []byte, errorClass string, cause error, receivedAt time.Time) error {
+	deadLetter := &entity.DeadLetter{
+		Topic:        topic,
+		ContentType:  contentType,
+		Payload:      payload,
+		ErrorClass:   errorClass,
+		ErrorMessage: errorMessage(cause),
+		ReceivedAt:   receivedAt,
+	}
+	if err := u.Repository.Create(ctx, deadLetter); err != nil {
+		u.Log.WithError(err).WithField("topic", topic).Error("failed to store dead letter")
+		return err
+	}
+	return nil
+}
+
+func (u *DeadLetterUsecase) List(ctx context.Context, req *model.DeadLetterListRequest) ([]model.DeadLetterResponse, *model.PageMetadata, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deadLetters, meta, err := u.Repository.FindAll(ctx, req.ErrorClass, req.Page, req.PageSize)
+	if err != nil {
+		u.Log.WithError(err).Error("error getting dead letters")
+		return nil, nil, echo.ErrInternalServerError
+	}
+
+	return converter.DeadLettersToResponse(deadLetters), meta, nil
+}
+
+func (u *DeadLetterUsecase) Get(ctx context.Context, req *model.DeadLetterGetRequest) (*model.DeadLetterResponse, error) {
+	deadLetter, err := u.find(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return converter.DeadLetterToResponse(deadLetter), nil
+}
+
+// Update replaces the payload (and optionally the topic) of a dead letter so
+// it can be fixed before a replay
+func (u *DeadLetterUsecase) Update(ctx context.Context, req *model.DeadLetterUpdateRequest) (*model.DeadLetterResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deadLetter, err := u.find(ctx, &model.DeadLetterGetRequest{ID: req.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	payload := []byte(req.Payload)
+	if req.PayloadEncoding == "base64" {
+		payload, err = base64.StdEncoding.DecodeString(req.Payload)
+		if err != nil {
+			u.Log.WithError(err).Warn("failed to decode base64 payload")
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "payload is not valid base64")
+		}
+	}
+	if req.ContentType != "" {
+		c, ok := u.Codecs.Lookup(req.ContentType)
+		if !ok {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "unsupported content type")
+		}
+		deadLetter.ContentType = codec.ContentType(c)
+	}
+	if req.Topic != "" {
+		deadLetter.Topic = req.Topic
+	}
+	deadLetter.Payload = payload
+
+	if _, err := u.Repository.UpdatePayload(ctx, deadLetter.DeadLetterID, deadLetter.Topic, deadLetter.ContentType, deadLetter.Payload); err != nil {
+		u.Log.WithError(err).Error("error updating dead letter")
+		return nil, echo.ErrInternalServerError
+	}
+
+	return converter.DeadLetterToResponse(deadLetter), nil
+}
+
+// Replay decodes the payload of a dead letter with its codec, fills the fields
+// carried by its stored topic and feeds it through SensorUsecase. Readings
+// that are stored are removed from the dead letter, which is deleted once
+// nothing is left to replay. The remaining readings are kept as JSON.
+func (u *DeadLetterUsecase) Replay(ctx context.Context, req *model.DeadLetterGetRequest) (*model.DeadLetterReplayResponse, error) {
+	deadLetter, err := u.find(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	isArray, requests, err := u.decodeSensorRequests(deadLetter)
+	if err != nil {
+		u.Log.WithError(err).WithField("id", deadLetter.DeadLetterID).Warn("failed to decode dead letter payload")
+		deadLetter.ErrorClass = entity.DeadLetterInvalidPayload
+		deadLetter.ErrorMessage = err.Error()
+		if err := u.Repository.UpdateReplayFailure(ctx, deadLetter, time.Now()); err != nil {
+			return nil, echo.ErrInternalServerError
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "dead letter payload cannot be decoded")
+	}
+
+	// Missing or out-of-range timestamps fall back to the original receive time
+	for _, request := range requests {
+		request.ReceivedAt = deadLetter.ReceivedAt
+		if u.Topics == nil {
+			continue
+		}
+		if err := u.Topics.ApplyTopic(deadLetter.Topic, request); err != nil {
+			u.Log.WithError(err).WithField("id", deadLetter.DeadLetterID).Warn("failed to apply dead letter topic")
+			deadLetter.ErrorClass = entity.DeadLetterInvalidTopic
+			deadLetter.ErrorMessage = err.Error()
+			if err := u.Repository.UpdateReplayFailure(ctx, deadLetter, time.Now()); err != nil {
+				return nil, echo.ErrInternalServerError
+			}
+			return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	results, err := u.SensorUsecase.CreateBatch(ctx, requests)
+	if err != nil {
+		deadLetter.ErrorClass = entity.DeadLetterDatabase
+		deadLetter.ErrorMessage = errorMessage(err)
+		if err := u.Repository.UpdateReplayFailure(ctx, deadLetter, time.Now()); err != nil {
+			return nil, echo.ErrInternalServerError
+		}
+		return nil, err
+	}
+
+	failed := make([]*model.CreateSensorRequest, 0)
+	messages := make([]string, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, requests[result.Index])
+			messages = append(messages, errorMessage(result.Err))
+		}
+	}
+
+	response := &model.DeadLetterReplayResponse{
+		Replayed: len(requests) - len(failed),
+		Failed:   len(failed),
+	}
+
+	if len(failed) == 0 {
+		if _, err := u.Repository.Delete(ctx, deadLetter.DeadLetterID); err != nil {
+			u.Log.WithError(err).Error("error deleting replayed dead letter")
+			return nil, echo.ErrInternalServerError
+		}
+		return response, nil
+	}
+
+	// Keep only the readings that are still rejected
+	var remaining any = failed
+	if !isArray {
+		remaining = failed[0]
+	}
+	deadLetter.Payload, err = json.Marshal(remaining)
+	if err != nil {
+		u.Log.WithError(err).Error("failed to encode remaining dead letter payload")
+		return nil, echo.ErrInternalServerError
+	}
+	deadLetter.ContentType = codec.ContentTypeJSON
+	deadLetter.ErrorClass = entity.DeadLetterValidation
+	deadLetter.ErrorMessage = strings.Join(messages, "; ")
+	if err := u.Repository.UpdateReplayFailure(ctx, deadLetter, time.Now()); err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+
+	response.DeadLetter = converter.DeadLetterToResponse(deadLetter)
+	return response, nil
+}
+
+func (u *DeadLetterUsecase) Delete(ctx context.Context, req *model.DeadLetterGetRequest) (*model.DeadLetterPurgeResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deleted, err := u.Repository.Delete(ctx, req.ID)
+	if err != nil {
+		u.Log.WithError(err).Error("error deleting dead letter")
+		return nil, echo.ErrInternalServerError
+	}
+	if deleted == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+	}
+
+	return &model.DeadLetterPurgeResponse{Deleted: deleted}, nil
+}
+
+func (u *DeadLetterUsecase) Purge(ctx context.Context, req *model.DeadLetterPurgeRequest) (*model.DeadLetterPurgeResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	before := req.Before
+	if before.IsZero() {
+		before = time.Now()
+	}
+
+	deleted, err := u.Repository.Purge(ctx, req.ErrorClass, before)
+	if err != nil {
+		u.Log.WithError(err).Error("error purging dead letters")
+		return nil, echo.ErrInternalServerError
+	}
+
+	return &model.DeadLetterPurgeResponse{Deleted: deleted}, nil
+}
+
+func (u *DeadLetterUsecase) find(ctx context.Context, req *model.DeadLetterGetRequest) (*entity.DeadLetter, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deadLetter, err := u.Repository.FindByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "dead letter not found")
+		}
+		u.Log.WithError(err).Error("error getting dead letter")
+		return nil, echo.ErrInternalServerError
+	}
+	return deadLetter, nil
+}
+
+// decodeSensorRequests decodes a single reading or a list of readings with
+// the codec the dead letter was received with
+func (u *DeadLetterUsecase) decodeSensorRequests(deadLetter *entity.DeadLetter) (bool, []*model.CreateSensorRequest, error) {
+	c, err := u.Codecs.Resolve(deadLetter.Topic, deadLetter.ContentType)
+	if err != nil {
+		return false, nil, err
+	}
+
+	readings, isArray, err := c.Decode(deadLetter.Payload)
+	if err != nil {
+		return isArray, nil, err
+	}
+
+	requests := make([]*model.CreateSensorRequest, len(readings))
+	for i, reading := range readings {
+		if reading.Err != nil {
+			return isArray, nil, reading.Err
+		}
+		requests[i] = reading.Request
+	}
+	return isArray, requests, nil
+}
+
+// errorMessage returns the message of an echo HTTP error followed by the
+// internal error it wraps, if any, or else the error text
+func errorMessage(err error) string {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		if msg, ok := httpErr.Message.(string); ok {
+			if httpErr.Internal != nil {
+				return msg + ": " + httpErr.Internal.Error()
+			}
+			return msg
+		}
+	}
+	return err.Error()
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/repository"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceCommandUsecase sends commands to devices and tracks them until the
+// device acknowledges them or they expire. Expired commands are timed out by
+// a background sweep every SweepInterval.
+type DeviceCommandUsecase struct {
+	Log              *logrus.Logger
+	Validate         *validator.Validate
+	Repository       *repository.DeviceCommandRepository
+	SensorRepository *repository.SensorRepository
+	Producer         *messaging.CommandProducer
+	DefaultTimeout   time.Duration
+	SweepInterval    time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewDeviceCommandUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	repo *repository.DeviceCommandRepository,
+	sensorRepository *repository.SensorRepository,
+	producer *messaging.CommandProducer,
+	defaultTimeout time.Duration,
+	sweepInterval time.Duration,
+) *DeviceCommandUsecase {
+	if defaultTimeout <= 0 {
+		defaultTimeout = time.Minute
+	}
+	if sweepInterval <= 0 {
+		sweepInterval = 10 * time.Second
+	}
+
+	u := &DeviceCommandUsecase{
+		Log:              logger,
+		Validate:         validate,
+		Repository:       repo,
+		SensorRepository: sensorRepository,
+		Producer:         producer,
+		DefaultTimeout:   defaultTimeout,
+		SweepInterval:    sweepInterval,
+		stop:             make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+	go u.sweep()
+	return u
+}
+
+// Create stores a command and publishes it to a known device. A command the
+// broker does not accept is stored as failed and returned with its error. A
+// delivered command is only accepted by the broker, the device may not have
+// received it yet.
+func (u *DeviceCommandUsecase) Create(ctx context.Context, req *model.DeviceCommandCreateRequest) (*model.DeviceCommandResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.Params) > 0 && !json.Valid(req.Params) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "params is not valid JSON")
+	}
+
+	exists, err := u.SensorRepository.ExistsDevice(ctx, req.ID1, req.ID2)
+	if err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+	if !exists {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "device not found")
+	}
+
+	timeout := u.DefaultTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	now := time.Now().UTC()
+	command := &entity.DeviceCommand{
+		ID1:       req.ID1,
+		ID2:       req.ID2,
+		Name:      req.Name,
+		Status:    entity.CommandPending,
+		CreatedBy: req.CreatedBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(timeout),
+	}
+	if len(req.Params) > 0 {
+		command.Params = req.Params
+	}
+	if err := u.Repository.Create(ctx, command); err != nil {
+		u.Log.WithError(err).Error("error creating device command")
+		return nil, echo.ErrInternalServerError
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = u.Producer.Send(publishCtx, command.ID1, command.ID2, &model.DeviceCommandMessage{
+		CommandID: command.CommandID,
+		Name:      command.Name,
+		Params:    command.Params,
+		ExpiresAt: command.ExpiresAt,
+	})
+	if err != nil {
+		u.Log.WithError(err).WithField("command", command.CommandID).Warn("failed to publish device command")
+		completedAt := time.Now().UTC()
+		command.Status, command.ErrorMessage, command.CompletedAt = entity.CommandFailed, "publish failed: "+err.Error(), &completedAt
+		if _, err := u.Repository.Complete(ctx, command.CommandID, command.ID1, command.ID2, command.Status, nil, command.ErrorMessage, completedAt); err != nil {
+			return nil, echo.ErrInternalServerError
+		}
+		return converter.DeviceCommandToResponse(command), nil
+	}
+
+	deliveredAt := time.Now().UTC()
+	if _, err := u.Repository.MarkDelivered(ctx, command.CommandID, deliveredAt); err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+
+	// Re-read, the device may have answered already
+	return u.Get(ctx, &model.DeviceCommandGetRequest{ID: command.CommandID})
+}
+
+func (u *DeviceCommandUsecase) List(ctx context.Context, req *model.DeviceCommandListRequest) ([]model.DeviceCommandResponse, *model.PageMetadata, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	commands, meta, err := u.Repository.FindAll(ctx, req.ID1, req.ID2, req.Status, req.Page, req.PageSize)
+	if err != nil {
+		u.Log.WithError(err).Error("error getting device commands")
+		return nil, nil, echo.ErrInternalServerError
+	}
+
+	return converter.DeviceCommandsToResponse(commands), meta, nil
+}
+
+func (u *DeviceCommandUsecase) Get(ctx context.Context, req *model.DeviceCommandGetRequest) (*model.DeviceCommandResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.ErrBadRequest
+	}
+
+	command, err := u.Repository.FindByID(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "device command not found")
+		}
+		u.Log.WithError(err).Error("error getting device command")
+		return nil, echo.ErrInternalServerError
+	}
+	return converter.DeviceCommandToResponse(command), nil
+}
+
+// Ack records a device's answer. Answers to unknown, foreign, expired or
+// already completed commands are ignored.
+func (u *DeviceCommandUsecase) Ack(ctx context.Context, ack *model.DeviceCommandAck) error {
+	if err := u.Validate.Struct(ack); err != nil {
+		u.Log.WithError(err).Warn("invalid device command ack")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(ack.Result) > 0 && !json.Valid(ack.Result) {
+		return echo.NewHTTPError(http.StatusBadRequest, "result is not valid JSON")
+	}
+
+	status := entity.CommandAcked
+	if ack.Status == "error" {
+		status = entity.CommandFailed
+	}
+	var result []byte
+	if len(ack.Result) > 0 {
+		result = ack.Result
+	}
+
+	affected, err := u.Repository.Complete(ctx, ack.CommandID, ack.ID1, ack.ID2, status, result, ack.Error, time.Now().UTC())
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+	if affected == 0 {
+		u.Log.WithField("command", ack.CommandID).WithField("id1", ack.ID1).Warn("ignored ack of unknown, expired or completed command")
+		return nil
+	}
+
+	u.Log.WithField("command", ack.CommandID).WithField("status", status).Info("device command completed")
+	return nil
+}
+
+// Stop ends the timeout sweep
+func (u *DeviceCommandUsecase) Stop(ctx context.Context) error {
+	select {
+	case <-u.stop:
+	default:
+		close(u.stop)
+	}
+
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (u *DeviceCommandUsecase) sweep() {
+	defer close(u.done)
+
+	ticker := time.NewTicker(u.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			expired, err := u.Repository.ExpireOpen(context.Background(), time.Now().UTC())
+			if err != nil {
+				continue
+			}
+			if expired > 0 {
+				u.Log.WithField("count", expired).Info("device commands timed out")
+			}
+		case <-u.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/repository"
+	"iot-server/internal/util"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxShadowDocumentSize = 64 * 1024
+	// shadowUpdateAttempts bounds the retries of an unversioned update that
+	// keeps losing the race against concurrent updates
+	shadowUpdateAttempts = 3
+)
+
+// DeviceShadowUsecase keeps the desired and reported configuration of each
+// known device. Whenever the desired values the device has not reported yet
+// differ from the delta last sent, the new delta is published to the device.
+type DeviceShadowUsecase struct {
+	DB               *sql.DB
+	Log              *logrus.Logger
+	Validate         *validator.Validate
+	Repository       *repository.DeviceShadowRepository
+	SensorRepository *repository.SensorRepository
+	Producer         *messaging.ShadowProducer
+}
+
+func NewDeviceShadowUsecase(
+	db *sql.DB,
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	repo *repository.DeviceShadowRepository,
+	sensorRepository *repository.SensorRepository,
+	producer *messaging.ShadowProducer,
+) *DeviceShadowUsecase {
+	return &DeviceShadowUsecase{
+		DB:               db,
+		Log:              logger,
+		Validate:         validate,
+		Repository:       repo,
+		SensorRepository: sensorRepository,
+		Producer:         producer,
+	}
+}
+
+func (u *DeviceShadowUsecase) Get(ctx context.Context, req *model.DeviceShadowGetRequest) (*model.DeviceShadowResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	shadow, err := u.Repository.Find(ctx, req.ID1, req.ID2)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "device shadow not found")
+		}
+		u.Log.WithError(err).Error("error getting device shadow")
+		return nil, echo.ErrInternalServerError
+	}
+
+	desired, reported, err := decodeShadow(shadow)
+	if err != nil {
+		u.Log.WithError(err).Error("error decoding device shadow")
+		return nil, echo.ErrInternalServerError
+	}
+	return u.toResponse(shadow, util.JSONDelta(desired, reported))
+}
+
+// UpdateDesired merges a patch into the desired document
+func (u *DeviceShadowUsecase) UpdateDesired(ctx context.Context, req *model.DeviceShadowUpdateRequest) (*model.DeviceShadowResponse, error) {
+	return u.update(ctx, entity.ShadowDesired, req)
+}
+
+// UpdateReported merges a patch into the reported document
+func (u *DeviceShadowUsecase) UpdateReported(ctx context.Context, req *model.DeviceShadowUpdateRequest) (*model.DeviceShadowResponse, error) {
+	return u.update(ctx, entity.ShadowReported, req)
+}
+
+// update applies a merge patch with optimistic locking: the document is only
+// written if its version did not change since it was read. A request with a
+// version fails with 409 on a mismatch, one without is retried.
+func (u *DeviceShadowUsecase) update(ctx context.Context, document string, req *model.DeviceShadowUpdateRequest) (*model.DeviceShadowResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if len(req.State) > maxShadowDocumentSize {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "state exceeds 64 KiB")
+	}
+	var patch map[string]any
+	if err := json.Unmarshal(req.State, &patch); err != nil || patch == nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "state must be a JSON object")
+	}
+
+	exists, err := u.SensorRepository.ExistsDevice(ctx, req.ID1, req.ID2)
+	if err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+	if !exists {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "device not found")
+	}
+	if err := u.Repository.Ensure(ctx, req.ID1, req.ID2); err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+
+	for attempt := 0; attempt < shadowUpdateAttempts; attempt++ {
+		shadow, err := u.Repository.Find(ctx, req.ID1, req.ID2)
+		if err != nil {
+			u.Log.WithError(err).Error("error getting device shadow")
+			return nil, echo.ErrInternalServerError
+		}
+		desired, reported, err := decodeShadow(shadow)
+		if err != nil {
+			u.Log.WithError(err).Error("error decoding device shadow")
+			return nil, echo.ErrInternalServerError
+		}
+
+		target, version := desired, shadow.DesiredVersion
+		if document == entity.ShadowReported {
+			target, version = reported, shadow.ReportedVersion
+		}
+		if req.Version != nil && *req.Version != version {
+			return nil, echo.NewHTTPError(http.StatusConflict, "device shadow version conflict")
+		}
+
+		target = util.MergePatch(target, patch).(map[string]any)
+		value, err := json.Marshal(target)
+		if err != nil {
+			return nil, echo.ErrInternalServerError
+		}
+		if len(value) > maxShadowDocumentSize {
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, document+" document exceeds 64 KiB")
+		}
+
+		updatedAt := time.Now().UTC()
+		affected, err := u.Repository.UpdateDocument(ctx, req.ID1, req.ID2, document, value, version, updatedAt)
+		if err != nil {
+			return nil, echo.ErrInternalServerError
+		}
+		if affected == 0 {
+			if req.Version != nil {
+				return nil, echo.NewHTTPError(http.StatusConflict, "device shadow version conflict")
+			}
+			continue
+		}
+
+		if document == entity.ShadowDesired {
+			desired = target
+			shadow.Desired, shadow.DesiredVersion, shadow.DesiredUpdatedAt = value, version+1, &updatedAt
+		} else {
+			reported = target
+			shadow.Reported, shadow.ReportedVersion, shadow.ReportedUpdatedAt = value, version+1, &updatedAt
+		}
+
+		u.publish(ctx, req.ID1, req.ID2)
+		return u.toResponse(shadow, util.JSONDelta(desired, reported))
+	}
+
+	return nil, echo.NewHTTPError(http.StatusConflict, "device shadow is being updated concurrently")
+}
+
+// publish sends the delta of the stored documents unless it is the one last
+// sent. The shadow stays locked while publishing, so concurrent updates
+// publish in the order they were stored and skip a delta already superseded.
+// A failed publish is logged and keeps the last sent delta, so the next update
+// of either document publishes it again.
+func (u *DeviceShadowUsecase) publish(ctx context.Context, id1 string, id2 int64) {
+	if u.Producer == nil {
+		return
+	}
+	log := u.Log.WithField("id1", id1).WithField("id2", id2)
+
+	tx, err := u.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		log.WithError(err).Warn("failed to begin transaction")
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	shadow, err := u.Repository.FindForUpdateTx(ctx, tx, id1, id2)
+	if err != nil {
+		return
+	}
+	desired, reported, err := decodeShadow(shadow)
+	if err != nil {
+		log.WithError(err).Warn("error decoding device shadow")
+		return
+	}
+	delta := util.JSONDelta(desired, reported)
+	published := map[string]any{}
+	if len(shadow.PublishedDelta) > 0 {
+		if err := json.Unmarshal(shadow.PublishedDelta, &published); err != nil {
+			log.WithError(err).Warn("error decoding published shadow delta")
+		}
+	}
+	if reflect.DeepEqual(delta, published) {
+		return
+	}
+
+	var message *model.DeviceShadowDelta
+	if len(delta) > 0 {
+		message = &model.DeviceShadowDelta{
+			Version:   shadow.DesiredVersion,
+			State:     delta,
+			Timestamp: time.Now().UTC(),
+		}
+	}
+	value, err := json.Marshal(delta)
+	if err != nil {
+		return
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := u.Producer.Send(publishCtx, id1, id2, message); err != nil {
+		log.WithError(err).Warn("failed to publish shadow delta")
+		return
+	}
+	if err := u.Repository.UpdatePublishedDeltaTx(ctx, tx, id1, id2, value); err != nil {
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.WithError(err).Warn("failed to commit published shadow delta")
+	}
+}
+
+func (u *DeviceShadowUsecase) toResponse(shadow *entity.DeviceShadow, delta map[string]any) (*model.DeviceShadowResponse, error) {
+	value, err := json.Marshal(delta)
+	if err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+	return converter.DeviceShadowToResponse(shadow, value), nil
+}
+
+// decodeShadow returns both documents of a shadow, empty when not set yet
+func decodeShadow(shadow *entity.DeviceShadow) (desired, reported map[string]any, err error) {
+	desired, reported = map[string]any{}, map[string]any{}
+	if len(shadow.Desired) > 0 {
+		if err := json.Unmarshal(shadow.Desired, &desired); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(shadow.Reported) > 0 {
+		if err := json.Unmarshal(shadow.Reported, &reported); err != nil {
+			return nil, nil, err
+		}
+	}
+	return desired, reported, nil
+}
@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy decides what happens to a job when the ingest queue is full
+type OverflowPolicy string
+
+const (
+	OverflowBlock      OverflowPolicy = "block"
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	OverflowDeadLetter OverflowPolicy = "dead-letter"
+)
+
+var errQueueFull = errors.New("ingest queue is full")
+
+// IngestJob is a received message waiting for an ingestion worker
+type IngestJob struct {
+	Topic       string
+	ContentType string
+	Payload     []byte
+	ReceivedAt  time.Time
+	// Process ingests the message and calls done with whether it was stored
+	// or dead-lettered. done may be called after Process returned, e.g. once
+	// the write-behind batch holding the message is committed, which frees the
+	// worker for the next message.
+	Process func(done func(ok bool))
+	// Ack acknowledges the message to the broker
+	Ack func()
+
+	finished bool // set by ackOrder once the job was processed
+}
+
+// ackOrder sends acknowledgements in the order the messages arrived, as MQTT
+// requires of PUBACKs. A job finished ahead of an older one waits for it.
+type ackOrder struct {
+	mu      sync.Mutex
+	pending []*IngestJob
+	sending bool // a caller is sending acknowledgements
+}
+
+func (o *ackOrder) push(job *IngestJob) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(o.pending, job)
+}
+
+// done marks a job as finished and sends the acknowledgements no longer held
+// back. They are sent after unlocking, so a slow Ack does not block workers
+// finishing other jobs; the ready jobs are taken off the queue in one go, and
+// only one caller sends at a time to keep them in order.
+func (o *ackOrder) done(job *IngestJob) {
+	o.mu.Lock()
+	job.finished = true
+	if o.sending {
+		o.mu.Unlock()
+		return
+	}
+	o.sending = true
+	for {
+		var ready []*IngestJob
+		for len(o.pending) > 0 && o.pending[0].finished {
+			ready = append(ready, o.pending[0])
+			o.pending[0] = nil
+			o.pending = o.pending[1:]
+		}
+		if len(ready) == 0 {
+			o.sending = false
+			o.mu.Unlock()
+			return
+		}
+		o.mu.Unlock()
+
+		for _, job := range ready {
+			job.Ack()
+		}
+		o.mu.Lock()
+	}
+}
+
+// IngestPipeline decouples message delivery from persistence with a bounded
+// queue drained by a fixed number of workers
+type IngestPipeline struct {
+	Log        *logrus.Logger
+	DeadLetter *DeadLetterUsecase
+	Policy     OverflowPolicy
+
+	queue   chan *IngestJob
+	acks    ackOrder
+	workers int
+	wg      sync.WaitGroup
+	pending sync.WaitGroup // submitted jobs not finished yet
+	mu      sync.RWMutex
+	closed  bool
+
+	submitted atomic.Int64
+	processed atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+	spilled   atomic.Int64
+	refused   atomic.Int64 // submitted after Stop
+}
+
+func NewIngestPipeline(
+	logger *logrus.Logger,
+	deadLetter *DeadLetterUsecase,
+	queueSize int,
+	workers int,
+	policy OverflowPolicy,
+) *IngestPipeline {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	p := &IngestPipeline{
+		Log:        logger,
+		DeadLetter: deadLetter,
+		Policy:     policy,
+		queue:      make(chan *IngestJob, queueSize),
+		workers:    workers,
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues a job, applying the overflow policy when the queue is full
+func (p *IngestPipeline) Submit(job *IngestJob) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.refused.Add(1)
+		p.Log.WithField("topic", job.Topic).Warn("ingest pipeline stopped, message left for redelivery")
+		return
+	}
+	p.submitted.Add(1)
+	p.pending.Add(1)
+	p.acks.push(job)
+
+	switch p.Policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case p.queue <- job:
+				return
+			default:
+			}
+			select {
+			case oldest := <-p.queue:
+				p.drop(oldest)
+			default:
+			}
+		}
+	case OverflowDeadLetter:
+		select {
+		case p.queue <- job:
+		default:
+			p.spill(job)
+		}
+	default:
+		p.queue <- job
+	}
+}
+
+// Stop refuses new jobs and waits until the queued ones are finished
+func (p *IngestPipeline) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		p.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the queue depth and the job counters
+func (p *IngestPipeline) Stats() *model.IngestStatsResponse {
+	return &model.IngestStatsResponse{
+		QueueDepth:    len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Workers:       p.workers,
+		Policy:        string(p.Policy),
+		Submitted:     p.submitted.Load(),
+		Processed:     p.processed.Load(),
+		Failed:        p.failed.Load(),
+		Dropped:       p.dropped.Load(),
+		Spilled:       p.spilled.Load(),
+		Refused:       p.refused.Load(),
+	}
+}
+
+func (p *IngestPipeline) work() {
+	defer p.wg.Done()
+
+	for job := range p.queue {
+		job.Process(func(ok bool) {
+			if !ok {
+				p.failed.Add(1)
+			}
+			p.processed.Add(1)
+			p.finish(job)
+		})
+	}
+}
+
+// finish acknowledges a job in arrival order. A message that could neither be
+// stored nor dead-lettered is acknowledged as well: left in flight it would
+// hold back the acknowledgements of every later message.
+func (p *IngestPipeline) finish(job *IngestJob) {
+	p.acks.done(job)
+	p.pending.Done()
+}
+
+// drop discards a job. It is acknowledged so the broker does not keep it in flight.
+func (p *IngestPipeline) drop(job *IngestJob) {
+	p.dropped.Add(1)
+	p.Log.WithField("topic", job.Topic).Warn("ingest queue full, dropped oldest message")
+	p.finish(job)
+}
+
+// spill stores a job as a dead letter instead of queueing it
+func (p *IngestPipeline) spill(job *IngestJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.DeadLetter.Store(ctx, job.Topic, job.ContentType, job.Payload, entity.DeadLetterOverflow, errQueueFull, job.ReceivedAt); err != nil {
+		p.failed.Add(1)
+		p.finish(job)
+		return
+	}
+	p.spilled.Add(1)
+	p.finish(job)
+}
@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/repository"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSensorTypeLength is the size of sensors.sensor_type
+const maxSensorTypeLength = 50
+
+// LoRaWANUsecase stores the decoded uplinks of LoRaWAN network servers, one
+// reading per measurement, with the radio metadata alongside
+type LoRaWANUsecase struct {
+	Log           *logrus.Logger
+	Validate      *validator.Validate
+	SensorUsecase *SensorUsecase
+	Repository    *repository.LoRaWANUplinkRepository
+}
+
+func NewLoRaWANUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	sensorUsecase *SensorUsecase,
+	repo *repository.LoRaWANUplinkRepository,
+) *LoRaWANUsecase {
+	return &LoRaWANUsecase{
+		Log:           logger,
+		Validate:      validate,
+		SensorUsecase: sensorUsecase,
+		Repository:    repo,
+	}
+}
+
+// ChirpStackUplink stores an uplink event of the ChirpStack v4 HTTP integration
+func (u *LoRaWANUsecase) ChirpStackUplink(ctx context.Context, event *model.ChirpStackUplink) (*model.LoRaWANUplinkResponse, error) {
+	return u.Uplink(ctx, converter.ChirpStackToUplink(event))
+}
+
+// TTSUplink stores an uplink message of a The Things Stack v3 webhook
+func (u *LoRaWANUsecase) TTSUplink(ctx context.Context, event *model.TTSUplink) (*model.LoRaWANUplinkResponse, error) {
+	return u.Uplink(ctx, converter.TTSToUplink(event))
+}
+
+// Uplink stores the measurements of an uplink as readings of id1 (DevEUI)
+// and id2 (fPort). Measurements that are not numbers, or are rejected by the
+// sensor use case, are reported as failed.
+func (u *LoRaWANUsecase) Uplink(ctx context.Context, req *model.LoRaWANUplinkRequest) (*model.LoRaWANUplinkResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	response := &model.LoRaWANUplinkResponse{ID1: strings.ToUpper(req.DevEUI), ID2: req.FPort}
+	if req.FPort == 0 {
+		// MAC commands only, there is no application payload to store
+		return response, nil
+	}
+	if req.ReceivedAt.IsZero() {
+		req.ReceivedAt = time.Now()
+	}
+
+	values := make(map[string]float64)
+	flattenMeasurements("", req.Measurements, values, &response.Failed)
+	sort.Slice(response.Failed, func(i, j int) bool { return response.Failed[i].Measurement < response.Failed[j].Measurement })
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	requests := make([]*model.CreateSensorRequest, len(names))
+	for i, name := range names {
+		requests[i] = &model.CreateSensorRequest{
+			ID1:         response.ID1,
+			ID2:         response.ID2,
+			SensorType:  name,
+			SensorValue: values[name],
+			Timestamp:   req.ReceivedAt,
+			MessageID:   req.MessageID,
+		}
+	}
+
+	if len(requests) > 0 {
+		results, err := u.SensorUsecase.CreateBatch(ctx, requests)
+		if err != nil {
+			return nil, err
+		}
+		for i, result := range results {
+			switch {
+			case result.Err != nil:
+				response.Failed = append(response.Failed, model.LoRaWANMeasurementError{Measurement: names[i], Message: errorMessage(result.Err)})
+			case result.Duplicate:
+				response.Duplicates++
+			default:
+				response.Created++
+			}
+		}
+	}
+
+	created, err := u.Repository.Create(ctx, &entity.LoRaWANUplink{
+		ID1:        response.ID1,
+		ID2:        response.ID2,
+		Network:    req.Network,
+		MessageID:  req.MessageID,
+		FCnt:       req.FCnt,
+		RSSI:       req.RSSI,
+		SNR:        req.SNR,
+		GatewayID:  req.GatewayID,
+		Readings:   response.Created + response.Duplicates,
+		ReceivedAt: req.ReceivedAt,
+	})
+	if err != nil {
+		u.Log.WithError(err).Error("failed to store LoRaWAN uplink metadata")
+		return nil, echo.ErrInternalServerError
+	}
+	if !created {
+		u.Log.WithField("message_id", req.MessageID).Debug("LoRaWAN uplink redelivered")
+	}
+	return response, nil
+}
+
+// List returns the radio metadata of the uplinks of a device and port
+func (u *LoRaWANUsecase) List(ctx context.Context, req *model.LoRaWANUplinkListRequest) ([]model.LoRaWANUplinkMetadataResponse, *model.PageMetadata, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	uplinks, meta, err := u.Repository.FindAll(ctx, req.ID1, req.ID2, req.Page, req.PageSize)
+	if err != nil {
+		u.Log.WithError(err).Error("error getting LoRaWAN uplinks")
+		return nil, nil, echo.ErrInternalServerError
+	}
+
+	return converter.LoRaWANUplinksToResponse(uplinks), meta, nil
+}
+
+// flattenMeasurements collects the numbers and booleans of a decoded payload,
+// naming nested values by their path, e.g. "battery.voltage"
+func flattenMeasurements(prefix string, object map[string]any, values map[string]float64, failed *[]model.LoRaWANMeasurementError) {
+	for key, value := range object {
+		name := prefix + key
+		switch v := value.(type) {
+		case map[string]any:
+			flattenMeasurements(name+".", v, values, failed)
+			continue
+		case float64:
+			values[name] = v
+		case bool:
+			values[name] = 0
+			if v {
+				values[name] = 1
+			}
+		default:
+			*failed = append(*failed, model.LoRaWANMeasurementError{Measurement: name, Message: "not a number"})
+			continue
+		}
+
+		if len(name) > maxSensorTypeLength {
+			delete(values, name)
+			*failed = append(*failed, model.LoRaWANMeasurementError{
+				Measurement: name,
+				Message:     fmt.Sprintf("name longer than %d characters", maxSensorTypeLength),
+			})
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// deviceStatusKey is a hash of the last announced DeviceStatus per id1/id2
+const deviceStatusKey = "device-status"
+
+// PresenceUsecase tells whether sensors are alive. A sensor is online while
+// it had a reading within Timeout, unless its device announced a status
+// after that reading, which then takes precedence.
+type PresenceUsecase struct {
+	Log              *logrus.Logger
+	Validate         *validator.Validate
+	Redis            *redis.Client
+	SensorRepository *repository.SensorRepository
+	Timeout          time.Duration
+}
+
+func NewPresenceUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	redis *redis.Client,
+	sensorRepository *repository.SensorRepository,
+	timeout time.Duration,
+) *PresenceUsecase {
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	return &PresenceUsecase{
+		Log:              logger,
+		Validate:         validate,
+		Redis:            redis,
+		SensorRepository: sensorRepository,
+		Timeout:          timeout,
+	}
+}
+
+// UpdateStatus stores a status announced by a device
+func (u *PresenceUsecase) UpdateStatus(ctx context.Context, req *model.DeviceStatusRequest) error {
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Warn("invalid device status")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Timestamp.IsZero() {
+		req.Timestamp = time.Now()
+	}
+
+	value, err := json.Marshal(entity.DeviceStatus{Status: req.Status, At: req.Timestamp.UTC()})
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+	field := deviceStatusField(req.ID1, req.ID2)
+	if req.Retained {
+		err = u.Redis.HSetNX(ctx, deviceStatusKey, field, value).Err()
+	} else {
+		err = u.Redis.HSet(ctx, deviceStatusKey, field, value).Err()
+	}
+	if err != nil {
+		u.Log.WithError(err).WithField("device", field).Error("failed to store device status")
+		return echo.ErrInternalServerError
+	}
+	return nil
+}
+
+func (u *PresenceUsecase) List(ctx context.Context, req *model.SensorPresenceListRequest) ([]model.SensorPresenceResponse, *model.PageMetadata, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	now := time.Now()
+
+	// Silent sensors are all but those seen since the cutoff
+	var exclude []int64
+	if req.SilentMinutes > 0 {
+		cutoff := now.Add(-time.Duration(req.SilentMinutes) * time.Minute).UnixMilli()
+		members, err := u.Redis.ZRangeByScore(ctx, sensorLastSeenKey, &redis.ZRangeBy{
+			Min: strconv.FormatInt(cutoff, 10),
+			Max: "+inf",
+		}).Result()
+		if err != nil {
+			u.Log.WithError(err).Error("failed to get recently seen sensors")
+			return nil, nil, echo.ErrInternalServerError
+		}
+		exclude = make([]int64, 0, len(members))
+		for _, member := range members {
+			if id, err := strconv.ParseInt(member, 10, 64); err == nil {
+				exclude = append(exclude, id)
+			}
+		}
+	}
+
+	sensors, meta, err := u.SensorRepository.FindAll(ctx, req.ID1, req.ID2, exclude, req.Page, req.PageSize)
+	if err != nil {
+		return nil, nil, echo.ErrInternalServerError
+	}
+	responses := make([]model.SensorPresenceResponse, 0, len(sensors))
+	if len(sensors) == 0 {
+		return responses, meta, nil
+	}
+
+	members := make([]string, len(sensors))
+	fields := make([]string, len(sensors))
+	for i, sensor := range sensors {
+		members[i] = strconv.FormatInt(sensor.SensorID, 10)
+		fields[i] = deviceStatusField(sensor.ID1, sensor.ID2)
+	}
+	scores, err := u.Redis.ZMScore(ctx, sensorLastSeenKey, members...).Result()
+	if err != nil {
+		u.Log.WithError(err).Error("failed to get sensor last-seen times")
+		return nil, nil, echo.ErrInternalServerError
+	}
+	statuses, err := u.Redis.HMGet(ctx, deviceStatusKey, fields...).Result()
+	if err != nil {
+		u.Log.WithError(err).Error("failed to get device statuses")
+		return nil, nil, echo.ErrInternalServerError
+	}
+
+	for i, sensor := range sensors {
+		response := model.SensorPresenceResponse{
+			ID1:        sensor.ID1,
+			ID2:        sensor.ID2,
+			SensorType: sensor.SensorType,
+			Status:     entity.PresenceUnknown,
+		}
+		if scores[i] > 0 {
+			lastSeen := time.UnixMilli(int64(scores[i])).UTC()
+			response.LastSeen = &lastSeen
+			response.Status = entity.PresenceOffline
+			if now.Sub(lastSeen) <= u.Timeout {
+				response.Status = entity.PresenceOnline
+			}
+		}
+		if raw, ok := statuses[i].(string); ok {
+			var status entity.DeviceStatus
+			if err := json.Unmarshal([]byte(raw), &status); err == nil {
+				response.DeviceStatus, response.DeviceStatusAt = status.Status, &status.At
+				if response.LastSeen == nil || !status.At.Before(*response.LastSeen) {
+					response.Status = status.Status
+				}
+			}
+		}
+		responses = append(responses, response)
+	}
+	return responses, meta, nil
+}
+
+func deviceStatusField(id1 string, id2 int64) string {
+	return id1 + "/" + strconv.FormatInt(id2, 10)
+}
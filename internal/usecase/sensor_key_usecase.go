@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/model/converter"
+	"iot-server/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Bounds of a pre-shared key, in bytes. Generated keys have the maximum length
+// most constrained DTLS stacks accept.
+const (
+	minSensorKeySize       = 16
+	maxSensorKeySize       = 64
+	generatedSensorKeySize = 32
+)
+
+// SensorKeyUsecase manages the pre-shared keys sensor devices authenticate
+// with on the CoAP endpoint
+type SensorKeyUsecase struct {
+	Log        *logrus.Logger
+	Validate   *validator.Validate
+	Repository *repository.SensorKeyRepository
+}
+
+func NewSensorKeyUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	repo *repository.SensorKeyRepository,
+) *SensorKeyUsecase {
+	return &SensorKeyUsecase{
+		Log:        logger,
+		Validate:   validate,
+		Repository: repo,
+	}
+}
+
+// Set stores the key of a device, replacing its previous key
+func (u *SensorKeyUsecase) Set(ctx context.Context, req *model.SensorKeySetRequest) (*model.SensorKeyResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var psk []byte
+	if req.PSK == "" {
+		psk = make([]byte, generatedSensorKeySize)
+		if _, err := rand.Read(psk); err != nil {
+			u.Log.WithError(err).Error("failed to generate sensor key")
+			return nil, echo.ErrInternalServerError
+		}
+	} else {
+		var err error
+		psk, err = hex.DecodeString(req.PSK)
+		if err != nil || len(psk) < minSensorKeySize || len(psk) > maxSensorKeySize {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("psk has to be %d to %d hex encoded bytes", minSensorKeySize, maxSensorKeySize))
+		}
+	}
+
+	now := time.Now().UTC()
+	key := &entity.SensorKey{ID1: req.ID1, ID2: req.ID2, PSK: psk, CreatedAt: now, UpdatedAt: now}
+	if err := u.Repository.Upsert(ctx, key); err != nil {
+		return nil, echo.ErrInternalServerError
+	}
+
+	u.Log.WithField("device", SensorKeyIdentity(req.ID1, req.ID2)).Info("sensor key set")
+	return converter.SensorKeyToResponse(key, SensorKeyIdentity(key.ID1, key.ID2)), nil
+}
+
+func (u *SensorKeyUsecase) Delete(ctx context.Context, req *model.SensorKeyDeleteRequest) error {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deleted, err := u.Repository.Delete(ctx, req.ID1, req.ID2)
+	if err != nil {
+		return echo.ErrInternalServerError
+	}
+	if deleted == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "sensor key not found")
+	}
+	return nil
+}
+
+// Lookup returns the key of the device a DTLS PSK identity names
+func (u *SensorKeyUsecase) Lookup(ctx context.Context, identity string) ([]byte, error) {
+	id1, id2, err := ParseSensorKeyIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := u.Repository.Find(ctx, id1, id2)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("no key for identity %q", identity)
+		}
+		u.Log.WithError(err).Error("error getting sensor key")
+		return nil, err
+	}
+	return key.PSK, nil
+}
+
+// SensorKeyIdentity returns the PSK identity of a device, "<id1>/<id2>"
+func SensorKeyIdentity(id1 string, id2 int64) string {
+	return id1 + "/" + strconv.FormatInt(id2, 10)
+}
+
+// ParseSensorKeyIdentity splits a PSK identity into id1 and id2
+func ParseSensorKeyIdentity(identity string) (string, int64, error) {
+	id1, raw, ok := strings.Cut(identity, "/")
+	if !ok || id1 == "" {
+		return "", 0, fmt.Errorf("invalid identity %q, expected <id1>/<id2>", identity)
+	}
+	id2, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid identity %q, id2 is not a number", identity)
+	}
+	return id1, id2, nil
+}
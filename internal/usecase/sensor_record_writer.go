@@ -0,0 +1,264 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/repository"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errWriterStopped = errors.New("sensor record writer stopped")
+
+// deadlockAttempts bounds how often a batch is written again after MySQL
+// rolled it back to resolve a deadlock
+const deadlockAttempts = 3
+
+type pendingRecord struct {
+	record *entity.SensorRecord
+	done   func(writeResult)
+}
+
+type writeResult struct {
+	duplicate bool
+	err       error
+}
+
+// SensorRecordWriter buffers sensor records and inserts them with multi-row
+// INSERTs once BatchSize records are pending or FlushInterval has elapsed.
+// Enqueue returns at once and reports the outcome through a callback once the
+// batch is committed, so a batch fills up however few callers there are and
+// callers still acknowledge only after the commit. Write waits for it.
+type SensorRecordWriter struct {
+	DB            *sql.DB
+	Log           *logrus.Logger
+	Repository    *repository.SensorRecordRepository
+	BatchSize     int
+	FlushInterval time.Duration
+
+	input     chan *pendingRecord
+	stop      chan struct{}
+	done      chan struct{}
+	callbacks sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+}
+
+func NewSensorRecordWriter(
+	db *sql.DB,
+	logger *logrus.Logger,
+	repo *repository.SensorRecordRepository,
+	batchSize int,
+	flushInterval time.Duration,
+) *SensorRecordWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = 50 * time.Millisecond
+	}
+
+	w := &SensorRecordWriter{
+		DB:            db,
+		Log:           logger,
+		Repository:    repo,
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		input:         make(chan *pendingRecord, batchSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Write queues a record and waits until the batch holding it is committed.
+// It reports whether the record was skipped as a duplicate.
+func (w *SensorRecordWriter) Write(ctx context.Context, record *entity.SensorRecord) (bool, error) {
+	results := make(chan writeResult, 1)
+	err := w.Enqueue(ctx, record, func(duplicate bool, err error) {
+		results <- writeResult{duplicate: duplicate, err: err}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	select {
+	case result := <-results:
+		return result.duplicate, result.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Enqueue queues a record and returns once it is buffered, blocking only
+// while the buffer is full. done is called once the batch holding the record
+// is committed or failed, with whether the record was skipped as a duplicate.
+// It is not called when Enqueue returns an error.
+func (w *SensorRecordWriter) Enqueue(ctx context.Context, record *entity.SensorRecord, done func(duplicate bool, err error)) error {
+	pending := &pendingRecord{record: record, done: func(result writeResult) {
+		done(result.duplicate, result.err)
+	}}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return errWriterStopped
+	}
+	select {
+	case w.input <- pending:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop refuses new records, flushes the buffered ones and waits for the flush
+func (w *SensorRecordWriter) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		<-w.done
+		w.callbacks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *SensorRecordWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.FlushInterval)
+	defer ticker.Stop()
+
+	buffer := make([]*pendingRecord, 0, w.BatchSize)
+	for {
+		select {
+		case pending := <-w.input:
+			buffer = append(buffer, pending)
+			if len(buffer) >= w.BatchSize {
+				buffer = w.flush(buffer)
+			}
+		case <-ticker.C:
+			buffer = w.flush(buffer)
+		case <-w.stop:
+			// No Write can enqueue anymore, drain what is left
+			for {
+				select {
+				case pending := <-w.input:
+					buffer = append(buffer, pending)
+				default:
+					w.flush(buffer)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush commits the buffered records and returns a new buffer. The callers
+// are notified on their own goroutine so the next batch is not held up.
+func (w *SensorRecordWriter) flush(buffer []*pendingRecord) []*pendingRecord {
+	if len(buffer) == 0 {
+		return buffer
+	}
+
+	results := w.commit(buffer)
+	w.callbacks.Add(1)
+	go func() {
+		defer w.callbacks.Done()
+		for i, pending := range buffer {
+			pending.done(results[i])
+		}
+	}()
+	return make([]*pendingRecord, 0, w.BatchSize)
+}
+
+// commit inserts the buffered records and returns the result of each
+func (w *SensorRecordWriter) commit(buffer []*pendingRecord) []writeResult {
+	records := make([]*entity.SensorRecord, len(buffer))
+	for i, pending := range buffer {
+		records[i] = pending.record
+	}
+
+	results := make([]writeResult, len(buffer))
+	var duplicates []bool
+	err := w.insert(func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		duplicates, err = w.Repository.UpsertBatchTx(ctx, tx, records)
+		return err
+	})
+	if err == nil {
+		for i := range results {
+			results[i].duplicate = duplicates[i]
+		}
+		return results
+	}
+
+	// One bad row fails the whole statement, retry row by row so only the
+	// offending records are reported
+	w.Log.WithError(err).WithField("size", len(buffer)).Warn("batch insert failed, retrying records one by one")
+	for i, record := range records {
+		results[i].err = w.insert(func(ctx context.Context, tx *sql.Tx) error {
+			duplicates, err := w.Repository.UpsertBatchTx(ctx, tx, []*entity.SensorRecord{record})
+			if err == nil {
+				results[i].duplicate = duplicates[0]
+			}
+			return err
+		})
+	}
+	return results
+}
+
+// insert runs fn in a transaction, again if the transaction deadlocked
+func (w *SensorRecordWriter) insert(fn func(ctx context.Context, tx *sql.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= deadlockAttempts; attempt++ {
+		err = w.insertOnce(fn)
+		if !repository.IsDeadlock(err) {
+			return err
+		}
+		w.Log.WithError(err).WithField("attempt", attempt).Warn("sensor record batch deadlocked")
+	}
+	return err
+}
+
+func (w *SensorRecordWriter) insertOnce(fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := w.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		w.Log.WithError(err).Error("failed to begin transaction")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		w.Log.WithError(err).Error("failed to commit transaction")
+		return err
+	}
+	return nil
+}
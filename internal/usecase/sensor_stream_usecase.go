@@ -0,0 +1,251 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// StreamDropPolicy decides what happens to a reading when the buffer of a
+// live stream client is full
+type StreamDropPolicy string
+
+const (
+	StreamDropOldest StreamDropPolicy = "drop-oldest"
+	StreamDropNewest StreamDropPolicy = "drop-newest"
+	StreamDisconnect StreamDropPolicy = "disconnect"
+)
+
+// SensorStream is a live stream client, receiving the committed readings
+// that match its filter through a bounded buffer
+type SensorStream struct {
+	filter  model.SensorStreamRequest
+	events  chan *model.SensorReadingEvent
+	closed  chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// Events returns the buffered readings
+func (s *SensorStream) Events() <-chan *model.SensorReadingEvent {
+	return s.events
+}
+
+// Closed is closed when the server ends the stream, on shutdown or when the
+// disconnect policy drops a slow client
+func (s *SensorStream) Closed() <-chan struct{} {
+	return s.closed
+}
+
+// TakeDropped returns the number of readings dropped since the last call
+func (s *SensorStream) TakeDropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *SensorStream) matches(event *model.SensorReadingEvent) bool {
+	return (s.filter.ID1 == "" || s.filter.ID1 == event.ID1) &&
+		(s.filter.ID2 == 0 || s.filter.ID2 == event.ID2) &&
+		(s.filter.SensorType == "" || s.filter.SensorType == event.SensorType)
+}
+
+func (s *SensorStream) close() {
+	s.once.Do(func() { close(s.closed) })
+}
+
+// SensorStreamUsecase fans the readings published on a Redis channel by any
+// replica out to the live stream clients connected to this one
+type SensorStreamUsecase struct {
+	Log        *logrus.Logger
+	Validate   *validator.Validate
+	TokenUtil  *util.TokenUtil
+	BufferSize int
+	Policy     StreamDropPolicy
+	TicketTTL  time.Duration
+
+	pubsub  *redis.PubSub
+	done    chan struct{}
+	mu      sync.Mutex
+	streams map[*SensorStream]struct{}
+}
+
+func NewSensorStreamUsecase(
+	logger *logrus.Logger,
+	validate *validator.Validate,
+	tokenUtil *util.TokenUtil,
+	redis *redis.Client,
+	channel string,
+	bufferSize int,
+	policy StreamDropPolicy,
+	ticketTTL time.Duration,
+) *SensorStreamUsecase {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	u := &SensorStreamUsecase{
+		Log:        logger,
+		Validate:   validate,
+		TokenUtil:  tokenUtil,
+		BufferSize: bufferSize,
+		Policy:     policy,
+		TicketTTL:  ticketTTL,
+		pubsub:     redis.Subscribe(context.Background(), channel),
+		done:       make(chan struct{}),
+		streams:    make(map[*SensorStream]struct{}),
+	}
+	go u.run()
+	return u
+}
+
+// Subscribe opens a stream of the readings matching the request
+func (u *SensorStreamUsecase) Subscribe(req *model.SensorStreamRequest) (*SensorStream, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	stream := &SensorStream{
+		filter: *req,
+		events: make(chan *model.SensorReadingEvent, u.BufferSize),
+		closed: make(chan struct{}),
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.streams == nil {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "live streams are stopped")
+	}
+	u.streams[stream] = struct{}{}
+	return stream, nil
+}
+
+// CreateTicket issues a single-use ticket that opens a stream as auth within
+// TicketTTL. Browsers cannot set the Authorization header on WebSocket and
+// EventSource requests, and a ticket in the URL is worthless once used.
+func (u *SensorStreamUsecase) CreateTicket(ctx context.Context, auth *model.Auth) (*model.SensorStreamTicketResponse, error) {
+	ticket, err := u.TokenUtil.CreateTicket(ctx, auth, u.TicketTTL)
+	if err != nil {
+		u.Log.WithError(err).Error("failed to create stream ticket")
+		return nil, echo.ErrInternalServerError
+	}
+	return &model.SensorStreamTicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: time.Now().Add(u.TicketTTL).UTC(),
+	}, nil
+}
+
+// RedeemTicket returns the user of a ticket, which cannot be used again
+func (u *SensorStreamUsecase) RedeemTicket(ctx context.Context, ticket string) (*model.Auth, error) {
+	auth, err := u.TokenUtil.RedeemTicket(ctx, ticket)
+	if err != nil {
+		u.Log.WithError(err).Warn("invalid stream ticket")
+		return nil, echo.ErrUnauthorized
+	}
+	return auth, nil
+}
+
+// Unsubscribe closes a stream once its client is gone
+func (u *SensorStreamUsecase) Unsubscribe(stream *SensorStream) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.streams, stream)
+	stream.close()
+}
+
+// Stop closes every stream and the Redis subscription
+func (u *SensorStreamUsecase) Stop(ctx context.Context) error {
+	u.mu.Lock()
+	for stream := range u.streams {
+		stream.close()
+	}
+	u.streams = nil
+	u.mu.Unlock()
+
+	if err := u.pubsub.Close(); err != nil {
+		return err
+	}
+	select {
+	case <-u.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run dispatches the published readings until the subscription is closed.
+// The channel of go-redis resubscribes by itself after a lost connection.
+func (u *SensorStreamUsecase) run() {
+	defer close(u.done)
+
+	for msg := range u.pubsub.Channel() {
+		var event model.SensorReadingEvent
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			u.Log.WithError(err).Warn("invalid sensor reading on stream channel")
+			continue
+		}
+		u.dispatch(&event)
+	}
+}
+
+// dispatch offers a reading to the matching streams. The lock is only held to
+// list them, so slow clients do not block Subscribe and Unsubscribe.
+func (u *SensorStreamUsecase) dispatch(event *model.SensorReadingEvent) {
+	u.mu.Lock()
+	matching := make([]*SensorStream, 0, len(u.streams))
+	for stream := range u.streams {
+		if stream.matches(event) {
+			matching = append(matching, stream)
+		}
+	}
+	u.mu.Unlock()
+
+	for _, stream := range matching {
+		if !u.offer(stream, event) {
+			u.Log.WithField("filter", stream.filter).Warn("live stream client too slow, disconnected")
+			u.Unsubscribe(stream)
+		}
+	}
+}
+
+// offer buffers a reading, applying the drop policy when the buffer is full.
+// It reports false when the stream has to be disconnected.
+func (u *SensorStreamUsecase) offer(stream *SensorStream, event *model.SensorReadingEvent) bool {
+	select {
+	case stream.events <- event:
+		return true
+	default:
+	}
+
+	switch u.Policy {
+	case StreamDisconnect:
+		return false
+	case StreamDropNewest:
+		stream.dropped.Add(1)
+		return true
+	default:
+		// The client may read concurrently, so the buffer may have room again
+		for {
+			select {
+			case stream.events <- event:
+				return true
+			default:
+			}
+			select {
+			case <-stream.events:
+				stream.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
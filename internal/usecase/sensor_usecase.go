@@ -1,16 +1,24 @@
 package usecase
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"iot-server/internal/codec"
 	"iot-server/internal/entity"
+	"iot-server/internal/gateway/messaging"
 	"iot-server/internal/model"
 	"iot-server/internal/model/converter"
 	"iot-server/internal/repository"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -19,6 +27,15 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// bulkChunkSize is the number of lines BulkCreate stores per transaction
+	bulkChunkSize = 500
+	// maxBulkLineSize bounds a single line of a bulk body
+	maxBulkLineSize = 64 * 1024
+	// maxBulkErrors bounds the rejected lines listed in a bulk summary
+	maxBulkErrors = 1000
+)
+
 type SensorUsecase struct {
 	DB               *sql.DB
 	Log              *logrus.Logger
@@ -26,6 +43,11 @@ type SensorUsecase struct {
 	Redis            *redis.Client
 	SensorRepository *repository.SensorRepository
 	SensorRecordRepo *repository.SensorRecordRepository
+	Writer           *SensorRecordWriter             // optional write-behind buffer for Create
+	Producer         *messaging.SensorProducer       // optional, republishes committed readings
+	Stream           *messaging.SensorStreamProducer // optional, feeds the live streams
+	DuplicatePolicy  string                          // default for sensors without their own policy
+	Timestamps       TimestampWindow
 }
 
 func NewSensorUsecase(
@@ -35,6 +57,11 @@ func NewSensorUsecase(
 	redis *redis.Client,
 	sensorRepository *repository.SensorRepository,
 	sensorRecordRepo *repository.SensorRecordRepository,
+	writer *SensorRecordWriter,
+	producer *messaging.SensorProducer,
+	stream *messaging.SensorStreamProducer,
+	duplicatePolicy string,
+	timestamps TimestampWindow,
 ) *SensorUsecase {
 	return &SensorUsecase{
 		DB:               db,
@@ -43,107 +70,680 @@ func NewSensorUsecase(
 		Redis:            redis,
 		SensorRepository: sensorRepository,
 		SensorRecordRepo: sensorRecordRepo,
+		Writer:           writer,
+		Producer:         producer,
+		Stream:           stream,
+		DuplicatePolicy:  duplicatePolicy,
+		Timestamps:       timestamps,
 	}
 }
 
 func (u *SensorUsecase) Create(ctx context.Context, request *model.CreateSensorRequest) (*model.SensorResponse, error) {
+	if err := u.prepare(ctx, request); err != nil {
+		return nil, err
+	}
+
+	if u.Writer != nil {
+		return u.createBuffered(ctx, request)
+	}
+
+	// begin tx
+	tx, err := u.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		u.Log.WithError(err).Error("failed to begin transaction")
+		return nil, internalError(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	sensor, cached, err := u.resolveSensor(ctx, tx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create initial sensor record
+	record := u.newRecord(sensor, request)
+	duplicates, err := u.SensorRecordRepo.UpsertBatchTx(ctx, tx, []*entity.SensorRecord{record})
+	if err != nil {
+		u.Log.WithError(err).Error("failed to create sensor record")
+		return nil, internalError(err)
+	}
+
+	// commit
+	if err := tx.Commit(); err != nil {
+		u.Log.WithError(err).Error("failed to commit transaction")
+		return nil, internalError(err)
+	}
+
+	// Populate/refresh cache
+	if !cached {
+		u.cacheSensor(ctx, sensor)
+	}
+	if !request.Historical {
+		u.markSeen(ctx, sensor.SensorID)
+	}
+	if !duplicates[0] {
+		u.publish(request, record)
+	}
+
+	return recordToResponse(sensor, record, duplicates[0]), nil
+}
+
+// CreateAsync stores a reading like Create and calls done with the outcome.
+// With the write-behind writer it returns once the reading is buffered, and
+// done is called after the batch holding it is committed, so callers that
+// acknowledge in done do not limit the size of a batch. Without the writer
+// done is called before CreateAsync returns.
+func (u *SensorUsecase) CreateAsync(ctx context.Context, request *model.CreateSensorRequest, done func(*model.SensorResponse, error)) {
+	if u.Writer == nil {
+		done(u.Create(ctx, request))
+		return
+	}
+
+	if err := u.prepare(ctx, request); err != nil {
+		done(nil, err)
+		return
+	}
+	if err := u.enqueue(ctx, request, done); err != nil {
+		done(nil, err)
+	}
+}
+
+// prepare validates a request and settles its timestamp
+func (u *SensorUsecase) prepare(ctx context.Context, request *model.CreateSensorRequest) error {
 	// validate
 	if err := u.Validate.Struct(request); err != nil {
 		u.Log.WithError(err).Error("failed to validate request body")
-		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	return u.applyTimestamp(ctx, request)
+}
 
-	var cachedSensorID int64
-	key := fmt.Sprintf("%v-%v-%v", request.ID1, request.ID2, request.SensorType)
-	if val, err := u.Redis.Get(ctx, key).Result(); err == nil && val != "" {
-		id, err := strconv.ParseInt(val, 10, 64)
+// createBuffered hands the record to the write-behind writer and returns
+// once the record is committed
+func (u *SensorUsecase) createBuffered(ctx context.Context, request *model.CreateSensorRequest) (*model.SensorResponse, error) {
+	type result struct {
+		response *model.SensorResponse
+		err      error
+	}
+	results := make(chan result, 1)
+	err := u.enqueue(ctx, request, func(response *model.SensorResponse, err error) {
+		results <- result{response, err}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-results:
+		return r.response, r.err
+	case <-ctx.Done():
+		return nil, internalError(ctx.Err())
+	}
+}
+
+// enqueue resolves the sensor in its own transaction and hands the record to
+// the write-behind writer. done is called once the record is committed, unless
+// enqueue returns an error.
+func (u *SensorUsecase) enqueue(ctx context.Context, request *model.CreateSensorRequest, done func(*model.SensorResponse, error)) error {
+	// begin tx
+	tx, err := u.DB.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		u.Log.WithError(err).Error("failed to begin transaction")
+		return internalError(err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	sensor, cached, err := u.resolveSensor(ctx, tx, request)
+	if err != nil {
+		return err
+	}
+
+	// commit the sensor, if it was created, before its records are written
+	if err := tx.Commit(); err != nil {
+		u.Log.WithError(err).Error("failed to commit transaction")
+		return internalError(err)
+	}
+
+	// The caller may be gone by the time the batch is committed
+	committed := context.WithoutCancel(ctx)
+	record := u.newRecord(sensor, request)
+	err = u.Writer.Enqueue(ctx, record, func(duplicate bool, err error) {
 		if err != nil {
-			u.Log.WithError(err).Error("failed to parse sensor id")
-			return nil, echo.ErrInternalServerError
+			u.Log.WithError(err).Error("failed to write sensor record")
+			done(nil, internalError(err))
+			return
+		}
+
+		if !cached {
+			u.cacheSensor(committed, sensor)
+		}
+		if !request.Historical {
+			u.markSeen(committed, sensor.SensorID)
+		}
+		if !duplicate {
+			u.publish(request, record)
+		}
+		done(recordToResponse(sensor, record, duplicate), nil)
+	})
+	if err != nil {
+		u.Log.WithError(err).Error("failed to queue sensor record")
+		return internalError(err)
+	}
+	return nil
+}
+
+// CreateBatch validates every request on its own and persists the valid ones
+// in a single transaction. Validation failures are reported per item in the
+// result, while a database failure aborts the whole batch.
+func (u *SensorUsecase) CreateBatch(ctx context.Context, requests []*model.CreateSensorRequest) ([]model.CreateSensorResult, error) {
+	results := make([]model.CreateSensorResult, len(requests))
+	valid := make([]int, 0, len(requests))
+	for i, request := range requests {
+		results[i].Index = i
+		if err := u.Validate.Struct(request); err != nil {
+			u.Log.WithError(err).WithField("index", i).Warn("failed to validate batch item")
+			results[i].Err = echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			continue
+		}
+		if err := u.applyTimestamp(ctx, request); err != nil {
+			results[i].Err = err
+			continue
 		}
-		cachedSensorID = id
+		valid = append(valid, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
 	}
 
 	// begin tx
 	tx, err := u.DB.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
 		u.Log.WithError(err).Error("failed to begin transaction")
-		return nil, echo.ErrInternalServerError
+		return nil, internalError(err)
 	}
 	defer func() {
 		_ = tx.Rollback()
 	}()
 
-	var sensor *entity.Sensor
+	// Resolve each distinct sensor once per batch
+	sensors := make(map[string]*entity.Sensor)
+	uncached := make([]*entity.Sensor, 0)
+	records := make([]*entity.SensorRecord, 0, len(valid))
+	seen := make([]int64, 0)
+	marked := make(map[int64]bool)
+	for _, i := range valid {
+		request := requests[i]
+		key := sensorCacheKey(request.ID1, request.ID2, request.SensorType)
+		sensor, ok := sensors[key]
+		if !ok {
+			var cached bool
+			sensor, cached, err = u.resolveSensor(ctx, tx, request)
+			if err != nil {
+				return nil, err
+			}
+			sensors[key] = sensor
+			if !cached {
+				uncached = append(uncached, sensor)
+			}
+		}
+		// A sensor counts as seen if any of its readings is live
+		if !request.Historical && !marked[sensor.SensorID] {
+			marked[sensor.SensorID] = true
+			seen = append(seen, sensor.SensorID)
+		}
 
-	// sensor found in redis
-	if cachedSensorID > 0 {
-		sensor = &entity.Sensor{
-			SensorID:   cachedSensorID,
-			ID1:        request.ID1,
-			ID2:        request.ID2,
-			SensorType: request.SensorType,
+		records = append(records, u.newRecord(sensor, request))
+	}
+
+	duplicates, err := u.SensorRecordRepo.UpsertBatchTx(ctx, tx, records)
+	if err != nil {
+		u.Log.WithError(err).Error("failed to create sensor records")
+		return nil, internalError(err)
+	}
+
+	// commit
+	if err := tx.Commit(); err != nil {
+		u.Log.WithError(err).Error("failed to commit transaction")
+		return nil, internalError(err)
+	}
+
+	for _, sensor := range uncached {
+		u.cacheSensor(ctx, sensor)
+	}
+	u.markSeen(ctx, seen...)
+
+	for n, i := range valid {
+		record := records[n]
+		results[i].Duplicate = duplicates[n]
+		results[i].Response = recordToResponse(&record.Sensor, record, duplicates[n])
+		if !duplicates[n] {
+			u.publish(requests[i], record)
+		}
+	}
+	return results, nil
+}
+
+// Ingest stores decoded readings as one batch and summarises the outcome.
+// Readings that could not be decoded are reported as failed.
+func (u *SensorUsecase) Ingest(ctx context.Context, readings []codec.Reading, receivedAt time.Time) (*model.SensorIngestResponse, error) {
+	response := &model.SensorIngestResponse{Received: len(readings)}
+
+	requests := make([]*model.CreateSensorRequest, 0, len(readings))
+	indexes := make([]int, 0, len(readings))
+	for i, reading := range readings {
+		if reading.Err != nil {
+			response.Failed = append(response.Failed, model.SensorIngestError{Index: i, Message: reading.Err.Error()})
+			continue
+		}
+		reading.Request.ReceivedAt = receivedAt
+		requests = append(requests, reading.Request)
+		indexes = append(indexes, i)
+	}
+
+	results, err := u.CreateBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			response.Failed = append(response.Failed, model.SensorIngestError{Index: indexes[result.Index], Message: errorMessage(result.Err)})
+		case result.Duplicate:
+			response.Duplicates++
+		default:
+			response.Created++
+		}
+	}
+	sort.Slice(response.Failed, func(i, j int) bool {
+		return response.Failed[i].Index < response.Failed[j].Index
+	})
+	return response, nil
+}
+
+// BulkCreate stores newline-delimited CreateSensorRequest objects while the
+// body is read, committing every bulkChunkSize lines in their own transaction.
+// Malformed or invalid lines are rejected by line number. A read or database
+// failure stops the import and is returned along with the summary so far;
+// chunks committed before it are kept. Lines are historical data, exempt from
+// the timestamp window and not republished.
+func (u *SensorUsecase) BulkCreate(ctx context.Context, body io.Reader, receivedAt time.Time) (*model.SensorBulkResponse, error) {
+	response := &model.SensorBulkResponse{}
+
+	requests := make([]*model.CreateSensorRequest, 0, bulkChunkSize)
+	lines := make([]int, 0, bulkChunkSize)
+	flush := func() error {
+		if len(requests) == 0 {
+			return nil
 		}
-	} else {
-		// try to find sensor in DB
-		sensor, err = u.SensorRepository.FindByUnique(ctx, request.ID1, request.ID2, request.SensorType)
+		results, err := u.CreateBatch(ctx, requests)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				// Create new sensor
-				sensor = &entity.Sensor{
-					ID1:        request.ID1,
-					ID2:        request.ID2,
-					SensorType: request.SensorType,
-				}
-				if err := u.SensorRepository.CreateTx(ctx, tx, sensor); err != nil {
-					u.Log.WithError(err).Error("failed to create sensor")
-					return nil, echo.ErrInternalServerError
-				}
-			} else {
-				u.Log.WithError(err).Error("failed to find sensor")
-				return nil, echo.ErrInternalServerError
+			return err
+		}
+		for _, result := range results {
+			switch {
+			case result.Err != nil:
+				rejectBulkLine(response, lines[result.Index], errorMessage(result.Err))
+			case result.Duplicate:
+				response.Accepted++
+				response.Duplicates++
+			default:
+				response.Accepted++
+			}
+		}
+		requests, lines = requests[:0], lines[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxBulkLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		response.Lines++
+
+		request := new(model.CreateSensorRequest)
+		if err := json.Unmarshal(text, request); err != nil {
+			rejectBulkLine(response, line, err.Error())
+			continue
+		}
+		request.ReceivedAt = receivedAt
+		request.Historical = true
+		requests = append(requests, request)
+		lines = append(lines, line)
+
+		if len(requests) == bulkChunkSize {
+			if err := flush(); err != nil {
+				u.Log.WithError(err).WithField("line", line).Error("bulk create stopped")
+				return response, err
 			}
 		}
 	}
 
-	// Create initial sensor record
-	record := &entity.SensorRecord{
-		SensorID:    sensor.SensorID,
-		SensorValue: request.SensorValue,
-		Timestamp:   request.Timestamp,
+	// Lines read completely before a read error are still stored
+	if err := flush(); err != nil {
+		u.Log.WithError(err).WithField("line", line).Error("bulk create stopped")
+		return response, err
 	}
-	if err := u.SensorRecordRepo.CreateTx(ctx, tx, record); err != nil {
-		u.Log.WithError(err).Error("failed to create sensor record")
+	if err := scanner.Err(); err != nil {
+		u.Log.WithError(err).WithField("line", line+1).Warn("failed to read bulk body")
+		if errors.Is(err, bufio.ErrTooLong) {
+			return response, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d exceeds %d bytes", line+1, maxBulkLineSize))
+		}
+		return response, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("failed to read line %d", line+1))
+	}
+	return response, nil
+}
+
+// rejectBulkLine counts a rejected line and lists it while below maxBulkErrors
+func rejectBulkLine(response *model.SensorBulkResponse, line int, message string) {
+	response.Rejected++
+	if len(response.Errors) >= maxBulkErrors {
+		response.Truncated = true
+		return
+	}
+	response.Errors = append(response.Errors, model.SensorBulkError{Line: line, Message: message})
+}
+
+// UpdateDuplicatePolicy sets how duplicate readings of one sensor are stored
+func (u *SensorUsecase) UpdateDuplicatePolicy(ctx context.Context, req *model.SensorDuplicatePolicyRequest) (*model.SensorDuplicatePolicyResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := u.SensorRepository.FindByUnique(ctx, req.ID1, req.ID2, req.SensorType); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "sensor not found")
+		}
+		u.Log.WithError(err).Error("failed to find sensor")
 		return nil, echo.ErrInternalServerError
 	}
 
-	// commit
-	if err := tx.Commit(); err != nil {
-		u.Log.WithError(err).Error("failed to commit transaction")
+	if _, err := u.SensorRepository.UpdateDuplicatePolicy(ctx, req.ID1, req.ID2, req.SensorType, req.DuplicatePolicy); err != nil {
+		u.Log.WithError(err).Error("error when updating sensor duplicate policy")
 		return nil, echo.ErrInternalServerError
 	}
 
-	// Populate/refresh cache
-	if u.Redis != nil && sensor.SensorID > 0 && cachedSensorID == 0 {
-		ttl := 1 * time.Hour
-		if err := u.Redis.Set(ctx, key, strconv.FormatInt(sensor.SensorID, 10), ttl).Err(); err != nil {
-			u.Log.WithError(err).WithField("key", key).Warn("failed to set sensor cache")
+	// Drop the cached sensor so the next reading picks up the new policy
+	key := sensorCacheKey(req.ID1, req.ID2, req.SensorType)
+	if err := u.Redis.Del(ctx, key).Err(); err != nil {
+		u.Log.WithError(err).WithField("key", key).Warn("failed to invalidate sensor cache")
+	}
+
+	return &model.SensorDuplicatePolicyResponse{
+		ID1:             req.ID1,
+		ID2:             req.ID2,
+		SensorType:      req.SensorType,
+		DuplicatePolicy: u.effectivePolicy(req.DuplicatePolicy),
+		Default:         req.DuplicatePolicy == "",
+	}, nil
+}
+
+// TimestampStats returns the timestamp fallback counters of a sensor
+func (u *SensorUsecase) TimestampStats(ctx context.Context, req *model.SensorTimestampStatsRequest) (*model.SensorTimestampStatsResponse, error) {
+	// validate
+	if err := u.Validate.Struct(req); err != nil {
+		u.Log.WithError(err).Error("failed to validate request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	counters, err := u.Redis.HGetAll(ctx, timestampStatsKey(req.ID1, req.ID2, req.SensorType)).Result()
+	if err != nil {
+		u.Log.WithError(err).Error("failed to get timestamp stats")
+		return nil, echo.ErrInternalServerError
+	}
+
+	count := func(field string) int64 {
+		n, _ := strconv.ParseInt(counters[field], 10, 64)
+		return n
+	}
+	return &model.SensorTimestampStatsResponse{
+		ID1:        req.ID1,
+		ID2:        req.ID2,
+		SensorType: req.SensorType,
+		Missing:    count(timestampMissing),
+		Corrected:  count(timestampCorrected),
+		Rejected:   count(timestampRejected),
+		Window:     int64(u.Timestamps.statsWindow() / time.Second),
+	}, nil
+}
+
+// applyTimestamp falls back to the receive time when the device timestamp is
+// missing or outside the acceptance window, unless the late-data policy
+// rejects out-of-range readings. Historical readings skip the window.
+func (u *SensorUsecase) applyTimestamp(ctx context.Context, request *model.CreateSensorRequest) error {
+	receivedAt := request.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	if request.Timestamp.IsZero() {
+		u.countTimestamp(ctx, request, timestampMissing)
+		request.Timestamp, request.TimestampSource = receivedAt, entity.TimestampSourceServer
+		return nil
+	}
+
+	if request.Historical {
+		request.TimestampSource = entity.TimestampSourceDevice
+		return nil
+	}
+
+	err := u.Timestamps.Check(request.Timestamp, receivedAt)
+	if err == nil {
+		request.TimestampSource = entity.TimestampSourceDevice
+		return nil
+	}
+
+	if u.Timestamps.LateData == LateDataReject {
+		u.countTimestamp(ctx, request, timestampRejected)
+		u.Log.WithError(err).WithField("id1", request.ID1).Warn("rejected out-of-range timestamp")
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	u.countTimestamp(ctx, request, timestampCorrected)
+	u.Log.WithError(err).WithField("id1", request.ID1).Debug("replaced out-of-range timestamp with receive time")
+	request.Timestamp, request.TimestampSource = receivedAt, entity.TimestampSourceServer
+	return nil
+}
+
+func (u *SensorUsecase) countTimestamp(ctx context.Context, request *model.CreateSensorRequest, field string) {
+	if u.Redis == nil {
+		return
+	}
+
+	// The counters start over once the window of their first count expired
+	key := timestampStatsKey(request.ID1, request.ID2, request.SensorType)
+	_, err := u.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, key, field, 1)
+		pipe.ExpireNX(ctx, key, u.Timestamps.statsWindow())
+		return nil
+	})
+	if err != nil {
+		u.Log.WithError(err).WithField("key", key).Warn("failed to count timestamp fallback")
+	}
+}
+
+// resolveSensor finds the sensor of a request in the cache or database and
+// creates it inside tx when it does not exist yet. The returned flag reports
+// whether the sensor came from the cache.
+func (u *SensorUsecase) resolveSensor(ctx context.Context, tx *sql.Tx, request *model.CreateSensorRequest) (*entity.Sensor, bool, error) {
+	key := sensorCacheKey(request.ID1, request.ID2, request.SensorType)
+	if val, err := u.Redis.Get(ctx, key).Result(); err == nil && val != "" {
+		// cached as "<sensor_id>:<duplicate_policy>"
+		rawID, policy, _ := strings.Cut(val, ":")
+		id, err := strconv.ParseInt(rawID, 10, 64)
+		if err != nil {
+			u.Log.WithError(err).Error("failed to parse sensor id")
+			return nil, false, internalError(err)
+		}
+		if id > 0 {
+			return &entity.Sensor{
+				SensorID:        id,
+				ID1:             request.ID1,
+				ID2:             request.ID2,
+				SensorType:      request.SensorType,
+				DuplicatePolicy: policy,
+			}, true, nil
+		}
+	}
+
+	// try to find sensor in DB
+	sensor, err := u.SensorRepository.FindByUnique(ctx, request.ID1, request.ID2, request.SensorType)
+	if err == nil {
+		return sensor, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		u.Log.WithError(err).Error("failed to find sensor")
+		return nil, false, internalError(err)
+	}
+
+	// Create new sensor
+	sensor = &entity.Sensor{
+		ID1:        request.ID1,
+		ID2:        request.ID2,
+		SensorType: request.SensorType,
+	}
+	if err := u.SensorRepository.CreateTx(ctx, tx, sensor); err != nil {
+		// Another worker created the sensor since it was looked up
+		if repository.IsDuplicateKey(err) {
+			if existing, findErr := u.SensorRepository.FindByUnique(ctx, request.ID1, request.ID2, request.SensorType); findErr == nil {
+				return existing, false, nil
+			}
 		}
+		u.Log.WithError(err).Error("failed to create sensor")
+		return nil, false, internalError(err)
 	}
+	return sensor, false, nil
+}
+
+// internalError hides err behind a 500 while keeping it as the internal
+// error, so callers can still tell what failed
+func internalError(err error) error {
+	return echo.NewHTTPError(http.StatusInternalServerError).SetInternal(err)
+}
+
+func (u *SensorUsecase) cacheSensor(ctx context.Context, sensor *entity.Sensor) {
+	if u.Redis == nil || sensor.SensorID <= 0 {
+		return
+	}
+
+	key := sensorCacheKey(sensor.ID1, sensor.ID2, sensor.SensorType)
+	value := strconv.FormatInt(sensor.SensorID, 10) + ":" + sensor.DuplicatePolicy
+	ttl := 1 * time.Hour
+	if err := u.Redis.Set(ctx, key, value, ttl).Err(); err != nil {
+		u.Log.WithError(err).WithField("key", key).Warn("failed to set sensor cache")
+	}
+}
+
+// markSeen records the current time as the last-seen time of sensors that
+// just had a reading stored. Scores only move forward, so a slow request
+// does not roll back the time set by a newer one.
+func (u *SensorUsecase) markSeen(ctx context.Context, sensorIDs ...int64) {
+	if u.Redis == nil || len(sensorIDs) == 0 {
+		return
+	}
+
+	now := float64(time.Now().UnixMilli())
+	members := make([]redis.Z, 0, len(sensorIDs))
+	for _, id := range sensorIDs {
+		if id > 0 {
+			members = append(members, redis.Z{Score: now, Member: id})
+		}
+	}
+	if len(members) == 0 {
+		return
+	}
+	if err := u.Redis.ZAddGT(ctx, sensorLastSeenKey, members...).Err(); err != nil {
+		u.Log.WithError(err).Warn("failed to update sensor last-seen time")
+	}
+}
+
+// publish hands a committed record to the MQTT gateway and the live streams.
+// Historical records from bulk and CSV imports are not republished, they
+// would replace the retained latest readings.
+func (u *SensorUsecase) publish(request *model.CreateSensorRequest, record *entity.SensorRecord) {
+	if request.Historical || (u.Producer == nil && u.Stream == nil) {
+		return
+	}
+	event := converter.SensorRecordToEvent(record)
+	if u.Producer != nil {
+		_ = u.Producer.Send(event)
+	}
+	if u.Stream != nil {
+		_ = u.Stream.Send(event)
+	}
+}
+
+// newRecord builds the record of a request, carrying the sensor with its
+// effective duplicate policy
+func (u *SensorUsecase) newRecord(sensor *entity.Sensor, request *model.CreateSensorRequest) *entity.SensorRecord {
+	recordSensor := *sensor
+	recordSensor.DuplicatePolicy = u.effectivePolicy(sensor.DuplicatePolicy)
+
+	return &entity.SensorRecord{
+		SensorID:        sensor.SensorID,
+		SensorValue:     request.SensorValue,
+		Timestamp:       request.Timestamp,
+		MessageID:       request.MessageID,
+		TimestampSource: request.TimestampSource,
+		Sensor:          recordSensor,
+	}
+}
+
+func (u *SensorUsecase) effectivePolicy(policy string) string {
+	if policy != "" {
+		return policy
+	}
+	if u.DuplicatePolicy != "" {
+		return u.DuplicatePolicy
+	}
+	return entity.DuplicateKeepAll
+}
+
+func sensorCacheKey(id1 string, id2 int64, sensorType string) string {
+	return fmt.Sprintf("%v-%v-%v", id1, id2, sensorType)
+}
 
-	// Build response
-	resp := &model.SensorResponse{
+// Fields of the per-sensor timestamp counters
+const (
+	timestampMissing   = "missing"
+	timestampCorrected = "corrected"
+	timestampRejected  = "rejected"
+)
+
+// sensorLastSeenKey is a sorted set of sensor IDs scored by the unix
+// milliseconds of their latest stored reading
+const sensorLastSeenKey = "sensor-last-seen"
+
+func timestampStatsKey(id1 string, id2 int64, sensorType string) string {
+	return "timestamp-stats:" + sensorCacheKey(id1, id2, sensorType)
+}
+
+func recordToResponse(sensor *entity.Sensor, record *entity.SensorRecord, duplicate bool) *model.SensorResponse {
+	return &model.SensorResponse{
 		ID1:        sensor.ID1,
 		ID2:        sensor.ID2,
 		SensorType: sensor.SensorType,
 		SensorsRecords: []model.SensorRecord{
 			{
-				SensorValue: record.SensorValue,
-				Timestamp:   record.Timestamp,
+				SensorValue:     record.SensorValue,
+				Timestamp:       record.Timestamp,
+				TimestampSource: record.TimestampSource,
+				Duplicate:       duplicate,
 			},
 		},
 	}
-	return resp, nil
 }
 
 func (u *SensorUsecase) SearchByIdCombination(ctx context.Context, req *model.SensorSearchByIdRequest) (*model.SensorResponse, *model.PageMetadata, error) {
@@ -153,6 +753,10 @@ func (u *SensorUsecase) SearchByIdCombination(ctx context.Context, req *model.Se
 		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if req.SensorType != "" {
+		return u.searchSensor(ctx, req.ID1, req.ID2, req.SensorType, time.Time{}, time.Time{}, req.Page, req.PageSize)
+	}
+
 	sensor, meta, err := u.SensorRepository.FindSensorRecordsByIdCombination(ctx, req.ID1, req.ID2, req.Page, req.PageSize)
 	if err != nil {
 		u.Log.WithError(err).Error("error getting sensor records")
@@ -187,6 +791,10 @@ func (u *SensorUsecase) SearchByIdAndTimeRange(ctx context.Context, req *model.S
 		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if req.SensorType != "" {
+		return u.searchSensor(ctx, req.ID1, req.ID2, req.SensorType, req.Start, req.End, req.Page, req.PageSize)
+	}
+
 	sensor, meta, err := u.SensorRepository.FindSensorRecordsByIdAndTimeRange(ctx, req.ID1, req.ID2, req.Start, req.End, req.Page, req.PageSize)
 	if err != nil {
 		u.Log.WithError(err).Error("error getting sensor records")
@@ -205,7 +813,16 @@ func (u *SensorUsecase) DeleteByIdCombination(ctx context.Context, req *model.Se
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	deletedRow, err := u.SensorRepository.DeleteRecordsByIdCombination(ctx, req.ID1, req.ID2)
+	var deletedRow int64
+	// Scoped to one sensor when sensor_type is set, an unknown one has no records
+	sensor, err := u.findSensorOfType(ctx, req.ID1, req.ID2, req.SensorType)
+	switch {
+	case err != nil:
+	case sensor != nil:
+		deletedRow, err = u.SensorRepository.DeleteRecordsBySensorID(ctx, sensor.SensorID, time.Time{}, time.Time{})
+	case req.SensorType == "":
+		deletedRow, err = u.SensorRepository.DeleteRecordsByIdCombination(ctx, req.ID1, req.ID2)
+	}
 	if err != nil {
 		u.Log.WithError(err).Error("error when deleting sensor records")
 		return nil, echo.ErrInternalServerError
@@ -243,7 +860,16 @@ func (u *SensorUsecase) DeleteByIdAndTimeRange(ctx context.Context, req *model.S
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	deletedRows, err := u.SensorRepository.DeleteRecordsByIdAndTimeRange(ctx, req.ID1, req.ID2, req.Start, req.End)
+	var deletedRows int64
+	// Scoped to one sensor when sensor_type is set, an unknown one has no records
+	sensor, err := u.findSensorOfType(ctx, req.ID1, req.ID2, req.SensorType)
+	switch {
+	case err != nil:
+	case sensor != nil:
+		deletedRows, err = u.SensorRepository.DeleteRecordsBySensorID(ctx, sensor.SensorID, req.Start, req.End)
+	case req.SensorType == "":
+		deletedRows, err = u.SensorRepository.DeleteRecordsByIdAndTimeRange(ctx, req.ID1, req.ID2, req.Start, req.End)
+	}
 	if err != nil {
 		u.Log.WithError(err).Error("error when deleting sensors records")
 		return nil, echo.ErrInternalServerError
@@ -262,7 +888,16 @@ func (u *SensorUsecase) UpdateByIdCombination(ctx context.Context, req *model.Se
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	affectedRow, err := u.SensorRepository.UpdateSensorValuesByIdCombination(ctx, req.ID1, req.ID2, req.SensorValue)
+	var affectedRow int64
+	// Scoped to one sensor when sensor_type is set, an unknown one has no records
+	sensor, err := u.findSensorOfType(ctx, req.ID1, req.ID2, req.SensorType)
+	switch {
+	case err != nil:
+	case sensor != nil:
+		affectedRow, err = u.SensorRepository.UpdateSensorValuesBySensorID(ctx, sensor.SensorID, time.Time{}, time.Time{}, req.SensorValue)
+	case req.SensorType == "":
+		affectedRow, err = u.SensorRepository.UpdateSensorValuesByIdCombination(ctx, req.ID1, req.ID2, req.SensorValue)
+	}
 	if err != nil {
 		u.Log.WithError(err).Error("error when updating sensor records")
 		return nil, echo.ErrInternalServerError
@@ -300,7 +935,16 @@ func (u *SensorUsecase) UpdateByIdAndTimeRange(ctx context.Context, req *model.S
 		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	affectedRow, err := u.SensorRepository.UpdateSensorValueByIdAndTimeRange(ctx, req.ID1, req.ID2, req.Start, req.End, req.SensorValue)
+	var affectedRow int64
+	// Scoped to one sensor when sensor_type is set, an unknown one has no records
+	sensor, err := u.findSensorOfType(ctx, req.ID1, req.ID2, req.SensorType)
+	switch {
+	case err != nil:
+	case sensor != nil:
+		affectedRow, err = u.SensorRepository.UpdateSensorValuesBySensorID(ctx, sensor.SensorID, req.Start, req.End, req.SensorValue)
+	case req.SensorType == "":
+		affectedRow, err = u.SensorRepository.UpdateSensorValueByIdAndTimeRange(ctx, req.ID1, req.ID2, req.Start, req.End, req.SensorValue)
+	}
 	if err != nil {
 		u.Log.WithError(err).Error("error when updating sensor records")
 		return nil, echo.ErrInternalServerError
@@ -311,3 +955,45 @@ func (u *SensorUsecase) UpdateByIdAndTimeRange(ctx context.Context, req *model.S
 	}
 	return resp, nil
 }
+
+// findSensorOfType returns the sensor of type sensorType of device id1/id2.
+// It returns nil without error when sensorType is empty, for the whole
+// device, and when the device has no such sensor.
+func (u *SensorUsecase) findSensorOfType(ctx context.Context, id1 string, id2 int64, sensorType string) (*entity.Sensor, error) {
+	if sensorType == "" {
+		return nil, nil
+	}
+	sensor, err := u.SensorRepository.FindByUnique(ctx, id1, id2, sensorType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return sensor, err
+}
+
+// searchSensor is a device search narrowed to the sensor of one type. Zero
+// start and end times search every record.
+func (u *SensorUsecase) searchSensor(
+	ctx context.Context,
+	id1 string, id2 int64, sensorType string,
+	start, end time.Time,
+	page, pageSize int,
+) (*model.SensorResponse, *model.PageMetadata, error) {
+	sensor, err := u.findSensorOfType(ctx, id1, id2, sensorType)
+	if err != nil {
+		u.Log.WithError(err).Error("error getting sensor")
+		return nil, nil, echo.ErrInternalServerError
+	}
+	if sensor == nil {
+		empty := &entity.Sensor{ID1: id1, ID2: id2, SensorType: sensorType}
+		return converter.SensorToResponse(empty), &model.PageMetadata{Page: page, Size: pageSize}, nil
+	}
+
+	records, meta, err := u.SensorRepository.FindSensorRecordsBySensorID(ctx, sensor.SensorID, start, end, page, pageSize)
+	if err != nil {
+		u.Log.WithError(err).Error("error getting sensor records")
+		return nil, nil, echo.ErrInternalServerError
+	}
+
+	sensor.Records = records
+	return converter.SensorToResponse(sensor), meta, nil
+}
@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"fmt"
+	"iot-server/internal/broker"
+	"iot-server/internal/model"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SubscriptionManager owns the MQTT subscriptions of the server. A clean or
+// expired session and a broker restart lose the subscriptions while the
+// client reconnects on its own, so every subscription is made again after
+// each reconnect, and retried until the broker granted all of them. It
+// tracks whether each one is granted and when its last message arrived.
+type SubscriptionManager struct {
+	Client  broker.Client
+	Log     *logrus.Logger
+	Timeout time.Duration // per subscribe request
+	// RetryBackoff is the first pause before subscribing again after a
+	// failure, doubled every time up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	mu            sync.Mutex
+	subscriptions []*subscription
+	stopped       atomic.Bool
+	connection    atomic.Int64 // reconnects so far, ends the retries of older connections
+}
+
+type subscription struct {
+	topic   string
+	qos     byte
+	handler broker.Handler
+
+	mu           sync.Mutex
+	subscribed   bool
+	err          error
+	subscribedAt time.Time
+
+	lastMessage atomic.Int64 // unix nanoseconds
+	messages    atomic.Int64
+}
+
+func NewSubscriptionManager(client broker.Client, logger *logrus.Logger, timeout time.Duration) *SubscriptionManager {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	m := &SubscriptionManager{
+		Client:          client,
+		Log:             logger,
+		Timeout:         timeout,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: time.Minute,
+	}
+	client.OnConnect(func() { go m.resubscribe() })
+	return m
+}
+
+// Subscribe subscribes handler to topic, a filter or shared subscription,
+// and keeps it subscribed across reconnects. It returns the error of the
+// first attempt, e.g. when the broker refused the topic.
+func (m *SubscriptionManager) Subscribe(topic string, qos byte, handler broker.Handler) error {
+	s := &subscription{topic: topic, qos: qos}
+	s.handler = func(msg broker.Message) {
+		// Left unacknowledged, the broker redelivers it after the restart
+		if m.stopped.Load() {
+			return
+		}
+		s.lastMessage.Store(time.Now().UnixNano())
+		s.messages.Add(1)
+		handler(msg)
+	}
+
+	m.mu.Lock()
+	m.subscriptions = append(m.subscriptions, s)
+	m.mu.Unlock()
+
+	return m.subscribe(s)
+}
+
+// Stop stops handing messages to the handlers, so the ingest pipeline can be
+// drained before the client disconnects. The subscriptions stay in the broker
+// session, which keeps queueing messages for the next start.
+func (m *SubscriptionManager) Stop() {
+	m.stopped.Store(true)
+}
+
+// Status reports the connection and every subscription
+func (m *SubscriptionManager) Status() *model.MQTTSubscriptionsResponse {
+	m.mu.Lock()
+	subscriptions := append([]*subscription(nil), m.subscriptions...)
+	m.mu.Unlock()
+
+	response := &model.MQTTSubscriptionsResponse{
+		Connected:     m.Client.IsConnected(),
+		Subscriptions: make([]model.MQTTSubscriptionResponse, 0, len(subscriptions)),
+	}
+	response.Healthy = response.Connected
+	for _, s := range subscriptions {
+		status := model.MQTTSubscriptionResponse{
+			Topic:    s.topic,
+			Qos:      s.qos,
+			Messages: s.messages.Load(),
+		}
+		s.mu.Lock()
+		status.Subscribed = s.subscribed
+		if s.err != nil {
+			status.Error = s.err.Error()
+		}
+		if !s.subscribedAt.IsZero() {
+			subscribedAt := s.subscribedAt
+			status.SubscribedAt = &subscribedAt
+		}
+		s.mu.Unlock()
+		if last := s.lastMessage.Load(); last > 0 {
+			lastMessageAt := time.Unix(0, last).UTC()
+			status.LastMessageAt = &lastMessageAt
+		}
+
+		response.Healthy = response.Healthy && status.Subscribed
+		response.Subscriptions = append(response.Subscriptions, status)
+	}
+	return response
+}
+
+// resubscribe makes every subscription again after a reconnect. Failed ones
+// are retried with backoff until all are granted, the manager is stopped or
+// the client reconnected again, which starts over.
+func (m *SubscriptionManager) resubscribe() {
+	connection := m.connection.Add(1)
+	current := func() bool {
+		return !m.stopped.Load() && m.connection.Load() == connection
+	}
+	if !current() {
+		return
+	}
+
+	m.mu.Lock()
+	pending := append([]*subscription(nil), m.subscriptions...)
+	m.mu.Unlock()
+
+	m.Log.WithField("count", len(pending)).Info("MQTT reconnected, subscribing again")
+	backoff := m.RetryBackoff
+	for {
+		failed := pending[:0]
+		for _, s := range pending {
+			if !current() {
+				return
+			}
+			if err := m.subscribe(s); err != nil {
+				failed = append(failed, s)
+			}
+		}
+		pending = failed
+		if len(pending) == 0 {
+			return
+		}
+
+		m.Log.WithField("count", len(pending)).WithField("retry_in", backoff).Warn("MQTT subscriptions not granted, retrying")
+		time.Sleep(backoff)
+		if !current() {
+			return
+		}
+		backoff = min(backoff*2, m.MaxRetryBackoff)
+	}
+}
+
+// subscribe sends the subscribe request and waits for the broker's answer
+func (m *SubscriptionManager) subscribe(s *subscription) error {
+	s.mu.Lock()
+	s.subscribed = false
+	s.mu.Unlock()
+
+	token := m.Client.Subscribe(s.topic, s.qos, s.handler)
+	var err error
+	select {
+	case <-token.Done():
+		err = token.Error()
+	case <-time.After(m.Timeout):
+		err = fmt.Errorf("subscription to %s timed out", s.topic)
+	}
+
+	s.mu.Lock()
+	s.subscribed, s.err = err == nil, err
+	if err == nil {
+		s.subscribedAt = time.Now().UTC()
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		m.Log.WithField("topic", s.topic).WithError(err).Error("MQTT subscribe failed")
+		return err
+	}
+	m.Log.WithField("topic", s.topic).WithField("qos", s.qos).Info("MQTT subscribed")
+	return nil
+}
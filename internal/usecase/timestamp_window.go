@@ -0,0 +1,49 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+)
+
+// LateDataPolicy decides what happens to a reading whose timestamp lies
+// outside the acceptance window
+type LateDataPolicy string
+
+const (
+	// LateDataCorrect replaces the timestamp with the server receive time
+	LateDataCorrect LateDataPolicy = "correct"
+	// LateDataReject rejects the reading
+	LateDataReject LateDataPolicy = "reject"
+)
+
+// TimestampWindow bounds how far a device timestamp may lie from the time
+// the reading was received
+type TimestampWindow struct {
+	MaxPast   time.Duration // 0 disables the check
+	MaxFuture time.Duration // 0 disables the check
+	LateData  LateDataPolicy
+	// StatsWindow is how long the timestamp counters of a sensor are kept,
+	// counted from the first reading they count
+	StatsWindow time.Duration
+}
+
+// defaultStatsWindow applies when no StatsWindow is configured
+const defaultStatsWindow = 24 * time.Hour
+
+func (w TimestampWindow) statsWindow() time.Duration {
+	if w.StatsWindow <= 0 {
+		return defaultStatsWindow
+	}
+	return w.StatsWindow
+}
+
+// Check returns an error when timestamp lies outside the window around receivedAt
+func (w TimestampWindow) Check(timestamp, receivedAt time.Time) error {
+	if w.MaxPast > 0 && timestamp.Before(receivedAt.Add(-w.MaxPast)) {
+		return fmt.Errorf("timestamp %s is more than %s in the past", timestamp.Format(time.RFC3339), w.MaxPast)
+	}
+	if w.MaxFuture > 0 && timestamp.After(receivedAt.Add(w.MaxFuture)) {
+		return fmt.Errorf("timestamp %s is more than %s in the future", timestamp.Format(time.RFC3339), w.MaxFuture)
+	}
+	return nil
+}
@@ -50,7 +50,7 @@ func (u *UserUsecase) Create(ctx context.Context, request *model.RegisterUserReq
 
 	// Validate role
 	role := entity.Role(request.Role)
-	if role != entity.RoleAdmin && role != entity.RoleUser {
+	if role != entity.RoleAdmin && role != entity.RoleUser && role != entity.RoleIngest {
 		u.Log.Error("invalid role")
 		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid role")
 	}
@@ -0,0 +1,46 @@
+package util
+
+import "reflect"
+
+// MergePatch applies a JSON merge patch (RFC 7386) to a decoded JSON
+// document: objects are merged key by key, null removes a key and any other
+// value replaces the target
+func MergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = MergePatch(t[key], value)
+	}
+	return t
+}
+
+// JSONDelta returns the values of desired that are missing from or differ
+// from reported, descending into objects present in both
+func JSONDelta(desired, reported map[string]any) map[string]any {
+	delta := make(map[string]any)
+	for key, want := range desired {
+		got, ok := reported[key]
+		wantObject, isObject := want.(map[string]any)
+		gotObject, bothObjects := got.(map[string]any)
+		if ok && isObject && bothObjects {
+			if sub := JSONDelta(wantObject, gotObject); len(sub) > 0 {
+				delta[key] = sub
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(want, got) {
+			delta[key] = want
+		}
+	}
+	return delta
+}
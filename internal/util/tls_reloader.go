@@ -0,0 +1,153 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TLSReloader serves a CA bundle and a client certificate that are read again
+// whenever one of the files changes on disk, so rotated certificates are used
+// from the next handshake on without a restart.
+type TLSReloader struct {
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	Log      *logrus.Logger
+
+	mu       sync.Mutex
+	loaded   bool
+	modTimes [3]time.Time
+	roots    *x509.CertPool
+	cert     *tls.Certificate
+}
+
+// NewTLSReloader loads the files once, failing on an unreadable or invalid
+// file. Either the CA file or the certificate and key may be empty.
+func NewTLSReloader(caFile, certFile, keyFile string, log *logrus.Logger) (*TLSReloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("client certificate and key have to be set together")
+	}
+	r := &TLSReloader{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, Log: log}
+	if _, _, err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns a client TLS configuration using the reloaded files. The
+// server certificate is verified against the current CA bundle, or the system
+// roots without one, for serverName unless skipVerify is set.
+func (r *TLSReloader) Config(serverName string, minVersion uint16, skipVerify bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: minVersion,
+		ServerName: serverName,
+		// Verification happens in VerifyConnection against the current roots
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if skipVerify {
+				return nil
+			}
+			return r.verify(state, serverName)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			_, cert, err := r.load()
+			if err != nil {
+				return nil, err
+			}
+			if cert == nil {
+				// no certificate is sent
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+}
+
+func (r *TLSReloader) verify(state tls.ConnectionState, serverName string) error {
+	roots, _, err := r.load()
+	if err != nil {
+		return err
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	if serverName == "" {
+		serverName = state.ServerName
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+	})
+	return err
+}
+
+// load returns the current roots and certificate, reading the files again
+// if any modification time changed. A failed reload keeps the previous ones.
+func (r *TLSReloader) load() (*x509.CertPool, *tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var modTimes [3]time.Time
+	for i, file := range []string{r.CAFile, r.CertFile, r.KeyFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return r.current(err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.loaded && modTimes == r.modTimes {
+		return r.roots, r.cert, nil
+	}
+
+	var roots *x509.CertPool
+	if r.CAFile != "" {
+		pem, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return r.current(err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return r.current(fmt.Errorf("no certificate found in %s", r.CAFile))
+		}
+	}
+	var cert *tls.Certificate
+	if r.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+		if err != nil {
+			return r.current(err)
+		}
+		cert = &pair
+	}
+
+	if r.loaded {
+		r.Log.Info("TLS certificates reloaded")
+	}
+	r.loaded, r.modTimes, r.roots, r.cert = true, modTimes, roots, cert
+	return roots, cert, nil
+}
+
+// current falls back to the loaded files after a failed reload, e.g. while
+// a certificate and its key are replaced one after the other
+func (r *TLSReloader) current(err error) (*x509.CertPool, *tls.Certificate, error) {
+	if !r.loaded {
+		return nil, nil, err
+	}
+	r.Log.WithError(err).Warn("failed to reload TLS certificates, keeping the previous ones")
+	return r.roots, r.cert, nil
+}
@@ -2,6 +2,10 @@ package util
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"iot-server/internal/model"
 	"time"
 
@@ -82,3 +86,42 @@ func (t TokenUtil) RemoveToken(ctx context.Context, auth *model.Auth) error {
 
 	return nil
 }
+
+// ticketPrefix namespaces the tickets among the token keys, which are user IDs
+const ticketPrefix = "ticket:"
+
+// CreateTicket issues a single-use ticket standing in for the token of auth
+// for ttl, for requests that cannot carry an Authorization header
+func (t TokenUtil) CreateTicket(ctx context.Context, auth *model.Auth, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ticket := hex.EncodeToString(b)
+
+	value, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	if err := t.Redis.SetEx(ctx, ticketPrefix+ticket, value, ttl).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// RedeemTicket returns the user of a ticket and invalidates it
+func (t TokenUtil) RedeemTicket(ctx context.Context, ticket string) (*model.Auth, error) {
+	value, err := t.Redis.GetDel(ctx, ticketPrefix+ticket).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, echo.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	auth := new(model.Auth)
+	if err := json.Unmarshal(value, auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
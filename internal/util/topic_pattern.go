@@ -0,0 +1,154 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// topicLevelEscaper percent-encodes the characters that split a topic level or
+// act as wildcards, and "%" itself so every level decodes back unchanged
+var topicLevelEscaper = strings.NewReplacer("%", "%25", "/", "%2F", "+", "%2B", "#", "%23")
+
+// EscapeTopicLevel makes value a single topic level without wildcards, so an
+// identifier can address a device topic. UnescapeTopicLevel reverses it.
+func EscapeTopicLevel(value string) string {
+	return topicLevelEscaper.Replace(value)
+}
+
+// UnescapeTopicLevel returns the value of a level built by EscapeTopicLevel
+func UnescapeTopicLevel(level string) (string, error) {
+	return url.PathUnescape(level)
+}
+
+// TopicPattern is an MQTT topic filter with named segments,
+// e.g. "iot/{id1}/{id2}/{sensor_type}". Named segments behave like the
+// single-level wildcard "+" and their values are captured on Match.
+type TopicPattern struct {
+	Pattern   string
+	separator string
+	segments  []string
+	names     []string
+}
+
+func NewTopicPattern(pattern string) (*TopicPattern, error) {
+	return NewNamePattern(pattern, "/")
+}
+
+// NewNamePattern parses a pattern whose segments are split by separator
+// instead of "/", e.g. "{id1}:{id2}:{sensor_type}" for device-chosen names
+func NewNamePattern(pattern, separator string) (*TopicPattern, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("topic pattern is empty")
+	}
+	if separator == "" {
+		return nil, fmt.Errorf("topic pattern %q has an empty separator", pattern)
+	}
+
+	segments := strings.Split(pattern, separator)
+	names := make([]string, len(segments))
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			name := segment[1 : len(segment)-1]
+			if name == "" {
+				return nil, fmt.Errorf("topic pattern %q has an unnamed segment", pattern)
+			}
+			names[i] = name
+		case segment == "#" && i != len(segments)-1:
+			return nil, fmt.Errorf("topic pattern %q: '#' must be the last segment", pattern)
+		case strings.ContainsAny(segment, "{}") || (segment != "+" && segment != "#" && strings.ContainsAny(segment, "+#")):
+			return nil, fmt.Errorf("topic pattern %q has an invalid segment %q", pattern, segment)
+		}
+	}
+
+	return &TopicPattern{
+		Pattern:   pattern,
+		separator: separator,
+		segments:  segments,
+		names:     names,
+	}, nil
+}
+
+// Subscription returns the MQTT topic filter to subscribe to, with every
+// named segment replaced by "+".
+func (p *TopicPattern) Subscription() string {
+	filter := make([]string, len(p.segments))
+	for i, segment := range p.segments {
+		if p.names[i] != "" {
+			filter[i] = "+"
+			continue
+		}
+		filter[i] = segment
+	}
+	return strings.Join(filter, p.separator)
+}
+
+// SharedSubscription returns the subscription as the MQTT shared
+// subscription "$share/<group>/<filter>", so the broker hands each message to
+// only one client of the group. An empty group returns the plain filter.
+func (p *TopicPattern) SharedSubscription(group string) string {
+	if group == "" {
+		return p.Subscription()
+	}
+	return "$share/" + group + "/" + p.Subscription()
+}
+
+// Topic fills the named segments with values, e.g. to address a single
+// device. It fails for a missing value or a wildcard segment.
+func (p *TopicPattern) Topic(values map[string]string) (string, error) {
+	levels := make([]string, len(p.segments))
+	for i, segment := range p.segments {
+		switch {
+		case p.names[i] != "":
+			value, ok := values[p.names[i]]
+			if !ok {
+				return "", fmt.Errorf("topic pattern %q: no value for {%s}", p.Pattern, p.names[i])
+			}
+			levels[i] = value
+		case segment == "+" || segment == "#":
+			return "", fmt.Errorf("topic pattern %q has a wildcard segment", p.Pattern)
+		default:
+			levels[i] = segment
+		}
+	}
+	return strings.Join(levels, p.separator), nil
+}
+
+// HasNames reports whether the pattern captures any named segment
+func (p *TopicPattern) HasNames() bool {
+	for _, name := range p.names {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether topic matches the pattern and returns the values of
+// the named segments.
+func (p *TopicPattern) Match(topic string) (map[string]string, bool) {
+	levels := strings.Split(topic, p.separator)
+	values := make(map[string]string)
+
+	for i, segment := range p.segments {
+		if segment == "#" {
+			return values, true
+		}
+		if i >= len(levels) {
+			return nil, false
+		}
+		switch {
+		case p.names[i] != "":
+			values[p.names[i]] = levels[i]
+		case segment == "+":
+		case segment != levels[i]:
+			return nil, false
+		}
+	}
+
+	if len(levels) != len(p.segments) {
+		return nil, false
+	}
+	return values, true
+}
@@ -0,0 +1,190 @@
+package coap_test_test
+
+import (
+	"bytes"
+	"context"
+	"iot-server/internal/codec"
+	"iot-server/internal/delivery/coap"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	piondtls "github.com/pion/dtls/v3"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/udp/coder"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+var sensorKey = []byte("0123456789abcdef")
+
+func startServer(t *testing.T) (*coap.Server, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	log := logrus.New()
+	validate := validator.New()
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validate, rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", usecase.TimestampWindow{},
+	)
+	keys := usecase.NewSensorKeyUsecase(log, validate, repository.NewSensorKeyRepository(db, log))
+
+	server, err := coap.NewServer("127.0.0.1:0", keys, coap.NewSensorHandler(sensorUsecase, codec.NewRegistry(), log), log)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Stop(ctx)
+	})
+	return server, mock
+}
+
+func expectKey(mock sqlmock.Sqlmock, id1 string, id2 int64, key []byte) {
+	rows := sqlmock.NewRows([]string{"id1", "id2", "psk", "created_at", "updated_at"})
+	if key != nil {
+		rows.AddRow(id1, id2, key, time.Now(), time.Now())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id1, id2, psk, created_at, updated_at`)).
+		WithArgs(id1, id2).
+		WillReturnRows(rows)
+}
+
+func dial(address, identity string, key []byte) (*piondtls.Conn, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := piondtls.Dial("udp", addr, &piondtls.Config{
+		PSK:             func([]byte) ([]byte, error) { return key, nil },
+		PSKIdentityHint: []byte(identity),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// post sends a confirmable POST with the given message ID and returns the
+// piggybacked response
+func post(t *testing.T, conn *piondtls.Conn, messageID int32, path, payload string) *pool.Message {
+	t.Helper()
+
+	req := pool.NewMessage(context.Background())
+	req.SetCode(codes.POST)
+	req.SetType(message.Confirmable)
+	req.SetMessageID(messageID)
+	req.SetToken(message.Token{byte(messageID)})
+	if err := req.SetPath(path); err != nil {
+		t.Fatalf("SetPath: %v", err)
+	}
+	req.SetContentFormat(message.AppJSON)
+	req.SetBody(bytes.NewReader([]byte(payload)))
+	data, err := req.MarshalWithEncoder(coder.DefaultCoder)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if _, err := conn.Write(data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	resp := pool.NewMessage(context.Background())
+	if _, err := resp.UnmarshalWithDecoder(coder.DefaultCoder, buf[:n]); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Type() != message.Acknowledgement || resp.MessageID() != messageID {
+		t.Fatalf("expected a piggybacked ACK for %d, got %s", messageID, resp.String())
+	}
+	return resp
+}
+
+// A confirmable reading is stored once, even when the device retransmits it
+func TestSensorHandler_CreateConfirmable(t *testing.T) {
+	server, mock := startServer(t)
+
+	expectKey(mock, "SENSOR-1", 1, sensorKey)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "temperature").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)).
+		WithArgs(int64(7), 21.5, sqlmock.AnyArg(), sqlmock.AnyArg(), "device").
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+
+	conn, err := dial(server.Address(), "SENSOR-1/1", sensorKey)
+	if err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	defer conn.Close()
+
+	payload := `{"sensor_value":21.5,"timestamp":"2025-08-28T12:00:00Z"}`
+	if resp := post(t, conn, 100, "/sensor/SENSOR-1/1/temperature", payload); resp.Code() != codes.Created {
+		t.Fatalf("expected 2.01 Created, got %s", resp.String())
+	}
+	// A retransmission of the same message is answered without a second insert
+	if resp := post(t, conn, 100, "/sensor/SENSOR-1/1/temperature", payload); resp.Code() != codes.Created {
+		t.Fatalf("expected the retransmission to get 2.01 Created, got %s", resp.String())
+	}
+	// The key of SENSOR-1/1 does not allow posting for another device
+	if resp := post(t, conn, 101, "/sensor/SENSOR-2/1/temperature", payload); resp.Code() != codes.Forbidden {
+		t.Fatalf("expected 4.03 Forbidden, got %s", resp.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorHandler_RefusesUnknownKeys(t *testing.T) {
+	server, mock := startServer(t)
+
+	expectKey(mock, "SENSOR-1", 1, sensorKey)
+	if conn, err := dial(server.Address(), "SENSOR-1/1", []byte("fedcba9876543210")); err == nil {
+		_ = conn.Close()
+		t.Fatalf("expected a wrong key to fail the handshake")
+	}
+
+	expectKey(mock, "SENSOR-9", 1, nil)
+	if conn, err := dial(server.Address(), "SENSOR-9/1", sensorKey); err == nil {
+		_ = conn.Close()
+		t.Fatalf("expected a device without key to fail the handshake")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+package codec_test_test
+
+import (
+	"encoding/json"
+	"iot-server/internal/codec"
+	"iot-server/internal/model"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var wantReading = model.CreateSensorRequest{
+	ID1:         "SENSOR-6",
+	ID2:         6,
+	SensorType:  "temperature",
+	SensorValue: 73.26,
+	Timestamp:   time.Date(2025, 8, 28, 0, 42, 57, 922000000, time.UTC),
+	MessageID:   "m-1",
+}
+
+func readingMap() map[string]any {
+	return map[string]any{
+		"id1":          wantReading.ID1,
+		"id2":          wantReading.ID2,
+		"sensor_type":  wantReading.SensorType,
+		"sensor_value": wantReading.SensorValue,
+		"timestamp":    wantReading.Timestamp,
+		"message_id":   wantReading.MessageID,
+	}
+}
+
+func protobufReading(r model.CreateSensorRequest) []byte {
+	var ts []byte
+	ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(r.Timestamp.Unix()))
+	ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(r.Timestamp.Nanosecond()))
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, r.ID1)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(r.ID2))
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, r.SensorType)
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(r.SensorValue))
+	b = protowire.AppendTag(b, 5, protowire.BytesType)
+	b = protowire.AppendBytes(b, ts)
+	b = protowire.AppendTag(b, 6, protowire.BytesType)
+	b = protowire.AppendString(b, r.MessageID)
+	// Unknown fields are skipped
+	b = protowire.AppendTag(b, 99, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	return b
+}
+
+func protobufBatch(readings ...[]byte) []byte {
+	var b []byte
+	for _, reading := range readings {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, reading)
+	}
+	return b
+}
+
+// cborMarshal encodes timestamps as tag 0 RFC 3339 strings, the default
+// encoding drops sub-second precision
+func cborMarshal(v any) ([]byte, error) {
+	mode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano, TimeTag: cbor.EncTagRequired}.EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return mode.Marshal(v)
+}
+
+func mustEncode(t *testing.T, marshal func(any) ([]byte, error), v any) []byte {
+	t.Helper()
+	payload, err := marshal(v)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return payload
+}
+
+func TestCodecs_DecodeSameReading(t *testing.T) {
+	jsonPayload := mustEncode(t, json.Marshal, readingMap())
+	cborPayload := mustEncode(t, cborMarshal, readingMap())
+	msgpackPayload := mustEncode(t, msgpack.Marshal, readingMap())
+
+	tests := []struct {
+		name    string
+		codec   codec.Codec
+		payload []byte
+		isBatch bool
+	}{
+		{name: "json", codec: codec.JSON{}, payload: jsonPayload},
+		{name: "cbor", codec: codec.CBOR{}, payload: cborPayload},
+		{name: "msgpack", codec: codec.MessagePack{}, payload: msgpackPayload},
+		{name: "protobuf", codec: codec.Protobuf{}, payload: protobufBatch(protobufReading(wantReading)), isBatch: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, isBatch, err := tt.codec.Decode(tt.payload)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if isBatch != tt.isBatch || len(readings) != 1 {
+				t.Fatalf("expected batch=%v with 1 reading, got batch=%v with %d", tt.isBatch, isBatch, len(readings))
+			}
+			got := *readings[0].Request
+			got.Timestamp = got.Timestamp.UTC()
+			if !reflect.DeepEqual(got, wantReading) {
+				t.Fatalf("unexpected reading:\n got  %+v\n want %+v", got, wantReading)
+			}
+		})
+	}
+}
+
+func TestCodecs_DecodeBatchWithBadItem(t *testing.T) {
+	good := readingMap()
+	bad := map[string]any{"id1": "SENSOR-6", "id2": "not a number"}
+
+	tests := []struct {
+		name    string
+		codec   codec.Codec
+		payload []byte
+	}{
+		{name: "json", codec: codec.JSON{}, payload: mustEncode(t, json.Marshal, []any{good, bad})},
+		{name: "cbor", codec: codec.CBOR{}, payload: mustEncode(t, cborMarshal, []any{good, bad})},
+		{name: "msgpack", codec: codec.MessagePack{}, payload: mustEncode(t, msgpack.Marshal, []any{good, bad})},
+		{name: "protobuf", codec: codec.Protobuf{}, payload: protobufBatch(
+			protobufReading(wantReading),
+			// id1 cut short
+			append(protowire.AppendTag(nil, 1, protowire.BytesType), 9, 'S'),
+		)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, isBatch, err := tt.codec.Decode(tt.payload)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !isBatch || len(readings) != 2 {
+				t.Fatalf("expected a batch of 2, got batch=%v with %d", isBatch, len(readings))
+			}
+			if readings[0].Err != nil || readings[0].Request.ID1 != wantReading.ID1 {
+				t.Fatalf("expected first reading to decode, got %+v", readings[0])
+			}
+			if readings[1].Err == nil {
+				t.Fatalf("expected second reading to fail")
+			}
+
+			// The raw item decodes on its own with the same codec
+			again, _, err := tt.codec.Decode(readings[1].Raw)
+			if err == nil && (len(again) != 1 || again[0].Err == nil) {
+				t.Fatalf("expected raw item to fail again, got %+v", again)
+			}
+		})
+	}
+}
+
+func TestCodecs_DecodeInvalidPayload(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON{}, codec.CBOR{}, codec.MessagePack{}, codec.Protobuf{}} {
+		if _, _, err := c.Decode([]byte{0xff, 0xff, 0xff}); err == nil {
+			t.Fatalf("%s: expected error", c.Name())
+		}
+	}
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	registry := codec.NewRegistry()
+	if err := registry.AddTopic("iot/cbor/#", "cbor"); err != nil {
+		t.Fatalf("AddTopic: %v", err)
+	}
+	if err := registry.AddTopic("iot/+/pb", "protobuf"); err != nil {
+		t.Fatalf("AddTopic: %v", err)
+	}
+	if err := registry.AddTopic("iot/#", "yaml"); err == nil {
+		t.Fatalf("expected unknown codec error")
+	}
+
+	tests := []struct {
+		topic       string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{topic: "iot/cbor/S1/1/temp", want: "cbor"},
+		{topic: "iot/S1/pb", want: "protobuf"},
+		{topic: "iot/S1/1/temp", want: "json"},
+		{topic: "iot/cbor/S1", contentType: "application/x-msgpack", want: "msgpack"},
+		{topic: "iot/S1", contentType: "application/json; charset=utf-8", want: "json"},
+		{topic: "iot/S1", contentType: "text/plain", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := registry.Resolve(tt.topic, tt.contentType)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("Resolve(%q, %q): expected error", tt.topic, tt.contentType)
+			}
+			continue
+		}
+		if err != nil || got.Name() != tt.want {
+			t.Fatalf("Resolve(%q, %q) = %v, %v; want %s", tt.topic, tt.contentType, got, err, tt.want)
+		}
+	}
+}
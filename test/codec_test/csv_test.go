@@ -0,0 +1,89 @@
+package codec_test_test
+
+import (
+	"errors"
+	"io"
+	"iot-server/internal/codec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVReader_ColumnMapping(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	body := "\uFEFFDevice;Channel;Kind;Reading;Logged At\n" +
+		"SENSOR-1;1;temperature;21.5;2025-08-28 19:00:00\n" +
+		"SENSOR-1;1;temperature;abc;2025-08-28 19:01:00\n" +
+		"SENSOR-1;1;temperature;22\n" +
+		"SENSOR-1;2;humidity;60;28/08/2025\n"
+
+	reader, err := codec.NewCSVReader(strings.NewReader(body), codec.CSVOptions{
+		Columns: codec.CSVColumns{
+			ID1:         "device",
+			ID2:         "channel",
+			SensorType:  "kind",
+			SensorValue: "reading",
+			Timestamp:   "logged at",
+		},
+		TimestampFormat: "2006-01-02 15:04:05",
+		Location:        jakarta,
+		Comma:           ';',
+	})
+	if err != nil {
+		t.Fatalf("NewCSVReader: %v", err)
+	}
+
+	reading, line, err := reader.Next()
+	if err != nil || reading.Err != nil {
+		t.Fatalf("Next: %v %v", err, reading.Err)
+	}
+	req := reading.Request
+	if line != 2 || req.ID1 != "SENSOR-1" || req.ID2 != 1 || req.SensorType != "temperature" || req.SensorValue != 21.5 {
+		t.Fatalf("unexpected reading on line %d: %+v", line, req)
+	}
+	if want := time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC); !req.Timestamp.Equal(want) {
+		t.Fatalf("timestamp = %s, want %s", req.Timestamp, want)
+	}
+
+	// Bad value, missing column and bad timestamp only fail their own rows
+	for _, wantLine := range []int{3, 4, 5} {
+		reading, line, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if reading.Err == nil || line != wantLine {
+			t.Fatalf("expected an error on line %d, got line %d: %+v", wantLine, line, reading)
+		}
+	}
+
+	if _, _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestCSVReader_UnixTimestamps(t *testing.T) {
+	body := "id1,id2,sensor_type,sensor_value,timestamp\nSENSOR-1,1,temperature,1,1756382400000\n"
+
+	reader, err := codec.NewCSVReader(strings.NewReader(body), codec.CSVOptions{TimestampFormat: codec.CSVTimestampUnixMs})
+	if err != nil {
+		t.Fatalf("NewCSVReader: %v", err)
+	}
+	reading, _, err := reader.Next()
+	if err != nil || reading.Err != nil {
+		t.Fatalf("Next: %v %v", err, reading.Err)
+	}
+	if want := time.Unix(1756382400, 0); !reading.Request.Timestamp.Equal(want) {
+		t.Fatalf("timestamp = %s, want %s", reading.Request.Timestamp, want)
+	}
+}
+
+func TestCSVReader_MissingColumn(t *testing.T) {
+	_, err := codec.NewCSVReader(strings.NewReader("id1,id2,sensor_type,value,timestamp\n"), codec.CSVOptions{})
+	if err == nil {
+		t.Fatalf("expected a header without sensor_value to fail")
+	}
+}
@@ -0,0 +1,169 @@
+package codec_test_test
+
+import (
+	"iot-server/internal/codec"
+	"iot-server/internal/util"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func newSenML(t *testing.T, isCBOR bool) *codec.SenML {
+	t.Helper()
+	names, err := util.NewNamePattern("{id1}:{id2}:{sensor_type}", ":")
+	if err != nil {
+		t.Fatalf("NewNamePattern: %v", err)
+	}
+	senml := codec.NewSenML(names, isCBOR)
+	senml.Now = func() time.Time { return time.Unix(1756341777, 0) }
+	return senml
+}
+
+func TestSenML_DecodeJSONPack(t *testing.T) {
+	payload := []byte(`[
+		{"bn":"SENSOR-6:6:","bt":1756341777.5,"bv":20,"n":"temperature","v":1.5},
+		{"n":"humidity","t":10,"v":40},
+		{"bn":"SENSOR-7:7:","n":"door","vb":true},
+		{"n":"label","vs":"text only"},
+		{"n":"pressure","t":-30,"v":1000}
+	]`)
+
+	readings, isBatch, err := newSenML(t, false).Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !isBatch || len(readings) != 5 {
+		t.Fatalf("expected a batch of 5, got batch=%v with %d", isBatch, len(readings))
+	}
+
+	first := readings[0].Request
+	if first.ID1 != "SENSOR-6" || first.ID2 != 6 || first.SensorType != "temperature" || first.SensorValue != 21.5 {
+		t.Fatalf("unexpected first reading: %+v", first)
+	}
+	if !first.Timestamp.Equal(time.Unix(1756341777, 500000000)) {
+		t.Fatalf("unexpected first timestamp: %s", first.Timestamp)
+	}
+
+	second := readings[1].Request
+	if second.SensorType != "humidity" || second.SensorValue != 60 || !second.Timestamp.Equal(time.Unix(1756341787, 500000000)) {
+		t.Fatalf("unexpected second reading: %+v", second)
+	}
+
+	// A new base name applies from its record on, base time and value still hold
+	third := readings[2].Request
+	if third.ID1 != "SENSOR-7" || third.SensorType != "door" || third.SensorValue != 1 {
+		t.Fatalf("unexpected third reading: %+v", third)
+	}
+
+	if readings[3].Err == nil {
+		t.Fatalf("expected a string value to be rejected")
+	}
+
+	// Base time plus a relative time is still absolute
+	fifth := readings[4].Request
+	if !fifth.Timestamp.Equal(time.Unix(1756341747, 500000000)) {
+		t.Fatalf("unexpected fifth timestamp: %s", fifth.Timestamp)
+	}
+}
+
+func TestSenML_DecodeRelativeAndMissingTime(t *testing.T) {
+	payload := []byte(`[{"n":"SENSOR-6:6:temperature","v":1,"t":-60},{"n":"SENSOR-6:6:temperature","v":2}]`)
+
+	readings, _, err := newSenML(t, false).Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := readings[0].Request.Timestamp; !got.Equal(time.Unix(1756341717, 0)) {
+		t.Fatalf("expected time relative to now, got %s", got)
+	}
+	if got := readings[1].Request.Timestamp; !got.IsZero() {
+		t.Fatalf("expected missing time to be left to the receive time, got %s", got)
+	}
+}
+
+func TestSenML_DecodeZeroBaseResets(t *testing.T) {
+	payload := []byte(`[
+		{"bn":"SENSOR-6:6:","bt":1756341777,"bv":20,"n":"temperature","v":1},
+		{"bt":0,"bv":0,"n":"humidity","v":40}
+	]`)
+
+	readings, _, err := newSenML(t, false).Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	// An explicit zero base time and value replace the earlier ones
+	second := readings[1].Request
+	if second.SensorValue != 40 {
+		t.Fatalf("expected base value to be reset, got %v", second.SensorValue)
+	}
+	if !second.Timestamp.IsZero() {
+		t.Fatalf("expected base time to be reset, got %s", second.Timestamp)
+	}
+}
+
+func TestSenML_DecodeCBORPack(t *testing.T) {
+	// RFC 8428 CBOR labels: bn=-2, bt=-3, bv=-5, n=0, v=2, t=6
+	pack := []map[int]any{
+		{-2: "SENSOR-6:6:", -3: 1756341777, 0: "temperature", 2: 21.5},
+		{0: "humidity", 2: 60, 6: 10},
+	}
+	payload, err := cbor.Marshal(pack)
+	if err != nil {
+		t.Fatalf("cbor.Marshal: %v", err)
+	}
+
+	readings, _, err := newSenML(t, true).Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(readings) != 2 {
+		t.Fatalf("expected 2 readings, got %d", len(readings))
+	}
+	first, second := readings[0].Request, readings[1].Request
+	if first.ID1 != "SENSOR-6" || first.SensorType != "temperature" || first.SensorValue != 21.5 || !first.Timestamp.Equal(time.Unix(1756341777, 0)) {
+		t.Fatalf("unexpected first reading: %+v", first)
+	}
+	if second.SensorType != "humidity" || second.SensorValue != 60 || !second.Timestamp.Equal(time.Unix(1756341787, 0)) {
+		t.Fatalf("unexpected second reading: %+v", second)
+	}
+}
+
+func TestSenML_RejectedRecordReplaysOnItsOwn(t *testing.T) {
+	senml := newSenML(t, false)
+	payload := []byte(`[{"bn":"SENSOR-6:6:","bt":1756341777,"n":"temperature","v":1},{"n":"unmatched:name","v":2}]`)
+
+	readings, _, err := senml.Decode(payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if readings[1].Err == nil {
+		t.Fatalf("expected a name that does not match the pattern to be rejected")
+	}
+
+	// The raw record carries its resolved name and time
+	again, _, err := senml.Decode(readings[0].Raw)
+	if err != nil || len(again) != 1 {
+		t.Fatalf("Decode raw: %v %v", again, err)
+	}
+	if got := again[0].Request; got.ID1 != "SENSOR-6" || !got.Timestamp.Equal(time.Unix(1756341777, 0)) {
+		t.Fatalf("unexpected replayed reading: %+v", got)
+	}
+}
+
+func TestRegistry_ResolveSenML(t *testing.T) {
+	registry := codec.NewRegistry()
+	registry.Register(newSenML(t, false))
+	registry.Register(newSenML(t, true))
+
+	for contentType, want := range map[string]string{
+		codec.ContentTypeSenMLJSON: "senml-json",
+		codec.ContentTypeSenMLCBOR: "senml-cbor",
+	} {
+		got, err := registry.Resolve("", contentType)
+		if err != nil || got.Name() != want {
+			t.Fatalf("Resolve(%q) = %v, %v; want %s", contentType, got, err, want)
+		}
+	}
+}
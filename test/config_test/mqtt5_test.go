@@ -0,0 +1,100 @@
+package config_test_test
+
+import (
+	"bytes"
+	"iot-server/internal/broker"
+	"iot-server/internal/config"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// MQTT 5 properties survive the round trip through the broker
+func TestNewMqtt_Version5Properties(t *testing.T) {
+	host, port := startBroker(t)
+
+	v := viper.New()
+	v.Set("MQTT_PROTOCOL", "tcp")
+	v.Set("MQTT_HOST", host)
+	v.Set("MQTT_PORT", port)
+	v.Set("MQTT_CLEAN_SESSION", true)
+	v.Set("MQTT_VERSION", "5")
+	client := config.NewMqtt(v, logrus.New())
+	defer client.Disconnect(100 * time.Millisecond)
+	if !client.IsConnected() {
+		t.Fatalf("expected the MQTT 5 client to be connected")
+	}
+
+	received := make(chan broker.Message, 1)
+	if err := waitToken(client.Subscribe("iot/+/+/+", 1, func(msg broker.Message) {
+		msg.Ack()
+		received <- msg
+	})); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	properties := &broker.Properties{
+		ContentType:     "application/cbor",
+		ResponseTopic:   "iot/reply/SENSOR-1",
+		CorrelationData: []byte("42"),
+		MessageExpiry:   time.Minute,
+		User:            map[string]string{"trace_id": "abc", "firmware": "1.2.0"},
+	}
+	if err := waitToken(client.Publish("iot/SENSOR-1/1/temperature", 1, false, []byte{0xa0}, properties)); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		got := msg.Properties()
+		if msg.Topic() != "iot/SENSOR-1/1/temperature" || msg.Qos() != 1 || !bytes.Equal(msg.Payload(), []byte{0xa0}) {
+			t.Fatalf("unexpected message: %s qos=%d %x", msg.Topic(), msg.Qos(), msg.Payload())
+		}
+		if got.ContentType != "application/cbor" || got.ResponseTopic != "iot/reply/SENSOR-1" || string(got.CorrelationData) != "42" {
+			t.Fatalf("unexpected properties: %+v", got)
+		}
+		if got.User["trace_id"] != "abc" || got.User["firmware"] != "1.2.0" {
+			t.Fatalf("unexpected user properties: %v", got.User)
+		}
+		if got.MessageExpiry <= 0 || got.MessageExpiry > time.Minute {
+			t.Fatalf("unexpected message expiry: %s", got.MessageExpiry)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+}
+
+func TestNewMqtt_Version311DropsProperties(t *testing.T) {
+	host, port := startBroker(t)
+
+	v := viper.New()
+	v.Set("MQTT_PROTOCOL", "tcp")
+	v.Set("MQTT_HOST", host)
+	v.Set("MQTT_PORT", port)
+	v.Set("MQTT_CLEAN_SESSION", true)
+	v.Set("MQTT_VERSION", "3.1.1")
+	client := config.NewMqtt(v, logrus.New())
+	defer client.Disconnect(100 * time.Millisecond)
+
+	received := make(chan broker.Message, 1)
+	if err := waitToken(client.Subscribe("iot/#", 1, func(msg broker.Message) {
+		msg.Ack()
+		received <- msg
+	})); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := waitToken(client.Publish("iot/SENSOR-1/1/temperature", 1, false, []byte("{}"), &broker.Properties{ContentType: "application/json"})); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case msg := <-received:
+		if got := msg.Properties(); got.ContentType != "" || got.User != nil {
+			t.Fatalf("expected no properties on MQTT 3.1.1, got %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+}
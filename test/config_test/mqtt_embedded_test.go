@@ -0,0 +1,176 @@
+package config_test_test
+
+import (
+	"context"
+	"encoding/json"
+	"iot-server/internal/config"
+	"iot-server/internal/model"
+	"iot-server/internal/util"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func newEmbeddedConfig() *viper.Viper {
+	v := viper.New()
+	v.Set("MQTT_EMBEDDED_ENABLED", true)
+	v.Set("MQTT_EMBEDDED_ADDRESS", "127.0.0.1:0")
+	v.Set("MQTT_USER", "iot")
+	v.Set("MQTT_PASS", "secret")
+	v.Set("MQTT_CLEAN_SESSION", true)
+	return v
+}
+
+func connectEmbedded(v *viper.Viper, clientID, password string) (mqtt.Client, error) {
+	client := mqtt.NewClient(mqtt.NewClientOptions().
+		AddBroker("tcp://" + net.JoinHostPort(v.GetString("MQTT_HOST"), v.GetString("MQTT_PORT"))).
+		SetClientID(clientID).
+		SetUsername("iot").
+		SetPassword(password))
+	token := client.Connect()
+	if !token.WaitTimeout(5 * time.Second) {
+		return nil, context.DeadlineExceeded
+	}
+	return client, token.Error()
+}
+
+func TestEmbeddedBroker_RequiresCredentials(t *testing.T) {
+	v := newEmbeddedConfig()
+	embedded := config.NewEmbeddedBroker(v, logrus.New())
+	defer embedded.Close()
+
+	if _, err := connectEmbedded(v, "intruder", "wrong"); err == nil {
+		t.Fatalf("expected a wrong password to be refused")
+	}
+	client, err := connectEmbedded(v, "device", "secret")
+	if err != nil {
+		t.Fatalf("connect with MQTT_USER and MQTT_PASS: %v", err)
+	}
+	client.Disconnect(100)
+}
+
+// A reading published to the embedded broker is stored and then returned by
+// the search API
+func TestEmbeddedBroker_ReadingEndToEnd(t *testing.T) {
+	log := logrus.New()
+	v := newEmbeddedConfig()
+	v.Set("MQTT_TOPIC", "iot/{id1}/{id2}/{sensor_type}")
+	v.Set("MQTT_QOS", 1)
+	v.Set("MQTT_PUBLISH_ENABLED", true)
+	v.Set("AUTH_SECRET", "test-secret")
+	v.Set("ADMIN_ID", "admin")
+	v.Set("ADMIN_PASSWORD", "admin-password")
+	v.Set("RATE_LIMIT_MAX_REQUEST", 100)
+	v.Set("RATE_LIMIT_DURATION", 60)
+	v.Set("COMMAND_SWEEP_INTERVAL_SECONDS", 3600)
+
+	embedded := config.NewEmbeddedBroker(v, log)
+	defer embedded.Close()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer rdb.Close()
+
+	timestamp := time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO users`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "temperature").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)).
+		WithArgs(int64(7), 21.5, sqlmock.AnyArg(), sqlmock.AnyArg(), "device").
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sr.record_id, sr.sensor_id, sr.sensor_value, sr.timestamp, s.id1, s.id2, s.sensor_type`)).
+		WithArgs("SENSOR-1", int64(1), 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"record_id", "sensor_id", "sensor_value", "timestamp", "id1", "id2", "sensor_type"}).
+			AddRow(int64(10), int64(7), 21.5, timestamp, "SENSOR-1", int64(1), "temperature"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(1)))
+
+	mqttClient := config.NewMqtt(v, log)
+	app := config.NewEcho(v)
+	runtime := config.Bootstrap(&config.BootstrapConfig{
+		DB:       db,
+		App:      app,
+		Log:      log,
+		Validate: config.NewValidator(v),
+		Config:   v,
+		Mqtt:     mqttClient,
+		Redis:    rdb,
+	})
+	defer func() {
+		mqttClient.Disconnect(100 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = runtime.IngestPipeline.Stop(ctx)
+		_ = runtime.DeviceCommand.Stop(ctx)
+		_ = runtime.CSVImport.Stop(ctx)
+	}()
+
+	// The server republishes committed readings, which tells when it is stored
+	device, err := connectEmbedded(v, "device", "secret")
+	if err != nil {
+		t.Fatalf("connect device: %v", err)
+	}
+	defer device.Disconnect(100)
+	processed := make(chan []byte, 1)
+	if token := device.Subscribe("iot/processed/#", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		processed <- msg.Payload()
+	}); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe: %v", token.Error())
+	}
+
+	// Retained, so it reaches the server however its subscription is timed
+	token := device.Publish("iot/SENSOR-1/1/temperature", 1, true, `{"sensor_value":21.5,"timestamp":"2025-08-28T12:00:00Z"}`)
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("publish: %v", token.Error())
+	}
+	select {
+	case <-processed:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("reading was not processed: %v", mock.ExpectationsWereMet())
+	}
+
+	jwt, err := util.NewTokenUtil("test-secret", rdb).CreateToken(context.Background(), &model.Auth{ID: "admin", Role: "admin"})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/sensor/search/by-id?id1=SENSOR-1&id2=1", nil)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var response model.WebResponse[*model.SensorResponse]
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response: %v", err)
+	}
+	if response.Data == nil || response.Data.ID1 != "SENSOR-1" || len(response.Data.SensorsRecords) != 1 {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+	if record := response.Data.SensorsRecords[0]; record.SensorValue != 21.5 || !record.Timestamp.Equal(timestamp) {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
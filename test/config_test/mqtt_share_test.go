@@ -0,0 +1,215 @@
+package config_test_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"iot-server/internal/broker"
+	"iot-server/internal/codec"
+	"iot-server/internal/config"
+	"iot-server/internal/delivery/messaging"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-playground/validator/v10"
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func startBroker(t *testing.T) (host, port string) {
+	t.Helper()
+
+	server := mqttserver.New(&mqttserver.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	host, port, err := net.SplitHostPort(tcp.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return host, port
+}
+
+// waitToken waits for a broker operation to complete
+func waitToken(token broker.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timed out")
+	}
+}
+
+func TestNewMqttClientID(t *testing.T) {
+	v := viper.New()
+	v.Set("MQTT_CLEAN_SESSION", true)
+	log := logrus.New()
+
+	first, second := config.NewMqttClientID(v, log), config.NewMqttClientID(v, log)
+	if first == "" || first == second {
+		t.Fatalf("expected distinct generated client IDs, got %q and %q", first, second)
+	}
+
+	v.Set("MQTT_CLEAN_SESSION", false)
+	v.Set("MQTT_CLIENT_ID", "iot-server-{hostname}")
+	if got := config.NewMqttClientID(v, log); got == "iot-server-{hostname}" {
+		t.Fatalf("expected {hostname} to be expanded, got %q", got)
+	}
+
+	// A persistent session defaults to a stable per-instance ID
+	hostname, _ := os.Hostname()
+	v.Set("MQTT_CLIENT_ID", "")
+	if got := config.NewMqttClientID(v, log); got != "iot-server-"+hostname {
+		t.Fatalf("expected iot-server-%s, got %q", hostname, got)
+	}
+}
+
+// replica is a server instance consuming readings: its own MQTT client,
+// consumer and ingest pipeline in front of the shared database
+type replica struct {
+	client   broker.Client
+	pipeline *usecase.IngestPipeline
+}
+
+func startReplica(t *testing.T, host, port string, db *sql.DB, rdb *redis.Client, topic *util.TopicPattern) *replica {
+	t.Helper()
+
+	v := viper.New()
+	v.Set("MQTT_PROTOCOL", "tcp")
+	v.Set("MQTT_HOST", host)
+	v.Set("MQTT_PORT", port)
+	v.Set("MQTT_CLEAN_SESSION", true)
+	v.Set("MQTT_SHARE_GROUP", "iot-server")
+
+	log := logrus.New()
+	client := config.NewMqtt(v, log)
+	codecs := codec.NewRegistry()
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", usecase.TimestampWindow{},
+	)
+	deadLetterUsecase := usecase.NewDeadLetterUsecase(log, validator.New(), repository.NewDeadLetterRepository(db, log), sensorUsecase, codecs)
+	pipeline := usecase.NewIngestPipeline(log, deadLetterUsecase, 10, 1, usecase.OverflowBlock)
+	consumer := messaging.NewSensorConsumer(client, sensorUsecase, deadLetterUsecase, pipeline, codecs, log, topic, messaging.TopicPrecedencePayload)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = pipeline.Stop(ctx)
+		client.Disconnect(100 * time.Millisecond)
+	})
+
+	subscription := topic.SharedSubscription(config.NewMqttShareGroup(v, log))
+	if err := waitToken(client.Subscribe(subscription, 1, consumer.SensorMQTTHandler)); err != nil {
+		t.Fatalf("subscribe %s: %v", subscription, err)
+	}
+	return &replica{client: client, pipeline: pipeline}
+}
+
+// Replicas subscribing with the same share group store every reading exactly
+// once between them
+func TestSharedSubscription_ReadingStoredOnceAcrossReplicas(t *testing.T) {
+	const messages = 8
+	host, port := startBroker(t)
+
+	topic, err := util.NewTopicPattern("iot/{id1}/{id2}/{sensor_type}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	replicas := []*replica{
+		startReplica(t, host, port, db, rdb, topic),
+		startReplica(t, host, port, db, rdb, topic),
+	}
+	processed := func() (total int64) {
+		for _, r := range replicas {
+			total += r.pipeline.Stats().Processed
+		}
+		return total
+	}
+
+	publisher := mqtt.NewClient(mqtt.NewClientOptions().AddBroker("tcp://" + net.JoinHostPort(host, port)).SetClientID("publisher"))
+	if token := publisher.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("connect publisher: %v", token.Error())
+	}
+	t.Cleanup(func() { publisher.Disconnect(100) })
+
+	// One reading at a time, so the statements of a reading stay in order
+	for i := 0; i < messages; i++ {
+		sensorType := fmt.Sprintf("type%d", i)
+		sensorID := int64(100 + i)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+			WithArgs("SENSOR-1", int64(1), sensorType).
+			WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+				AddRow(sensorID, "SENSOR-1", int64(1), sensorType, nil))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records`)).
+			WithArgs(sensorID, float64(i+1), sqlmock.AnyArg(), sqlmock.AnyArg(), "device").
+			WillReturnResult(sqlmock.NewResult(sensorID, 1))
+		mock.ExpectCommit()
+
+		payload := fmt.Sprintf(`{"sensor_value":%d,"timestamp":"2025-08-28T12:00:00Z"}`, i+1)
+		token := publisher.Publish("iot/SENSOR-1/1/"+sensorType, 1, false, payload)
+		if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("publish: %v", token.Error())
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for processed() < int64(i+1) && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := processed(); got != int64(i+1) {
+			t.Fatalf("processed %d readings after %d published", got, i+1)
+		}
+	}
+	// Give a duplicate delivery the chance to show up
+	time.Sleep(100 * time.Millisecond)
+
+	var submitted, failed int64
+	perReplica := make([]int64, len(replicas))
+	for i, r := range replicas {
+		stats := r.pipeline.Stats()
+		submitted += stats.Submitted
+		failed += stats.Failed
+		perReplica[i] = stats.Submitted
+	}
+	if submitted != messages || processed() != messages || failed != 0 {
+		t.Fatalf("submitted %d, processed %d, failed %d; want %d each and none failed", submitted, processed(), failed, messages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("readings per replica: %v", perReplica)
+}
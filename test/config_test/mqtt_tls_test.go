@@ -0,0 +1,223 @@
+package config_test_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"iot-server/internal/config"
+	"iot-server/internal/util"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) serverConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewMqtt_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+
+	server := mqttserver.New(&mqttserver.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "tls", Address: "127.0.0.1:0", TLSConfig: ca.serverConfig(t)})
+	if err := server.AddListener(listener); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	host, port, _ := net.SplitHostPort(listener.Address())
+
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "iot-server", x509.ExtKeyUsageClientAuth)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem, time.Now())
+	writeFile(t, filepath.Join(dir, "client.pem"), certPEM, time.Now())
+	writeFile(t, filepath.Join(dir, "client.key"), keyPEM, time.Now())
+
+	v := viper.New()
+	v.Set("MQTT_PROTOCOL", "ssl")
+	v.Set("MQTT_HOST", host)
+	v.Set("MQTT_PORT", port)
+	v.Set("MQTT_CLEAN_SESSION", true)
+	v.Set("MQTT_TLS_CA_FILE", filepath.Join(dir, "ca.pem"))
+	v.Set("MQTT_TLS_CERT_FILE", filepath.Join(dir, "client.pem"))
+	v.Set("MQTT_TLS_KEY_FILE", filepath.Join(dir, "client.key"))
+	v.Set("MQTT_TLS_SERVER_NAME", "localhost")
+	v.Set("MQTT_TLS_MIN_VERSION", "1.3")
+
+	client := config.NewMqtt(v, logrus.New())
+	defer client.Disconnect(100 * time.Millisecond)
+	if !client.IsConnected() {
+		t.Fatalf("expected the client to connect over mutual TLS")
+	}
+}
+
+func TestTLSReloader_RotatesClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", ca.serverConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	commonNames := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				commonNames <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	certPEM, keyPEM := ca.issue(t, "client-a", x509.ExtKeyUsageClientAuth)
+	past := time.Now().Add(-time.Minute)
+	writeFile(t, caFile, ca.pem, past)
+	writeFile(t, certFile, certPEM, past)
+	writeFile(t, keyFile, keyPEM, past)
+
+	reloader, err := util.NewTLSReloader(caFile, certFile, keyFile, logrus.New())
+	if err != nil {
+		t.Fatalf("NewTLSReloader: %v", err)
+	}
+	tlsConfig := reloader.Config("localhost", tls.VersionTLS12, false)
+
+	dial := func() string {
+		t.Helper()
+		conn, err := tls.Dial("tcp", listener.Addr().String(), tlsConfig)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		select {
+		case name := <-commonNames:
+			return name
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not complete the handshake")
+			return ""
+		}
+	}
+
+	if name := dial(); name != "client-a" {
+		t.Fatalf("client certificate = %s, want client-a", name)
+	}
+
+	certPEM, keyPEM = ca.issue(t, "client-b", x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, certPEM, time.Now())
+	writeFile(t, keyFile, keyPEM, time.Now())
+	if name := dial(); name != "client-b" {
+		t.Fatalf("client certificate = %s, want the rotated client-b", name)
+	}
+
+	// The server name is verified
+	if conn, err := tls.Dial("tcp", listener.Addr().String(), reloader.Config("broker.example.com", tls.VersionTLS12, false)); err == nil {
+		conn.Close()
+		t.Fatalf("expected a server name mismatch to fail")
+	}
+}
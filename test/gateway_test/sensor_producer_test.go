@@ -0,0 +1,88 @@
+package gateway_test_test
+
+import (
+	"encoding/json"
+	"iot-server/internal/broker"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// doneToken is a token that completed successfully
+type doneToken struct{}
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (doneToken) Error() error { return nil }
+
+type published struct {
+	topic      string
+	qos        byte
+	retained   bool
+	payload    []byte
+	properties *broker.Properties
+}
+
+// recordingClient records publishes, other client methods are not used
+type recordingClient struct {
+	broker.Client
+	published []published
+}
+
+func (c *recordingClient) Publish(topic string, qos byte, retained bool, payload []byte, properties *broker.Properties) broker.Token {
+	c.published = append(c.published, published{topic, qos, retained, payload, properties})
+	return doneToken{}
+}
+
+func TestSensorProducer_Send(t *testing.T) {
+	client := &recordingClient{}
+	producer := messaging.NewSensorProducer(client, logrus.New(), "iot/processed/", 1, true)
+
+	event := &model.SensorReadingEvent{
+		SensorID:        7,
+		ID1:             "SENSOR-1",
+		ID2:             2,
+		SensorType:      "temperature",
+		SensorValue:     21.5,
+		Timestamp:       time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC),
+		TimestampSource: "device",
+		DuplicatePolicy: "keep_all",
+	}
+	if err := producer.Send(event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(client.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(client.published))
+	}
+	msg := client.published[0]
+	if msg.topic != "iot/processed/SENSOR-1/2/temperature" || msg.qos != 1 || !msg.retained {
+		t.Fatalf("unexpected publish: %s qos=%d retained=%t", msg.topic, msg.qos, msg.retained)
+	}
+	if msg.properties == nil || msg.properties.ContentType != "application/json" {
+		t.Fatalf("unexpected properties: %+v", msg.properties)
+	}
+
+	var got model.SensorReadingEvent
+	if err := json.Unmarshal(msg.payload, &got); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if got.SensorID != 7 || got.SensorValue != 21.5 || !got.Timestamp.Equal(event.Timestamp) || got.DuplicatePolicy != "keep_all" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestSensorProducer_TopicEscapesLevels(t *testing.T) {
+	producer := messaging.NewSensorProducer(nil, logrus.New(), "iot/processed", 0, false)
+
+	topic := producer.Topic(&model.SensorReadingEvent{ID1: "A/B", ID2: 1, SensorType: "temp+#"})
+	if topic != "iot/processed/A_B/1/temp__" {
+		t.Fatalf("topic = %s", topic)
+	}
+}
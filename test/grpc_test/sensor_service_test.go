@@ -0,0 +1,274 @@
+package grpc_test_test
+
+import (
+	"context"
+	"io"
+	"iot-server/api/proto/iotserverv1"
+	"iot-server/internal/delivery/grpc"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fixture struct {
+	client    iotserverv1.SensorServiceClient
+	mock      sqlmock.Sqlmock
+	tokenUtil *util.TokenUtil
+}
+
+func startServer(t *testing.T) *fixture {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	log := logrus.New()
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", usecase.TimestampWindow{},
+	)
+	tokenUtil := util.NewTokenUtil("secret", rdb)
+	auth := grpc.NewAuthInterceptor(tokenUtil, util.NewRateLimiterUtil(rdb, log, 100, 60), log, grpc.SensorServiceRoles)
+
+	server, err := grpc.NewServer("127.0.0.1:0", auth, grpc.NewSensorService(sensorUsecase, log), log)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Stop(ctx)
+	})
+
+	conn, err := grpclib.NewClient(server.Address(), grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &fixture{client: iotserverv1.NewSensorServiceClient(conn), mock: mock, tokenUtil: tokenUtil}
+}
+
+// login returns a context carrying the token of a user with the given role
+func (f *fixture) login(t *testing.T, id string, role entity.Role) context.Context {
+	t.Helper()
+	token, err := f.tokenUtil.CreateToken(context.Background(), &model.Auth{ID: id, Role: string(role)})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func expectCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("expected %s, got %v", want, err)
+	}
+}
+
+func TestSensorService_Auth(t *testing.T) {
+	f := startServer(t)
+	req := &iotserverv1.UpdateByIdRequest{Id1: "SENSOR-1", Id2: 1, SensorValue: 20}
+
+	_, err := f.client.UpdateById(context.Background(), req)
+	expectCode(t, err, codes.Unauthenticated)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	_, err = f.client.UpdateById(ctx, req)
+	expectCode(t, err, codes.Unauthenticated)
+
+	// Mutations are admin-only, as on the HTTP API
+	_, err = f.client.UpdateById(f.login(t, "user", entity.RoleUser), req)
+	expectCode(t, err, codes.PermissionDenied)
+
+	// Live batches are admin-only like /ingest; ingest only gets /bulk and /import
+	_, err = f.client.CreateReadings(f.login(t, "ingest", entity.RoleIngest), &iotserverv1.SensorReadingBatch{})
+	expectCode(t, err, codes.PermissionDenied)
+
+	f.mock.ExpectExec(regexp.QuoteMeta(`UPDATE sensor_records sr`)).
+		WithArgs(20.0, "SENSOR-1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	resp, err := f.client.UpdateById(f.login(t, "admin", entity.RoleAdmin), req)
+	if err != nil {
+		t.Fatalf("UpdateById: %v", err)
+	}
+	if resp.GetUpdated() != 3 {
+		t.Fatalf("updated %d, want 3", resp.GetUpdated())
+	}
+
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthInterceptor_DeniesUnlistedMethod(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	log := logrus.New()
+	tokenUtil := util.NewTokenUtil("secret", rdb)
+	auth := grpc.NewAuthInterceptor(tokenUtil, util.NewRateLimiterUtil(rdb, log, 100, 60), log, grpc.SensorServiceRoles)
+
+	token, err := tokenUtil.CreateToken(context.Background(), &model.Auth{ID: "admin", Role: string(entity.RoleAdmin)})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+
+	called := false
+	handler := func(ctx context.Context, req any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	_, err = auth.Unary(ctx, nil, &grpclib.UnaryServerInfo{FullMethod: "/iotserver.v1.SensorService/Unlisted"}, handler)
+	expectCode(t, err, codes.PermissionDenied)
+	if called {
+		t.Fatal("handler of an unlisted method was called")
+	}
+}
+
+func TestSensorService_InvalidArgument(t *testing.T) {
+	f := startServer(t)
+
+	_, err := f.client.SearchByTimeRange(f.login(t, "user", entity.RoleUser), &iotserverv1.SearchByTimeRangeRequest{
+		Start: timestamppb.New(time.Date(2025, 8, 28, 0, 0, 0, 0, time.UTC)),
+	})
+	expectCode(t, err, codes.InvalidArgument)
+}
+
+// The stream walks every page of the time range
+func TestSensorService_StreamTimeRange(t *testing.T) {
+	f := startServer(t)
+	start := time.Date(2025, 8, 28, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	columns := []string{"record_id", "sensor_id", "sensor_value", "timestamp", "sensor_id", "id1", "id2", "sensor_type"}
+	pages := [][]float64{{1, 2}, {3}}
+	for i, values := range pages {
+		rows := sqlmock.NewRows(columns)
+		for j, value := range values {
+			rows.AddRow(int64(i*2+j+1), int64(7), value, start.Add(time.Duration(i*2+j)*time.Minute), int64(7), "SENSOR-1", int64(1), "temperature")
+		}
+		f.mock.ExpectQuery(regexp.QuoteMeta(`WHERE r.timestamp BETWEEN ? AND ?`)).
+			WithArgs(start, end, 2, i*2).
+			WillReturnRows(rows)
+		f.mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+			WithArgs(start, end).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	}
+
+	stream, err := f.client.StreamTimeRange(f.login(t, "user", entity.RoleUser), &iotserverv1.StreamTimeRangeRequest{
+		Start:    timestamppb.New(start),
+		End:      timestamppb.New(end),
+		PageSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("StreamTimeRange: %v", err)
+	}
+
+	var values []float64
+	for {
+		sensor, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if sensor.GetId1() != "SENSOR-1" || sensor.GetId2() != 1 {
+			t.Fatalf("unexpected sensor %v", sensor)
+		}
+		for _, record := range sensor.GetRecords() {
+			values = append(values, record.GetSensorValue())
+		}
+	}
+	if len(values) != 3 || values[0] != 1 || values[1] != 2 || values[2] != 3 {
+		t.Fatalf("streamed %v, want [1 2 3]", values)
+	}
+
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// With a sensor type, an update only touches the records of that sensor
+func TestSensorService_UpdateByIdOfSensorType(t *testing.T) {
+	f := startServer(t)
+
+	f.mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "humidity").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(8), "SENSOR-1", int64(1), "humidity", nil))
+	f.mock.ExpectExec(regexp.QuoteMeta(`UPDATE sensor_records SET sensor_value = ? WHERE sensor_id = ?`)).
+		WithArgs(20.0, int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	resp, err := f.client.UpdateById(f.login(t, "admin", entity.RoleAdmin), &iotserverv1.UpdateByIdRequest{
+		Id1: "SENSOR-1", Id2: 1, SensorType: "humidity", SensorValue: 20,
+	})
+	if err != nil {
+		t.Fatalf("UpdateById: %v", err)
+	}
+	if resp.GetUpdated() != 2 {
+		t.Fatalf("updated %d, want 2", resp.GetUpdated())
+	}
+
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A device search spanning several sensor types labels each record
+func TestSensorService_SearchByIdSeveralSensorTypes(t *testing.T) {
+	f := startServer(t)
+	at := time.Date(2025, 8, 28, 0, 0, 0, 0, time.UTC)
+
+	f.mock.ExpectQuery(regexp.QuoteMeta(`WHERE id1 = ? AND id2 = ?`)).
+		WithArgs("SENSOR-1", int64(1), 20, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"record_id", "sensor_id", "sensor_value", "timestamp", "id1", "id2", "sensor_type"}).
+			AddRow(int64(1), int64(7), 21.5, at, "SENSOR-1", int64(1), "temperature").
+			AddRow(int64(2), int64(8), 40.0, at, "SENSOR-1", int64(1), "humidity"))
+	f.mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(2)))
+
+	resp, err := f.client.SearchById(f.login(t, "user", entity.RoleUser), &iotserverv1.SearchByIdRequest{Id1: "SENSOR-1", Id2: 1})
+	if err != nil {
+		t.Fatalf("SearchById: %v", err)
+	}
+
+	sensor := resp.GetSensor()
+	if sensor.GetSensorType() != "" || len(sensor.GetRecords()) != 2 {
+		t.Fatalf("unexpected sensor %v", sensor)
+	}
+	if sensor.GetRecords()[0].GetSensorType() != "temperature" || sensor.GetRecords()[1].GetSensorType() != "humidity" {
+		t.Fatalf("unexpected record types %v", sensor.GetRecords())
+	}
+
+	if err := f.mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
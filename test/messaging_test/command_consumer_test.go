@@ -0,0 +1,103 @@
+package messaging_test_test
+
+import (
+	"context"
+	"errors"
+	"iot-server/internal/delivery/messaging"
+	"iot-server/internal/entity"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+func newCommandConsumer(t *testing.T) (*messaging.CommandConsumer, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	topic, err := util.NewTopicPattern("iot/command/{id1}/{id2}/ack")
+	if err != nil {
+		t.Fatalf("NewTopicPattern: %v", err)
+	}
+
+	log := logrus.New()
+	commandUsecase := usecase.NewDeviceCommandUsecase(
+		log, validator.New(),
+		repository.NewDeviceCommandRepository(db, log),
+		repository.NewSensorRepository(db, log),
+		nil, time.Minute, time.Hour,
+	)
+	t.Cleanup(func() { _ = commandUsecase.Stop(context.Background()) })
+	return messaging.NewCommandConsumer(commandUsecase, log, topic), mock
+}
+
+var completeCommand = regexp.QuoteMeta(`SET status = ?, result = ?, error_message = ?, completed_at = ?`)
+
+func TestCommandConsumer_DecodesDeviceFromTopic(t *testing.T) {
+	consumer, mock := newCommandConsumer(t)
+
+	// The command producer escapes "SENSOR/1" to a single topic level
+	mock.ExpectExec(completeCommand).
+		WithArgs(entity.CommandAcked, []byte(`{"seconds":30}`), "", sqlmock.AnyArg(), int64(42), "SENSOR/1", int64(1),
+			entity.CommandPending, entity.CommandDelivered, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	msg := &fakeMessage{topic: "iot/command/SENSOR%2F1/1/ack", payload: []byte(`{"command_id":42,"status":"ok","result":{"seconds":30}}`)}
+	consumer.CommandAckHandler(msg)
+
+	if msg.acked.Load() != 1 {
+		t.Fatalf("expected the ack to be acknowledged")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandConsumer_InvalidAcksAreDropped(t *testing.T) {
+	consumer, mock := newCommandConsumer(t)
+
+	for _, msg := range []*fakeMessage{
+		{topic: "iot/command/SENSOR-1/1/ack", payload: []byte(`not json`)},
+		{topic: "iot/command/SENSOR%zz/1/ack", payload: []byte(`{"command_id":42,"status":"ok"}`)},
+		{topic: "iot/command/SENSOR-1/x/ack", payload: []byte(`{"command_id":42,"status":"ok"}`)},
+		{topic: "iot/command/SENSOR-1/1/ack", payload: []byte(`{"command_id":42,"status":"maybe"}`)},
+	} {
+		consumer.CommandAckHandler(msg)
+		if msg.acked.Load() != 1 {
+			t.Fatalf("expected %s %s to be dropped", msg.topic, msg.payload)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandConsumer_DatabaseFailureIsRetried(t *testing.T) {
+	consumer, mock := newCommandConsumer(t)
+
+	// Every attempt fails, the ack is logged and acknowledged
+	for i := 0; i < 3; i++ {
+		mock.ExpectExec(completeCommand).WillReturnError(errors.New("connection refused"))
+	}
+
+	msg := &fakeMessage{topic: "iot/command/SENSOR-1/1/ack", payload: []byte(`{"command_id":42,"status":"ok"}`)}
+	consumer.CommandAckHandler(msg)
+
+	if msg.acked.Load() != 1 {
+		t.Fatalf("expected the message to be acknowledged")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+package messaging_test_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iot-server/internal/broker"
+	"iot-server/internal/codec"
+	"iot-server/internal/delivery/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeMessage struct {
+	topic   string
+	payload []byte
+	acked   atomic.Int64
+}
+
+func (m *fakeMessage) Topic() string                 { return m.topic }
+func (m *fakeMessage) Payload() []byte               { return m.payload }
+func (m *fakeMessage) Qos() byte                     { return 1 }
+func (m *fakeMessage) Retained() bool                { return false }
+func (m *fakeMessage) Properties() broker.Properties { return broker.Properties{} }
+func (m *fakeMessage) Ack()                          { m.acked.Add(1) }
+
+func newSensorConsumer(t *testing.T, topic *util.TopicPattern) (*messaging.SensorConsumer, *usecase.DeadLetterUsecase, *usecase.IngestPipeline, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	log := logrus.New()
+	codecs := codec.NewRegistry()
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", usecase.TimestampWindow{},
+	)
+	deadLetterUsecase := usecase.NewDeadLetterUsecase(log, validator.New(), repository.NewDeadLetterRepository(db, log), sensorUsecase, codecs)
+	pipeline := usecase.NewIngestPipeline(log, deadLetterUsecase, 10, 1, usecase.OverflowBlock)
+
+	consumer := messaging.NewSensorConsumer(nil, sensorUsecase, deadLetterUsecase, pipeline, codecs, log, topic, messaging.TopicPrecedencePayload)
+	deadLetterUsecase.Topics = consumer
+	return consumer, deadLetterUsecase, pipeline, mock
+}
+
+const reading = `{"id1":"SENSOR-1","id2":1,"sensor_type":"temperature","sensor_value":21.5}`
+
+func TestSensorConsumer_DuplicateKeyIsDeadLettered(t *testing.T) {
+	consumer, _, pipeline, mock := newSensorConsumer(t, nil)
+
+	findSensor := regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(findSensor).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensors`)).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectQuery(findSensor).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	// Not retried, stored as a dead letter and acknowledged
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO dead_letters`)).
+		WithArgs("iot/sensor/data", codec.ContentTypeJSON, sqlmock.AnyArg(), "database", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	msg := &fakeMessage{topic: "iot/sensor/data", payload: []byte(reading)}
+	consumer.SensorMQTTHandler(msg)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if msg.acked.Load() != 1 {
+		t.Fatalf("expected the message to be acknowledged")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorConsumer_TransientFailureIsRetried(t *testing.T) {
+	consumer, _, pipeline, mock := newSensorConsumer(t, nil)
+
+	// Every attempt fails, the reading is dead-lettered and acknowledged
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO dead_letters`)).
+		WithArgs("iot/sensor/data", codec.ContentTypeJSON, sqlmock.AnyArg(), "database", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	msg := &fakeMessage{topic: "iot/sensor/data", payload: []byte(reading)}
+	consumer.SensorMQTTHandler(msg)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if msg.acked.Load() != 1 {
+		t.Fatalf("expected the message to be acknowledged")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorConsumer_FailedDeadLetterIsAcknowledged(t *testing.T) {
+	consumer, _, pipeline, mock := newSensorConsumer(t, nil)
+
+	// Neither the reading nor its dead letter can be stored
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	}
+	for i := 0; i < 3; i++ {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO dead_letters`)).WillReturnError(errors.New("connection refused"))
+	}
+
+	msg := &fakeMessage{topic: "iot/sensor/data", payload: []byte(reading)}
+	consumer.SensorMQTTHandler(msg)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Acknowledged anyway, so it does not hold back later messages
+	if msg.acked.Load() != 1 {
+		t.Fatalf("expected the message to be acknowledged")
+	}
+	if stats := pipeline.Stats(); stats.Failed != 1 {
+		t.Fatalf("expected 1 failed message, got %+v", stats)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterUsecase_ReplayAppliesStoredTopic(t *testing.T) {
+	topic, err := util.NewTopicPattern("iot/{id1}/{id2}/{sensor_type}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, deadLetterUsecase, _, mock := newSensorConsumer(t, topic)
+
+	receivedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM dead_letters`)).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"dead_letter_id", "topic", "content_type", "payload", "error_class", "error_message", "received_at", "replay_count", "last_replayed_at"}).
+			AddRow(int64(5), "iot/SENSOR-9/3/humidity", codec.ContentTypeJSON, []byte(`{"sensor_value":55}`), "database", "Internal Server Error", receivedAt, 0, nil))
+	mock.ExpectBegin()
+	// The device identity comes from the stored topic
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-9", int64(3), "humidity").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-9", int64(3), "humidity", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records`)).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM dead_letters`)).
+		WithArgs(int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	response, err := deadLetterUsecase.Replay(context.Background(), &model.DeadLetterGetRequest{ID: 5})
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if response.Replayed != 1 || response.Failed != 0 {
+		t.Fatalf("unexpected replay response: %+v", response)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterUsecase_StoreKeepsInternalError(t *testing.T) {
+	_, deadLetterUsecase, _, mock := newSensorConsumer(t, nil)
+
+	cause := echo.NewHTTPError(http.StatusInternalServerError).SetInternal(errors.New("Error 1205: Lock wait timeout exceeded"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO dead_letters`)).
+		WithArgs("iot/sensor/data", codec.ContentTypeJSON, sqlmock.AnyArg(), "database", "Internal Server Error: Error 1205: Lock wait timeout exceeded", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := deadLetterUsecase.Store(context.Background(), "iot/sensor/data", codec.ContentTypeJSON, []byte(reading), "database", cause, time.Now()); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
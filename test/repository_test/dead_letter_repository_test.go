@@ -0,0 +1,160 @@
+package repository_test_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/repository"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sirupsen/logrus"
+)
+
+func newDeadLetterRepo(t *testing.T) (*repository.DeadLetterRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	return repository.NewDeadLetterRepository(db, logrus.New()), mock, db
+}
+
+var deadLetterColumns = []string{
+	"dead_letter_id", "topic", "content_type", "payload", "error_class", "error_message", "received_at", "replay_count", "last_replayed_at",
+}
+
+func TestDeadLetterRepository_Create_Success(t *testing.T) {
+	repo, mock, db := newDeadLetterRepo(t)
+	defer db.Close()
+
+	d := &entity.DeadLetter{
+		Topic:        "iot/sensor/data",
+		ContentType:  "application/json",
+		Payload:      []byte("{bad"),
+		ErrorClass:   entity.DeadLetterInvalidPayload,
+		ErrorMessage: "unexpected end of JSON input",
+		ReceivedAt:   time.Now(),
+	}
+
+	query := regexp.QuoteMeta(`
+		INSERT INTO dead_letters (topic, content_type, payload, error_class, error_message, received_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	mock.ExpectExec(query).
+		WithArgs(d.Topic, d.ContentType, d.Payload, d.ErrorClass, d.ErrorMessage, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(55, 1))
+
+	if err := repo.Create(context.Background(), d); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d.DeadLetterID != 55 {
+		t.Fatalf("expected DeadLetterID=55, got %d", d.DeadLetterID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterRepository_Create_InsertError(t *testing.T) {
+	repo, mock, db := newDeadLetterRepo(t)
+	defer db.Close()
+
+	d := &entity.DeadLetter{Topic: "t", Payload: []byte("x"), ErrorClass: entity.DeadLetterValidation, ReceivedAt: time.Now()}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO dead_letters`)).
+		WillReturnError(errors.New("insert failed"))
+
+	if err := repo.Create(context.Background(), d); err == nil {
+		t.Fatalf("expected error")
+	}
+	if d.DeadLetterID != 0 {
+		t.Fatalf("expected DeadLetterID unchanged, got %d", d.DeadLetterID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterRepository_FindByID_NotFound(t *testing.T) {
+	repo, mock, db := newDeadLetterRepo(t)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM dead_letters`)).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows(deadLetterColumns))
+
+	_, err := repo.FindByID(context.Background(), 9)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected sql.ErrNoRows, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterRepository_FindAll_Success(t *testing.T) {
+	repo, mock, db := newDeadLetterRepo(t)
+	defer db.Close()
+
+	now := time.Now()
+	q := regexp.QuoteMeta(`
+		SELECT dead_letter_id, topic, content_type, payload, error_class, error_message, received_at, replay_count, last_replayed_at
+		FROM dead_letters
+		WHERE (? = '' OR error_class = ?)
+		ORDER BY received_at DESC, dead_letter_id DESC
+		LIMIT ? OFFSET ?
+	`)
+	rows := sqlmock.NewRows(deadLetterColumns).
+		AddRow(int64(2), "iot/a", "application/json", []byte(`{"id1":"A"}`), entity.DeadLetterValidation, "bad", now, 1, now).
+		AddRow(int64(1), "iot/b", "application/json", []byte(`{`), entity.DeadLetterValidation, "bad", now, 0, nil)
+	mock.ExpectQuery(q).
+		WithArgs(entity.DeadLetterValidation, entity.DeadLetterValidation, 10, 10).
+		WillReturnRows(rows)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).
+		WithArgs(entity.DeadLetterValidation, entity.DeadLetterValidation).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(12)))
+
+	out, meta, err := repo.FindAll(context.Background(), entity.DeadLetterValidation, 2, 10)
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if len(out) != 2 || out[0].DeadLetterID != 2 || out[0].LastReplayedAt == nil || out[1].LastReplayedAt != nil {
+		t.Fatalf("unexpected rows: %+v", out)
+	}
+	if meta.TotalItem != 12 || meta.TotalPage != 2 {
+		t.Fatalf("unexpected paging: %+v", meta)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterRepository_Purge_Success(t *testing.T) {
+	repo, mock, db := newDeadLetterRepo(t)
+	defer db.Close()
+
+	q := regexp.QuoteMeta(`
+		DELETE FROM dead_letters
+		WHERE (? = '' OR error_class = ?)
+		  AND received_at < ?
+	`)
+	mock.ExpectExec(q).
+		WithArgs("", "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := repo.Purge(context.Background(), "", time.Now())
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 deleted, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,123 @@
+package repository_test_test
+
+import (
+	"context"
+	"database/sql"
+	"iot-server/internal/entity"
+	"iot-server/internal/repository"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sirupsen/logrus"
+)
+
+func newDeviceCommandRepo(t *testing.T) (*repository.DeviceCommandRepository, sqlmock.Sqlmock, *sql.DB) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	return repository.NewDeviceCommandRepository(db, logrus.New()), mock, db
+}
+
+var deviceCommandColumns = []string{
+	"command_id", "id1", "id2", "name", "params", "status", "result", "error_message",
+	"created_by", "created_at", "delivered_at", "completed_at", "expires_at",
+}
+
+func TestDeviceCommandRepository_Create_Success(t *testing.T) {
+	repo, mock, db := newDeviceCommandRepo(t)
+	defer db.Close()
+
+	now := time.Now()
+	c := &entity.DeviceCommand{
+		ID1:       "SENSOR-1",
+		ID2:       1,
+		Name:      "set_sampling_interval",
+		Params:    []byte(`{"seconds":30}`),
+		Status:    entity.CommandPending,
+		CreatedBy: "admin",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Minute),
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO device_commands (id1, id2, name, params, status, created_by, created_at, expires_at)`)).
+		WithArgs(c.ID1, c.ID2, c.Name, c.Params, c.Status, c.CreatedBy, c.CreatedAt, c.ExpiresAt).
+		WillReturnResult(sqlmock.NewResult(12, 1))
+
+	if err := repo.Create(context.Background(), c); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if c.CommandID != 12 {
+		t.Fatalf("expected CommandID=12, got %d", c.CommandID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCommandRepository_FindByID_NullColumns(t *testing.T) {
+	repo, mock, db := newDeviceCommandRepo(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM device_commands`)).
+		WithArgs(int64(12)).
+		WillReturnRows(sqlmock.NewRows(deviceCommandColumns).
+			AddRow(int64(12), "SENSOR-1", int64(1), "reboot", nil, entity.CommandPending, nil, "", "admin", now, nil, nil, now.Add(time.Minute)))
+
+	c, err := repo.FindByID(context.Background(), 12)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	if c.Params != nil || c.Result != nil || c.DeliveredAt != nil || c.CompletedAt != nil {
+		t.Fatalf("expected NULL columns to stay empty: %+v", c)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCommandRepository_Complete_OnlyOpenCommandsOfDevice(t *testing.T) {
+	repo, mock, db := newDeviceCommandRepo(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectExec(regexp.QuoteMeta(`WHERE command_id = ? AND id1 = ? AND id2 = ? AND status IN (?, ?) AND expires_at >= ?`)).
+		WithArgs(entity.CommandAcked, []byte(`{"ok":true}`), "", now, int64(12), "SENSOR-1", int64(1), entity.CommandPending, entity.CommandDelivered, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	affected, err := repo.Complete(context.Background(), 12, "SENSOR-1", 1, entity.CommandAcked, []byte(`{"ok":true}`), "", now)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if affected != 0 {
+		t.Fatalf("expected no affected rows, got %d", affected)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCommandRepository_ExpireOpen(t *testing.T) {
+	repo, mock, db := newDeviceCommandRepo(t)
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectExec(regexp.QuoteMeta(`WHERE status IN (?, ?) AND expires_at < ?`)).
+		WithArgs(entity.CommandTimedOut, now, entity.CommandPending, entity.CommandDelivered, now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	expired, err := repo.ExpireOpen(context.Background(), now)
+	if err != nil {
+		t.Fatalf("ExpireOpen: %v", err)
+	}
+	if expired != 3 {
+		t.Fatalf("expected 3 expired, got %d", expired)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"iot-server/internal/entity"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
 )
 
@@ -139,3 +140,355 @@ func TestSensorRecordRepository_CreateTx_LastInsertIDError(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+func TestSensorRecordRepository_CreateBatchTx_Success(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	now := time.Now()
+	recs := []*entity.SensorRecord{
+		{SensorID: 1, SensorValue: 1.5, Timestamp: now},
+		{SensorID: 2, SensorValue: 2.5, Timestamp: now},
+		{SensorID: 1, SensorValue: 3.5, Timestamp: now},
+	}
+
+	query := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)
+	mock.ExpectExec(query).
+		WithArgs(
+			int64(1), 1.5, sqlmock.AnyArg(), nil, "device",
+			int64(2), 2.5, sqlmock.AnyArg(), nil, "device",
+			int64(1), 3.5, sqlmock.AnyArg(), nil, "device",
+		).
+		WillReturnResult(sqlmock.NewResult(100, 3))
+
+	duplicates, err := repo.CreateBatchTx(context.Background(), tx, recs)
+	if err != nil {
+		t.Fatalf("CreateBatchTx returned error: %v", err)
+	}
+	for i, rec := range recs {
+		if duplicates[i] {
+			t.Fatalf("record %d unexpectedly reported as duplicate", i)
+		}
+		if rec.RecordID != int64(100+i) {
+			t.Fatalf("expected RecordID=%d, got %d", 100+i, rec.RecordID)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSensorRecordRepository_CreateBatchTx_InsertError(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	recs := []*entity.SensorRecord{
+		{SensorID: 1, SensorValue: 1.5, Timestamp: time.Now()},
+	}
+
+	query := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)
+	mock.ExpectExec(query).
+		WithArgs(int64(1), 1.5, sqlmock.AnyArg(), nil, "device").
+		WillReturnError(errors.New("insert failed"))
+
+	if _, err := repo.CreateBatchTx(context.Background(), tx, recs); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if recs[0].RecordID != 0 {
+		t.Fatalf("expected RecordID unchanged (0), got %d", recs[0].RecordID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSensorRecordRepository_CreateBatchTx_DuplicateKey(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	now := time.Now()
+	recs := []*entity.SensorRecord{
+		{SensorID: 1, SensorValue: 1.5, Timestamp: now},
+		{SensorID: 2, SensorValue: 2.5, Timestamp: now},
+		{SensorID: 3, SensorValue: 3.5, Timestamp: now},
+	}
+	duplicateKey := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	row := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WillReturnError(duplicateKey)
+	// The chunk is retried row by row
+	mock.ExpectExec(row).
+		WithArgs(int64(1), 1.5, sqlmock.AnyArg(), nil, "device").
+		WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectExec(row).
+		WithArgs(int64(2), 2.5, sqlmock.AnyArg(), nil, "device").
+		WillReturnError(duplicateKey)
+	mock.ExpectExec(row).
+		WithArgs(int64(3), 3.5, sqlmock.AnyArg(), nil, "device").
+		WillReturnResult(sqlmock.NewResult(102, 1))
+
+	duplicates, err := repo.CreateBatchTx(context.Background(), tx, recs)
+	if err != nil {
+		t.Fatalf("CreateBatchTx returned error: %v", err)
+	}
+	if duplicates[0] || !duplicates[1] || duplicates[2] {
+		t.Fatalf("unexpected duplicates: %v", duplicates)
+	}
+	if recs[0].RecordID != 100 || recs[1].RecordID != 0 || recs[2].RecordID != 102 {
+		t.Fatalf("unexpected record ids: %d, %d, %d", recs[0].RecordID, recs[1].RecordID, recs[2].RecordID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSensorRecordRepository_CreateBatchTx_Empty(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	if _, err := repo.CreateBatchTx(context.Background(), tx, nil); err != nil {
+		t.Fatalf("CreateBatchTx returned error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSensorRecordRepository_UpsertBatchTx_Policies(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	duplicateKey := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	insertQuery := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)
+	dedupeQuery := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source, dedupe_timestamp) VALUES (?, ?, ?, ?, ?, ?)`)
+	overwriteQuery := regexp.QuoteMeta(`ON DUPLICATE KEY UPDATE`)
+
+	tests := []struct {
+		name      string
+		policy    string
+		expect    func(mock sqlmock.Sqlmock)
+		duplicate bool
+		recordID  int64
+	}{
+		{
+			name:   "keep all inserts corrected value",
+			policy: entity.DuplicateKeepAll,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device").
+					WillReturnResult(sqlmock.NewResult(8, 1))
+			},
+			recordID: 8,
+		},
+		{
+			name:   "keep all skips exact resend",
+			policy: entity.DuplicateKeepAll,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insertQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device").
+					WillReturnError(duplicateKey)
+			},
+			duplicate: true,
+		},
+		{
+			name:   "ignore inserts first value",
+			policy: entity.DuplicateIgnore,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(dedupeQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(8, 1))
+			},
+			recordID: 8,
+		},
+		{
+			name:   "ignore skips corrected value",
+			policy: entity.DuplicateIgnore,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(dedupeQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnError(duplicateKey)
+			},
+			duplicate: true,
+		},
+		{
+			name:   "overwrite inserts first value",
+			policy: entity.DuplicateOverwrite,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(overwriteQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(8, 1))
+			},
+			recordID: 8,
+		},
+		{
+			name:   "overwrite updates stored row",
+			policy: entity.DuplicateOverwrite,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(overwriteQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(7, 2))
+			},
+			recordID: 7,
+		},
+		{
+			name:   "overwrite skips stored value",
+			policy: entity.DuplicateOverwrite,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(overwriteQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(7, 0))
+			},
+			duplicate: true,
+		},
+		{
+			name:   "overwrite skips value stored in another row",
+			policy: entity.DuplicateOverwrite,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(overwriteQuery).
+					WithArgs(int64(1), 2.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+					WillReturnError(duplicateKey)
+			},
+			duplicate: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, tx := beginTx(t)
+			defer db.Close()
+			defer func() {
+				mock.ExpectRollback()
+				_ = tx.Rollback()
+			}()
+
+			// No existing rows are read, the unique keys decide
+			tt.expect(mock)
+
+			repo := repository.NewSensorRecordRepository(logrus.New())
+			rec := &entity.SensorRecord{
+				SensorID:    1,
+				SensorValue: 2.5,
+				Timestamp:   now,
+				Sensor:      entity.Sensor{SensorID: 1, DuplicatePolicy: tt.policy},
+			}
+			duplicates, err := repo.UpsertBatchTx(context.Background(), tx, []*entity.SensorRecord{rec})
+			if err != nil {
+				t.Fatalf("UpsertBatchTx returned error: %v", err)
+			}
+			if duplicates[0] != tt.duplicate {
+				t.Fatalf("expected duplicate=%v, got %v", tt.duplicate, duplicates[0])
+			}
+			if rec.RecordID != tt.recordID {
+				t.Fatalf("expected RecordID=%d, got %d", tt.recordID, rec.RecordID)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestSensorRecordRepository_UpsertBatchTx_MessageID(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now().Truncate(time.Second)
+	duplicateKey := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	row := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WillReturnError(duplicateKey)
+	// unique_message rejects the stored message id
+	mock.ExpectExec(row).
+		WithArgs(int64(1), 2.5, sqlmock.AnyArg(), "m-1", "device").
+		WillReturnError(duplicateKey)
+	mock.ExpectExec(row).
+		WithArgs(int64(1), 3.5, sqlmock.AnyArg(), "m-2", "device").
+		WillReturnResult(sqlmock.NewResult(8, 1))
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	sensor := entity.Sensor{SensorID: 1, DuplicatePolicy: entity.DuplicateKeepAll}
+	recs := []*entity.SensorRecord{
+		// Already stored message, sent again with a different timestamp
+		{SensorID: 1, SensorValue: 2.5, Timestamp: now, MessageID: "m-1", Sensor: sensor},
+		{SensorID: 1, SensorValue: 3.5, Timestamp: now, MessageID: "m-2", Sensor: sensor},
+	}
+	duplicates, err := repo.UpsertBatchTx(context.Background(), tx, recs)
+	if err != nil {
+		t.Fatalf("UpsertBatchTx returned error: %v", err)
+	}
+	if !duplicates[0] || duplicates[1] {
+		t.Fatalf("unexpected duplicates: %v", duplicates)
+	}
+	if recs[1].RecordID != 8 {
+		t.Fatalf("expected RecordID=8, got %d", recs[1].RecordID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// Readings of the same timestamp in one batch are written once, with the
+// value of the last one
+func TestSensorRecordRepository_UpsertBatchTx_OverwriteInBatch(t *testing.T) {
+	db, mock, tx := beginTx(t)
+	defer db.Close()
+	defer func() {
+		mock.ExpectRollback()
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now().Truncate(time.Second)
+	mock.ExpectExec(regexp.QuoteMeta(`ON DUPLICATE KEY UPDATE`)).
+		WithArgs(int64(1), 3.5, sqlmock.AnyArg(), nil, "device", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+
+	repo := repository.NewSensorRecordRepository(logrus.New())
+	sensor := entity.Sensor{SensorID: 1, DuplicatePolicy: entity.DuplicateOverwrite}
+	recs := []*entity.SensorRecord{
+		{SensorID: 1, SensorValue: 2.5, Timestamp: now, Sensor: sensor},
+		{SensorID: 1, SensorValue: 3.5, Timestamp: now, Sensor: sensor},
+	}
+	duplicates, err := repo.UpsertBatchTx(context.Background(), tx, recs)
+	if err != nil {
+		t.Fatalf("UpsertBatchTx returned error: %v", err)
+	}
+	if duplicates[0] || !duplicates[1] {
+		t.Fatalf("unexpected duplicates: %v", duplicates)
+	}
+	if recs[0].RecordID != 8 || recs[0].SensorValue != 3.5 {
+		t.Fatalf("expected record 8 with value 3.5, got %d with %v", recs[0].RecordID, recs[0].SensorValue)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
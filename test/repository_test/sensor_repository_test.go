@@ -140,20 +140,20 @@ func TestSensorRepository_FindByUnique_Success(t *testing.T) {
 	id1, id2, st := "S1", int64(2), "temp"
 
 	query := regexp.QuoteMeta(`
-		SELECT sensor_id, id1, id2, sensor_type
+		SELECT sensor_id, id1, id2, sensor_type, duplicate_policy
 		FROM sensors
 		WHERE id1 = ? AND id2 = ? AND sensor_type = ?
 		LIMIT 1
 	`)
-	rows := sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type"}).
-		AddRow(int64(10), id1, id2, st)
+	rows := sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+		AddRow(int64(10), id1, id2, st, "ignore")
 	mock.ExpectQuery(query).WithArgs(id1, id2, st).WillReturnRows(rows)
 
 	got, err := repo.FindByUnique(context.Background(), id1, id2, st)
 	if err != nil {
 		t.Fatalf("FindByUnique: %v", err)
 	}
-	if got.SensorID != 10 || got.ID1 != id1 || got.ID2 != id2 || got.SensorType != st {
+	if got.SensorID != 10 || got.ID1 != id1 || got.ID2 != id2 || got.SensorType != st || got.DuplicatePolicy != "ignore" {
 		t.Fatalf("unexpected sensor: %+v", got)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -166,7 +166,7 @@ func TestSensorRepository_FindByUnique_NotFound(t *testing.T) {
 	defer db.Close()
 
 	query := regexp.QuoteMeta(`
-		SELECT sensor_id, id1, id2, sensor_type
+		SELECT sensor_id, id1, id2, sensor_type, duplicate_policy
 		FROM sensors
 		WHERE id1 = ? AND id2 = ? AND sensor_type = ?
 		LIMIT 1
@@ -587,3 +587,102 @@ func TestSensorRepository_UpdateSensorValueByIdAndTimeRange_RowsAffectedError(t
 		t.Fatal(err)
 	}
 }
+
+//
+// Sensor types of a device
+//
+
+func TestSensorRepository_FindSensorRecordsByIdCombination_SeveralTypes(t *testing.T) {
+	repo, mock, db := newRepoWithDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"record_id", "sensor_id", "sensor_value", "timestamp", "id1", "id2", "sensor_type"}).
+		AddRow(int64(1), int64(10), 21.5, now, "S1", int64(2), "temp").
+		AddRow(int64(2), int64(11), 40.0, now, "S1", int64(2), "humidity")
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id1 = ? AND id2 = ?`)).WithArgs("S1", int64(2), 10, 0).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*)`)).WithArgs("S1", int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(2)))
+
+	s, _, err := repo.FindSensorRecordsByIdCombination(context.Background(), "S1", 2, 1, 10)
+	if err != nil {
+		t.Fatalf("FindSensorRecordsByIdCombination: %v", err)
+	}
+	if s.SensorType != "" {
+		t.Fatalf("expected no sensor type for several types, got %q", s.SensorType)
+	}
+	if got := s.Records[1].Sensor; got.SensorID != 11 || got.ID1 != "S1" || got.ID2 != 2 || got.SensorType != "humidity" {
+		t.Fatalf("unexpected record sensor: %+v", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorRepository_FindSensorRecordsBySensorID_TimeRange(t *testing.T) {
+	repo, mock, db := newRepoWithDB(t)
+	defer db.Close()
+
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT record_id, sensor_id, sensor_value, timestamp FROM sensor_records WHERE sensor_id = ? AND timestamp BETWEEN ? AND ? ORDER BY timestamp ASC LIMIT ? OFFSET ?`)).
+		WithArgs(int64(10), start, end, 5, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"record_id", "sensor_id", "sensor_value", "timestamp"}).
+			AddRow(int64(6), int64(10), 1.5, start))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM sensor_records WHERE sensor_id = ? AND timestamp BETWEEN ? AND ?`)).
+		WithArgs(int64(10), start, end).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(6)))
+
+	records, meta, err := repo.FindSensorRecordsBySensorID(context.Background(), 10, start, end, 2, 5)
+	if err != nil {
+		t.Fatalf("FindSensorRecordsBySensorID: %v", err)
+	}
+	if len(records) != 1 || records[0].RecordID != 6 || meta.TotalItem != 6 || meta.TotalPage != 2 {
+		t.Fatalf("unexpected result: %+v %+v", records, meta)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorRepository_DeleteRecordsBySensorID(t *testing.T) {
+	repo, mock, db := newRepoWithDB(t)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sensor_records WHERE sensor_id = ?`)).
+		WithArgs(int64(10)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := repo.DeleteRecordsBySensorID(context.Background(), 10, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("DeleteRecordsBySensorID: %v", err)
+	}
+	if n != 4 {
+		t.Fatalf("expected 4 rows affected, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorRepository_UpdateSensorValuesBySensorID_TimeRange(t *testing.T) {
+	repo, mock, db := newRepoWithDB(t)
+	defer db.Close()
+
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE sensor_records SET sensor_value = ? WHERE sensor_id = ? AND timestamp BETWEEN ? AND ?`)).
+		WithArgs(7.5, int64(10), start, end).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := repo.UpdateSensorValuesBySensorID(context.Background(), 10, start, end, 7.5)
+	if err != nil {
+		t.Fatalf("UpdateSensorValuesBySensorID: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 rows affected, got %d", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
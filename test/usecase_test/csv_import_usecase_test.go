@@ -0,0 +1,97 @@
+package usecase_test_test
+
+import (
+	"context"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCSVImportUsecase_Import(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+	// Historical readings are stored even though the window rejects old data
+	sensorUsecase.Timestamps = usecase.TimestampWindow{MaxPast: time.Hour, LateData: usecase.LateDataReject}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "temperature").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", "ignore"))
+	// The first reading is stored already, unique_dedupe rejects it
+	row := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source, dedupe_timestamp) VALUES (?, ?, ?, ?, ?, ?)`)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source, dedupe_timestamp) VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)`)).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectExec(row).
+		WithArgs(int64(7), 21.5, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil, "device", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectExec(row).
+		WithArgs(int64(7), 22.0, time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC), nil, "device", time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	file := filepath.Join(t.TempDir(), "logger.csv")
+	body := "id1,id2,sensor_type,sensor_value,timestamp\n" +
+		"SENSOR-1,1,temperature,21.5,2020-01-01T00:00:00Z\n" +
+		"SENSOR-1,1,temperature,22,2020-01-01T00:01:00Z\n" +
+		"SENSOR-1,1,temperature,oops,2020-01-01T00:02:00Z\n"
+	if err := os.WriteFile(file, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	importUsecase := usecase.NewCSVImportUsecase(sensorUsecase.Log, sensorUsecase.Validate, sensorUsecase.Redis, sensorUsecase)
+	job, err := importUsecase.Start(context.Background(), &model.CSVImportRequest{File: file, FileName: "logger.csv"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status == usecase.CSVImportRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if job, err = importUsecase.Get(context.Background(), &model.CSVImportGetRequest{ID: job.ID}); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+
+	if job.Status != usecase.CSVImportCompleted {
+		t.Fatalf("status = %s (%s), want completed", job.Status, job.Error)
+	}
+	if job.Rows != 3 || job.Imported != 1 || job.Duplicates != 1 || job.Failed != 1 || job.BytesRead != int64(len(body)) {
+		t.Fatalf("unexpected progress: %+v", job)
+	}
+	if len(job.Errors) != 1 || job.Errors[0].Line != 4 {
+		t.Fatalf("unexpected failed rows: %+v", job.Errors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVImportUsecase_InvalidMapping(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	importUsecase := usecase.NewCSVImportUsecase(logrus.New(), validator.New(), rdb, nil)
+
+	file := filepath.Join(t.TempDir(), "logger.csv")
+	if err := os.WriteFile(file, []byte("id1,id2,sensor_type,value,timestamp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := importUsecase.Start(context.Background(), &model.CSVImportRequest{File: file, Temporary: true})
+	if err == nil {
+		t.Fatalf("expected a header without sensor_value to fail")
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary file to be removed, got %v", err)
+	}
+}
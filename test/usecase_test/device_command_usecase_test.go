@@ -0,0 +1,152 @@
+package usecase_test_test
+
+import (
+	"context"
+	"errors"
+	"iot-server/internal/entity"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+var deviceCommandColumns = []string{
+	"command_id", "id1", "id2", "name", "params", "status", "result", "error_message",
+	"created_by", "created_at", "delivered_at", "completed_at", "expires_at",
+}
+
+func newDeviceCommandUsecase(t *testing.T) (*usecase.DeviceCommandUsecase, sqlmock.Sqlmock, *recordingClient) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	log := logrus.New()
+	client := &recordingClient{}
+	commandUsecase := usecase.NewDeviceCommandUsecase(
+		log, validator.New(),
+		repository.NewDeviceCommandRepository(db, log),
+		repository.NewSensorRepository(db, log),
+		messaging.NewCommandProducer(client, log, "iot/command", 1, nil),
+		time.Minute, time.Hour,
+	)
+	t.Cleanup(func() { _ = commandUsecase.Stop(context.Background()) })
+	return commandUsecase, mock, client
+}
+
+func TestDeviceCommandUsecase_Create(t *testing.T) {
+	commandUsecase, mock, client := newDeviceCommandUsecase(t)
+
+	now := time.Now().UTC()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM sensors WHERE id1 = ? AND id2 = ?)`)).
+		WithArgs("SENSOR/1", int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO device_commands`)).
+		WillReturnResult(sqlmock.NewResult(42, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`SET status = ?, delivered_at = ?`)).
+		WithArgs(entity.CommandDelivered, sqlmock.AnyArg(), int64(42), entity.CommandPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM device_commands`)).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows(deviceCommandColumns).
+			AddRow(int64(42), "SENSOR/1", int64(1), "reboot", nil, entity.CommandDelivered, nil, "", "admin", now, now, nil, now.Add(time.Minute)))
+
+	response, err := commandUsecase.Create(context.Background(), &model.DeviceCommandCreateRequest{ID1: "SENSOR/1", ID2: 1, Name: "reboot"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if response.ID != 42 || response.Status != entity.CommandDelivered {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	// id1 is escaped to a single topic level
+	if len(client.topics) != 1 || client.topics[0] != "iot/command/SENSOR%2F1/1" {
+		t.Fatalf("unexpected publishes: %v", client.topics)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCommandUsecase_Create_UnknownDevice(t *testing.T) {
+	commandUsecase, mock, client := newDeviceCommandUsecase(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).
+		WithArgs("SENSOR-9", int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := commandUsecase.Create(context.Background(), &model.DeviceCommandCreateRequest{ID1: "SENSOR-9", ID2: 9, Name: "reboot"})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+	if len(client.topics) != 0 {
+		t.Fatalf("expected no publish, got %v", client.topics)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceCommandUsecase_Ack(t *testing.T) {
+	complete := regexp.QuoteMeta(`WHERE command_id = ? AND id1 = ? AND id2 = ? AND status IN (?, ?) AND expires_at >= ?`)
+	ack := func() *model.DeviceCommandAck {
+		return &model.DeviceCommandAck{CommandID: 42, Status: "ok", ID1: "SENSOR-1", ID2: 1}
+	}
+
+	tests := []struct {
+		name     string
+		ack      *model.DeviceCommandAck
+		affected int64
+		status   string
+	}{
+		{name: "acked", ack: ack(), affected: 1, status: entity.CommandAcked},
+		{name: "failed", ack: &model.DeviceCommandAck{CommandID: 42, Status: "error", Error: "busy", ID1: "SENSOR-1", ID2: 1}, affected: 1, status: entity.CommandFailed},
+		// Unknown, foreign, duplicate and expired acks match no open command
+		{name: "unknown device", ack: &model.DeviceCommandAck{CommandID: 42, Status: "ok", ID1: "SENSOR-9", ID2: 9}, status: entity.CommandAcked},
+		{name: "duplicate", ack: ack(), status: entity.CommandAcked},
+		{name: "expired", ack: ack(), status: entity.CommandAcked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commandUsecase, mock, _ := newDeviceCommandUsecase(t)
+
+			mock.ExpectExec(complete).
+				WithArgs(tt.status, []byte(nil), tt.ack.Error, sqlmock.AnyArg(), int64(42), tt.ack.ID1, tt.ack.ID2,
+					entity.CommandPending, entity.CommandDelivered, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			if err := commandUsecase.Ack(context.Background(), tt.ack); err != nil {
+				t.Fatalf("Ack: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDeviceCommandUsecase_Ack_Invalid(t *testing.T) {
+	commandUsecase, mock, _ := newDeviceCommandUsecase(t)
+
+	err := commandUsecase.Ack(context.Background(), &model.DeviceCommandAck{CommandID: 42, Status: "maybe", ID1: "SENSOR-1", ID2: 1})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
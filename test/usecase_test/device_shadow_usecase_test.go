@@ -0,0 +1,280 @@
+package usecase_test_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+var deviceShadowColumns = []string{
+	"id1", "id2", "desired", "desired_version", "desired_updated_at", "reported", "reported_version", "reported_updated_at",
+}
+
+// newDeviceShadowUsecase publishes deltas to client, or not at all when nil
+func newDeviceShadowUsecase(t *testing.T, client *recordingClient) (*usecase.DeviceShadowUsecase, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	log := logrus.New()
+	var producer *messaging.ShadowProducer
+	if client != nil {
+		producer = messaging.NewShadowProducer(client, log, "iot/shadow", 1)
+	}
+	return usecase.NewDeviceShadowUsecase(
+		db, log, validator.New(),
+		repository.NewDeviceShadowRepository(db, log),
+		repository.NewSensorRepository(db, log),
+		producer,
+	), mock
+}
+
+// expectDevice expects the lookup of a device and whether it exists
+func expectDevice(mock sqlmock.Sqlmock, exists bool) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM sensors WHERE id1 = ? AND id2 = ?)`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+}
+
+var shadowLockColumns = []string{"id1", "id2", "desired", "desired_version", "reported", "reported_version", "published_delta"}
+
+func TestDeviceShadowUsecase_UpdateDesired(t *testing.T) {
+	shadowUsecase, mock := newDeviceShadowUsecase(t, nil)
+	reportedAt := time.Now()
+
+	expectDevice(mock, true)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO device_shadows (id1, id2)`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM device_shadows`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnRows(sqlmock.NewRows(deviceShadowColumns).
+			AddRow("SENSOR-1", int64(1), []byte(`{"interval":60,"led":"on"}`), int64(2), reportedAt,
+				[]byte(`{"interval":60,"led":"on","firmware":"1.2"}`), int64(5), reportedAt))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE device_shadows SET desired = ?, desired_version = desired_version + 1`)).
+		WithArgs([]byte(`{"interval":30}`), sqlmock.AnyArg(), "SENSOR-1", int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	response, err := shadowUsecase.UpdateDesired(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"interval":30,"led":null}`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateDesired: %v", err)
+	}
+	if response.DesiredVersion != 3 || response.ReportedVersion != 5 {
+		t.Fatalf("unexpected versions: %+v", response)
+	}
+	if string(response.Delta) != `{"interval":30}` {
+		t.Fatalf("delta = %s", response.Delta)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceShadowUsecase_UpdateDesired_VersionConflict(t *testing.T) {
+	shadowUsecase, mock := newDeviceShadowUsecase(t, nil)
+
+	expectDevice(mock, true)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO device_shadows (id1, id2)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM device_shadows`)).
+		WillReturnRows(sqlmock.NewRows(deviceShadowColumns).
+			AddRow("SENSOR-1", int64(1), []byte(`{"interval":60}`), int64(4), time.Now(), nil, int64(0), nil))
+
+	version := int64(3)
+	_, err := shadowUsecase.UpdateDesired(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:     "SENSOR-1",
+		ID2:     1,
+		State:   json.RawMessage(`{"interval":30}`),
+		Version: &version,
+	})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceShadowUsecase_UpdateReported_RejectsNonObject(t *testing.T) {
+	shadowUsecase, _ := newDeviceShadowUsecase(t, nil)
+
+	_, err := shadowUsecase.UpdateReported(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`[1,2]`),
+	})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v", err)
+	}
+}
+
+func TestDeviceShadowUsecase_UpdateDesired_UnknownDevice(t *testing.T) {
+	shadowUsecase, mock := newDeviceShadowUsecase(t, nil)
+
+	expectDevice(mock, false)
+
+	_, err := shadowUsecase.UpdateDesired(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"interval":30}`),
+	})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectShadowUpdate expects a successful update of a shadow with the given
+// documents, up to the lock taken to publish
+func expectShadowUpdate(mock sqlmock.Sqlmock, desired, reported string, desiredVersion, reportedVersion int64) {
+	expectDevice(mock, true)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO device_shadows (id1, id2)`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM device_shadows`)).
+		WillReturnRows(sqlmock.NewRows(deviceShadowColumns).
+			AddRow("SENSOR-1", int64(1), []byte(desired), desiredVersion, time.Now(), []byte(reported), reportedVersion, time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE device_shadows`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+}
+
+func TestDeviceShadowUsecase_PublishesStoredDelta(t *testing.T) {
+	client := &recordingClient{}
+	shadowUsecase, mock := newDeviceShadowUsecase(t, client)
+
+	expectShadowUpdate(mock, `{"interval":60}`, `{"interval":60}`, 1, 1)
+	// A concurrent update stored version 3 first, its delta is the one sent
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id1, id2, desired, desired_version, reported, reported_version, published_delta`)).
+		WithArgs("SENSOR-1", int64(1)).
+		WillReturnRows(sqlmock.NewRows(shadowLockColumns).
+			AddRow("SENSOR-1", int64(1), []byte(`{"interval":10}`), int64(3), []byte(`{"interval":60}`), int64(1), nil))
+	mock.ExpectExec(regexp.QuoteMeta(`SET published_delta = ?`)).
+		WithArgs([]byte(`{"interval":10}`), "SENSOR-1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := shadowUsecase.UpdateDesired(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"interval":30}`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateDesired: %v", err)
+	}
+
+	if len(client.topics) != 1 || client.topics[0] != "iot/shadow/SENSOR-1/1/delta" {
+		t.Fatalf("unexpected publishes: %v", client.topics)
+	}
+	var delta model.DeviceShadowDelta
+	if err := json.Unmarshal(client.payloads[0], &delta); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if delta.Version != 3 || delta.State["interval"] != float64(10) {
+		t.Fatalf("unexpected delta: %+v", delta)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceShadowUsecase_SkipsPublishedDelta(t *testing.T) {
+	client := &recordingClient{}
+	shadowUsecase, mock := newDeviceShadowUsecase(t, client)
+
+	expectShadowUpdate(mock, `{"interval":60}`, `{}`, 1, 0)
+	// The delta of the stored documents was already sent
+	mock.ExpectQuery(regexp.QuoteMeta(`published_delta`)).
+		WillReturnRows(sqlmock.NewRows(shadowLockColumns).
+			AddRow("SENSOR-1", int64(1), []byte(`{"interval":60}`), int64(1), []byte(`{"firmware":"1.2"}`), int64(1), []byte(`{"interval":60}`)))
+	mock.ExpectRollback()
+
+	_, err := shadowUsecase.UpdateReported(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"firmware":"1.2"}`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateReported: %v", err)
+	}
+	if len(client.topics) != 0 {
+		t.Fatalf("expected no publish, got %v", client.topics)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceShadowUsecase_FailedPublishIsSentOnNextReport(t *testing.T) {
+	client := &recordingClient{err: errors.New("not connected")}
+	shadowUsecase, mock := newDeviceShadowUsecase(t, client)
+
+	lock := regexp.QuoteMeta(`published_delta`)
+	pending := sqlmock.NewRows(shadowLockColumns).
+		AddRow("SENSOR-1", int64(1), []byte(`{"interval":30}`), int64(2), []byte(`{"interval":60}`), int64(1), nil)
+
+	// The publish fails, the sent delta is not recorded
+	expectShadowUpdate(mock, `{"interval":60}`, `{"interval":60}`, 1, 1)
+	mock.ExpectQuery(lock).WillReturnRows(pending)
+	mock.ExpectRollback()
+
+	_, err := shadowUsecase.UpdateDesired(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"interval":30}`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateDesired: %v", err)
+	}
+
+	// A report that leaves the delta unchanged publishes it again
+	client.err = nil
+	expectShadowUpdate(mock, `{"interval":30}`, `{"interval":60}`, 2, 1)
+	mock.ExpectQuery(lock).
+		WillReturnRows(sqlmock.NewRows(shadowLockColumns).
+			AddRow("SENSOR-1", int64(1), []byte(`{"interval":30}`), int64(2), []byte(`{"interval":60,"led":"on"}`), int64(2), nil))
+	mock.ExpectExec(regexp.QuoteMeta(`SET published_delta = ?`)).
+		WithArgs([]byte(`{"interval":30}`), "SENSOR-1", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err = shadowUsecase.UpdateReported(context.Background(), &model.DeviceShadowUpdateRequest{
+		ID1:   "SENSOR-1",
+		ID2:   1,
+		State: json.RawMessage(`{"led":"on"}`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateReported: %v", err)
+	}
+	if len(client.topics) != 2 {
+		t.Fatalf("expected the delta to be published twice, got %v", client.topics)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,242 @@
+package usecase_test_test
+
+import (
+	"context"
+	"fmt"
+	"iot-server/internal/usecase"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newJob(process func() bool, acked *atomic.Int64) *usecase.IngestJob {
+	return &usecase.IngestJob{
+		Topic:      "iot/sensor/data",
+		ReceivedAt: time.Now(),
+		Process:    func(done func(bool)) { done(process()) },
+		Ack:        func() { acked.Add(1) },
+	}
+}
+
+func TestIngestPipeline_ProcessesAndAcks(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 10, 3, usecase.OverflowBlock)
+
+	var acked, processed atomic.Int64
+	for i := 0; i < 20; i++ {
+		ok := i%4 != 0 // every fourth job fails and is acknowledged anyway
+		pipeline.Submit(newJob(func() bool {
+			processed.Add(1)
+			return ok
+		}, &acked))
+	}
+
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	stats := pipeline.Stats()
+	if processed.Load() != 20 || stats.Processed != 20 || stats.Submitted != 20 {
+		t.Fatalf("expected 20 processed jobs, got %d (stats %+v)", processed.Load(), stats)
+	}
+	if acked.Load() != 20 || stats.Failed != 5 {
+		t.Fatalf("expected 20 acked and 5 failed, got %d acked (stats %+v)", acked.Load(), stats)
+	}
+}
+
+func TestIngestPipeline_DropOldest(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 2, 1, usecase.OverflowDropOldest)
+
+	var acked atomic.Int64
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	// Occupy the only worker so the queue fills up
+	pipeline.Submit(newJob(func() bool {
+		close(started)
+		<-release
+		return true
+	}, &acked))
+	<-started
+
+	for i := 0; i < 5; i++ {
+		pipeline.Submit(newJob(func() bool { return true }, &acked))
+	}
+
+	stats := pipeline.Stats()
+	if stats.QueueDepth != 2 || stats.Dropped != 3 {
+		t.Fatalf("expected depth 2 and 3 dropped, got %+v", stats)
+	}
+
+	close(release)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Dropped jobs are acknowledged as well, so nothing stays in flight
+	if acked.Load() != 6 {
+		t.Fatalf("expected 6 acks, got %d", acked.Load())
+	}
+}
+
+func TestIngestPipeline_AcksInArrivalOrder(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 10, 3, usecase.OverflowBlock)
+
+	var mu sync.Mutex
+	acked := make([]int, 0)
+	release := make(chan struct{})
+	finished := make(chan struct{}, 3)
+	for i := 0; i < 4; i++ {
+		i := i
+		pipeline.Submit(&usecase.IngestJob{
+			Topic:      "iot/sensor/data",
+			ReceivedAt: time.Now(),
+			Process: func(done func(bool)) {
+				if i == 0 {
+					<-release // the oldest message is the slowest
+				} else {
+					finished <- struct{}{}
+				}
+				done(i != 2) // the third one fails
+			},
+			Ack: func() {
+				mu.Lock()
+				defer mu.Unlock()
+				acked = append(acked, i)
+			},
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		<-finished
+	}
+	mu.Lock()
+	held := len(acked)
+	mu.Unlock()
+	if held != 0 {
+		t.Fatalf("expected acks to wait for the oldest message, got %v", acked)
+	}
+
+	close(release)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// The failed message is acknowledged in its turn as well
+	if want := "[0 1 2 3]"; fmt.Sprint(acked) != want {
+		t.Fatalf("expected acks %s, got %v", want, acked)
+	}
+}
+
+func TestIngestPipeline_AsyncCompletion(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 10, 1, usecase.OverflowBlock)
+
+	var acked atomic.Int64
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		pipeline.Submit(&usecase.IngestJob{
+			Topic:      "iot/sensor/data",
+			ReceivedAt: time.Now(),
+			// Completes later, like a reading waiting for its write-behind batch
+			Process: func(done func(bool)) {
+				started <- struct{}{}
+				go func() {
+					<-release
+					done(true)
+				}()
+			},
+			Ack: func() { acked.Add(1) },
+		})
+	}
+
+	// The only worker is free again before the first job completes
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("job %d not started while the first one is pending", i)
+		}
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- pipeline.Stop(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatalf("Stop returned before the pending jobs completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if acked.Load() != 2 {
+		t.Fatalf("expected 2 acks, got %d", acked.Load())
+	}
+}
+
+func TestIngestPipeline_SlowAckDoesNotBlockWorkers(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 10, 2, usecase.OverflowBlock)
+
+	var mu sync.Mutex
+	acked := make([]int, 0)
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	finished := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		pipeline.Submit(&usecase.IngestJob{
+			Topic:      "iot/sensor/data",
+			ReceivedAt: time.Now(),
+			Process: func(done func(bool)) {
+				done(true)
+				finished <- struct{}{}
+			},
+			Ack: func() {
+				if i == 0 {
+					close(entered)
+					<-release // the broker is slow to take the first ack
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				acked = append(acked, i)
+			},
+		})
+	}
+
+	<-entered
+	// The other jobs finish while the first ack is still being sent
+	for i := 1; i < 3; i++ {
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("a worker is blocked by a pending ack")
+		}
+	}
+
+	close(release)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if want := "[0 1 2]"; fmt.Sprint(acked) != want {
+		t.Fatalf("expected acks %s, got %v", want, acked)
+	}
+}
+
+func TestIngestPipeline_CountsRefusedAfterStop(t *testing.T) {
+	pipeline := usecase.NewIngestPipeline(logrus.New(), nil, 10, 1, usecase.OverflowBlock)
+	if err := pipeline.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	var acked atomic.Int64
+	pipeline.Submit(newJob(func() bool { return true }, &acked))
+
+	// Left unacknowledged for redelivery after the restart
+	stats := pipeline.Stats()
+	if acked.Load() != 0 || stats.Refused != 1 || stats.Submitted != 0 {
+		t.Fatalf("expected 1 refused message, got %d acked (stats %+v)", acked.Load(), stats)
+	}
+}
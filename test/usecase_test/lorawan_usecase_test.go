@@ -0,0 +1,127 @@
+package usecase_test_test
+
+import (
+	"context"
+	"errors"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+func newLoRaWANUsecase(t *testing.T) (*usecase.LoRaWANUsecase, sqlmock.Sqlmock) {
+	t.Helper()
+
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+	log := logrus.New()
+	return usecase.NewLoRaWANUsecase(log, validator.New(), sensorUsecase, repository.NewLoRaWANUplinkRepository(sensorUsecase.DB, log)), mock
+}
+
+func expectSensor(mock sqlmock.Sqlmock, id1 string, id2 int64, sensorType string, sensorID int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs(id1, id2, sensorType).
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(sensorID, id1, id2, sensorType, nil))
+}
+
+// Every measurement of the decoded object is a reading of DevEUI/fPort, with
+// the metadata of the best gateway kept alongside
+func TestLoRaWANUsecase_ChirpStackUplink(t *testing.T) {
+	lorawan, mock := newLoRaWANUsecase(t)
+	receivedAt := time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	expectSensor(mock, "A1B2C3D4E5F60708", 2, "battery.voltage", 7)
+	expectSensor(mock, "A1B2C3D4E5F60708", 2, "motion", 8)
+	expectSensor(mock, "A1B2C3D4E5F60708", 2, "temperature", 9)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WithArgs(
+			int64(7), 3.6, receivedAt, "dedup-1", "device",
+			int64(8), 1.0, receivedAt, "dedup-1", "device",
+			int64(9), 21.5, receivedAt, "dedup-1", "device",
+		).
+		WillReturnResult(sqlmock.NewResult(10, 3))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO lorawan_uplinks`)).
+		WithArgs("A1B2C3D4E5F60708", int64(2), "chirpstack", "dedup-1", int64(42), int64(-80), 7.5, "gw-2", 3, receivedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	response, err := lorawan.ChirpStackUplink(context.Background(), &model.ChirpStackUplink{
+		DeduplicationID: "dedup-1",
+		Time:            receivedAt,
+		DeviceInfo:      model.ChirpStackDeviceInfo{DevEUI: "a1b2c3d4e5f60708"},
+		FCnt:            42,
+		FPort:           2,
+		Object: map[string]any{
+			"temperature": 21.5,
+			"battery":     map[string]any{"voltage": 3.6},
+			"motion":      true,
+			"status":      "ok",
+		},
+		RxInfo: []model.ChirpStackRxInfo{
+			{GatewayID: "gw-1", RSSI: -110, SNR: -3},
+			{GatewayID: "gw-2", RSSI: -80, SNR: 7.5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ChirpStackUplink: %v", err)
+	}
+
+	if response.ID1 != "A1B2C3D4E5F60708" || response.ID2 != 2 || response.Created != 3 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if len(response.Failed) != 1 || response.Failed[0].Measurement != "status" {
+		t.Fatalf("unexpected failed measurements: %+v", response.Failed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Without a payload formatter only the radio metadata is kept
+func TestLoRaWANUsecase_TTSUplink(t *testing.T) {
+	lorawan, mock := newLoRaWANUsecase(t)
+	receivedAt := time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO lorawan_uplinks`)).
+		WithArgs("0004A30B001C0530", int64(15), "tts", "as:up:01H0000000000000000000000", int64(7), int64(-35), 5.2, "gw-1", 0, receivedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	response, err := lorawan.TTSUplink(context.Background(), &model.TTSUplink{
+		EndDeviceIDs:   model.TTSEndDeviceIDs{DevEUI: "0004A30B001C0530"},
+		CorrelationIDs: []string{"gs:uplink:01H1111111111111111111111", "as:up:01H0000000000000000000000"},
+		UplinkMessage: &model.TTSUplinkMessage{
+			FPort:      15,
+			FCnt:       7,
+			ReceivedAt: receivedAt,
+			RxMetadata: []model.TTSRxMetadata{{GatewayIDs: model.TTSGatewayIDs{GatewayID: "gw-1"}, RSSI: -35, SNR: 5.2}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("TTSUplink: %v", err)
+	}
+	if response.Created != 0 || len(response.Failed) != 0 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	_, err = lorawan.TTSUplink(context.Background(), &model.TTSUplink{
+		EndDeviceIDs:  model.TTSEndDeviceIDs{DevEUI: "not-an-eui"},
+		UplinkMessage: &model.TTSUplinkMessage{FPort: 15},
+	})
+	var httpErr *echo.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid DevEUI, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+package usecase_test_test
+
+import (
+	"context"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPresenceUsecase_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	log := logrus.New()
+	presenceUsecase := usecase.NewPresenceUsecase(log, validator.New(), rdb, repository.NewSensorRepository(db, log), 10*time.Minute)
+	ctx := context.Background()
+
+	// Sensor 1 reported a minute ago, sensor 2 an hour ago, sensor 3 never;
+	// sensor 2's device went offline before, sensor 3's after its last reading
+	now := time.Now()
+	rdb.ZAdd(ctx, "sensor-last-seen",
+		redis.Z{Score: float64(now.Add(-time.Minute).UnixMilli()), Member: 1},
+		redis.Z{Score: float64(now.Add(-time.Hour).UnixMilli()), Member: 2},
+	)
+	if err := presenceUsecase.UpdateStatus(ctx, &model.DeviceStatusRequest{ID1: "SENSOR-2", ID2: 1, Status: "offline", Timestamp: now.Add(-2 * time.Hour)}); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if err := presenceUsecase.UpdateStatus(ctx, &model.DeviceStatusRequest{ID1: "SENSOR-3", ID2: 1, Status: "offline"}); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	// A retained status does not replace the stored one
+	if err := presenceUsecase.UpdateStatus(ctx, &model.DeviceStatusRequest{ID1: "SENSOR-3", ID2: 1, Status: "online", Retained: true}); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	columns := []string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}
+	// Recently seen sensors are excluded through a temporary table
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMPORARY TABLE excluded_sensors`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT IGNORE INTO excluded_sensors (sensor_id) VALUES (?)`)).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM sensors s LEFT JOIN excluded_sensors e ON e.sensor_id = s.sensor_id WHERE (? = '' OR s.id1 = ?) AND (? = 0 OR s.id2 = ?) AND e.sensor_id IS NULL`)).
+		WithArgs("", "", int64(0), int64(0), 20, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(int64(2), "SENSOR-2", int64(1), "humidity", nil).
+			AddRow(int64(3), "SENSOR-3", int64(1), "temperature", nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM sensors s LEFT JOIN excluded_sensors e`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TEMPORARY TABLE IF EXISTS excluded_sensors`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	responses, meta, err := presenceUsecase.List(ctx, &model.SensorPresenceListRequest{SilentMinutes: 30, Page: 1, PageSize: 20})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if meta.TotalItem != 2 || len(responses) != 2 {
+		t.Fatalf("unexpected result: %+v %+v", meta, responses)
+	}
+
+	silent := responses[0]
+	if silent.Status != entity.PresenceOffline || silent.LastSeen == nil || silent.DeviceStatus != entity.PresenceOffline {
+		t.Fatalf("unexpected presence of SENSOR-2: %+v", silent)
+	}
+	announced := responses[1]
+	if announced.Status != entity.PresenceOffline || announced.LastSeen != nil || announced.DeviceStatusAt == nil {
+		t.Fatalf("unexpected presence of SENSOR-3: %+v", announced)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorUsecase_MarksSensorsSeen(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	before := time.Now().UnixMilli()
+	_, err := sensorUsecase.Create(context.Background(), &model.CreateSensorRequest{
+		ID1: "SENSOR-1", ID2: 1, SensorType: "temperature", SensorValue: 1, Timestamp: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	score, err := sensorUsecase.Redis.ZScore(context.Background(), "sensor-last-seen", strconv.Itoa(7)).Result()
+	if err != nil || int64(score) < before {
+		t.Fatalf("last seen = %v (%v), want >= %d", score, err, before)
+	}
+}
+
+func TestSensorUsecase_CreateBatch_MarksLiveReadingsSeen(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records`)).
+		WillReturnResult(sqlmock.NewResult(1, 2))
+	mock.ExpectCommit()
+
+	// The sensor is resolved for the historical reading, the live one still
+	// counts as contact
+	now := time.Now()
+	_, err := sensorUsecase.CreateBatch(context.Background(), []*model.CreateSensorRequest{
+		{ID1: "SENSOR-1", ID2: 1, SensorType: "temperature", SensorValue: 1, Timestamp: now.Add(-time.Hour), Historical: true},
+		{ID1: "SENSOR-1", ID2: 1, SensorType: "temperature", SensorValue: 2, Timestamp: now},
+	})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	if _, err := sensorUsecase.Redis.ZScore(context.Background(), "sensor-last-seen", "7").Result(); err != nil {
+		t.Fatalf("expected sensor 7 to be marked seen: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package usecase_test_test
+
+import (
+	"context"
+	"iot-server/internal/broker"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// recordingClient records publishes, other client methods are not used.
+// Publishes complete with err.
+type recordingClient struct {
+	broker.Client
+	topics   []string
+	payloads [][]byte
+	err      error
+}
+
+func (c *recordingClient) Publish(topic string, qos byte, retained bool, payload []byte, properties *broker.Properties) broker.Token {
+	c.topics = append(c.topics, topic)
+	c.payloads = append(c.payloads, payload)
+	return doneToken{err: c.err}
+}
+
+// doneToken is a token that completed with err
+type doneToken struct{ err error }
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+func (t doneToken) Error() error { return t.err }
+
+func newBulkSensorUsecase(t *testing.T) (*usecase.SensorUsecase, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+
+	log := logrus.New()
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", usecase.TimestampWindow{},
+	)
+	return sensorUsecase, mock
+}
+
+func TestSensorUsecase_BulkCreate(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "temperature").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WillReturnResult(sqlmock.NewResult(10, 2))
+	mock.ExpectCommit()
+
+	body := strings.Join([]string{
+		`{"id1":"SENSOR-1","id2":1,"sensor_type":"temperature","sensor_value":21.5,"timestamp":"2025-08-28T12:00:00Z"}`,
+		``,
+		`not json`,
+		`{"id1":"sensor-1","id2":1,"sensor_type":"temperature","sensor_value":21.5}`,
+		`{"id1":"SENSOR-1","id2":1,"sensor_type":"temperature","sensor_value":22,"timestamp":"2025-08-28T12:01:00Z"}`,
+	}, "\n")
+
+	response, err := sensorUsecase.BulkCreate(context.Background(), strings.NewReader(body), time.Now())
+	if err != nil {
+		t.Fatalf("BulkCreate: %v", err)
+	}
+
+	if response.Lines != 4 || response.Accepted != 2 || response.Duplicates != 0 || response.Rejected != 2 {
+		t.Fatalf("unexpected summary: %+v", response)
+	}
+	if len(response.Errors) != 2 || response.Errors[0].Line != 3 || response.Errors[1].Line != 4 {
+		t.Fatalf("unexpected rejected lines: %+v", response.Errors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorUsecase_BulkCreate_KeepsOldTimestamps(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+	sensorUsecase.Timestamps = usecase.TimestampWindow{MaxPast: time.Hour, LateData: usecase.LateDataReject}
+	client := &recordingClient{}
+	sensorUsecase.Producer = messaging.NewSensorProducer(client, sensorUsecase.Log, "iot/processed", 1, true)
+
+	timestamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT sensor_id, id1, id2, sensor_type, duplicate_policy`)).
+		WithArgs("SENSOR-1", int64(1), "temperature").
+		WillReturnRows(sqlmock.NewRows([]string{"sensor_id", "id1", "id2", "sensor_type", "duplicate_policy"}).
+			AddRow(int64(7), "SENSOR-1", int64(1), "temperature", nil))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)).
+		WithArgs(int64(7), 21.5, timestamp, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+
+	// Far outside the window, which would reject a live reading
+	body := `{"id1":"SENSOR-1","id2":1,"sensor_type":"temperature","sensor_value":21.5,"timestamp":"2020-01-01T00:00:00Z"}`
+	response, err := sensorUsecase.BulkCreate(context.Background(), strings.NewReader(body), time.Now())
+	if err != nil {
+		t.Fatalf("BulkCreate: %v", err)
+	}
+	if response.Accepted != 1 || response.Rejected != 0 {
+		t.Fatalf("unexpected summary: %+v", response)
+	}
+	// The retained latest reading is not replaced by an imported one
+	if len(client.topics) != 0 {
+		t.Fatalf("expected no republished readings, got %v", client.topics)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorUsecase_BulkCreate_LineTooLong(t *testing.T) {
+	sensorUsecase, mock := newBulkSensorUsecase(t)
+
+	body := `{"id1":"SENSOR-1","sensor_type":"` + strings.Repeat("x", 64*1024) + `"}`
+	response, err := sensorUsecase.BulkCreate(context.Background(), strings.NewReader(body), time.Now())
+	if err == nil {
+		t.Fatalf("expected an oversized line to stop the import")
+	}
+	if response == nil || response.Lines != 0 {
+		t.Fatalf("unexpected summary: %+v", response)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,300 @@
+package usecase_test_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iot-server/internal/entity"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSensorRecordWriter_FlushesOnBatchSize(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WillReturnResult(sqlmock.NewResult(10, 3))
+	mock.ExpectCommit()
+
+	log := logrus.New()
+	// A long interval makes sure the flush is triggered by the batch size
+	writer := usecase.NewSensorRecordWriter(db, log, repository.NewSensorRecordRepository(log), 3, time.Hour)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := writer.Write(context.Background(), &entity.SensorRecord{SensorID: 1, SensorValue: float64(i), Timestamp: time.Now()})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := writer.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A batch MySQL rolled back to resolve a deadlock is written again
+func TestSensorRecordWriter_RetriesDeadlock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	insert := regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)
+	mock.ExpectBegin()
+	mock.ExpectExec(insert).WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+
+	log := logrus.New()
+	writer := usecase.NewSensorRecordWriter(db, log, repository.NewSensorRecordRepository(log), 1, time.Hour)
+
+	record := &entity.SensorRecord{SensorID: 1, SensorValue: 1.5, Timestamp: time.Now()}
+	if _, err := writer.Write(context.Background(), record); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if record.RecordID != 10 {
+		t.Fatalf("expected RecordID=10, got %d", record.RecordID)
+	}
+	if err := writer.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A single caller fills a batch when it does not wait for the commit
+func TestSensorRecordWriter_EnqueueFillsBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)`)).
+		WillReturnResult(sqlmock.NewResult(10, 5))
+	mock.ExpectCommit()
+
+	log := logrus.New()
+	writer := usecase.NewSensorRecordWriter(db, log, repository.NewSensorRecordRepository(log), 5, time.Hour)
+
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		record := &entity.SensorRecord{SensorID: 1, SensorValue: float64(i), Timestamp: time.Now()}
+		if err := writer.Enqueue(context.Background(), record, func(_ bool, err error) { results <- err }); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				t.Fatalf("record %d: %v", i, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("record %d not committed", i)
+		}
+	}
+
+	if err := writer.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSensorRecordWriter_FlushesOnStop(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO sensor_records (sensor_id, sensor_value, timestamp, message_id, timestamp_source) VALUES (?, ?, ?, ?, ?)`)).
+		WillReturnResult(sqlmock.NewResult(10, 1))
+	mock.ExpectCommit()
+
+	log := logrus.New()
+	writer := usecase.NewSensorRecordWriter(db, log, repository.NewSensorRecordRepository(log), 100, time.Hour)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := writer.Write(context.Background(), &entity.SensorRecord{SensorID: 1, SensorValue: 1, Timestamp: time.Now()})
+		errs <- err
+	}()
+
+	// Wait until the record is buffered before stopping
+	time.Sleep(50 * time.Millisecond)
+	if err := writer.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := writer.Write(context.Background(), &entity.SensorRecord{SensorID: 1}); err == nil {
+		t.Fatalf("expected Write after Stop to fail")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The benchmarks compare the per-row transaction of SensorUsecase.Create with
+// the write-behind writer against a real MySQL, e.g.
+//
+//	BENCH_MYSQL_DSN='user:userpassword@tcp(localhost:3306)/worlder_team_db?parseTime=true' \
+//	  go test ./test/usecase_test -run '^$' -bench SensorRecordInsert
+func benchDB(b *testing.B) (*sql.DB, string) {
+	b.Helper()
+	dsn := os.Getenv("BENCH_MYSQL_DSN")
+	if dsn == "" {
+		b.Skip("BENCH_MYSQL_DSN is not set")
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatalf("sql.Open: %v", err)
+	}
+	db.SetMaxOpenConns(64)
+	db.SetMaxIdleConns(64)
+
+	id1 := fmt.Sprintf("BENCH-%d", time.Now().UnixNano())
+	res, err := db.Exec(`INSERT INTO sensors (id1, id2, sensor_type) VALUES (?, ?, ?)`, id1, 1, "bench")
+	if err != nil {
+		b.Fatalf("create bench sensor: %v", err)
+	}
+	sensorID, _ := res.LastInsertId()
+
+	b.Cleanup(func() {
+		_, _ = db.Exec(`DELETE FROM sensors WHERE sensor_id = ?`, sensorID)
+		_ = db.Close()
+	})
+	return db, id1
+}
+
+// benchUsecase is the SensorUsecase of the server, with the given writer
+func benchUsecase(b *testing.B, db *sql.DB, writer *usecase.SensorRecordWriter) *usecase.SensorUsecase {
+	log := logrus.New()
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(b).Addr()})
+	return usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		writer, nil, nil, "", usecase.TimestampWindow{},
+	)
+}
+
+// benchWorkers is the default INGEST_WORKERS
+const benchWorkers = 4
+
+// benchRequest returns a reading with a unique timestamp so rows never collide
+func benchRequest(id1 string, seq *atomic.Int64) *model.CreateSensorRequest {
+	n := seq.Add(1)
+	return &model.CreateSensorRequest{
+		ID1:         id1,
+		ID2:         1,
+		SensorType:  "bench",
+		SensorValue: float64(n),
+		Timestamp:   time.Unix(0, 0).Add(time.Duration(n) * time.Microsecond),
+	}
+}
+
+// runWorkers spreads b.N calls of op over benchWorkers goroutines, like the
+// ingest pipeline does with its messages
+func runWorkers(b *testing.B, op func() error) {
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < benchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for next.Add(1) <= int64(b.N) {
+				if err := op(); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkSensorRecordInsert_PerRow(b *testing.B) {
+	db, id1 := benchDB(b)
+	sensorUsecase := benchUsecase(b, db, nil)
+
+	var seq atomic.Int64
+	b.ResetTimer()
+	runWorkers(b, func() error {
+		_, err := sensorUsecase.Create(context.Background(), benchRequest(id1, &seq))
+		return err
+	})
+}
+
+// The workers only queue their readings, as the MQTT consumer does, so the
+// batches fill up to the batch size however few workers there are
+func BenchmarkSensorRecordInsert_Writer(b *testing.B) {
+	db, id1 := benchDB(b)
+	log := logrus.New()
+	writer := usecase.NewSensorRecordWriter(db, log, repository.NewSensorRecordRepository(log), 200, 20*time.Millisecond)
+	sensorUsecase := benchUsecase(b, db, writer)
+
+	var seq atomic.Int64
+	var committed sync.WaitGroup
+	b.ResetTimer()
+	runWorkers(b, func() error {
+		committed.Add(1)
+		sensorUsecase.CreateAsync(context.Background(), benchRequest(id1, &seq), func(_ *model.SensorResponse, err error) {
+			if err != nil {
+				b.Error(err)
+			}
+			committed.Done()
+		})
+		return nil
+	})
+	committed.Wait()
+	b.StopTimer()
+
+	if err := writer.Stop(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+}
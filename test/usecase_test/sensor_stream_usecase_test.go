@@ -0,0 +1,204 @@
+package usecase_test_test
+
+import (
+	"context"
+	"errors"
+	"iot-server/internal/gateway/messaging"
+	"iot-server/internal/model"
+	"iot-server/internal/usecase"
+	"iot-server/internal/util"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const streamChannel = "sensor-stream"
+
+// newSensorStream returns a stream use case and a producer of another
+// replica, sharing a Redis
+func newSensorStream(t *testing.T, bufferSize int, policy usecase.StreamDropPolicy) (*usecase.SensorStreamUsecase, *messaging.SensorStreamProducer) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	log := logrus.New()
+	subscriber := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = subscriber.Close() })
+	publisher := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = publisher.Close() })
+
+	tokenUtil := util.NewTokenUtil("secret", subscriber)
+	stream := usecase.NewSensorStreamUsecase(log, validator.New(), tokenUtil, subscriber, streamChannel, bufferSize, policy, time.Minute)
+	t.Cleanup(func() { _ = stream.Stop(context.Background()) })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		counts, err := publisher.PubSubNumSub(context.Background(), streamChannel).Result()
+		if err == nil && counts[streamChannel] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream did not subscribe to %s", streamChannel)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return stream, messaging.NewSensorStreamProducer(publisher, log, streamChannel, 64)
+}
+
+func send(t *testing.T, producer *messaging.SensorStreamProducer, id1 string, id2 int64, sensorType string, value float64) {
+	t.Helper()
+	if err := producer.Send(&model.SensorReadingEvent{ID1: id1, ID2: id2, SensorType: sensorType, SensorValue: value}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
+
+func nextReading(t *testing.T, stream *usecase.SensorStream) *model.SensorReadingEvent {
+	t.Helper()
+	select {
+	case event := <-stream.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no reading received")
+		return nil
+	}
+}
+
+func TestSensorStream_Filters(t *testing.T) {
+	streams, producer := newSensorStream(t, 10, usecase.StreamDropOldest)
+
+	all, err := streams.Subscribe(&model.SensorStreamRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	humidity, err := streams.Subscribe(&model.SensorStreamRequest{ID1: "SENSOR-1", ID2: 1, SensorType: "humidity"})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if _, err := streams.Subscribe(&model.SensorStreamRequest{ID1: "sensor-1"}); err == nil {
+		t.Fatalf("expected a lowercase id1 to be rejected")
+	}
+
+	send(t, producer, "SENSOR-1", 1, "temperature", 21.5)
+	send(t, producer, "SENSOR-2", 1, "humidity", 40)
+	send(t, producer, "SENSOR-1", 1, "humidity", 55)
+
+	for _, want := range []float64{21.5, 40, 55} {
+		if event := nextReading(t, all); event.SensorValue != want {
+			t.Fatalf("unfiltered stream got %v, want %v", event.SensorValue, want)
+		}
+	}
+	if event := nextReading(t, humidity); event.SensorValue != 55 {
+		t.Fatalf("filtered stream got %+v", event)
+	}
+	select {
+	case event := <-humidity.Events():
+		t.Fatalf("filtered stream got an unexpected reading %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// A slow client keeps the latest readings and learns how many it missed
+func TestSensorStream_DropOldest(t *testing.T) {
+	streams, producer := newSensorStream(t, 2, usecase.StreamDropOldest)
+
+	slow, err := streams.Subscribe(&model.SensorStreamRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	fast, err := streams.Subscribe(&model.SensorStreamRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		send(t, producer, "SENSOR-1", 1, "temperature", float64(i))
+		if event := nextReading(t, fast); event.SensorValue != float64(i) {
+			t.Fatalf("fast stream got %v, want %v", event.SensorValue, i)
+		}
+	}
+
+	if first, second := nextReading(t, slow), nextReading(t, slow); first.SensorValue != 4 || second.SensorValue != 5 {
+		t.Fatalf("slow stream kept %v and %v, want the last two", first.SensorValue, second.SensorValue)
+	}
+	if dropped := slow.TakeDropped(); dropped != 3 {
+		t.Fatalf("dropped %d, want 3", dropped)
+	}
+	if dropped := fast.TakeDropped(); dropped != 0 {
+		t.Fatalf("fast stream dropped %d", dropped)
+	}
+}
+
+func TestSensorStream_Disconnect(t *testing.T) {
+	streams, producer := newSensorStream(t, 1, usecase.StreamDisconnect)
+
+	slow, err := streams.Subscribe(&model.SensorStreamRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	send(t, producer, "SENSOR-1", 1, "temperature", 1)
+	send(t, producer, "SENSOR-1", 1, "temperature", 2)
+
+	select {
+	case <-slow.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("slow stream was not disconnected")
+	}
+	if event := nextReading(t, slow); event.SensorValue != 1 {
+		t.Fatalf("buffered reading %v, want 1", event.SensorValue)
+	}
+}
+
+// A ticket opens one stream as the user it was issued to
+func TestSensorStream_TicketIsSingleUse(t *testing.T) {
+	streams, _ := newSensorStream(t, 1, usecase.StreamDropOldest)
+	ctx := context.Background()
+
+	ticket, err := streams.CreateTicket(ctx, &model.Auth{ID: "viewer", Role: "user"})
+	if err != nil {
+		t.Fatalf("CreateTicket: %v", err)
+	}
+	if ticket.Ticket == "" || !ticket.ExpiresAt.After(time.Now()) {
+		t.Fatalf("unexpected ticket: %+v", ticket)
+	}
+
+	auth, err := streams.RedeemTicket(ctx, ticket.Ticket)
+	if err != nil {
+		t.Fatalf("RedeemTicket: %v", err)
+	}
+	if auth.ID != "viewer" || auth.Role != "user" {
+		t.Fatalf("unexpected user: %+v", auth)
+	}
+
+	var httpErr *echo.HTTPError
+	if _, err := streams.RedeemTicket(ctx, ticket.Ticket); !errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a used ticket to be refused, got %v", err)
+	}
+	if _, err := streams.RedeemTicket(ctx, "unknown"); !errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown ticket to be refused, got %v", err)
+	}
+}
+
+// The producer sends the readings queued while Redis was busy in one go,
+// in order
+func TestSensorStream_ProducerKeepsOrder(t *testing.T) {
+	streams, producer := newSensorStream(t, 100, usecase.StreamDropOldest)
+
+	all, err := streams.Subscribe(&model.SensorStreamRequest{})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	for i := 1; i <= 50; i++ {
+		send(t, producer, "SENSOR-1", 1, "temperature", float64(i))
+	}
+	for i := 1; i <= 50; i++ {
+		if event := nextReading(t, all); event.SensorValue != float64(i) {
+			t.Fatalf("got %v, want %v", event.SensorValue, i)
+		}
+	}
+}
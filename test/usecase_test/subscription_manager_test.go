@@ -0,0 +1,258 @@
+package usecase_test_test
+
+import (
+	"fmt"
+	"io"
+	"iot-server/internal/broker"
+	"iot-server/internal/usecase"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sirupsen/logrus"
+)
+
+func startEmbeddedBroker(t *testing.T, address string) *broker.Embedded {
+	t.Helper()
+	embedded, err := broker.NewEmbedded(address, "", "", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewEmbedded: %v", err)
+	}
+	return embedded
+}
+
+func publishOnce(t *testing.T, address, topic string) {
+	t.Helper()
+	client := mqtt.NewClient(mqtt.NewClientOptions().AddBroker("tcp://" + address).SetClientID("publisher"))
+	if token := client.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("connect publisher: %v", token.Error())
+	}
+	defer client.Disconnect(100)
+	if token := client.Publish(topic, 1, false, "{}"); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("publish: %v", token.Error())
+	}
+}
+
+func receive(t *testing.T, received <-chan string, want string) {
+	t.Helper()
+	select {
+	case topic := <-received:
+		if topic != want {
+			t.Fatalf("received %s, want %s", topic, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message on %s not received", want)
+	}
+}
+
+// Subscriptions lost with a broker restart are made again after the reconnect
+func TestSubscriptionManager_ResubscribesAfterBrokerRestart(t *testing.T) {
+	embedded := startEmbeddedBroker(t, "127.0.0.1:0")
+	address := embedded.Address()
+	defer func() { _ = embedded.Close() }()
+
+	opts := mqtt.NewClientOptions().
+		AddBroker("tcp://" + address).
+		SetClientID("server").
+		SetCleanSession(true).
+		SetAutoAckDisabled(true).
+		SetMaxReconnectInterval(100 * time.Millisecond)
+	client := broker.NewMQTT3Client(opts)
+	if err := client.Connect(5 * time.Second); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Disconnect(100 * time.Millisecond)
+
+	manager := usecase.NewSubscriptionManager(client, logrus.New(), 5*time.Second)
+	received := make(chan string, 10)
+	if err := manager.Subscribe("iot/+/data", 1, func(msg broker.Message) {
+		msg.Ack()
+		received <- msg.Topic()
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	status := manager.Status()
+	if !status.Healthy || len(status.Subscriptions) != 1 || !status.Subscriptions[0].Subscribed {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	firstSubscribedAt := *status.Subscriptions[0].SubscribedAt
+
+	publishOnce(t, address, "iot/SENSOR-1/data")
+	receive(t, received, "iot/SENSOR-1/data")
+
+	// The new broker knows nothing of the old session
+	_ = embedded.Close()
+	embedded = startEmbeddedBroker(t, address)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status = manager.Status()
+		if status.Healthy && status.Subscriptions[0].SubscribedAt.After(firstSubscribedAt) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("not subscribed again after the restart: %+v", status)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	publishOnce(t, address, "iot/SENSOR-2/data")
+	receive(t, received, "iot/SENSOR-2/data")
+
+	subscription := manager.Status().Subscriptions[0]
+	if subscription.Messages != 2 || subscription.LastMessageAt == nil || subscription.Error != "" {
+		t.Fatalf("unexpected subscription status: %+v", subscription)
+	}
+}
+
+func TestSubscriptionManager_ReportsRefusedSubscription(t *testing.T) {
+	embedded := startEmbeddedBroker(t, "127.0.0.1:0")
+	defer func() { _ = embedded.Close() }()
+
+	opts := mqtt.NewClientOptions().
+		AddBroker("tcp://" + embedded.Address()).
+		SetClientID("server").
+		SetAutoAckDisabled(true)
+	client := broker.NewMQTT3Client(opts)
+	if err := client.Connect(5 * time.Second); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Disconnect(100 * time.Millisecond)
+
+	manager := usecase.NewSubscriptionManager(client, logrus.New(), 5*time.Second)
+	if err := manager.Subscribe("iot/#/data", 1, func(msg broker.Message) { msg.Ack() }); err == nil {
+		t.Fatalf("expected an invalid filter to be refused")
+	}
+
+	status := manager.Status()
+	if status.Healthy || !status.Connected || status.Subscriptions[0].Subscribed || status.Subscriptions[0].Error == "" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+// After Stop, messages are no longer handed out nor acknowledged
+func TestSubscriptionManager_Stop(t *testing.T) {
+	embedded := startEmbeddedBroker(t, "127.0.0.1:0")
+	defer func() { _ = embedded.Close() }()
+
+	opts := mqtt.NewClientOptions().
+		AddBroker("tcp://" + embedded.Address()).
+		SetClientID("server").
+		SetAutoAckDisabled(true)
+	client := broker.NewMQTT3Client(opts)
+	if err := client.Connect(5 * time.Second); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Disconnect(100 * time.Millisecond)
+
+	manager := usecase.NewSubscriptionManager(client, logrus.New(), 5*time.Second)
+	received := make(chan string, 10)
+	if err := manager.Subscribe("iot/+/data", 1, func(msg broker.Message) {
+		msg.Ack()
+		received <- msg.Topic()
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	publishOnce(t, embedded.Address(), "iot/SENSOR-1/data")
+	receive(t, received, "iot/SENSOR-1/data")
+
+	manager.Stop()
+	publishOnce(t, embedded.Address(), "iot/SENSOR-2/data")
+	select {
+	case topic := <-received:
+		t.Fatalf("received %s after Stop", topic)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// flakyClient refuses the subscribe requests numbered in refuse and lets the
+// test trigger reconnects
+type flakyClient struct {
+	broker.Client
+	mu        sync.Mutex
+	attempts  int
+	refuse    map[int]bool
+	onConnect func()
+}
+
+func (c *flakyClient) Subscribe(filter string, qos byte, handler broker.Handler) broker.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if c.refuse[c.attempts] {
+		return doneToken{err: fmt.Errorf("subscription to %s refused", filter)}
+	}
+	return doneToken{}
+}
+
+func (c *flakyClient) IsConnected() bool { return true }
+
+func (c *flakyClient) OnConnect(callback func()) { c.onConnect = callback }
+
+func (c *flakyClient) subscribeAttempts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts
+}
+
+// A subscription refused after a reconnect is retried until it is granted
+func TestSubscriptionManager_RetriesRefusedResubscribe(t *testing.T) {
+	// The first request succeeds, the two after the reconnect are refused
+	client := &flakyClient{refuse: map[int]bool{2: true, 3: true}}
+	manager := usecase.NewSubscriptionManager(client, logrus.New(), time.Second)
+	manager.RetryBackoff, manager.MaxRetryBackoff = 10*time.Millisecond, 20*time.Millisecond
+	if err := manager.Subscribe("iot/+/data", 1, func(msg broker.Message) { msg.Ack() }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	client.onConnect()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for client.subscribeAttempts() < 4 || !manager.Status().Healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("not subscribed again after refusals: %+v", manager.Status())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if attempts := client.subscribeAttempts(); attempts != 4 {
+		t.Fatalf("expected no requests once granted, got %d", attempts)
+	}
+	if status := manager.Status().Subscriptions[0]; status.Error != "" {
+		t.Fatalf("expected the error to be cleared, got %q", status.Error)
+	}
+}
+
+// Retries stop once the manager is stopped
+func TestSubscriptionManager_StopEndsRetries(t *testing.T) {
+	client := &flakyClient{refuse: map[int]bool{}}
+	for i := 2; i < 100; i++ {
+		client.refuse[i] = true
+	}
+	manager := usecase.NewSubscriptionManager(client, logrus.New(), time.Second)
+	manager.RetryBackoff, manager.MaxRetryBackoff = 10*time.Millisecond, 10*time.Millisecond
+	if err := manager.Subscribe("iot/+/data", 1, func(msg broker.Message) { msg.Ack() }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.onConnect()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	manager.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("retries did not stop")
+	}
+	if manager.Status().Healthy {
+		t.Fatalf("expected the refused subscription to be reported")
+	}
+}
@@ -0,0 +1,98 @@
+package usecase_test_test
+
+import (
+	"context"
+	"iot-server/internal/model"
+	"iot-server/internal/repository"
+	"iot-server/internal/usecase"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTimestampWindow_Check(t *testing.T) {
+	receivedAt := time.Date(2025, 8, 28, 12, 0, 0, 0, time.UTC)
+	window := usecase.TimestampWindow{MaxPast: 24 * time.Hour, MaxFuture: 5 * time.Minute}
+
+	tests := []struct {
+		name      string
+		window    usecase.TimestampWindow
+		timestamp time.Time
+		wantErr   bool
+	}{
+		{name: "inside window", window: window, timestamp: receivedAt.Add(-time.Hour)},
+		{name: "on past bound", window: window, timestamp: receivedAt.Add(-24 * time.Hour)},
+		{name: "too old", window: window, timestamp: time.Unix(0, 0), wantErr: true},
+		{name: "on future bound", window: window, timestamp: receivedAt.Add(5 * time.Minute)},
+		{name: "too far in future", window: window, timestamp: receivedAt.Add(time.Hour), wantErr: true},
+		{name: "disabled", window: usecase.TimestampWindow{}, timestamp: time.Unix(0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.window.Check(tt.timestamp, receivedAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%s) error = %v, wantErr %v", tt.timestamp, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The counters expire a window after their first count instead of growing forever
+func TestSensorUsecase_TimestampStatsExpire(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	log := logrus.New()
+	window := usecase.TimestampWindow{MaxPast: time.Hour, LateData: usecase.LateDataReject, StatsWindow: 2 * time.Hour}
+	sensorUsecase := usecase.NewSensorUsecase(
+		db, log, validator.New(), rdb,
+		repository.NewSensorRepository(db, log),
+		repository.NewSensorRecordRepository(log),
+		nil, nil, nil, "", window,
+	)
+
+	request := func() *model.CreateSensorRequest {
+		return &model.CreateSensorRequest{
+			ID1: "SENSOR-1", ID2: 1, SensorType: "temperature", SensorValue: 21.5,
+			Timestamp:  time.Now().Add(-2 * time.Hour),
+			ReceivedAt: time.Now(),
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := sensorUsecase.Create(context.Background(), request()); err == nil {
+			t.Fatalf("expected the late reading to be rejected")
+		}
+		mr.FastForward(30 * time.Minute)
+	}
+
+	stats, err := sensorUsecase.TimestampStats(context.Background(), &model.SensorTimestampStatsRequest{ID1: "SENSOR-1", ID2: 1, SensorType: "temperature"})
+	if err != nil {
+		t.Fatalf("TimestampStats: %v", err)
+	}
+	if stats.Rejected != 2 || stats.Window != 7200 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The second count did not extend the window of the first
+	mr.FastForward(time.Hour)
+	stats, err = sensorUsecase.TimestampStats(context.Background(), &model.SensorTimestampStatsRequest{ID1: "SENSOR-1", ID2: 1, SensorType: "temperature"})
+	if err != nil {
+		t.Fatalf("TimestampStats: %v", err)
+	}
+	if stats.Rejected != 0 {
+		t.Fatalf("expected the counters to expire, got %+v", stats)
+	}
+}
@@ -0,0 +1,43 @@
+package util_test_test
+
+import (
+	"encoding/json"
+	"iot-server/internal/util"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, raw string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", raw, err)
+	}
+	return v
+}
+
+func TestMergePatch(t *testing.T) {
+	target := decodeJSON(t, `{"interval": 10, "led": {"color": "red", "on": true}, "mode": "eco"}`)
+	patch := decodeJSON(t, `{"interval": 30, "led": {"color": "blue"}, "mode": null, "fw": "1.2"}`)
+
+	got := util.MergePatch(target, patch)
+	want := decodeJSON(t, `{"interval": 30, "led": {"color": "blue", "on": true}, "fw": "1.2"}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MergePatch = %v, want %v", got, want)
+	}
+}
+
+func TestJSONDelta(t *testing.T) {
+	desired := decodeJSON(t, `{"interval": 30, "led": {"color": "blue", "on": true}, "fw": "1.2"}`)
+	reported := decodeJSON(t, `{"interval": 30, "led": {"color": "red", "on": true}, "uptime": 100}`)
+
+	got := util.JSONDelta(desired, reported)
+	want := decodeJSON(t, `{"led": {"color": "blue"}, "fw": "1.2"}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("JSONDelta = %v, want %v", got, want)
+	}
+
+	if delta := util.JSONDelta(reported, reported); len(delta) != 0 {
+		t.Fatalf("expected no delta between equal documents, got %v", delta)
+	}
+}
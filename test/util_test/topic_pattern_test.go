@@ -0,0 +1,101 @@
+package util_test_test
+
+import (
+	"iot-server/internal/util"
+	"testing"
+)
+
+func TestTopicPattern_Subscription(t *testing.T) {
+	pattern, err := util.NewTopicPattern("iot/{id1}/{id2}/{sensor_type}")
+	if err != nil {
+		t.Fatalf("NewTopicPattern: %v", err)
+	}
+	if got := pattern.Subscription(); got != "iot/+/+/+" {
+		t.Fatalf("expected subscription iot/+/+/+, got %q", got)
+	}
+	if !pattern.HasNames() {
+		t.Fatalf("expected pattern to have named segments")
+	}
+}
+
+func TestTopicPattern_Match(t *testing.T) {
+	pattern, err := util.NewTopicPattern("iot/{id1}/{id2}/{sensor_type}")
+	if err != nil {
+		t.Fatalf("NewTopicPattern: %v", err)
+	}
+
+	values, ok := pattern.Match("iot/SENSOR-1/7/temperature")
+	if !ok {
+		t.Fatalf("expected topic to match")
+	}
+	if values["id1"] != "SENSOR-1" || values["id2"] != "7" || values["sensor_type"] != "temperature" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+
+	for _, topic := range []string{"iot/SENSOR-1/7", "iot/SENSOR-1/7/temperature/extra", "other/SENSOR-1/7/temperature"} {
+		if _, ok := pattern.Match(topic); ok {
+			t.Fatalf("expected %q not to match", topic)
+		}
+	}
+}
+
+func TestTopicPattern_MatchWildcards(t *testing.T) {
+	pattern, err := util.NewTopicPattern("iot/+/{id1}/#")
+	if err != nil {
+		t.Fatalf("NewTopicPattern: %v", err)
+	}
+	if got := pattern.Subscription(); got != "iot/+/+/#" {
+		t.Fatalf("expected subscription iot/+/+/#, got %q", got)
+	}
+
+	values, ok := pattern.Match("iot/site-a/SENSOR-1/a/b")
+	if !ok || values["id1"] != "SENSOR-1" {
+		t.Fatalf("unexpected match result: %v %v", values, ok)
+	}
+}
+
+func TestTopicPattern_Topic(t *testing.T) {
+	pattern, err := util.NewTopicPattern("iot/command/{id1}/{id2}/ack")
+	if err != nil {
+		t.Fatalf("NewTopicPattern: %v", err)
+	}
+
+	topic, err := pattern.Topic(map[string]string{"id1": "SENSOR-1", "id2": "7"})
+	if err != nil || topic != "iot/command/SENSOR-1/7/ack" {
+		t.Fatalf("unexpected topic: %q %v", topic, err)
+	}
+	if _, err := pattern.Topic(map[string]string{"id1": "SENSOR-1"}); err == nil {
+		t.Fatalf("expected error for a missing value")
+	}
+
+	wildcard, _ := util.NewTopicPattern("iot/+/{id1}")
+	if _, err := wildcard.Topic(map[string]string{"id1": "SENSOR-1"}); err == nil {
+		t.Fatalf("expected error for a wildcard segment")
+	}
+}
+
+func TestTopicPattern_Invalid(t *testing.T) {
+	for _, pattern := range []string{"", "iot/#/data", "iot/{}/data", "iot/a+b", "iot/{id1"} {
+		if _, err := util.NewTopicPattern(pattern); err == nil {
+			t.Fatalf("expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestNamePattern_Match(t *testing.T) {
+	pattern, err := util.NewNamePattern("urn:dev:{id1}:{id2}:{sensor_type}", ":")
+	if err != nil {
+		t.Fatalf("NewNamePattern: %v", err)
+	}
+
+	values, ok := pattern.Match("urn:dev:SENSOR-6:6:temperature")
+	if !ok || values["id1"] != "SENSOR-6" || values["id2"] != "6" || values["sensor_type"] != "temperature" {
+		t.Fatalf("unexpected match result: %v %v", values, ok)
+	}
+	if _, ok := pattern.Match("urn:dev:SENSOR-6:temperature"); ok {
+		t.Fatalf("expected no match for a name with a missing segment")
+	}
+	if _, err := util.NewNamePattern("{id1}", ""); err == nil {
+		t.Fatalf("expected error for an empty separator")
+	}
+}